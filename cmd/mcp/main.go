@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	stdlog "log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/La002/personal-crm/config"
+	"github.com/La002/personal-crm/internal/mcpserver"
+	"github.com/La002/personal-crm/pkg/logger"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func main() {
+	transport := flag.String("transport", "stdio", "Transport to serve MCP on: stdio or sse")
+	addr := flag.String("addr", ":8081", "Listen address for the sse transport")
+	flag.Parse()
+
+	// stdout belongs to the MCP protocol when using stdio, so every log goes to stderr
+	cfg := config.NewConfig()
+	l := logger.NewWithOutput(cfg.Log.Level, os.Stderr)
+
+	contactRepo := repository.NewContactRepo(cfg, l)
+	contactRepo.DB.Logger = gormlogger.New(
+		stdlog.New(os.Stderr, "\r\n", stdlog.LstdFlags),
+		gormlogger.Config{
+			SlowThreshold:             200 * time.Millisecond,
+			LogLevel:                  gormlogger.Warn,
+			IgnoreRecordNotFoundError: true,
+		},
+	)
+
+	switch *transport {
+	case "stdio":
+		// The stdio server acts on behalf of the user the session token was issued to
+		token := os.Getenv("CRM_MCP_TOKEN")
+		if token == "" {
+			l.Fatal("CRM_MCP_TOKEN must be set to a session token for the stdio transport")
+		}
+
+		userID, err := mcpserver.UserFromToken(token, cfg.JWT.SecretKey)
+		if err != nil {
+			l.Fatal("Invalid CRM_MCP_TOKEN: %s", err)
+		}
+
+		l.Info("Serving MCP over stdio for user %d", userID)
+		server := mcpserver.NewServer(contactRepo, userID)
+		if err := server.Run(context.Background(), &mcp.StdioTransport{}); err != nil {
+			l.Fatal("MCP server stopped: %s", err)
+		}
+	case "sse":
+		l.Info("Serving MCP over SSE on %s", *addr)
+		handler := mcpserver.NewSSEHandler(contactRepo, cfg.JWT.SecretKey)
+		if err := http.ListenAndServe(*addr, handler); err != nil {
+			l.Fatal("MCP server stopped: %s", err)
+		}
+	default:
+		l.Fatal("Unknown transport %q, expected stdio or sse", *transport)
+	}
+}
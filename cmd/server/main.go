@@ -1,6 +1,11 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/La002/personal-crm/config"
@@ -12,6 +17,7 @@ import (
 	"github.com/La002/personal-crm/pkg/metrics"
 	"github.com/La002/personal-crm/pkg/postgres"
 	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/La002/personal-crm/pkg/storage"
 	"github.com/labstack/echo/v4"
 	echomiddleware "github.com/labstack/echo/v4/middleware"
 	"github.com/labstack/gommon/log"
@@ -50,6 +56,11 @@ func main() {
 		}
 	}()
 
+	attachmentStore, err := storage.New(cfg.Storage)
+	if err != nil {
+		l.Fatal("Failed to set up attachment storage:", err)
+	}
+
 	// Initialize repositories
 	contactRepo := repository.NewContactRepo(cfg, l)
 	userRepo := repository.NewUserRepo(cfg, l)
@@ -57,6 +68,11 @@ func main() {
 	// Initialize services
 	contactService := service.NewContactService(contactRepo)
 	dashboardService := service.NewDashboardService(contactRepo)
+	interactionService := service.NewInteractionService(contactRepo)
+	cadenceService := service.NewCadenceService(contactRepo)
+	tagService := service.NewTagService(contactRepo)
+	customFieldService := service.NewCustomFieldService(contactRepo)
+	relationshipTypeService := service.NewRelationshipTypeService(contactRepo)
 
 	// Debug: Log OAuth configuration
 	//clientSecretPreview := "EMPTY"
@@ -85,12 +101,59 @@ func main() {
 		cfg.OAuth.GoogleClientSecret,
 		cfg.OAuth.RedirectURL)
 
+	mergeService := service.NewMergeService(contactRepo, calendarService)
+	taskService := service.NewTaskService(contactRepo, calendarService)
+	noteService := service.NewNoteService(contactRepo)
+	vcardService := service.NewVCardService(contactRepo)
+	csvImportService := service.NewCSVImportService(contactRepo)
+	exportService := service.NewExportService(contactRepo)
+	linkService := service.NewLinkService(contactRepo)
+	smartListService := service.NewSmartListService(contactRepo)
+	bulkService := service.NewBulkService(contactRepo, calendarService)
+	attachmentService := service.NewAttachmentService(contactRepo, attachmentStore, cfg.Storage.MaxUploadMB)
+	householdService := service.NewHouseholdService(contactRepo, calendarService)
+	trashService := service.NewTrashService(contactRepo, calendarService, attachmentStore, cfg.Trash.RetentionDays)
+	strengthService := service.NewStrengthService(contactRepo)
+
+	// Purge contacts that stayed in the trash longer than the retention
+	go trashService.RunPurge(context.Background(), time.Hour, func(purged int, err error) {
+		if err != nil {
+			l.Error("Failed to purge the trash: %v", err)
+		} else if purged > 0 {
+			l.Info("Purged %d expired contacts from the trash", purged)
+		}
+	})
+
+	// Score the relationships again as interactions age and new ones are logged
+	go strengthService.RunRecompute(context.Background(), time.Hour, func(updated int, err error) {
+		if err != nil {
+			l.Error("Failed to recompute relationship strengths: %v", err)
+		} else if updated > 0 {
+			l.Debug("Recomputed the relationship strength of %d contacts", updated)
+		}
+	})
+
 	authHandler := service.NewAuthHandler(authService)
 	calendarHandler := service.NewCalendarHandler(calendarService)
+	apiHandler := service.NewAPIHandler(contactRepo, calendarService, dashboardService, attachmentService, trashService)
 	e := echo.New()
 	e.Logger.SetLevel(log.DEBUG)
 	e.HTTPErrorHandler = func(err error, c echo.Context) {
 		l.Error("HTTP Error occurred", "error", err.Error())
+
+		// API clients get the same structured error body as handled API errors
+		if strings.HasPrefix(c.Request().URL.Path, "/api/") {
+			status, message := http.StatusInternalServerError, "internal server error"
+			var he *echo.HTTPError
+			if errors.As(err, &he) {
+				status, message = he.Code, fmt.Sprint(he.Message)
+			}
+			c.JSON(status, map[string]service.APIError{
+				"error": {Code: strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_")), Message: message},
+			})
+			return
+		}
+
 		c.JSON(500, map[string]string{
 			"error": err.Error(),
 		})
@@ -137,11 +200,25 @@ func main() {
 	// Contacts
 	protected.GET("/contacts", contactService.GetAllContacts)
 	protected.GET("/contacts/search", contactService.SearchContacts)
+	protected.GET("/contacts/options", contactService.ContactOptions)
+	protected.GET("/contacts/duplicates", mergeService.GetDuplicates)
+	protected.GET("/contacts/import", vcardService.GetImport)
+	protected.POST("/contacts/import/vcard/preview", vcardService.PreviewVCardImport)
+	protected.POST("/contacts/import/vcard", vcardService.ImportVCard)
+	protected.POST("/contacts/import/csv/mapping", csvImportService.UploadCSV)
+	protected.POST("/contacts/import/csv/preview", csvImportService.PreviewCSVImport)
+	protected.POST("/contacts/import/csv", csvImportService.ImportCSV)
+	protected.GET("/contacts/export/vcard", vcardService.ExportVCard)
+	protected.GET("/contacts/export/greetings", vcardService.ExportGreetings)
 	protected.POST("/contacts/new", contactService.AddContact)
 	protected.DELETE("/contacts/:id", contactService.DeleteContact)
+	protected.POST("/contacts/bulk", bulkService.BulkContacts)
 	protected.GET("/contacts/:id", contactService.GetContact)
 	protected.GET("/contacts/:id/edit", contactService.EditContact)
 	protected.PUT("/contacts/:id", contactService.UpdateContact)
+	protected.GET("/contacts/:id/history", contactService.GetContactHistory)
+	protected.GET("/contacts/:id/vcard", vcardService.ExportContactVCard)
+	protected.POST("/contacts/:id/history/:revision/restore", contactService.RestoreContactRevision)
 	protected.POST("/auth/logout", authHandler.Logout)
 
 	// Calendar sync endpoints
@@ -153,6 +230,205 @@ func main() {
 	protected.POST("/contacts/:id/events", calendarHandler.CreateCustomEvent)
 	protected.DELETE("/contacts/:id/events/:eventId", calendarHandler.DeleteCustomEvent)
 
+	// Interaction log endpoints
+	protected.POST("/contacts/:id/interactions", interactionService.CreateInteraction)
+	protected.DELETE("/contacts/:id/interactions/:interactionId", interactionService.DeleteInteraction)
+
+	// Task endpoints
+	protected.POST("/contacts/:id/tasks", taskService.CreateTask)
+	protected.POST("/tasks/:taskId/complete", taskService.CompleteTask)
+	protected.POST("/tasks/:taskId/snooze", taskService.SnoozeTask)
+	protected.DELETE("/tasks/:taskId", taskService.DeleteTask)
+
+	// Note endpoints
+	protected.POST("/contacts/:id/notes", noteService.CreateNote)
+	protected.GET("/notes/:noteId", noteService.GetNote)
+	protected.GET("/notes/:noteId/edit", noteService.EditNoteForm)
+	protected.PUT("/notes/:noteId", noteService.UpdateNote)
+	protected.POST("/notes/:noteId/pin", noteService.TogglePin)
+	protected.DELETE("/notes/:noteId", noteService.DeleteNote)
+
+	// Attachment endpoints
+	protected.POST("/contacts/:id/attachments", attachmentService.UploadAttachment)
+	protected.GET("/attachments/:attachmentId", attachmentService.DownloadAttachment)
+	protected.DELETE("/attachments/:attachmentId", attachmentService.DeleteAttachment)
+	protected.GET("/contacts/:id/photo", attachmentService.ContactPhoto)
+	protected.POST("/contacts/:id/photo", attachmentService.SetPhoto)
+	protected.DELETE("/contacts/:id/photo", attachmentService.RemovePhoto)
+
+	// Keep-in-touch queue endpoints
+	protected.POST("/contacts/:id/keep-in-touch/done", cadenceService.CompleteContact)
+	protected.POST("/contacts/:id/keep-in-touch/snooze", cadenceService.SnoozeContact)
+
+	// Contact link endpoints
+	protected.POST("/contacts/:id/links", linkService.CreateLink)
+	protected.DELETE("/contacts/:id/links/:linkId", linkService.DeleteLink)
+
+	// Tag endpoints
+	protected.GET("/tags", tagService.GetTags)
+	protected.GET("/tags/filters", tagService.GetTagFilters)
+	protected.POST("/tags", tagService.CreateTag)
+	protected.PUT("/tags/:tagId/cadence", tagService.UpdateTagCadence)
+	protected.DELETE("/tags/:tagId", tagService.DeleteTag)
+	protected.POST("/contacts/:id/tags", tagService.AddContactTag)
+	protected.DELETE("/contacts/:id/tags/:tagId", tagService.RemoveContactTag)
+
+	// Merge endpoints, otherId is merged into id
+	protected.GET("/contacts/:id/merge/:otherId", mergeService.GetMergePreview)
+	protected.POST("/contacts/:id/merge/:otherId", mergeService.MergeContacts)
+
+	// Smart list endpoints
+	protected.GET("/lists", smartListService.GetSmartLists)
+	protected.GET("/lists/filters", smartListService.GetSmartListFilters)
+	protected.POST("/lists", smartListService.CreateSmartList)
+	protected.POST("/lists/:listId/pin", smartListService.TogglePin)
+	protected.POST("/lists/:listId/tags", smartListService.TagSmartList)
+	protected.DELETE("/lists/:listId", smartListService.DeleteSmartList)
+
+	// Relationship type endpoints
+	protected.GET("/relationships", relationshipTypeService.GetRelationshipTypes)
+	protected.POST("/relationships", relationshipTypeService.CreateRelationshipType)
+	protected.PUT("/relationships/:typeId", relationshipTypeService.RenameRelationshipType)
+	protected.DELETE("/relationships/:typeId", relationshipTypeService.DeleteRelationshipType)
+
+	// Household endpoints
+	protected.GET("/households", householdService.GetHouseholds)
+	protected.POST("/households", householdService.CreateHousehold)
+	protected.GET("/households/:householdId", householdService.GetHousehold)
+	protected.PUT("/households/:householdId", householdService.UpdateHousehold)
+	protected.DELETE("/households/:householdId", householdService.DeleteHousehold)
+	protected.POST("/households/:householdId/members", householdService.AddHouseholdMembers)
+	protected.DELETE("/households/:householdId/members/:contactId", householdService.RemoveHouseholdMember)
+	protected.POST("/households/:householdId/events", householdService.CreateHouseholdEvent)
+	protected.DELETE("/households/:householdId/events/:eventId", householdService.DeleteHouseholdEvent)
+
+	// Trash endpoints
+	protected.GET("/trash", trashService.GetTrash)
+	protected.DELETE("/trash", trashService.EmptyTrash)
+	protected.POST("/trash/restore", trashService.UndoDelete)
+	protected.POST("/trash/:id/restore", trashService.RestoreContact)
+	protected.DELETE("/trash/:id", trashService.PurgeContact)
+
+	// Custom field endpoints
+	protected.GET("/fields", customFieldService.GetCustomFields)
+	protected.POST("/fields", customFieldService.CreateCustomField)
+	protected.DELETE("/fields/:fieldId", customFieldService.DeleteCustomField)
+
+	// Account export
+	protected.GET("/account/export", exportService.ExportAccount)
+
+	// JSON API (authentication via bearer token or session cookie)
+	api := e.Group("/api/v1")
+	api.Use(middleware.APIAuthMiddleware(cfg.JWT.SecretKey))
+
+	api.GET("/dashboard", apiHandler.GetDashboard)
+	api.GET("/account/export", apiHandler.ExportAccount)
+
+	api.GET("/contacts", apiHandler.ListContacts)
+	api.POST("/contacts", apiHandler.CreateContact)
+	api.POST("/contacts/bulk", apiHandler.BulkContacts)
+	api.GET("/contacts/export/vcard", apiHandler.ExportVCard)
+	api.GET("/contacts/export/greetings", apiHandler.ExportGreetings)
+	api.POST("/contacts/import/vcard", apiHandler.ImportVCard)
+	api.POST("/contacts/import/csv", apiHandler.ImportCSV)
+	api.GET("/contacts/:id", apiHandler.GetContact)
+	api.GET("/contacts/:id/vcard", apiHandler.ExportContactVCard)
+	api.PUT("/contacts/:id", apiHandler.UpdateContact)
+	api.DELETE("/contacts/:id", apiHandler.DeleteContact)
+	api.GET("/contacts/:id/history", apiHandler.GetContactHistory)
+	api.POST("/contacts/:id/history/:revision/restore", apiHandler.RestoreContactRevision)
+
+	api.GET("/duplicates", apiHandler.ListDuplicates)
+	api.POST("/contacts/:id/merge", apiHandler.MergeContacts)
+	api.GET("/contacts/:id/merges", apiHandler.ListContactMerges)
+
+	api.GET("/contacts/:id/calendar/sync", apiHandler.GetCalendarSyncStatus)
+	api.POST("/contacts/:id/calendar/sync", apiHandler.SyncBirthday)
+	api.DELETE("/contacts/:id/calendar/sync", apiHandler.UnsyncBirthday)
+
+	api.GET("/contacts/:id/events", apiHandler.ListContactEvents)
+	api.POST("/contacts/:id/events", apiHandler.CreateEvent)
+	api.GET("/events/:eventId", apiHandler.GetEvent)
+	api.PUT("/events/:eventId", apiHandler.UpdateEvent)
+	api.DELETE("/events/:eventId", apiHandler.DeleteEvent)
+
+	api.GET("/contacts/:id/interactions", apiHandler.ListContactInteractions)
+	api.POST("/contacts/:id/interactions", apiHandler.CreateInteraction)
+	api.DELETE("/interactions/:interactionId", apiHandler.DeleteInteraction)
+
+	api.GET("/tasks", apiHandler.ListTasks)
+	api.POST("/tasks", apiHandler.CreateTask)
+	api.GET("/contacts/:id/tasks", apiHandler.ListContactTasks)
+	api.GET("/tasks/:taskId", apiHandler.GetTask)
+	api.PUT("/tasks/:taskId", apiHandler.UpdateTask)
+	api.DELETE("/tasks/:taskId", apiHandler.DeleteTask)
+	api.POST("/tasks/:taskId/complete", apiHandler.CompleteTask)
+	api.POST("/tasks/:taskId/snooze", apiHandler.SnoozeTask)
+
+	api.GET("/contacts/:id/notes", apiHandler.ListContactNotes)
+	api.POST("/contacts/:id/notes", apiHandler.CreateNote)
+	api.GET("/notes/:noteId", apiHandler.GetNote)
+	api.PUT("/notes/:noteId", apiHandler.UpdateNote)
+	api.DELETE("/notes/:noteId", apiHandler.DeleteNote)
+
+	api.GET("/contacts/:id/attachments", apiHandler.ListContactAttachments)
+	api.POST("/contacts/:id/attachments", apiHandler.CreateAttachment)
+	api.GET("/attachments/:attachmentId", apiHandler.DownloadAttachment)
+	api.DELETE("/attachments/:attachmentId", apiHandler.DeleteAttachment)
+	api.GET("/contacts/:id/photo", apiHandler.GetContactPhoto)
+	api.PUT("/contacts/:id/photo", apiHandler.SetContactPhoto)
+	api.DELETE("/contacts/:id/photo", apiHandler.DeleteContactPhoto)
+
+	api.GET("/keep-in-touch", apiHandler.ListKeepInTouch)
+	api.POST("/contacts/:id/keep-in-touch/done", apiHandler.CompleteKeepInTouch)
+	api.POST("/contacts/:id/keep-in-touch/snooze", apiHandler.SnoozeKeepInTouch)
+	api.GET("/drifting", apiHandler.ListDrifting)
+
+	api.GET("/link-types", apiHandler.ListLinkTypes)
+	api.GET("/contacts/:id/links", apiHandler.ListContactLinks)
+	api.POST("/contacts/:id/links", apiHandler.CreateContactLink)
+	api.DELETE("/links/:linkId", apiHandler.DeleteContactLink)
+
+	api.GET("/tags", apiHandler.ListTags)
+	api.POST("/tags", apiHandler.CreateTag)
+	api.PUT("/tags/:tagId", apiHandler.UpdateTag)
+	api.DELETE("/tags/:tagId", apiHandler.DeleteTag)
+	api.POST("/tags/:tagId/contacts", apiHandler.TagContacts)
+	api.DELETE("/tags/:tagId/contacts", apiHandler.UntagContacts)
+	api.POST("/contacts/:id/tags", apiHandler.AddContactTag)
+	api.DELETE("/contacts/:id/tags/:tagId", apiHandler.RemoveContactTag)
+
+	api.GET("/smart-lists", apiHandler.ListSmartLists)
+	api.POST("/smart-lists", apiHandler.CreateSmartList)
+	api.GET("/smart-lists/:listId", apiHandler.GetSmartList)
+	api.PUT("/smart-lists/:listId", apiHandler.UpdateSmartList)
+	api.DELETE("/smart-lists/:listId", apiHandler.DeleteSmartList)
+
+	api.GET("/relationship-types", apiHandler.ListRelationshipTypes)
+	api.POST("/relationship-types", apiHandler.CreateRelationshipType)
+	api.PUT("/relationship-types/:typeId", apiHandler.UpdateRelationshipType)
+	api.DELETE("/relationship-types/:typeId", apiHandler.DeleteRelationshipType)
+
+	api.GET("/households", apiHandler.ListHouseholds)
+	api.POST("/households", apiHandler.CreateHousehold)
+	api.GET("/households/:householdId", apiHandler.GetHousehold)
+	api.PUT("/households/:householdId", apiHandler.UpdateHousehold)
+	api.DELETE("/households/:householdId", apiHandler.DeleteHousehold)
+	api.POST("/households/:householdId/members", apiHandler.AddHouseholdMembers)
+	api.DELETE("/households/:householdId/members/:contactId", apiHandler.RemoveHouseholdMember)
+	api.POST("/households/:householdId/events", apiHandler.CreateHouseholdEvent)
+	api.DELETE("/households/:householdId/events/:eventId", apiHandler.DeleteHouseholdEvent)
+
+	api.GET("/trash", apiHandler.ListTrash)
+	api.DELETE("/trash", apiHandler.EmptyTrash)
+	api.POST("/trash/:id/restore", apiHandler.RestoreContact)
+	api.DELETE("/trash/:id", apiHandler.PurgeContact)
+
+	api.GET("/custom-fields", apiHandler.ListCustomFields)
+	api.POST("/custom-fields", apiHandler.CreateCustomField)
+	api.PUT("/custom-fields/:fieldId", apiHandler.UpdateCustomField)
+	api.DELETE("/custom-fields/:fieldId", apiHandler.DeleteCustomField)
+
 	// Start the HTTP server
 	e.Logger.Fatal(e.Start(":8080"))
 }
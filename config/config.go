@@ -8,10 +8,12 @@ import (
 )
 
 type Configuration struct {
-	DB    DB    `yaml:"db"`
-	Log   Log   `yaml:"log"`
-	OAuth OAuth `yaml:"oauth"`
-	JWT   JWT   `yaml:"jwt"`
+	DB      DB      `yaml:"db"`
+	Log     Log     `yaml:"log"`
+	OAuth   OAuth   `yaml:"oauth"`
+	JWT     JWT     `yaml:"jwt"`
+	Storage Storage `yaml:"storage"`
+	Trash   Trash   `yaml:"trash"`
 }
 
 type DB struct {
@@ -39,6 +41,31 @@ type JWT struct {
 	ExpiryHours int    `yaml:"expiry_hours" mapstructure:"expiry_hours" env:"JWT_EXPIRY_HOURS"`
 }
 
+// Storage configures where attachments and contact photos are kept. Backend
+// is "local" (the default) or "s3" for any S3-compatible service such as MinIO.
+type Storage struct {
+	Backend     string `yaml:"backend" mapstructure:"backend" env:"STORAGE_BACKEND"`
+	LocalPath   string `yaml:"local_path" mapstructure:"local_path" env:"STORAGE_LOCAL_PATH"`
+	MaxUploadMB int    `yaml:"max_upload_mb" mapstructure:"max_upload_mb" env:"STORAGE_MAX_UPLOAD_MB"`
+	S3          S3     `yaml:"s3" mapstructure:"s3"`
+}
+
+// S3 is an S3-compatible bucket. Endpoint includes the scheme, e.g.
+// "http://localhost:9000"; objects are addressed path-style.
+type S3 struct {
+	Endpoint  string `yaml:"endpoint" mapstructure:"endpoint" env:"STORAGE_S3_ENDPOINT"`
+	Region    string `yaml:"region" mapstructure:"region" env:"STORAGE_S3_REGION"`
+	Bucket    string `yaml:"bucket" mapstructure:"bucket" env:"STORAGE_S3_BUCKET"`
+	AccessKey string `yaml:"access_key" mapstructure:"access_key" env:"STORAGE_S3_ACCESS_KEY"`
+	SecretKey string `yaml:"secret_key" mapstructure:"secret_key" env:"STORAGE_S3_SECRET_KEY"`
+}
+
+// Trash configures how long deleted contacts can be restored before they are
+// purged, with their Google Calendar events and files. 30 days when not set.
+type Trash struct {
+	RetentionDays int `yaml:"retention_days" mapstructure:"retention_days" env:"TRASH_RETENTION_DAYS"`
+}
+
 func NewConfig() *Configuration {
 	var config Configuration
 
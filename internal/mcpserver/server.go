@@ -0,0 +1,105 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	jwtutil "github.com/La002/personal-crm/pkg/jwt"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/modelcontextprotocol/go-sdk/auth"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	serverName    = "personal-crm"
+	serverVersion = "1.0.0"
+
+	// actor recorded in the contact history for changes made through MCP
+	actor = "MCP assistant"
+)
+
+// tools holds the dependencies of the tool handlers. Every handler is bound to
+// a single user so that an MCP session can never read or modify other users' data.
+type tools struct {
+	repo   repository.ContactDao
+	userID uint
+}
+
+// NewServer creates an MCP server whose tools operate on the contacts of userID
+func NewServer(repo repository.ContactDao, userID uint) *mcp.Server {
+	server := mcp.NewServer(&mcp.Implementation{
+		Name:    serverName,
+		Title:   "Personal CRM",
+		Version: serverVersion,
+	}, nil)
+
+	t := &tools{repo: repo, userID: userID}
+	t.register(server)
+
+	return server
+}
+
+// UserFromToken validates a session JWT and returns the user it belongs to
+func UserFromToken(token, jwtSecret string) (uint, error) {
+	claims, err := jwtutil.ValidateToken(token, jwtSecret)
+	if err != nil {
+		return 0, err
+	}
+	return jwtutil.ExtractUserID(claims)
+}
+
+// NewSSEHandler serves MCP over HTTP/SSE. Each request must carry a session JWT
+// as a bearer token and gets a server scoped to the user in that token.
+func NewSSEHandler(repo repository.ContactDao, jwtSecret string) http.Handler {
+	verifier := func(ctx context.Context, token string, req *http.Request) (*auth.TokenInfo, error) {
+		claims, err := jwtutil.ValidateToken(token, jwtSecret)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", auth.ErrInvalidToken, err)
+		}
+
+		userID, err := jwtutil.ExtractUserID(claims)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", auth.ErrInvalidToken, err)
+		}
+
+		exp, err := claims.GetExpirationTime()
+		if err != nil || exp == nil {
+			return nil, fmt.Errorf("%w: token has no expiry", auth.ErrInvalidToken)
+		}
+
+		return &auth.TokenInfo{
+			UserID:     strconv.FormatUint(uint64(userID), 10),
+			Expiration: exp.Time,
+		}, nil
+	}
+
+	sse := mcp.NewSSEHandler(func(req *http.Request) *mcp.Server {
+		info := auth.TokenInfoFromContext(req.Context())
+		if info == nil {
+			return nil
+		}
+
+		userID, err := strconv.ParseUint(info.UserID, 10, 32)
+		if err != nil {
+			return nil
+		}
+		return NewServer(repo, uint(userID))
+	}, nil)
+
+	return auth.RequireBearerToken(verifier, nil)(sse)
+}
+
+// parseDate parses an optional YYYY-MM-DD tool argument
+func parseDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", value)
+	}
+	return &t, nil
+}
@@ -0,0 +1,918 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Contact is the representation of a contact returned by the tools. Emails,
+// Phones and Addresses list every value, primary first.
+type Contact struct {
+	ID            uint              `json:"id"`
+	Name          string            `json:"name"`
+	Relationship  string            `json:"relationship,omitempty"`
+	Company       string            `json:"company,omitempty"`
+	Industry      string            `json:"industry,omitempty"`
+	Birthday      string            `json:"birthday,omitempty"`
+	Vip           bool              `json:"vip"`
+	Location      string            `json:"location,omitempty"`
+	PhoneNumber   string            `json:"phone_number,omitempty"`
+	Email         string            `json:"email,omitempty"`
+	Emails        []string          `json:"emails,omitempty"`
+	Phones        []string          `json:"phones,omitempty"`
+	Addresses     []string          `json:"addresses,omitempty"`
+	Notes         []Note            `json:"notes,omitempty"`
+	LastMet       string            `json:"last_met,omitempty"`
+	LastContacted string            `json:"last_contacted,omitempty"`
+	LastUpdate    string            `json:"last_update,omitempty"`
+	Cadence       string            `json:"cadence,omitempty"`
+	NextDue       string            `json:"next_due,omitempty"`
+	StrengthScore int               `json:"strength_score" jsonschema:"relationship strength from 0 to 100, recomputed hourly"`
+	Drifting      bool              `json:"drifting,omitempty" jsonschema:"true when the relationship is fading"`
+	Tags          []string          `json:"tags,omitempty"`
+	CustomFields  map[string]string `json:"custom_fields,omitempty"`
+	// Snippet is the matching excerpt of a free-text search
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// Note is a note about a contact, pinned notes first, then newest first
+type Note struct {
+	ID        uint   `json:"id"`
+	Body      string `json:"body"`
+	Pinned    bool   `json:"pinned,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+func newContact(contact entity.Contact) Contact {
+	var tags []string
+	for _, tag := range contact.Tags {
+		tags = append(tags, tag.Name)
+	}
+	var addresses []string
+	for _, address := range contact.Addresses {
+		addresses = append(addresses, strings.Join(address.Lines(), ", "))
+	}
+	var notes []Note
+	for _, note := range contact.Notes {
+		notes = append(notes, Note{ID: note.ID, Body: note.Body, Pinned: note.Pinned, CreatedAt: note.CreatedAt.Format("2006-01-02 15:04")})
+	}
+	cadence, _ := contact.Cadence()
+
+	return Contact{
+		ID:            contact.ID,
+		Name:          contact.Name,
+		Relationship:  string(contact.Relationship),
+		Company:       contact.Company,
+		Industry:      contact.Industry,
+		Birthday:      contact.Birthday.String(),
+		Vip:           contact.Vip,
+		Location:      contact.Location,
+		PhoneNumber:   contact.PhoneNumber,
+		Email:         contact.Email,
+		Emails:        contact.ChannelValues(entity.EmailChannel),
+		Phones:        contact.ChannelValues(entity.PhoneChannel),
+		Addresses:     addresses,
+		Notes:         notes,
+		LastMet:       contact.LastMet.String(),
+		LastContacted: contact.LastContacted.String(),
+		LastUpdate:    contact.LastUpdate.String(),
+		Cadence:       entity.CadenceLabel(cadence),
+		NextDue:       contact.NextDue().String(),
+		StrengthScore: contact.StrengthScore,
+		Drifting:      !contact.DriftingSince.IsZero(),
+		Tags:          tags,
+		CustomFields:  contact.CustomFields,
+	}
+}
+
+func newContacts(contacts []entity.Contact) []Contact {
+	res := make([]Contact, 0, len(contacts))
+	for _, contact := range contacts {
+		res = append(res, newContact(contact))
+	}
+	return res
+}
+
+type ContactList struct {
+	Contacts []Contact `json:"contacts"`
+}
+
+type ListContactsInput struct {
+	Limit int `json:"limit,omitempty" jsonschema:"maximum number of contacts to return, 0 returns all"`
+}
+
+type SearchContactsInput struct {
+	SmartListID         uint              `json:"smart_list_id,omitempty" jsonschema:"start from the contacts of this smart list, the other filters narrow them down"`
+	Query               string            `json:"query,omitempty" jsonschema:"free text matched against names, companies, industries, locations, notes, event titles, emails, phone numbers and postal addresses; results are ranked"`
+	Relationship        string            `json:"relationship,omitempty" jsonschema:"relationship prefix, see list_relationship_types"`
+	VipOnly             *bool             `json:"vip_only,omitempty" jsonschema:"true for VIP contacts only, false for non-VIP contacts only"`
+	Location            string            `json:"location,omitempty" jsonschema:"location prefix, case insensitive, also matched against the city, region and country of every address"`
+	LastContactedBefore string            `json:"last_contacted_before,omitempty" jsonschema:"only contacts last contacted before this date (YYYY-MM-DD) or never"`
+	Tags                []string          `json:"tags,omitempty" jsonschema:"tag names, only contacts carrying all of them"`
+	CustomFields        map[string]string `json:"custom_fields,omitempty" jsonschema:"custom field values by key, text fields match on a substring"`
+	Limit               int               `json:"limit,omitempty" jsonschema:"maximum number of contacts to return, 0 returns all"`
+}
+
+type GetContactInput struct {
+	ID uint `json:"id" jsonschema:"contact id"`
+}
+
+type UpdateContactInput struct {
+	ID           uint              `json:"id" jsonschema:"contact id"`
+	Name         *string           `json:"name,omitempty"`
+	Relationship *string           `json:"relationship,omitempty" jsonschema:"one of the relationship types from list_relationship_types, empty to clear it"`
+	Company      *string           `json:"company,omitempty"`
+	Industry     *string           `json:"industry,omitempty"`
+	Birthday     *string           `json:"birthday,omitempty" jsonschema:"YYYY-MM-DD, or --MM-DD when the year is unknown"`
+	Vip          *bool             `json:"vip,omitempty"`
+	Location     *string           `json:"location,omitempty" jsonschema:"becomes the primary address, the other addresses are kept"`
+	PhoneNumber  *string           `json:"phone_number,omitempty" jsonschema:"becomes the primary phone number, the other numbers are kept"`
+	Email        *string           `json:"email,omitempty" jsonschema:"becomes the primary email, the other emails are kept"`
+	LastUpdate   *string           `json:"last_update,omitempty" jsonschema:"YYYY-MM-DD"`
+	CadenceDays  *int              `json:"cadence_days,omitempty" jsonschema:"keep in touch every this many days, 0 to use the cadence of the contact's tags"`
+	CustomFields map[string]string `json:"custom_fields,omitempty" jsonschema:"custom field values by key, an empty value clears the field"`
+}
+
+type AppendNoteInput struct {
+	ID   uint   `json:"id" jsonschema:"contact id"`
+	Note string `json:"note" jsonschema:"text of the note, it is stored with the current time"`
+}
+
+type LogInteractionInput struct {
+	ID         uint   `json:"id" jsonschema:"contact id"`
+	Type       string `json:"type" jsonschema:"one of call, meeting, message, email"`
+	Date       string `json:"date,omitempty" jsonschema:"YYYY-MM-DD, defaults to today"`
+	Notes      string `json:"notes,omitempty"`
+	ContactIDs []uint `json:"contact_ids,omitempty" jsonschema:"other contacts who took part"`
+}
+
+type ListCustomFieldsInput struct{}
+
+// CustomField describes a custom field the user added to their contacts
+type CustomField struct {
+	Key     string   `json:"key"`
+	Label   string   `json:"label"`
+	Type    string   `json:"type"`
+	Options []string `json:"options,omitempty"`
+}
+
+type CustomFieldList struct {
+	CustomFields []CustomField `json:"custom_fields"`
+}
+
+type ListRelationshipTypesInput struct{}
+
+type RelationshipTypeList struct {
+	RelationshipTypes []string `json:"relationship_types"`
+}
+
+type ListHouseholdsInput struct{}
+
+// Household is a group of contacts addressed together, such as a family
+type Household struct {
+	ID         uint              `json:"id"`
+	Name       string            `json:"name"`
+	Salutation string            `json:"salutation"`
+	Address    string            `json:"address,omitempty"`
+	Members    []HouseholdMember `json:"members"`
+	Events     []HouseholdEvent  `json:"events,omitempty"`
+}
+
+type HouseholdMember struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+type HouseholdEvent struct {
+	Title      string `json:"title"`
+	EventDate  string `json:"event_date"`
+	Recurrence string `json:"recurrence"`
+}
+
+type HouseholdList struct {
+	Households []Household `json:"households"`
+}
+
+type ListSmartListsInput struct{}
+
+// SmartList is a saved contact filter, pass its id as smart_list_id to
+// search_contacts to get its contacts
+type SmartList struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Pinned      bool   `json:"pinned"`
+	Count       int64  `json:"count"`
+}
+
+type SmartListList struct {
+	SmartLists []SmartList `json:"smart_lists"`
+}
+
+type FindDuplicatesInput struct{}
+
+// DuplicatePair is two contacts that probably describe the same person
+type DuplicatePair struct {
+	ContactID   uint     `json:"contact_id"`
+	ContactName string   `json:"contact_name"`
+	OtherID     uint     `json:"other_id"`
+	OtherName   string   `json:"other_name"`
+	Score       float64  `json:"score"`
+	Reasons     []string `json:"reasons"`
+}
+
+type DuplicateList struct {
+	Duplicates []DuplicatePair `json:"duplicates"`
+}
+
+type ListUpcomingEventsInput struct {
+	Days int `json:"days,omitempty" jsonschema:"number of days to look ahead, defaults to 30"`
+}
+
+// UpcomingEvent is a birthday or custom event happening soon
+type UpcomingEvent struct {
+	ContactID   uint   `json:"contact_id"`
+	ContactName string `json:"contact_name"`
+	Type        string `json:"type"`
+	Title       string `json:"title"`
+	Date        string `json:"date"`
+	DaysUntil   int    `json:"days_until"`
+	Age         int    `json:"age,omitempty" jsonschema:"age reached on a birthday, when the year of birth is known"`
+}
+
+type UpcomingEventList struct {
+	Events []UpcomingEvent `json:"events"`
+}
+
+type ListOverdueContactsInput struct{}
+
+// OverdueContact is a contact the user is due to get in touch with
+type OverdueContact struct {
+	ContactID     uint   `json:"contact_id"`
+	ContactName   string `json:"contact_name"`
+	LastContacted string `json:"last_contacted,omitempty"`
+	Cadence       string `json:"cadence"`
+	NextDue       string `json:"next_due"`
+	DaysOverdue   int    `json:"days_overdue"`
+}
+
+type OverdueContactList struct {
+	Contacts []OverdueContact `json:"contacts"`
+}
+
+type ListDriftingContactsInput struct {
+	Limit int `json:"limit,omitempty" jsonschema:"maximum number of contacts to return, 0 returns all"`
+}
+
+// DriftingContact is a contact the relationship with is fading
+type DriftingContact struct {
+	ContactID     uint   `json:"contact_id"`
+	ContactName   string `json:"contact_name"`
+	StrengthScore int    `json:"strength_score"`
+	LastContacted string `json:"last_contacted"`
+	DriftingSince string `json:"drifting_since"`
+}
+
+type DriftingContactList struct {
+	Contacts []DriftingContact `json:"contacts"`
+}
+
+type ListTasksInput struct {
+	Status        string `json:"status,omitempty" jsonschema:"open or done, defaults to open"`
+	ContactID     uint   `json:"contact_id,omitempty" jsonschema:"only tasks about this contact"`
+	DueWithinDays int    `json:"due_within_days,omitempty" jsonschema:"only tasks overdue or due in the next number of days"`
+}
+
+type CreateTaskInput struct {
+	Title      string `json:"title"`
+	Notes      string `json:"notes,omitempty"`
+	DueOn      string `json:"due_on,omitempty" jsonschema:"YYYY-MM-DD, leave out for a task without due date"`
+	Priority   string `json:"priority,omitempty" jsonschema:"one of high, normal, low, defaults to normal"`
+	ContactIDs []uint `json:"contact_ids" jsonschema:"the contacts the task is about, at least one"`
+}
+
+// Task is a follow-up to-do about one or more contacts
+type Task struct {
+	ID       uint          `json:"id"`
+	Title    string        `json:"title"`
+	Notes    string        `json:"notes,omitempty"`
+	DueOn    string        `json:"due_on,omitempty"`
+	Priority string        `json:"priority"`
+	Status   string        `json:"status"`
+	Overdue  bool          `json:"overdue"`
+	Contacts []TaskContact `json:"contacts"`
+}
+
+// TaskContact is a contact a task is about
+type TaskContact struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+func newTask(task entity.Task) Task {
+	res := Task{
+		ID:       task.ID,
+		Title:    task.Title,
+		Notes:    task.Notes,
+		DueOn:    task.DueOn.String(),
+		Priority: string(task.Priority),
+		Status:   string(task.Status),
+		Overdue:  task.IsOverdue(entity.Today()),
+		Contacts: []TaskContact{},
+	}
+	for _, contact := range task.Contacts {
+		res.Contacts = append(res.Contacts, TaskContact{ID: contact.ID, Name: contact.Name})
+	}
+	return res
+}
+
+type TaskList struct {
+	Tasks []Task `json:"tasks"`
+}
+
+func (t *tools) register(server *mcp.Server) {
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "list_contacts",
+		Description: "List the user's contacts",
+	}, t.listContacts)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "search_contacts",
+		Description: "Search contacts by free text, relationship, VIP status, location, tags, custom fields and last contact date",
+	}, t.searchContacts)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "get_contact",
+		Description: "Get all details of a single contact",
+	}, t.getContact)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "update_contact",
+		Description: "Update fields of a contact. Only the fields provided are changed",
+	}, t.updateContact)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "append_note",
+		Description: "Add a timestamped note to a contact",
+	}, t.appendNote)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "log_interaction",
+		Description: "Log a call, meeting, message or email with a contact. Last contacted and last met are updated from it",
+	}, t.logInteraction)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "list_custom_fields",
+		Description: "List the custom fields defined for contacts, with their keys, types and select options",
+	}, t.listCustomFields)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "list_relationship_types",
+		Description: "List the relationship types the user gives their contacts, such as Friend or Mentor",
+	}, t.listRelationshipTypes)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "list_households",
+		Description: "List the user's households, groups of contacts such as a family, with their members, greeting, shared address and shared events like anniversaries",
+	}, t.listHouseholds)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "list_smart_lists",
+		Description: "List the user's smart lists, saved contact filters such as \"Investors to call\", with their conditions and number of contacts",
+	}, t.listSmartLists)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "find_duplicates",
+		Description: "Find pairs of contacts that share an email or phone number or have similar names. Merging is done in the web app",
+	}, t.findDuplicates)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "list_upcoming_events",
+		Description: "List birthdays and custom events in the coming days",
+	}, t.listUpcomingEvents)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "list_overdue_contacts",
+		Description: "List the contacts due to be contacted according to their keep-in-touch cadence, the longest overdue first. Logging an interaction makes a contact due again one cadence later",
+	}, t.listOverdueContacts)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "list_drifting_contacts",
+		Description: "List the contacts the relationship with is drifting away, the weakest first: contacts not heard from for well beyond their usual rhythm or cadence, with a low strength score",
+	}, t.listDriftingContacts)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "list_tasks",
+		Description: "List follow-up tasks about contacts, open ones first by due date",
+	}, t.listTasks)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "create_task",
+		Description: "Create a follow-up task about one or more contacts, e.g. sending an article by Friday",
+	}, t.createTask)
+}
+
+func (t *tools) listContacts(ctx context.Context, req *mcp.CallToolRequest, in ListContactsInput) (*mcp.CallToolResult, ContactList, error) {
+	contacts, err := t.repo.GetAllContactsWithLimit(t.userID, in.Limit)
+	if err != nil {
+		return nil, ContactList{}, fmt.Errorf("failed to list contacts: %w", err)
+	}
+	return nil, ContactList{Contacts: newContacts(contacts)}, nil
+}
+
+func (t *tools) searchContacts(ctx context.Context, req *mcp.CallToolRequest, in SearchContactsInput) (*mcp.CallToolResult, ContactList, error) {
+	filters := repository.ContactSearchFilters{}
+	if in.SmartListID != 0 {
+		list, err := t.repo.GetSmartListByID(in.SmartListID, t.userID)
+		if err != nil {
+			return nil, ContactList{}, fmt.Errorf("smart list %d not found", in.SmartListID)
+		}
+		filters = repository.SmartListFilters(list.Filters, time.Now())
+	}
+	filters.Limit = in.Limit
+	filters.Tags = append(filters.Tags, in.Tags...)
+	if in.VipOnly != nil {
+		filters.VipOnly = in.VipOnly
+	}
+	if in.Relationship != "" {
+		filters.Relationship = &in.Relationship
+	}
+	if in.Location != "" {
+		filters.Location = &in.Location
+	}
+	for key, value := range in.CustomFields {
+		if filters.CustomFields == nil {
+			filters.CustomFields = map[string]string{}
+		}
+		filters.CustomFields[key] = value
+	}
+
+	before, err := parseDate(in.LastContactedBefore)
+	if err != nil {
+		return nil, ContactList{}, err
+	}
+	if before != nil {
+		filters.LastContactedBefore = before
+	}
+
+	if strings.TrimSpace(in.Query) != "" {
+		results, err := t.repo.SearchContactsFullText(t.userID, in.Query, filters)
+		if err != nil {
+			return nil, ContactList{}, fmt.Errorf("failed to search contacts: %w", err)
+		}
+
+		contacts := make([]Contact, 0, len(results))
+		for _, result := range results {
+			contact := newContact(result.Contact)
+			contact.Snippet = strings.NewReplacer(repository.HighlightStart, "", repository.HighlightStop, "").Replace(result.Snippet)
+			contacts = append(contacts, contact)
+		}
+		return nil, ContactList{Contacts: contacts}, nil
+	}
+
+	contacts, err := t.repo.SearchContactsAdvanced(t.userID, filters)
+	if err != nil {
+		return nil, ContactList{}, fmt.Errorf("failed to search contacts: %w", err)
+	}
+	return nil, ContactList{Contacts: newContacts(contacts)}, nil
+}
+
+func (t *tools) getContact(ctx context.Context, req *mcp.CallToolRequest, in GetContactInput) (*mcp.CallToolResult, Contact, error) {
+	contact, err := t.repo.GetContact(fmt.Sprintf("%d", in.ID), t.userID)
+	if err != nil {
+		return nil, Contact{}, fmt.Errorf("contact %d not found", in.ID)
+	}
+	return nil, newContact(contact), nil
+}
+
+func (t *tools) updateContact(ctx context.Context, req *mcp.CallToolRequest, in UpdateContactInput) (*mcp.CallToolResult, Contact, error) {
+	updates := map[string]interface{}{}
+	setString := func(column string, value *string) {
+		if value != nil {
+			updates[column] = strings.TrimSpace(*value)
+		}
+	}
+
+	if in.Name != nil && strings.TrimSpace(*in.Name) == "" {
+		return nil, Contact{}, fmt.Errorf("name cannot be empty")
+	}
+	if in.Relationship != nil && strings.TrimSpace(*in.Relationship) != "" {
+		types, err := t.repo.GetRelationshipTypes(t.userID)
+		if err != nil {
+			return nil, Contact{}, fmt.Errorf("failed to list relationship types: %w", err)
+		}
+		relationshipType, ok := entity.FindRelationshipType(types, *in.Relationship)
+		if !ok {
+			return nil, Contact{}, fmt.Errorf("relationship must be one of %s", strings.Join(entity.RelationshipTypeNames(types), ", "))
+		}
+		in.Relationship = &relationshipType.Name
+	}
+	// Date columns take a parsed date, an empty value clears them
+	if in.Birthday != nil {
+		birthday, err := entity.ParseBirthday(strings.TrimSpace(*in.Birthday))
+		if err != nil {
+			return nil, Contact{}, err
+		}
+		updates["birthday"] = birthday
+	}
+	if in.LastUpdate != nil {
+		lastUpdate, err := entity.ParseDate(strings.TrimSpace(*in.LastUpdate))
+		if err != nil {
+			return nil, Contact{}, err
+		}
+		updates["last_update"] = lastUpdate
+	}
+
+	setString("name", in.Name)
+	setString("relationship", in.Relationship)
+	setString("company", in.Company)
+	setString("industry", in.Industry)
+	setString("location", in.Location)
+	setString("phone_number", in.PhoneNumber)
+	setString("email", in.Email)
+	if in.Vip != nil {
+		updates["vip"] = *in.Vip
+	}
+	if in.CadenceDays != nil {
+		if err := entity.ValidCadence(*in.CadenceDays); err != nil {
+			return nil, Contact{}, err
+		}
+		updates["cadence_days"] = *in.CadenceDays
+	}
+
+	id := fmt.Sprintf("%d", in.ID)
+	if len(in.CustomFields) > 0 {
+		current, err := t.repo.GetContact(id, t.userID)
+		if err != nil {
+			return nil, Contact{}, fmt.Errorf("contact %d not found", in.ID)
+		}
+		defs, err := t.repo.GetCustomFieldDefinitions(t.userID)
+		if err != nil {
+			return nil, Contact{}, fmt.Errorf("failed to load custom fields: %w", err)
+		}
+		customFields, err := entity.ApplyCustomFields(defs, current.CustomFields, in.CustomFields)
+		if err != nil {
+			return nil, Contact{}, err
+		}
+		updates["custom_fields"] = customFields
+	}
+
+	// Clear the relationship with NULL, as entity.Relation stores "", so the
+	// relationship filters and counts see no relationship the same way
+	if relationship, ok := updates["relationship"]; ok && relationship == "" {
+		updates["relationship"] = nil
+	}
+
+	if len(updates) == 0 {
+		return nil, Contact{}, fmt.Errorf("no fields to update")
+	}
+
+	if err := t.repo.UpdateContactFields(id, t.userID, updates, actor); err != nil {
+		return nil, Contact{}, fmt.Errorf("failed to update contact: %w", err)
+	}
+
+	contact, err := t.repo.GetContact(id, t.userID)
+	if err != nil {
+		return nil, Contact{}, fmt.Errorf("failed to fetch updated contact: %w", err)
+	}
+	return nil, newContact(contact), nil
+}
+
+func (t *tools) appendNote(ctx context.Context, req *mcp.CallToolRequest, in AppendNoteInput) (*mcp.CallToolResult, Contact, error) {
+	note := strings.TrimSpace(in.Note)
+	if note == "" {
+		return nil, Contact{}, fmt.Errorf("note cannot be empty")
+	}
+
+	id := fmt.Sprintf("%d", in.ID)
+	if _, err := t.repo.AppendNotes(id, t.userID, note); err != nil {
+		return nil, Contact{}, fmt.Errorf("failed to append note: %w", err)
+	}
+
+	contact, err := t.repo.GetContact(id, t.userID)
+	if err != nil {
+		return nil, Contact{}, fmt.Errorf("failed to fetch updated contact: %w", err)
+	}
+	return nil, newContact(contact), nil
+}
+
+func (t *tools) logInteraction(ctx context.Context, req *mcp.CallToolRequest, in LogInteractionInput) (*mcp.CallToolResult, Contact, error) {
+	interactionType := entity.InteractionType(in.Type)
+	if !interactionType.Valid() {
+		return nil, Contact{}, fmt.Errorf("type must be one of call, meeting, message, email")
+	}
+
+	occurredOn := entity.Today()
+	date, err := parseDate(in.Date)
+	if err != nil {
+		return nil, Contact{}, err
+	}
+	if date != nil {
+		occurredOn = entity.NewDate(*date)
+	}
+
+	interaction := &entity.Interaction{
+		UserID:     t.userID,
+		Type:       interactionType,
+		OccurredOn: occurredOn,
+		Notes:      strings.TrimSpace(in.Notes),
+	}
+	if err := t.repo.CreateInteraction(interaction, append([]uint{in.ID}, in.ContactIDs...)); err != nil {
+		return nil, Contact{}, fmt.Errorf("failed to log interaction: %w", err)
+	}
+
+	contact, err := t.repo.GetContact(fmt.Sprintf("%d", in.ID), t.userID)
+	if err != nil {
+		return nil, Contact{}, fmt.Errorf("failed to fetch updated contact: %w", err)
+	}
+	return nil, newContact(contact), nil
+}
+
+func (t *tools) findDuplicates(ctx context.Context, req *mcp.CallToolRequest, in FindDuplicatesInput) (*mcp.CallToolResult, DuplicateList, error) {
+	pairs, err := t.repo.FindDuplicateContacts(t.userID)
+	if err != nil {
+		return nil, DuplicateList{}, fmt.Errorf("failed to find duplicates: %w", err)
+	}
+
+	duplicates := make([]DuplicatePair, 0, len(pairs))
+	for _, pair := range pairs {
+		duplicates = append(duplicates, DuplicatePair{
+			ContactID:   pair.Contact.ID,
+			ContactName: pair.Contact.Name,
+			OtherID:     pair.Other.ID,
+			OtherName:   pair.Other.Name,
+			Score:       pair.Score,
+			Reasons:     pair.Reasons,
+		})
+	}
+	return nil, DuplicateList{Duplicates: duplicates}, nil
+}
+
+func (t *tools) listCustomFields(ctx context.Context, req *mcp.CallToolRequest, in ListCustomFieldsInput) (*mcp.CallToolResult, CustomFieldList, error) {
+	defs, err := t.repo.GetCustomFieldDefinitions(t.userID)
+	if err != nil {
+		return nil, CustomFieldList{}, fmt.Errorf("failed to list custom fields: %w", err)
+	}
+
+	fields := make([]CustomField, 0, len(defs))
+	for _, def := range defs {
+		fields = append(fields, CustomField{
+			Key:     def.Key,
+			Label:   def.Label,
+			Type:    string(def.Type),
+			Options: def.Options,
+		})
+	}
+	return nil, CustomFieldList{CustomFields: fields}, nil
+}
+
+func (t *tools) listHouseholds(ctx context.Context, req *mcp.CallToolRequest, in ListHouseholdsInput) (*mcp.CallToolResult, HouseholdList, error) {
+	households, err := t.repo.GetHouseholds(t.userID)
+	if err != nil {
+		return nil, HouseholdList{}, fmt.Errorf("failed to list households: %w", err)
+	}
+	events, err := t.repo.GetHouseholdEvents(t.userID)
+	if err != nil {
+		return nil, HouseholdList{}, fmt.Errorf("failed to list household events: %w", err)
+	}
+	eventsOf := map[uint][]HouseholdEvent{}
+	for _, event := range events {
+		eventsOf[event.HouseholdID] = append(eventsOf[event.HouseholdID], HouseholdEvent{
+			Title:      event.Title,
+			EventDate:  event.EventDate.String(),
+			Recurrence: event.Recurrence,
+		})
+	}
+
+	res := HouseholdList{Households: []Household{}}
+	for _, household := range households {
+		h := Household{
+			ID:         household.ID,
+			Name:       household.Name,
+			Salutation: household.Salutation(),
+			Members:    []HouseholdMember{},
+			Events:     eventsOf[household.ID],
+		}
+		if address, ok := household.MailingAddress(); ok {
+			h.Address = strings.Join(address.Lines(), ", ")
+		}
+		for _, member := range household.Members {
+			h.Members = append(h.Members, HouseholdMember{ID: member.ID, Name: member.Name})
+		}
+		res.Households = append(res.Households, h)
+	}
+	return nil, res, nil
+}
+
+func (t *tools) listRelationshipTypes(ctx context.Context, req *mcp.CallToolRequest, in ListRelationshipTypesInput) (*mcp.CallToolResult, RelationshipTypeList, error) {
+	types, err := t.repo.GetRelationshipTypes(t.userID)
+	if err != nil {
+		return nil, RelationshipTypeList{}, fmt.Errorf("failed to list relationship types: %w", err)
+	}
+	return nil, RelationshipTypeList{RelationshipTypes: entity.RelationshipTypeNames(types)}, nil
+}
+
+func (t *tools) listSmartLists(ctx context.Context, req *mcp.CallToolRequest, in ListSmartListsInput) (*mcp.CallToolResult, SmartListList, error) {
+	lists, err := t.repo.GetSmartLists(t.userID)
+	if err != nil {
+		return nil, SmartListList{}, fmt.Errorf("failed to list smart lists: %w", err)
+	}
+
+	res := make([]SmartList, 0, len(lists))
+	for _, list := range lists {
+		count, _ := t.repo.CountContacts(t.userID, repository.SmartListFilters(list.Filters, time.Now()))
+		res = append(res, SmartList{
+			ID:          list.ID,
+			Name:        list.Name,
+			Description: list.Filters.Describe(),
+			Pinned:      list.Pinned,
+			Count:       count,
+		})
+	}
+	return nil, SmartListList{SmartLists: res}, nil
+}
+
+func (t *tools) listUpcomingEvents(ctx context.Context, req *mcp.CallToolRequest, in ListUpcomingEventsInput) (*mcp.CallToolResult, UpcomingEventList, error) {
+	days := in.Days
+	if days <= 0 {
+		days = 30
+	}
+
+	today := entity.Today()
+	events := []UpcomingEvent{}
+
+	birthdays, err := t.repo.GetUpcomingBirthdays(t.userID, days)
+	if err != nil {
+		return nil, UpcomingEventList{}, fmt.Errorf("failed to list birthdays: %w", err)
+	}
+	for _, contact := range birthdays {
+		next := contact.Birthday.Next(today)
+		event := UpcomingEvent{
+			ContactID:   contact.ID,
+			ContactName: contact.Name,
+			Type:        "birthday",
+			Title:       fmt.Sprintf("%s's Birthday", contact.Name),
+			Date:        next.String(),
+			DaysUntil:   today.DaysUntil(next),
+		}
+		if age, ok := contact.Birthday.AgeOn(next); ok {
+			event.Age = age
+		}
+		events = append(events, event)
+	}
+
+	customEvents, err := t.repo.GetUpcomingEvents(t.userID, days)
+	if err != nil {
+		return nil, UpcomingEventList{}, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	names := map[uint]string{}
+	for _, event := range customEvents {
+		next := entity.NextOccurrence(event.EventDate, event.Recurrence, today)
+		daysUntil := today.DaysUntil(next)
+		if daysUntil < 0 || daysUntil > days {
+			continue
+		}
+
+		name, ok := names[event.ContactID]
+		if !ok {
+			contact, err := t.repo.GetContact(fmt.Sprintf("%d", event.ContactID), t.userID)
+			if err != nil {
+				continue
+			}
+			name = contact.Name
+			names[event.ContactID] = name
+		}
+
+		events = append(events, UpcomingEvent{
+			ContactID:   event.ContactID,
+			ContactName: name,
+			Type:        "custom",
+			Title:       event.Title,
+			Date:        next.String(),
+			DaysUntil:   daysUntil,
+		})
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].DaysUntil < events[j].DaysUntil
+	})
+
+	return nil, UpcomingEventList{Events: events}, nil
+}
+
+func (t *tools) listOverdueContacts(ctx context.Context, req *mcp.CallToolRequest, in ListOverdueContactsInput) (*mcp.CallToolResult, OverdueContactList, error) {
+	contacts, err := t.repo.GetContactsWithCadence(t.userID)
+	if err != nil {
+		return nil, OverdueContactList{}, fmt.Errorf("failed to list contacts: %w", err)
+	}
+
+	today := entity.Today()
+	overdue := []OverdueContact{}
+	for _, contact := range contacts {
+		due := contact.NextDue()
+		if due.IsZero() || today.Before(due) {
+			continue
+		}
+		cadence, _ := contact.Cadence()
+		overdue = append(overdue, OverdueContact{
+			ContactID:     contact.ID,
+			ContactName:   contact.Name,
+			LastContacted: contact.LastContacted.String(),
+			Cadence:       entity.CadenceLabel(cadence),
+			NextDue:       due.String(),
+			DaysOverdue:   due.DaysUntil(today),
+		})
+	}
+
+	sort.SliceStable(overdue, func(i, j int) bool {
+		return overdue[i].DaysOverdue > overdue[j].DaysOverdue
+	})
+	return nil, OverdueContactList{Contacts: overdue}, nil
+}
+
+func (t *tools) listDriftingContacts(ctx context.Context, req *mcp.CallToolRequest, in ListDriftingContactsInput) (*mcp.CallToolResult, DriftingContactList, error) {
+	contacts, err := t.repo.GetDriftingContacts(t.userID, in.Limit)
+	if err != nil {
+		return nil, DriftingContactList{}, fmt.Errorf("failed to list contacts: %w", err)
+	}
+
+	drifting := []DriftingContact{}
+	for _, contact := range contacts {
+		drifting = append(drifting, DriftingContact{
+			ContactID:     contact.ID,
+			ContactName:   contact.Name,
+			StrengthScore: contact.StrengthScore,
+			LastContacted: contact.LastContacted.String(),
+			DriftingSince: contact.DriftingSince.String(),
+		})
+	}
+	return nil, DriftingContactList{Contacts: drifting}, nil
+}
+
+func (t *tools) listTasks(ctx context.Context, req *mcp.CallToolRequest, in ListTasksInput) (*mcp.CallToolResult, TaskList, error) {
+	filters := repository.TaskFilters{Status: entity.TaskOpen, ContactID: in.ContactID}
+	if in.Status != "" {
+		filters.Status = entity.TaskStatus(in.Status)
+		if !filters.Status.Valid() {
+			return nil, TaskList{}, fmt.Errorf("status must be one of open, done")
+		}
+	}
+	if in.DueWithinDays > 0 {
+		filters.DueBy = entity.Today().AddDays(in.DueWithinDays)
+	}
+
+	tasks, err := t.repo.GetTasks(t.userID, filters)
+	if err != nil {
+		return nil, TaskList{}, fmt.Errorf("failed to list tasks: %w", err)
+	}
+	res := make([]Task, 0, len(tasks))
+	for _, task := range tasks {
+		res = append(res, newTask(task))
+	}
+	return nil, TaskList{Tasks: res}, nil
+}
+
+func (t *tools) createTask(ctx context.Context, req *mcp.CallToolRequest, in CreateTaskInput) (*mcp.CallToolResult, Task, error) {
+	title := strings.TrimSpace(in.Title)
+	if title == "" {
+		return nil, Task{}, fmt.Errorf("title is required")
+	}
+	if len(in.ContactIDs) == 0 {
+		return nil, Task{}, fmt.Errorf("contact_ids must list at least one contact")
+	}
+	priority := entity.NormalPriority
+	if in.Priority != "" {
+		priority = entity.TaskPriority(in.Priority)
+		if !priority.Valid() {
+			return nil, Task{}, fmt.Errorf("priority must be one of high, normal, low")
+		}
+	}
+
+	task := &entity.Task{
+		UserID:   t.userID,
+		Title:    title,
+		Notes:    strings.TrimSpace(in.Notes),
+		Priority: priority,
+		Status:   entity.TaskOpen,
+	}
+	if in.DueOn != "" {
+		due, err := entity.ParseDate(in.DueOn)
+		if err != nil {
+			return nil, Task{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", in.DueOn)
+		}
+		task.DueOn = due
+	}
+
+	if err := t.repo.CreateTask(task, in.ContactIDs); err != nil {
+		return nil, Task{}, fmt.Errorf("failed to create task: %w", err)
+	}
+	return nil, newTask(*task), nil
+}
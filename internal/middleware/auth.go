@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"net/http"
+	"strings"
 
 	jwtutil "github.com/La002/personal-crm/pkg/jwt"
 	"github.com/labstack/echo/v4"
@@ -44,3 +45,49 @@ func AuthMiddleware(jwtSecret string) echo.MiddlewareFunc {
 		}
 	}
 }
+
+// APIAuthMiddleware validates JWT tokens for the JSON API. The token is read from
+// the "Authorization: Bearer" header, falling back to the session cookie, and
+// failures are reported as JSON instead of redirecting to the login page.
+func APIAuthMiddleware(jwtSecret string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token := ""
+			if header := c.Request().Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+				token = strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+			} else if cookie, err := c.Cookie("auth_token"); err == nil {
+				token = cookie.Value
+			}
+
+			if token == "" {
+				return unauthorized(c, "missing authentication token")
+			}
+
+			claims, err := jwtutil.ValidateToken(token, jwtSecret)
+			if err != nil {
+				return unauthorized(c, "invalid authentication token")
+			}
+
+			userID, err := jwtutil.ExtractUserID(claims)
+			if err != nil {
+				return unauthorized(c, "invalid authentication token")
+			}
+
+			email, _ := (*claims)["email"].(string)
+
+			c.Set("user_id", userID)
+			c.Set("user_email", email)
+
+			return next(c)
+		}
+	}
+}
+
+func unauthorized(c echo.Context, message string) error {
+	return c.JSON(http.StatusUnauthorized, map[string]interface{}{
+		"error": map[string]string{
+			"code":    "unauthorized",
+			"message": message,
+		},
+	})
+}
@@ -1,9 +1,10 @@
 package renderer
 
 import (
-	"github.com/labstack/echo/v4"
+	"html/template"
 	"io"
-	"text/template"
+
+	"github.com/labstack/echo/v4"
 )
 
 type Template struct {
@@ -14,8 +15,11 @@ func (t *Template) Render(w io.Writer, name string, data interface{}, c echo.Con
 	return t.Templates.ExecuteTemplate(w, name, data)
 }
 
+// NewTemplateRenderer parses the templates matching paths. They are
+// html/template templates, so every value is escaped for the context it is
+// printed in; markup built in Go must be passed as template.HTML.
 func NewTemplateRenderer(e *echo.Echo, paths ...string) {
-	tmpl := &template.Template{}
+	tmpl := template.New("")
 	for i := range paths {
 		template.Must(tmpl.ParseGlob(paths[i]))
 	}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/labstack/echo/v4"
+)
+
+// AttachmentResponse is the JSON representation of an attachment. URL
+// downloads the file; ThumbnailURL is set for images with a thumbnail.
+type AttachmentResponse struct {
+	ID           uint      `json:"id"`
+	ContactID    uint      `json:"contact_id"`
+	FileName     string    `json:"file_name"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func newAttachmentResponse(attachment entity.Attachment) AttachmentResponse {
+	res := AttachmentResponse{
+		ID:          attachment.ID,
+		ContactID:   attachment.ContactID,
+		FileName:    attachment.FileName,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		URL:         fmt.Sprintf("/api/v1/attachments/%d", attachment.ID),
+		CreatedAt:   attachment.CreatedAt,
+	}
+	if attachment.ThumbnailKey != "" {
+		res.ThumbnailURL = res.URL + "?thumbnail=true"
+	}
+	return res
+}
+
+// PhotoRequest is the body accepted to choose a contact's photo
+type PhotoRequest struct {
+	AttachmentID uint `json:"attachment_id"`
+}
+
+// apiAttachmentError maps upload errors onto API responses
+func apiAttachmentError(c echo.Context, err error) error {
+	var uploadErr attachmentError
+	if !errors.As(err, &uploadErr) {
+		return apiRepoError(c, err, "contact")
+	}
+	switch {
+	case errors.Is(err, errAttachmentTooLarge):
+		return apiError(c, http.StatusRequestEntityTooLarge, "file_too_large", uploadErr.Error())
+	case errors.Is(err, errAttachmentType):
+		return apiError(c, http.StatusUnsupportedMediaType, "unsupported_media_type", uploadErr.Error())
+	}
+	return apiError(c, http.StatusUnprocessableEntity, "validation_failed", uploadErr.Error())
+}
+
+func (h *APIHandler) ListContactAttachments(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	attachments, err := h.Repo.GetAttachmentsByContact(id, userID)
+	if err != nil {
+		return apiRepoError(c, err, "attachments")
+	}
+	res := make([]AttachmentResponse, 0, len(attachments))
+	for _, attachment := range attachments {
+		res = append(res, newAttachmentResponse(attachment))
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"attachments": res})
+}
+
+// CreateAttachment uploads the multipart "file" field as an attachment of the
+// contact; with photo=true it also becomes the contact's photo
+func (h *APIHandler) CreateAttachment(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "send the file as the multipart form field \"file\"")
+	}
+	asPhoto := false
+	if photo := c.FormValue("photo"); photo != "" {
+		if asPhoto, err = strconv.ParseBool(photo); err != nil {
+			return apiError(c, http.StatusBadRequest, "invalid_parameter", "photo must be true or false")
+		}
+	}
+
+	attachment, err := h.AttachmentService.Store(c.Request().Context(), userID, id, fileHeader, asPhoto)
+	if err != nil {
+		return apiAttachmentError(c, err)
+	}
+	return c.JSON(http.StatusCreated, newAttachmentResponse(attachment))
+}
+
+// DownloadAttachment sends the file of an attachment, or its thumbnail with
+// thumbnail=true
+func (h *APIHandler) DownloadAttachment(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	attachmentID, err := parseIDParam(c, "attachmentId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	attachment, err := h.Repo.GetAttachmentByID(attachmentID, userID)
+	if err != nil {
+		return apiRepoError(c, err, "attachment")
+	}
+	key, contentType := attachment.StorageKey, attachment.ContentType
+	if thumbnail, _ := strconv.ParseBool(c.QueryParam("thumbnail")); thumbnail {
+		if attachment.ThumbnailKey == "" {
+			return apiError(c, http.StatusNotFound, "not_found", "attachment has no thumbnail")
+		}
+		key, contentType = attachment.ThumbnailKey, "image/jpeg"
+	}
+
+	if err := h.AttachmentService.serve(c, key, contentType, attachment.FileName); err != nil {
+		c.Logger().Error("API attachment read failed: ", err)
+		return apiError(c, http.StatusNotFound, "not_found", "file not found")
+	}
+	return nil
+}
+
+// DeleteAttachment removes an attachment and its file. When it was the
+// contact's photo, the contact no longer has one.
+func (h *APIHandler) DeleteAttachment(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	attachmentID, err := parseIDParam(c, "attachmentId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	attachment, err := h.Repo.GetAttachmentByID(attachmentID, userID)
+	if err != nil {
+		return apiRepoError(c, err, "attachment")
+	}
+	if err := h.AttachmentService.Remove(c.Request().Context(), attachment); err != nil {
+		return apiRepoError(c, err, "attachment")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetContactPhoto sends the avatar thumbnail of a contact
+func (h *APIHandler) GetContactPhoto(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	photo, err := h.Repo.GetContactPhoto(id, userID)
+	if err != nil {
+		return apiRepoError(c, err, "photo")
+	}
+	if err := h.AttachmentService.serve(c, photo.ThumbnailKey, "image/jpeg", photo.FileName); err != nil {
+		c.Logger().Error("API photo read failed: ", err)
+		return apiError(c, http.StatusNotFound, "not_found", "photo not found")
+	}
+	return nil
+}
+
+// SetContactPhoto makes one of the contact's image attachments its photo
+func (h *APIHandler) SetContactPhoto(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	var req PhotoRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+	attachment, err := h.Repo.GetAttachmentByID(req.AttachmentID, userID)
+	if err != nil || attachment.ContactID != id {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", "attachment_id must be an attachment of the contact")
+	}
+	if attachment.ThumbnailKey == "" {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", errNotAPhoto.Error())
+	}
+
+	if err := h.Repo.SetContactPhoto(id, userID, &attachment.ID); err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// DeleteContactPhoto removes the photo of a contact, the attachment is kept
+func (h *APIHandler) DeleteContactPhoto(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	if err := h.Repo.SetContactPhoto(id, userID, nil); err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// BulkContacts applies one action to several contacts and returns a result
+// for every contact. Database actions are all or nothing; contacts that do not
+// exist, and contacts whose birthday could not be synced, are reported as
+// failed without affecting the others.
+func (h *APIHandler) BulkContacts(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var req BulkRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+	if err := req.validate(h.Repo, userID); err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+
+	summary, err := runBulkAction(h.Repo, h.CalendarService, userID, req, actorFromContext(c))
+	if err != nil {
+		return apiRepoError(c, err, "contacts")
+	}
+	return c.JSON(http.StatusOK, summary)
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/labstack/echo/v4"
+)
+
+// SnoozeRequest is the body of the snooze endpoints. Until takes precedence
+// over Days; without either the contact or task is snoozed for a week.
+type SnoozeRequest struct {
+	Days  int    `json:"days"`
+	Until string `json:"until"`
+}
+
+// until returns the date the snooze ends
+func (r SnoozeRequest) until() (entity.Date, error) {
+	if r.Until == "" {
+		days := ""
+		if r.Days != 0 {
+			days = strconv.Itoa(r.Days)
+		}
+		return snoozeUntil(days)
+	}
+	until, err := entity.ParseDate(r.Until)
+	if err != nil {
+		return entity.Date{}, fmt.Errorf("until: %w", err)
+	}
+	if !entity.Today().Before(until) {
+		return entity.Date{}, fmt.Errorf("until must be in the future")
+	}
+	return until, nil
+}
+
+// CompleteRequest is the body of the done endpoint
+type CompleteRequest struct {
+	Type  string `json:"type"` // interaction type, message by default
+	Notes string `json:"notes"`
+}
+
+// ListKeepInTouch returns the contacts due to be contacted, the longest overdue first
+func (h *APIHandler) ListKeepInTouch(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	queue, err := keepInTouchQueue(h.Repo, userID)
+	if err != nil {
+		return apiRepoError(c, err, "contacts")
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"contacts": queue})
+}
+
+// ListDrifting returns the contacts whose relationship is drifting away, the
+// weakest first
+func (h *APIHandler) ListDrifting(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	drifting, err := driftingContacts(h.Repo, userID, 0)
+	if err != nil {
+		return apiRepoError(c, err, "contacts")
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"contacts": drifting})
+}
+
+// SnoozeKeepInTouch postpones the next due date of a contact. The snooze ends
+// early when an interaction is logged.
+func (h *APIHandler) SnoozeKeepInTouch(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	var req SnoozeRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+
+	until, err := req.until()
+	if err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+
+	if err := h.Repo.SnoozeContact(id, userID, until); err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+	contact, err := h.Repo.GetContact(fmt.Sprintf("%d", id), userID)
+	if err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+	return c.JSON(http.StatusOK, newContactResponse(contact))
+}
+
+// CompleteKeepInTouch marks a contact as done by logging an interaction today
+// and returns the contact with its new next due date
+func (h *APIHandler) CompleteKeepInTouch(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	var req CompleteRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+
+	interaction, err := keepInTouchInteraction(userID, req.Type, req.Notes)
+	if err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+	if err := h.Repo.CreateInteraction(interaction, []uint{id}); err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+
+	contact, err := h.Repo.GetContact(fmt.Sprintf("%d", id), userID)
+	if err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+	return c.JSON(http.StatusOK, newContactResponse(contact))
+}
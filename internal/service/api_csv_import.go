@@ -0,0 +1,115 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// CSVImportRequest is the body of a CSV import. Mapping maps column headers (or
+// "Column N" for files without a header row) to contact fields, "tags" or
+// "cf.<key>"; when omitted the mapping is detected from the headers.
+type CSVImportRequest struct {
+	CSV            string            `json:"csv"`
+	Mapping        map[string]string `json:"mapping"`
+	SkipDuplicates *bool             `json:"skip_duplicates"`
+	DryRun         bool              `json:"dry_run"`
+}
+
+// CSVImportResponse summarizes a CSV import or dry run
+type CSVImportResponse struct {
+	DryRun      bool              `json:"dry_run"`
+	Total       int               `json:"total"`
+	Created     int               `json:"created"`
+	Invalid     int               `json:"invalid"`
+	Skipped     int               `json:"skipped"`
+	Mapping     map[string]string `json:"mapping"`
+	Rows        []CSVRowResponse  `json:"rows"`
+	ErrorReport string            `json:"error_report,omitempty"`
+}
+
+// CSVRowResponse is a row that has errors or was skipped
+type CSVRowResponse struct {
+	Line    int      `json:"line"`
+	Name    string   `json:"name"`
+	Errors  []string `json:"errors,omitempty"`
+	Skipped string   `json:"skipped,omitempty"`
+}
+
+// ImportCSV validates every row of a CSV file and imports the valid ones in a
+// single transaction. With dry_run nothing is saved.
+func (h *APIHandler) ImportCSV(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var req CSVImportRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+	if len(req.CSV) > maxImportSize {
+		return apiError(c, http.StatusRequestEntityTooLarge, "too_large", fmt.Sprintf("the file is larger than %d MB", maxImportSize>>20))
+	}
+
+	defs, err := h.Repo.GetCustomFieldDefinitions(userID)
+	if err != nil {
+		return apiRepoError(c, err, "custom fields")
+	}
+	table, err := parseCSV([]byte(req.CSV), defs)
+	if err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+
+	mapping := make([]string, len(table.Headers))
+	used := map[string]string{}
+	for i, header := range table.Headers {
+		if req.Mapping != nil {
+			mapping[i] = req.Mapping[header]
+		} else if table.HasHeader {
+			mapping[i] = detectCSVTarget(header, defs)
+		}
+		if mapping[i] != "" {
+			used[header] = mapping[i]
+		}
+	}
+	for header := range req.Mapping {
+		if _, ok := used[header]; !ok && req.Mapping[header] != "" {
+			return apiError(c, http.StatusUnprocessableEntity, "validation_failed", fmt.Sprintf("the file has no column %q", header))
+		}
+	}
+	if err := validCSVMapping(mapping, defs); err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+
+	skipDuplicates := req.SkipDuplicates == nil || *req.SkipDuplicates
+	rows, err := buildCSVRows(h.Repo, userID, table, mapping, defs, skipDuplicates)
+	if err != nil {
+		return apiRepoError(c, err, "contacts")
+	}
+
+	summary := summarizeCSVRows(rows)
+	res := CSVImportResponse{
+		DryRun:  req.DryRun,
+		Total:   summary.Total,
+		Invalid: summary.Invalid,
+		Skipped: summary.Skipped,
+		Mapping: used,
+		Rows:    []CSVRowResponse{},
+	}
+	for _, row := range rows {
+		if len(row.Errors) > 0 || row.Skipped != "" {
+			res.Rows = append(res.Rows, CSVRowResponse{Line: row.Line, Name: row.Import.Contact.Name, Errors: row.Errors, Skipped: row.Skipped})
+		}
+	}
+	if len(res.Rows) > 0 {
+		res.ErrorReport = string(csvErrorReport(table, rows))
+	}
+
+	imports := importableCSVRows(rows)
+	if !req.DryRun {
+		if err := h.Repo.ImportContacts(userID, imports); err != nil {
+			return apiRepoError(c, err, "contacts")
+		}
+	}
+	res.Created = len(imports)
+	return c.JSON(http.StatusOK, res)
+}
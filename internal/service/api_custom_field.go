@@ -0,0 +1,136 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/labstack/echo/v4"
+)
+
+// CustomFieldResponse is the JSON representation of a custom field definition
+type CustomFieldResponse struct {
+	ID       uint     `json:"id"`
+	Key      string   `json:"key"`
+	Label    string   `json:"label"`
+	Type     string   `json:"type"`
+	Options  []string `json:"options"`
+	Position int      `json:"position"`
+}
+
+func newCustomFieldResponse(def entity.CustomFieldDefinition) CustomFieldResponse {
+	options := []string(def.Options)
+	if options == nil {
+		options = []string{}
+	}
+	return CustomFieldResponse{
+		ID:       def.ID,
+		Key:      def.Key,
+		Label:    def.Label,
+		Type:     string(def.Type),
+		Options:  options,
+		Position: def.Position,
+	}
+}
+
+// CustomFieldRequest is the body accepted when creating or updating a custom
+// field. The type can only be set on creation.
+type CustomFieldRequest struct {
+	Label    string   `json:"label"`
+	Type     string   `json:"type"`
+	Options  []string `json:"options"`
+	Position *int     `json:"position"`
+}
+
+func (h *APIHandler) ListCustomFields(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	defs, err := h.Repo.GetCustomFieldDefinitions(userID)
+	if err != nil {
+		return apiRepoError(c, err, "custom fields")
+	}
+
+	res := make([]CustomFieldResponse, 0, len(defs))
+	for _, def := range defs {
+		res = append(res, newCustomFieldResponse(def))
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"custom_fields": res})
+}
+
+func (h *APIHandler) CreateCustomField(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var req CustomFieldRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+
+	def, err := newCustomFieldDefinition(userID, req.Label, req.Type, req.Options)
+	if err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+	if exists, err := customFieldKeyExists(h.Repo, userID, def.Key); err != nil {
+		return apiRepoError(c, err, "custom field")
+	} else if exists {
+		return apiError(c, http.StatusConflict, "already_exists", "a custom field with the key "+def.Key+" already exists")
+	}
+
+	if err := h.Repo.CreateCustomFieldDefinition(def); err != nil {
+		return apiRepoError(c, err, "custom field")
+	}
+	return c.JSON(http.StatusCreated, newCustomFieldResponse(*def))
+}
+
+// UpdateCustomField changes the label, options or position of a field. The key
+// stays the same so stored values keep matching.
+func (h *APIHandler) UpdateCustomField(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	fieldID, err := parseIDParam(c, "fieldId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	def, err := h.Repo.GetCustomFieldDefinitionByID(fieldID, userID)
+	if err != nil {
+		return apiRepoError(c, err, "custom field")
+	}
+
+	var req CustomFieldRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+	if req.Type != "" && entity.CustomFieldType(req.Type) != def.Type {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", "the type of a custom field cannot be changed")
+	}
+
+	if label := strings.TrimSpace(req.Label); label != "" {
+		def.Label = label
+	}
+	if req.Options != nil {
+		if err := setCustomFieldOptions(&def, req.Options); err != nil {
+			return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		}
+	}
+	if req.Position != nil {
+		def.Position = *req.Position
+	}
+
+	if err := h.Repo.UpdateCustomFieldDefinition(&def); err != nil {
+		return apiRepoError(c, err, "custom field")
+	}
+	return c.JSON(http.StatusOK, newCustomFieldResponse(def))
+}
+
+// DeleteCustomField deletes a field and its value on every contact
+func (h *APIHandler) DeleteCustomField(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	fieldID, err := parseIDParam(c, "fieldId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	if err := h.Repo.DeleteCustomFieldDefinition(fieldID, userID); err != nil {
+		return apiRepoError(c, err, "custom field")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
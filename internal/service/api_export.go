@@ -0,0 +1,15 @@
+package service
+
+import (
+	"github.com/labstack/echo/v4"
+)
+
+// ExportAccount returns all data of the user as a zip archive, see package archive
+func (h *APIHandler) ExportAccount(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	if err := writeAccountArchive(c, h.Repo, userID); err != nil {
+		return apiRepoError(c, err, "account")
+	}
+	return nil
+}
@@ -0,0 +1,934 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// APIHandler serves the versioned JSON API under /api/v1
+type APIHandler struct {
+	Repo              repository.ContactDao
+	CalendarService   *CalendarService
+	DashboardService  *DashboardService
+	AttachmentService *AttachmentService
+	TrashService      *TrashService
+}
+
+// NewAPIHandler creates a new API handler instance
+func NewAPIHandler(repo repository.ContactDao, calendarService *CalendarService, dashboardService *DashboardService, attachmentService *AttachmentService, trashService *TrashService) *APIHandler {
+	return &APIHandler{
+		Repo:              repo,
+		CalendarService:   calendarService,
+		DashboardService:  dashboardService,
+		AttachmentService: attachmentService,
+		TrashService:      trashService,
+	}
+}
+
+// APIError is the body returned for every failed API request
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func apiError(c echo.Context, status int, code, message string) error {
+	return c.JSON(status, map[string]APIError{
+		"error": {Code: code, Message: message},
+	})
+}
+
+// apiRepoError maps repository errors onto API responses
+func apiRepoError(c echo.Context, err error, what string) error {
+	if isNotFoundError(err) {
+		return apiError(c, http.StatusNotFound, "not_found", what+" not found")
+	}
+	c.Logger().Error("API request failed: ", err)
+	return apiError(c, http.StatusInternalServerError, "internal_error", "internal server error")
+}
+
+// isNotFoundError reports whether err means the requested record does not exist.
+// Repository methods return "no <record> found ..." errors when nothing was affected.
+func isNotFoundError(err error) bool {
+	msg := err.Error()
+	return errors.Is(err, gorm.ErrRecordNotFound) || (strings.HasPrefix(msg, "no ") && strings.Contains(msg, " found"))
+}
+
+// apiCalendarError maps calendar service errors onto API responses
+func apiCalendarError(c echo.Context, err error) error {
+	if isCalendarAuthError(err) {
+		return apiError(c, http.StatusUnauthorized, "calendar_auth_expired", "Google authentication expired, please login again")
+	}
+	c.Logger().Error("API calendar request failed: ", err)
+	return apiError(c, http.StatusBadGateway, "calendar_error", "failed to reach Google Calendar")
+}
+
+// ContactResponse is the JSON representation of a contact
+type ContactResponse struct {
+	ID                    uint                `json:"id"`
+	Name                  string              `json:"name"`
+	Relationship          string              `json:"relationship"`
+	Industry              string              `json:"industry"`
+	Company               string              `json:"company"`
+	Birthday              string              `json:"birthday"`
+	Vip                   bool                `json:"vip"`
+	Spouse                string              `json:"spouse"`
+	Children              string              `json:"children"`
+	Location              string              `json:"location"`
+	PhoneNumber           string              `json:"phone_number"`
+	Email                 string              `json:"email"`
+	Emails                []ChannelValue      `json:"emails"`
+	Phones                []ChannelValue      `json:"phones"`
+	Addresses             []AddressValue      `json:"addresses"`
+	LinkedIn              string              `json:"linked_in"`
+	Instagram             string              `json:"instagram"`
+	X                     string              `json:"x"`
+	Notes                 []NoteResponse      `json:"notes"`
+	PhotoID               *uint               `json:"photo_id"`
+	PhotoURL              string              `json:"photo_url,omitempty"`
+	LastMet               string              `json:"last_met"`
+	LastContacted         string              `json:"last_contacted"`
+	LastUpdate            string              `json:"last_update"`
+	CadenceDays           int                 `json:"cadence_days"`
+	SnoozedUntil          string              `json:"snoozed_until"`
+	NextDue               string              `json:"next_due"`       // from the effective cadence, "" without one
+	StrengthScore         int                 `json:"strength_score"` // relationship strength from 0 to 100
+	DriftingSince         string              `json:"drifting_since"` // "" unless the relationship is fading
+	CalendarSyncEnabled   bool                `json:"calendar_sync_enabled"`
+	GoogleCalendarEventID string              `json:"google_calendar_event_id"`
+	Tags                  []TagResponse       `json:"tags"`
+	CustomFields          entity.CustomFields `json:"custom_fields"`
+	CreatedAt             time.Time           `json:"created_at"`
+	UpdatedAt             time.Time           `json:"updated_at"`
+}
+
+// contactPhotoURL is where the API serves the contact's photo, "" without one
+func contactPhotoURL(contact entity.Contact) string {
+	if contact.PhotoID == nil {
+		return ""
+	}
+	return fmt.Sprintf("/api/v1/contacts/%d/photo", contact.ID)
+}
+
+func newContactResponse(contact entity.Contact) ContactResponse {
+	return ContactResponse{
+		ID:                    contact.ID,
+		Name:                  contact.Name,
+		Relationship:          string(contact.Relationship),
+		Industry:              contact.Industry,
+		Company:               contact.Company,
+		Birthday:              contact.Birthday.String(),
+		Vip:                   contact.Vip,
+		Spouse:                contact.Spouse,
+		Children:              contact.Children,
+		Location:              contact.Location,
+		PhoneNumber:           contact.PhoneNumber,
+		Email:                 contact.Email,
+		Emails:                newChannelValues(contact, entity.EmailChannel),
+		Phones:                newChannelValues(contact, entity.PhoneChannel),
+		Addresses:             newAddressValues(contact.Addresses),
+		LinkedIn:              contact.LinkedIn,
+		Instagram:             contact.Instagram,
+		X:                     contact.X,
+		Notes:                 newNoteResponses(contact.Notes),
+		PhotoID:               contact.PhotoID,
+		PhotoURL:              contactPhotoURL(contact),
+		LastMet:               contact.LastMet.String(),
+		LastContacted:         contact.LastContacted.String(),
+		LastUpdate:            contact.LastUpdate.String(),
+		CadenceDays:           contact.CadenceDays,
+		SnoozedUntil:          contact.SnoozedUntil.String(),
+		NextDue:               contact.NextDue().String(),
+		StrengthScore:         contact.StrengthScore,
+		DriftingSince:         contact.DriftingSince.String(),
+		CalendarSyncEnabled:   contact.CalendarSyncEnabled,
+		GoogleCalendarEventID: contact.GoogleCalendarEventID,
+		Tags:                  newTagResponses(contact.Tags),
+		CustomFields:          contact.CustomFields,
+		CreatedAt:             contact.CreatedAt,
+		UpdatedAt:             contact.UpdatedAt,
+	}
+}
+
+// ChannelValue is an email address or phone number in requests and responses
+type ChannelValue struct {
+	Value   string `json:"value"`
+	Label   string `json:"label"`
+	Primary bool   `json:"primary"`
+}
+
+// AddressValue is a postal address in requests and responses
+type AddressValue struct {
+	Label      string `json:"label"`
+	Street     string `json:"street"`
+	City       string `json:"city"`
+	Region     string `json:"region"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+	Primary    bool   `json:"primary"`
+}
+
+func newChannelValues(contact entity.Contact, kind entity.ChannelKind) []ChannelValue {
+	res := []ChannelValue{}
+	for _, channel := range contact.ChannelsOf(kind) {
+		res = append(res, ChannelValue{Value: channel.Value, Label: channel.Label, Primary: channel.Primary})
+	}
+	return res
+}
+
+func newAddressValues(addresses []entity.ContactAddress) []AddressValue {
+	res := []AddressValue{}
+	for _, a := range addresses {
+		res = append(res, AddressValue{Label: a.Label, Street: a.Street, City: a.City, Region: a.Region,
+			PostalCode: a.PostalCode, Country: a.Country, Primary: a.Primary})
+	}
+	return res
+}
+
+// ContactSearchResponse is a contact returned by a free-text search
+type ContactSearchResponse struct {
+	ContactResponse
+	Rank float64 `json:"rank"`
+	// SnippetHTML is the escaped matching excerpt with <mark> around matched words
+	SnippetHTML string `json:"snippet_html,omitempty"`
+}
+
+// ContactRequest is the body accepted when creating or updating a contact.
+// Fields left out of an update request keep their current value.
+type ContactRequest struct {
+	Name         *string `json:"name"`
+	Relationship *string `json:"relationship"`
+	Industry     *string `json:"industry"`
+	Company      *string `json:"company"`
+	Birthday     *string `json:"birthday"`
+	Vip          *bool   `json:"vip"`
+	Spouse       *string `json:"spouse"`
+	Children     *string `json:"children"`
+	Location     *string `json:"location"`
+	PhoneNumber  *string `json:"phone_number"`
+	Email        *string `json:"email"`
+	LinkedIn     *string `json:"linked_in"`
+	Instagram    *string `json:"instagram"`
+	X            *string `json:"x"`
+	// Notes is the first note of a new contact. The notes of an existing
+	// contact are managed with the notes endpoints.
+	Notes      *string `json:"notes"`
+	LastUpdate *string `json:"last_update"`
+	// CadenceDays is the keep-in-touch cadence in days, 0 to use the tags'
+	CadenceDays *int `json:"cadence_days"`
+	// Emails, Phones and Addresses replace all values of their kind. The first
+	// value is primary unless another one is marked. When a list is given the
+	// matching email, phone_number or location field is ignored.
+	Emails    []ChannelValue `json:"emails"`
+	Phones    []ChannelValue `json:"phones"`
+	Addresses []AddressValue `json:"addresses"`
+	// CustomFields sets custom field values by key, an empty value clears the field
+	CustomFields map[string]string `json:"custom_fields"`
+}
+
+// apply copies the fields present in the request onto contact. It fails when
+// a date is not in YYYY-MM-DD format, or --MM-DD for a birthday without year.
+func (r ContactRequest) apply(contact *entity.Contact) error {
+	setString := func(dst *string, src *string) {
+		if src != nil {
+			*dst = strings.TrimSpace(*src)
+		}
+	}
+	setDate := func(dst *entity.Date, src *string, parse func(string) (entity.Date, error), field string) error {
+		if src == nil {
+			return nil
+		}
+		date, err := parse(strings.TrimSpace(*src))
+		if err != nil {
+			return fmt.Errorf("%s: %w", field, err)
+		}
+		*dst = date
+		return nil
+	}
+
+	setString(&contact.Name, r.Name)
+	setString(&contact.Industry, r.Industry)
+	setString(&contact.Company, r.Company)
+	if err := setDate(&contact.Birthday, r.Birthday, entity.ParseBirthday, "birthday"); err != nil {
+		return err
+	}
+	if r.Vip != nil {
+		contact.Vip = *r.Vip
+	}
+	setString(&contact.Spouse, r.Spouse)
+	setString(&contact.Children, r.Children)
+	setString(&contact.Location, r.Location)
+	setString(&contact.PhoneNumber, r.PhoneNumber)
+	setString(&contact.Email, r.Email)
+	setString(&contact.LinkedIn, r.LinkedIn)
+	setString(&contact.Instagram, r.Instagram)
+	setString(&contact.X, r.X)
+	if r.Notes != nil {
+		if contact.ID != 0 {
+			return fmt.Errorf("notes: add, edit and delete notes with the notes endpoints")
+		}
+		if body := strings.TrimSpace(*r.Notes); body != "" {
+			contact.Notes = []entity.Note{{Body: body}}
+		}
+	}
+	if r.CadenceDays != nil {
+		if err := entity.ValidCadence(*r.CadenceDays); err != nil {
+			return fmt.Errorf("cadence_days: %w", err)
+		}
+		contact.CadenceDays = *r.CadenceDays
+	}
+	return setDate(&contact.LastUpdate, r.LastUpdate, entity.ParseDate, "last_update")
+}
+
+// hasChannels reports whether the request replaces any list of emails, phone
+// numbers or addresses
+func (r ContactRequest) hasChannels() bool {
+	return r.Emails != nil || r.Phones != nil || r.Addresses != nil
+}
+
+// applyChannels replaces the contact's lists given in the request. The lists
+// left out keep their values, with the single email, phone_number and location
+// fields of the request, already copied by apply, as their primary value.
+func (r ContactRequest) applyChannels(contact *entity.Contact) {
+	var channels []entity.ContactChannel
+	for _, kind := range []entity.ChannelKind{entity.EmailChannel, entity.PhoneChannel} {
+		values, single := r.Emails, contact.Email
+		if kind == entity.PhoneChannel {
+			values, single = r.Phones, contact.PhoneNumber
+		}
+		if values != nil {
+			for _, value := range values {
+				channels = append(channels, entity.ContactChannel{Kind: kind, Value: value.Value, Label: value.Label, Primary: value.Primary})
+			}
+			continue
+		}
+		channels = append(channels, withPrimaryChannel(contact.ChannelsOf(kind), kind, single)...)
+	}
+	contact.Channels = channels
+
+	if r.Addresses == nil {
+		if contact.Location != "" && (len(contact.Addresses) == 0 || contact.Addresses[0].Summary() != contact.Location) {
+			contact.Addresses = append([]entity.ContactAddress{{City: contact.Location, Primary: true}}, contact.Addresses...)
+		}
+		return
+	}
+	contact.Addresses = []entity.ContactAddress{}
+	for _, a := range r.Addresses {
+		contact.Addresses = append(contact.Addresses, entity.ContactAddress{Label: a.Label, Street: a.Street, City: a.City,
+			Region: a.Region, PostalCode: a.PostalCode, Country: a.Country, Primary: a.Primary})
+	}
+}
+
+// withPrimaryChannel makes value the primary one of channels, replacing the
+// current primary value. An empty value removes the primary value.
+func withPrimaryChannel(channels []entity.ContactChannel, kind entity.ChannelKind, value string) []entity.ContactChannel {
+	res := []entity.ContactChannel{}
+	if value != "" {
+		res = append(res, entity.ContactChannel{Kind: kind, Value: value, Primary: true})
+	}
+	for _, channel := range channels {
+		if !channel.Primary {
+			res = append(res, channel)
+		}
+	}
+	return res
+}
+
+// validateContact checks the fields the database and templates rely on
+func validateContact(contact entity.Contact) error {
+	if contact.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if contact.Birthday.HasYear() && entity.Today().Before(contact.Birthday) {
+		return fmt.Errorf("birthday must not be in the future")
+	}
+	return nil
+}
+
+// EventResponse is the JSON representation of a custom event
+type EventResponse struct {
+	ID                    uint      `json:"id"`
+	ContactID             uint      `json:"contact_id"`
+	Title                 string    `json:"title"`
+	EventDate             string    `json:"event_date"`
+	Recurrence            string    `json:"recurrence"`
+	GoogleCalendarEventID string    `json:"google_calendar_event_id"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+func newEventResponse(event entity.Event) EventResponse {
+	return EventResponse{
+		ID:                    event.ID,
+		ContactID:             event.ContactID,
+		Title:                 event.Title,
+		EventDate:             event.EventDate.String(),
+		Recurrence:            event.Recurrence,
+		GoogleCalendarEventID: event.GoogleCalendarEventID,
+		CreatedAt:             event.CreatedAt,
+		UpdatedAt:             event.UpdatedAt,
+	}
+}
+
+// EventRequest is the body accepted when creating or updating a custom event
+type EventRequest struct {
+	Title      string `json:"title"`
+	EventDate  string `json:"event_date"`
+	Recurrence string `json:"recurrence"`
+
+	date entity.Date // EventDate parsed by validate
+}
+
+func (r *EventRequest) validate() error {
+	r.Title = strings.TrimSpace(r.Title)
+	if r.Title == "" || r.EventDate == "" {
+		return fmt.Errorf("title and event_date are required")
+	}
+	date, err := entity.ParseDate(r.EventDate)
+	if err != nil {
+		return fmt.Errorf("event_date must be a date in YYYY-MM-DD format")
+	}
+	r.date = date
+
+	// Default to "none" if not specified
+	if r.Recurrence == "" {
+		r.Recurrence = "none"
+	}
+	if r.Recurrence != "none" && r.Recurrence != "monthly" && r.Recurrence != "yearly" {
+		return fmt.Errorf("recurrence must be one of none, monthly, yearly")
+	}
+	return nil
+}
+
+// applyCustomFields validates the custom field values of a request against the
+// user's field definitions and merges them into the contact
+func (h *APIHandler) applyCustomFields(contact *entity.Contact, values map[string]string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	defs, err := h.Repo.GetCustomFieldDefinitions(contact.UserID)
+	if err != nil {
+		return err
+	}
+	contact.CustomFields, err = entity.ApplyCustomFields(defs, contact.CustomFields, values)
+	return err
+}
+
+// applyRelationship sets the relationship of the contact to one of the user's
+// relationship types when the request has one
+func (h *APIHandler) applyRelationship(contact *entity.Contact, name *string) error {
+	if name == nil {
+		return nil
+	}
+	relationship, err := resolveRelationship(h.Repo, contact.UserID, *name)
+	if err != nil {
+		return err
+	}
+	contact.Relationship = relationship
+	return nil
+}
+
+// apiActor returns the name recorded as the author of changes made through the API
+func apiActor(c echo.Context) string {
+	return actorFromContext(c) + " (API)"
+}
+
+func parseIDParam(c echo.Context, name string) (uint, error) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid %s", name)
+	}
+	return uint(id), nil
+}
+
+// invalidParameterError is a problem with a query parameter, reported as a 400
+type invalidParameterError string
+
+func (e invalidParameterError) Error() string {
+	return string(e)
+}
+
+// apiParamError reports invalid parameters as 400 and anything else like apiRepoError
+func apiParamError(c echo.Context, err error, what string) error {
+	var paramErr invalidParameterError
+	if errors.As(err, &paramErr) {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", paramErr.Error())
+	}
+	return apiRepoError(c, err, what)
+}
+
+// contactFilters reads the smart_list, relationship, vip, location, tag
+// (repeatable), cf.<key> custom field and limit query parameters. The other
+// parameters narrow down the contacts of the smart list.
+func (h *APIHandler) contactFilters(c echo.Context, userID uint) (repository.ContactSearchFilters, error) {
+	filters := repository.ContactSearchFilters{}
+	if id := c.QueryParam("smart_list"); id != "" {
+		var listID uint
+		if _, err := fmt.Sscan(id, &listID); err != nil {
+			return filters, invalidParameterError("smart_list must be a smart list ID")
+		}
+		listFilters, err := smartListContactFilters(h.Repo, userID, listID)
+		if err != nil {
+			if isNotFoundError(err) {
+				return filters, invalidParameterError(fmt.Sprintf("no smart list found with id %d", listID))
+			}
+			return filters, err
+		}
+		filters = listFilters
+	}
+	if relationship := c.QueryParam("relationship"); relationship != "" {
+		filters.Relationship = &relationship
+	}
+	if location := c.QueryParam("location"); location != "" {
+		filters.Location = &location
+	}
+	if vip := c.QueryParam("vip"); vip != "" {
+		vipOnly, err := strconv.ParseBool(vip)
+		if err != nil {
+			return filters, invalidParameterError("vip must be true or false")
+		}
+		filters.VipOnly = &vipOnly
+	}
+	if limit := c.QueryParam("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return filters, invalidParameterError("limit must be a positive number")
+		}
+		filters.Limit = n
+	}
+	filters.Tags = append(filters.Tags, c.QueryParams()["tag"]...)
+	for name, values := range c.QueryParams() {
+		if key, ok := strings.CutPrefix(name, "cf."); ok && len(values) > 0 {
+			if filters.CustomFields == nil {
+				filters.CustomFields = map[string]string{}
+			}
+			filters.CustomFields[key] = values[0]
+		}
+	}
+	if len(filters.CustomFields) > 0 {
+		// Validate up front so a malformed value is reported as a bad parameter
+		defs, err := h.Repo.GetCustomFieldDefinitions(userID)
+		if err != nil {
+			return filters, err
+		}
+		if _, err := entity.ApplyCustomFields(defs, nil, filters.CustomFields); err != nil {
+			return filters, invalidParameterError(err.Error())
+		}
+	}
+	return filters, nil
+}
+
+// ListContacts returns a page of the user's contacts, optionally filtered by
+// relationship, vip, location, tag (repeatable) and cf.<key> custom field
+// query parameters and ordered by sort and order. limit is the page size and
+// next_cursor, passed back as cursor, reads the next page. With q the results
+// are a ranked full-text search instead, limited to limit results.
+func (h *APIHandler) ListContacts(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	filters, err := h.contactFilters(c, userID)
+	if err != nil {
+		return apiParamError(c, err, "contacts")
+	}
+
+	// Free-text search returns the best matches first, with highlighted snippets
+	if q := strings.TrimSpace(c.QueryParam("q")); q != "" {
+		results, err := h.Repo.SearchContactsFullText(userID, q, filters)
+		if err != nil {
+			return apiRepoError(c, err, "contacts")
+		}
+
+		res := make([]ContactSearchResponse, 0, len(results))
+		for _, result := range results {
+			res = append(res, ContactSearchResponse{
+				ContactResponse: newContactResponse(result.Contact),
+				Rank:            result.Rank,
+				SnippetHTML:     highlightSnippet(result.Snippet),
+			})
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{"contacts": res})
+	}
+
+	page := repository.ContactPageRequest{
+		Sort:   c.QueryParam("sort"),
+		Cursor: c.QueryParam("cursor"),
+		Limit:  filters.Limit,
+	}
+	if page.Sort != "" && !repository.IsContactSort(page.Sort) {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", "sort must be one of "+strings.Join(repository.ContactSorts, ", "))
+	}
+	switch c.QueryParam("order") {
+	case "", "asc":
+	case "desc":
+		page.Desc = true
+	default:
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", "order must be asc or desc")
+	}
+
+	result, err := h.Repo.ListContactsPage(userID, filters, page)
+	if isContactListError(err) {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+	if err != nil {
+		return apiRepoError(c, err, "contacts")
+	}
+
+	res := make([]ContactResponse, 0, len(result.Contacts))
+	for _, contact := range result.Contacts {
+		res = append(res, newContactResponse(contact))
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"contacts": res, "next_cursor": result.NextCursor})
+}
+
+func (h *APIHandler) GetContact(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	contact, err := h.Repo.GetContact(fmt.Sprintf("%d", id), userID)
+	if err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+	return c.JSON(http.StatusOK, newContactResponse(contact))
+}
+
+func (h *APIHandler) CreateContact(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var req ContactRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+
+	contact := &entity.Contact{UserID: userID}
+	if err := req.apply(contact); err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+	if req.hasChannels() {
+		req.applyChannels(contact)
+	}
+	if err := validateContact(*contact); err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+	if err := h.applyRelationship(contact, req.Relationship); err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+	if err := h.applyCustomFields(contact, req.CustomFields); err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+
+	// Refuse a second contact with the same email or phone number unless asked to
+	if c.QueryParam("allow_duplicate") != "true" {
+		duplicates, err := h.Repo.FindDuplicatesOf(*contact)
+		if err != nil {
+			return apiRepoError(c, err, "contact")
+		}
+		if len(duplicates) > 0 {
+			var ids []string
+			for _, duplicate := range duplicates {
+				ids = append(ids, fmt.Sprintf("%d (%s)", duplicate.Contact.ID, strings.Join(duplicate.Reasons, ", ")))
+			}
+			return apiError(c, http.StatusConflict, "duplicate_contact",
+				"contact looks like a duplicate of "+strings.Join(ids, "; ")+"; pass allow_duplicate=true to create it anyway")
+		}
+	}
+
+	if err := h.Repo.CreateContact(contact); err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+	return c.JSON(http.StatusCreated, newContactResponse(*contact))
+}
+
+func (h *APIHandler) UpdateContact(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	contact, err := h.Repo.GetContact(fmt.Sprintf("%d", id), userID)
+	if err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+
+	var req ContactRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+
+	if err := req.apply(&contact); err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+	if err := validateContact(contact); err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+	if err := h.applyRelationship(&contact, req.Relationship); err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+	if err := h.applyCustomFields(&contact, req.CustomFields); err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+
+	save := h.Repo.SaveContactWithHistory
+	if req.hasChannels() {
+		req.applyChannels(&contact)
+		save = h.Repo.SaveContactWithChannels
+	}
+	if err := save(&contact, apiActor(c)); err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+	if contact, err = h.Repo.GetContact(fmt.Sprintf("%d", id), userID); err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+	return c.JSON(http.StatusOK, newContactResponse(contact))
+}
+
+func (h *APIHandler) DeleteContact(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	if err := h.Repo.DeleteContact(fmt.Sprintf("%d", id), userID); err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *APIHandler) GetContactHistory(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	if _, err := h.Repo.GetContact(fmt.Sprintf("%d", id), userID); err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+
+	changes, err := h.Repo.GetContactHistory(id, userID)
+	if err != nil {
+		return apiRepoError(c, err, "history")
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"revisions": groupRevisions(changes)})
+}
+
+func (h *APIHandler) RestoreContactRevision(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	revision, err := uuid.Parse(c.Param("revision"))
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", "invalid revision")
+	}
+
+	contact, err := h.Repo.RestoreContactRevision(id, userID, revision, apiActor(c))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apiError(c, http.StatusNotFound, "not_found", "revision not found")
+		}
+		if strings.Contains(err.Error(), "already the current version") {
+			return apiError(c, http.StatusConflict, "already_current", err.Error())
+		}
+		return apiRepoError(c, err, "contact")
+	}
+	return c.JSON(http.StatusOK, newContactResponse(contact))
+}
+
+func (h *APIHandler) ListContactEvents(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	if _, err := h.Repo.GetContact(fmt.Sprintf("%d", id), userID); err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+
+	events, err := h.Repo.GetEventsByContact(id, userID)
+	if err != nil {
+		return apiRepoError(c, err, "events")
+	}
+
+	res := make([]EventResponse, 0, len(events))
+	for _, event := range events {
+		res = append(res, newEventResponse(event))
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"events": res})
+}
+
+func (h *APIHandler) CreateEvent(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	var req EventRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+	if err := req.validate(); err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+
+	if _, err := h.Repo.GetContact(fmt.Sprintf("%d", id), userID); err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+
+	event, err := h.CalendarService.CreateCustomEvent(userID, id, req.Title, req.date, req.Recurrence)
+	if err != nil {
+		return apiCalendarError(c, err)
+	}
+	return c.JSON(http.StatusCreated, newEventResponse(*event))
+}
+
+func (h *APIHandler) GetEvent(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	eventID, err := parseIDParam(c, "eventId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	event, err := h.Repo.GetEventByID(eventID, userID)
+	if err != nil {
+		return apiRepoError(c, err, "event")
+	}
+	return c.JSON(http.StatusOK, newEventResponse(event))
+}
+
+func (h *APIHandler) UpdateEvent(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	eventID, err := parseIDParam(c, "eventId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	if _, err := h.Repo.GetEventByID(eventID, userID); err != nil {
+		return apiRepoError(c, err, "event")
+	}
+
+	var req EventRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+	if err := req.validate(); err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+
+	event, err := h.CalendarService.UpdateCustomEvent(userID, eventID, req.Title, req.date, req.Recurrence)
+	if err != nil {
+		return apiCalendarError(c, err)
+	}
+	return c.JSON(http.StatusOK, newEventResponse(*event))
+}
+
+func (h *APIHandler) DeleteEvent(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	eventID, err := parseIDParam(c, "eventId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	if _, err := h.Repo.GetEventByID(eventID, userID); err != nil {
+		return apiRepoError(c, err, "event")
+	}
+
+	if err := h.CalendarService.DeleteCustomEvent(userID, eventID); err != nil {
+		return apiCalendarError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *APIHandler) GetCalendarSyncStatus(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	contactID := fmt.Sprintf("%d", id)
+	if _, err := h.Repo.GetContact(contactID, userID); err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+
+	synced, err := h.CalendarService.GetEventStatus(userID, contactID)
+	if err != nil {
+		return apiCalendarError(c, err)
+	}
+	return c.JSON(http.StatusOK, map[string]bool{"synced": synced})
+}
+
+func (h *APIHandler) SyncBirthday(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	contactID := fmt.Sprintf("%d", id)
+	contact, err := h.Repo.GetContact(contactID, userID)
+	if err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+	if contact.Birthday.IsZero() {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", "contact has no birthday")
+	}
+
+	if err := h.CalendarService.CreateBirthdayReminder(userID, contactID); err != nil {
+		return apiCalendarError(c, err)
+	}
+
+	contact, err = h.Repo.GetContact(contactID, userID)
+	if err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+	return c.JSON(http.StatusOK, newContactResponse(contact))
+}
+
+func (h *APIHandler) UnsyncBirthday(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	contactID := fmt.Sprintf("%d", id)
+	contact, err := h.Repo.GetContact(contactID, userID)
+	if err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+	if contact.GoogleCalendarEventID == "" {
+		return apiError(c, http.StatusConflict, "not_synced", "contact birthday is not synced")
+	}
+
+	if err := h.CalendarService.DeleteBirthdayReminder(userID, contactID); err != nil {
+		return apiCalendarError(c, err)
+	}
+
+	contact, err = h.Repo.GetContact(contactID, userID)
+	if err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+	return c.JSON(http.StatusOK, newContactResponse(contact))
+}
+
+func (h *APIHandler) GetDashboard(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.DashboardService.buildDashboard(c))
+}
@@ -0,0 +1,340 @@
+package service
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/labstack/echo/v4"
+)
+
+// HouseholdResponse is the JSON representation of a household
+type HouseholdResponse struct {
+	ID         uint                      `json:"id"`
+	Name       string                    `json:"name"`
+	Greeting   string                    `json:"greeting"`
+	Salutation string                    `json:"salutation"` // the greeting, or the one derived from the members
+	Street     string                    `json:"street"`
+	City       string                    `json:"city"`
+	Region     string                    `json:"region"`
+	PostalCode string                    `json:"postal_code"`
+	Country    string                    `json:"country"`
+	Members    []HouseholdMemberResponse `json:"members"`
+	Events     []HouseholdEventResponse  `json:"events,omitempty"`
+	CreatedAt  time.Time                 `json:"created_at"`
+	UpdatedAt  time.Time                 `json:"updated_at"`
+}
+
+// HouseholdMemberResponse is a contact in a household
+type HouseholdMemberResponse struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// HouseholdEventResponse is the JSON representation of a household event
+type HouseholdEventResponse struct {
+	ID                    uint      `json:"id"`
+	HouseholdID           uint      `json:"household_id"`
+	Title                 string    `json:"title"`
+	EventDate             string    `json:"event_date"`
+	Recurrence            string    `json:"recurrence"`
+	GoogleCalendarEventID string    `json:"google_calendar_event_id"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+func newHouseholdResponse(household entity.Household) HouseholdResponse {
+	res := HouseholdResponse{
+		ID:         household.ID,
+		Name:       household.Name,
+		Greeting:   household.Greeting,
+		Salutation: household.Salutation(),
+		Street:     household.Street,
+		City:       household.City,
+		Region:     household.Region,
+		PostalCode: household.PostalCode,
+		Country:    household.Country,
+		Members:    []HouseholdMemberResponse{},
+		CreatedAt:  household.CreatedAt,
+		UpdatedAt:  household.UpdatedAt,
+	}
+	for _, member := range household.Members {
+		res.Members = append(res.Members, HouseholdMemberResponse{ID: member.ID, Name: member.Name})
+	}
+	for _, event := range household.Events {
+		res.Events = append(res.Events, newHouseholdEventResponse(event))
+	}
+	return res
+}
+
+func newHouseholdEventResponse(event entity.HouseholdEvent) HouseholdEventResponse {
+	return HouseholdEventResponse{
+		ID:                    event.ID,
+		HouseholdID:           event.HouseholdID,
+		Title:                 event.Title,
+		EventDate:             event.EventDate.String(),
+		Recurrence:            event.Recurrence,
+		GoogleCalendarEventID: event.GoogleCalendarEventID,
+		CreatedAt:             event.CreatedAt,
+		UpdatedAt:             event.UpdatedAt,
+	}
+}
+
+// HouseholdRequest is the body accepted when creating or updating a
+// household. Fields left out keep their current value on update.
+type HouseholdRequest struct {
+	Name       *string `json:"name"`
+	Greeting   *string `json:"greeting"`
+	Street     *string `json:"street"`
+	City       *string `json:"city"`
+	Region     *string `json:"region"`
+	PostalCode *string `json:"postal_code"`
+	Country    *string `json:"country"`
+	ContactIDs []uint  `json:"contact_ids"` // members to add on create
+}
+
+func (r HouseholdRequest) apply(household *entity.Household) {
+	setString := func(dst *string, src *string) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	setString(&household.Name, r.Name)
+	setString(&household.Greeting, r.Greeting)
+	setString(&household.Street, r.Street)
+	setString(&household.City, r.City)
+	setString(&household.Region, r.Region)
+	setString(&household.PostalCode, r.PostalCode)
+	setString(&household.Country, r.Country)
+}
+
+// HouseholdMembersRequest is the body accepted when adding members
+type HouseholdMembersRequest struct {
+	ContactIDs []uint `json:"contact_ids"`
+}
+
+// householdResponse looks up a household with its members and events
+func (h *APIHandler) householdResponse(c echo.Context, householdID, userID uint, status int) error {
+	household, err := h.Repo.GetHouseholdByID(householdID, userID)
+	if err != nil {
+		return apiRepoError(c, err, "household")
+	}
+	return c.JSON(status, newHouseholdResponse(household))
+}
+
+func (h *APIHandler) ListHouseholds(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	households, err := h.Repo.GetHouseholds(userID)
+	if err != nil {
+		return apiRepoError(c, err, "households")
+	}
+	res := make([]HouseholdResponse, 0, len(households))
+	for _, household := range households {
+		res = append(res, newHouseholdResponse(household))
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"households": res})
+}
+
+func (h *APIHandler) GetHousehold(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	householdID, err := parseIDParam(c, "householdId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+	return h.householdResponse(c, householdID, userID, http.StatusOK)
+}
+
+func (h *APIHandler) CreateHousehold(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var req HouseholdRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+	household := entity.Household{UserID: userID}
+	req.apply(&household)
+	if err := validateHousehold(&household); err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+	if taken, err := householdNameTaken(h.Repo, userID, 0, household.Name); err != nil {
+		return apiRepoError(c, err, "household")
+	} else if taken {
+		return apiError(c, http.StatusConflict, "already_exists", "a household with this name already exists")
+	}
+
+	if err := h.Repo.CreateHousehold(&household); err != nil {
+		return apiRepoError(c, err, "household")
+	}
+	if _, err := h.Repo.AddHouseholdMembers(household.ID, userID, req.ContactIDs); err != nil {
+		return apiRepoError(c, err, "household")
+	}
+	return h.householdResponse(c, household.ID, userID, http.StatusCreated)
+}
+
+// UpdateHousehold changes the name, greeting or shared address of a household
+func (h *APIHandler) UpdateHousehold(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	householdID, err := parseIDParam(c, "householdId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	household, err := h.Repo.GetHouseholdByID(householdID, userID)
+	if err != nil {
+		return apiRepoError(c, err, "household")
+	}
+
+	var req HouseholdRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+	req.apply(&household)
+	if err := validateHousehold(&household); err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+	if taken, err := householdNameTaken(h.Repo, userID, householdID, household.Name); err != nil {
+		return apiRepoError(c, err, "household")
+	} else if taken {
+		return apiError(c, http.StatusConflict, "already_exists", "a household with this name already exists")
+	}
+
+	if err := h.Repo.UpdateHousehold(&household); err != nil {
+		return apiRepoError(c, err, "household")
+	}
+	return h.householdResponse(c, householdID, userID, http.StatusOK)
+}
+
+// DeleteHousehold deletes a household and removes its events from the
+// calendar. Its members are kept.
+func (h *APIHandler) DeleteHousehold(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	householdID, err := parseIDParam(c, "householdId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	if _, err := h.Repo.GetHouseholdByID(householdID, userID); err != nil {
+		return apiRepoError(c, err, "household")
+	}
+	if err := h.CalendarService.DeleteHousehold(userID, householdID); err != nil {
+		return apiCalendarError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// AddHouseholdMembers adds the contacts in the request body to a household
+func (h *APIHandler) AddHouseholdMembers(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	householdID, err := parseIDParam(c, "householdId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	var req HouseholdMembersRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+	if len(req.ContactIDs) == 0 {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", "contact_ids is required")
+	}
+
+	if _, err := h.Repo.AddHouseholdMembers(householdID, userID, req.ContactIDs); err != nil {
+		return apiRepoError(c, err, "household")
+	}
+	return h.householdResponse(c, householdID, userID, http.StatusOK)
+}
+
+func (h *APIHandler) RemoveHouseholdMember(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	householdID, err := parseIDParam(c, "householdId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+	contactID, err := parseIDParam(c, "contactId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	n, err := h.Repo.RemoveHouseholdMembers(householdID, userID, []uint{contactID})
+	if err != nil {
+		return apiRepoError(c, err, "household")
+	}
+	if n == 0 {
+		return apiError(c, http.StatusNotFound, "not_found", "contact is not a member of the household")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// CreateHouseholdEvent adds an event shared by a household, like an
+// anniversary, to the calendar
+func (h *APIHandler) CreateHouseholdEvent(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	householdID, err := parseIDParam(c, "householdId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	var req EventRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+	if err := req.validate(); err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+
+	if _, err := h.Repo.GetHouseholdByID(householdID, userID); err != nil {
+		return apiRepoError(c, err, "household")
+	}
+
+	event, err := h.CalendarService.CreateHouseholdEvent(userID, householdID, req.Title, req.date, req.Recurrence)
+	if err != nil {
+		return apiCalendarError(c, err)
+	}
+	return c.JSON(http.StatusCreated, newHouseholdEventResponse(*event))
+}
+
+func (h *APIHandler) DeleteHouseholdEvent(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	eventID, err := parseIDParam(c, "eventId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	if _, err := h.Repo.GetHouseholdEventByID(eventID, userID); err != nil {
+		return apiRepoError(c, err, "event")
+	}
+
+	if err := h.CalendarService.DeleteHouseholdEvent(userID, eventID); err != nil {
+		return apiCalendarError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ExportGreetings returns a greeting list as CSV of the contacts matching the
+// same filters as ExportVCard. With per_household=true the members of a
+// household are addressed together.
+func (h *APIHandler) ExportGreetings(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	filters, err := h.contactFilters(c, userID)
+	if err != nil {
+		return apiParamError(c, err, "contacts")
+	}
+	if ids := c.QueryParams()["id"]; len(ids) > 0 {
+		filters.IDs = parseContactIDs(ids)
+		if len(filters.IDs) != len(ids) {
+			return apiError(c, http.StatusBadRequest, "invalid_parameter", "id must be a contact id")
+		}
+	}
+
+	contacts, err := h.Repo.SearchContactsAdvanced(userID, filters)
+	if err != nil {
+		return apiRepoError(c, err, "contacts")
+	}
+	households, err := h.Repo.GetHouseholds(userID)
+	if err != nil {
+		return apiRepoError(c, err, "households")
+	}
+	return writeGreetingList(c, buildGreetingList(contacts, households, c.QueryParam("per_household") == "true"))
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/labstack/echo/v4"
+)
+
+// InteractionResponse is the JSON representation of an interaction
+type InteractionResponse struct {
+	ID         uint      `json:"id"`
+	Type       string    `json:"type"`
+	OccurredOn string    `json:"occurred_on"`
+	Notes      string    `json:"notes"`
+	ContactIDs []uint    `json:"contact_ids"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func newInteractionResponse(interaction entity.Interaction) InteractionResponse {
+	contactIDs := make([]uint, 0, len(interaction.Contacts))
+	for _, contact := range interaction.Contacts {
+		contactIDs = append(contactIDs, contact.ID)
+	}
+	return InteractionResponse{
+		ID:         interaction.ID,
+		Type:       string(interaction.Type),
+		OccurredOn: interaction.OccurredOn.String(),
+		Notes:      interaction.Notes,
+		ContactIDs: contactIDs,
+		CreatedAt:  interaction.CreatedAt,
+	}
+}
+
+// InteractionRequest is the body accepted when logging an interaction.
+// ContactIDs lists the other contacts that took part, besides the one in the URL.
+type InteractionRequest struct {
+	Type       string `json:"type"`
+	OccurredOn string `json:"occurred_on"`
+	Notes      string `json:"notes"`
+	ContactIDs []uint `json:"contact_ids"`
+}
+
+func (h *APIHandler) ListContactInteractions(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	interactions, err := h.Repo.GetInteractionsByContact(id, userID)
+	if err != nil {
+		return apiRepoError(c, err, "interactions")
+	}
+
+	res := make([]InteractionResponse, 0, len(interactions))
+	for _, interaction := range interactions {
+		res = append(res, newInteractionResponse(interaction))
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"interactions": res})
+}
+
+// CreateInteraction logs an interaction and updates the derived
+// last_contacted and last_met dates of every contact involved
+func (h *APIHandler) CreateInteraction(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	var req InteractionRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+
+	// Default to today, like the form on the contact page
+	if req.OccurredOn == "" {
+		req.OccurredOn = time.Now().Format("2006-01-02")
+	}
+	interaction, err := parseInteraction(userID, req.Type, req.OccurredOn, req.Notes)
+	if err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+
+	contactIDs := append([]uint{id}, req.ContactIDs...)
+	if err := h.Repo.CreateInteraction(interaction, contactIDs); err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+
+	created, err := h.Repo.GetInteractionByID(interaction.ID, userID)
+	if err != nil {
+		return apiRepoError(c, err, "interaction")
+	}
+	return c.JSON(http.StatusCreated, newInteractionResponse(created))
+}
+
+func (h *APIHandler) DeleteInteraction(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	interactionID, err := parseIDParam(c, "interactionId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	if _, err := h.Repo.GetInteractionByID(interactionID, userID); err != nil {
+		return apiRepoError(c, err, "interaction")
+	}
+
+	if err := h.Repo.DeleteInteraction(interactionID, userID); err != nil {
+		return apiRepoError(c, err, "interaction")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// LinkResponse is a link as seen from the contact in the URL. Label reads
+// "<contact> label <other contact>", e.g. "Reports to".
+type LinkResponse struct {
+	ID        uint   `json:"id"`
+	Type      string `json:"type"`
+	Label     string `json:"label"`
+	Outgoing  bool   `json:"outgoing"`
+	ContactID uint   `json:"contact_id"`
+	Name      string `json:"name"`
+	Note      string `json:"note"`
+}
+
+func newLinkResponse(link repository.LinkedContact) LinkResponse {
+	return LinkResponse{
+		ID:        link.LinkID,
+		Type:      link.Type.Key,
+		Label:     link.Label,
+		Outgoing:  link.Outgoing,
+		ContactID: link.Contact.ID,
+		Name:      link.Contact.Name,
+		Note:      link.Note,
+	}
+}
+
+// LinkRequest is the body accepted when linking a contact. The link reads
+// "<contact in the URL> <type label> <contact_id>"; with inverse it is stored
+// the other way around, e.g. type "manager" with inverse for "reports to".
+type LinkRequest struct {
+	Type      string `json:"type"`
+	ContactID uint   `json:"contact_id"`
+	Inverse   bool   `json:"inverse"`
+	Note      string `json:"note"`
+}
+
+// ListLinkTypes returns the catalogue of link types
+func (h *APIHandler) ListLinkTypes(c echo.Context) error {
+	types, err := h.Repo.GetLinkTypes()
+	if err != nil {
+		return apiRepoError(c, err, "link types")
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"link_types": types})
+}
+
+func (h *APIHandler) ListContactLinks(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	if _, err := h.Repo.GetContact(c.Param("id"), userID); err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+	links, err := h.Repo.GetContactLinks(id, userID)
+	if err != nil {
+		return apiRepoError(c, err, "links")
+	}
+
+	res := make([]LinkResponse, 0, len(links))
+	for _, link := range links {
+		res = append(res, newLinkResponse(link))
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"links": res})
+}
+
+func (h *APIHandler) CreateContactLink(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	var req LinkRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+	if req.ContactID == 0 {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", "contact_id is required")
+	}
+	if req.ContactID == id {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", "a contact cannot be linked to itself")
+	}
+	linkType, err := h.Repo.GetLinkTypeByKey(req.Type)
+	if err != nil {
+		if isNotFoundError(err) {
+			return apiError(c, http.StatusUnprocessableEntity, "validation_failed", "type must be one of the link types")
+		}
+		return apiRepoError(c, err, "link type")
+	}
+
+	link := &entity.ContactLink{
+		UserID:        userID,
+		FromContactID: id,
+		ToContactID:   req.ContactID,
+		LinkTypeID:    linkType.ID,
+		Note:          strings.TrimSpace(req.Note),
+	}
+	if req.Inverse {
+		link.FromContactID, link.ToContactID = req.ContactID, id
+	}
+	if err := h.Repo.CreateContactLink(link); err != nil {
+		if errors.Is(err, repository.ErrLinkExists) {
+			return apiError(c, http.StatusConflict, "already_exists", err.Error())
+		}
+		return apiRepoError(c, err, "contact")
+	}
+
+	links, err := h.Repo.GetContactLinks(id, userID)
+	if err != nil {
+		return apiRepoError(c, err, "links")
+	}
+	for _, linked := range links {
+		if linked.LinkID == link.ID {
+			return c.JSON(http.StatusCreated, newLinkResponse(linked))
+		}
+	}
+	return apiError(c, http.StatusNotFound, "not_found", "link not found")
+}
+
+func (h *APIHandler) DeleteContactLink(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	linkID, err := parseIDParam(c, "linkId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	if err := h.Repo.DeleteContactLink(linkID, userID); err != nil {
+		return apiRepoError(c, err, "link")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
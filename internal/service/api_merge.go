@@ -0,0 +1,129 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/labstack/echo/v4"
+)
+
+// DuplicateResponse is a pair of contacts that probably describe the same person
+type DuplicateResponse struct {
+	Contact ContactResponse `json:"contact"`
+	Other   ContactResponse `json:"other"`
+	Score   float64         `json:"score"`
+	Reasons []string        `json:"reasons"`
+}
+
+// MergeRequest is the body of the merge endpoint. Choices maps a field, e.g.
+// "email" or "custom_fields.diet", to "survivor" or "merged". Notes are
+// always kept from both contacts.
+// Fields left out keep the survivor's value unless it is empty.
+type MergeRequest struct {
+	MergedID uint              `json:"merged_id"`
+	Choices  map[string]string `json:"choices"`
+}
+
+// ContactMergeResponse is the JSON representation of a recorded merge
+type ContactMergeResponse struct {
+	ID          uint              `json:"id"`
+	SurvivorID  uint              `json:"survivor_id"`
+	MergedID    uint              `json:"merged_id"`
+	MergedName  string            `json:"merged_name"`
+	Snapshot    map[string]string `json:"snapshot"`
+	TakenFields []string          `json:"taken_fields"`
+	Actor       string            `json:"actor"`
+	MergedAt    string            `json:"merged_at"`
+}
+
+// ListDuplicates returns the user's probable duplicate contacts, best matches first
+func (h *APIHandler) ListDuplicates(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	pairs, err := h.Repo.FindDuplicateContacts(userID)
+	if err != nil {
+		return apiRepoError(c, err, "duplicates")
+	}
+
+	res := make([]DuplicateResponse, 0, len(pairs))
+	for _, pair := range pairs {
+		res = append(res, DuplicateResponse{
+			Contact: newContactResponse(pair.Contact),
+			Other:   newContactResponse(pair.Other),
+			Score:   pair.Score,
+			Reasons: pair.Reasons,
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"duplicates": res})
+}
+
+// MergeContacts merges merged_id into the contact in the path and returns the survivor
+func (h *APIHandler) MergeContacts(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	survivorID, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	var req MergeRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+	if req.MergedID == 0 {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", "merged_id is required")
+	}
+	if req.MergedID == survivorID {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", "a contact cannot be merged into itself")
+	}
+
+	choices := map[string]entity.MergeSource{}
+	for column, value := range req.Choices {
+		source := entity.MergeSource(value)
+		if !source.Valid() {
+			return apiError(c, http.StatusUnprocessableEntity, "validation_failed",
+				fmt.Sprintf("choice for %s must be survivor or merged", column))
+		}
+		choices[column] = source
+	}
+
+	contact, err := mergeContacts(h.Repo, h.CalendarService, survivorID, req.MergedID, userID, choices, apiActor(c))
+	if err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+	return c.JSON(http.StatusOK, newContactResponse(contact))
+}
+
+// ListContactMerges returns the contacts merged into a contact, newest first
+func (h *APIHandler) ListContactMerges(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	if _, err := h.Repo.GetContact(fmt.Sprintf("%d", id), userID); err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+
+	merges, err := h.Repo.GetContactMerges(id, userID)
+	if err != nil {
+		return apiRepoError(c, err, "merges")
+	}
+
+	res := make([]ContactMergeResponse, 0, len(merges))
+	for _, merge := range merges {
+		res = append(res, ContactMergeResponse{
+			ID:          merge.ID,
+			SurvivorID:  merge.SurvivorID,
+			MergedID:    merge.MergedID,
+			MergedName:  merge.MergedName,
+			Snapshot:    merge.Snapshot,
+			TakenFields: merge.TakenFields,
+			Actor:       merge.Actor,
+			MergedAt:    merge.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"merges": res})
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/labstack/echo/v4"
+)
+
+// NoteResponse is the JSON representation of a note. EditedAt is null until
+// the body is changed.
+type NoteResponse struct {
+	ID        uint       `json:"id"`
+	ContactID uint       `json:"contact_id"`
+	Body      string     `json:"body"`
+	Pinned    bool       `json:"pinned"`
+	CreatedAt time.Time  `json:"created_at"`
+	EditedAt  *time.Time `json:"edited_at"`
+}
+
+func newNoteResponse(note entity.Note) NoteResponse {
+	return NoteResponse{
+		ID:        note.ID,
+		ContactID: note.ContactID,
+		Body:      note.Body,
+		Pinned:    note.Pinned,
+		CreatedAt: note.CreatedAt,
+		EditedAt:  note.EditedAt,
+	}
+}
+
+func newNoteResponses(notes []entity.Note) []NoteResponse {
+	res := make([]NoteResponse, 0, len(notes))
+	for _, note := range notes {
+		res = append(res, newNoteResponse(note))
+	}
+	return res
+}
+
+// NoteRequest is the body accepted when adding or updating a note. Fields
+// left out of an update keep their current value.
+type NoteRequest struct {
+	Body   *string `json:"body"`
+	Pinned *bool   `json:"pinned"`
+}
+
+// apply copies the fields present in the request onto note
+func (r NoteRequest) apply(note *entity.Note) error {
+	if r.Body != nil {
+		note.Body = strings.TrimSpace(*r.Body)
+	}
+	if r.Pinned != nil {
+		note.Pinned = *r.Pinned
+	}
+	if note.Body == "" {
+		return errEmptyNote
+	}
+	return nil
+}
+
+func (h *APIHandler) ListContactNotes(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	notes, err := h.Repo.GetNotesByContact(id, userID)
+	if err != nil {
+		return apiRepoError(c, err, "notes")
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"notes": newNoteResponses(notes)})
+}
+
+func (h *APIHandler) CreateNote(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	var req NoteRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+	note := entity.Note{UserID: userID, ContactID: id}
+	if err := req.apply(&note); err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+
+	if err := h.Repo.CreateNote(&note); err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+	return c.JSON(http.StatusCreated, newNoteResponse(note))
+}
+
+func (h *APIHandler) GetNote(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	noteID, err := parseIDParam(c, "noteId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	note, err := h.Repo.GetNoteByID(noteID, userID)
+	if err != nil {
+		return apiRepoError(c, err, "note")
+	}
+	return c.JSON(http.StatusOK, newNoteResponse(note))
+}
+
+// UpdateNote edits the body of a note or pins and unpins it
+func (h *APIHandler) UpdateNote(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	noteID, err := parseIDParam(c, "noteId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	note, err := h.Repo.GetNoteByID(noteID, userID)
+	if err != nil {
+		return apiRepoError(c, err, "note")
+	}
+
+	var req NoteRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+	if err := req.apply(&note); err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+
+	if err := h.Repo.UpdateNote(&note); err != nil {
+		return apiRepoError(c, err, "note")
+	}
+	return c.JSON(http.StatusOK, newNoteResponse(note))
+}
+
+func (h *APIHandler) DeleteNote(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	noteID, err := parseIDParam(c, "noteId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	if err := h.Repo.DeleteNote(noteID, userID); err != nil {
+		return apiRepoError(c, err, "note")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// RelationshipTypeResponse is the JSON representation of a relationship type
+type RelationshipTypeResponse struct {
+	ID           uint   `json:"id"`
+	Name         string `json:"name"`
+	Position     int    `json:"position"`
+	ContactCount int    `json:"contact_count"`
+}
+
+func newRelationshipTypeResponse(t repository.RelationshipTypeWithCount) RelationshipTypeResponse {
+	return RelationshipTypeResponse{
+		ID:           t.ID,
+		Name:         t.Name,
+		Position:     t.Position,
+		ContactCount: t.ContactCount,
+	}
+}
+
+// RelationshipTypeRequest is the body accepted when creating or updating a
+// relationship type. Fields left out keep their current value on update.
+type RelationshipTypeRequest struct {
+	Name     *string `json:"name"`
+	Position *int    `json:"position"`
+}
+
+// relationshipTypeResponse looks up a relationship type with its contact count
+func (h *APIHandler) relationshipTypeResponse(c echo.Context, typeID, userID uint, status int) error {
+	types, err := h.Repo.GetRelationshipTypeCounts(userID)
+	if err != nil {
+		return apiRepoError(c, err, "relationship type")
+	}
+	for _, t := range types {
+		if t.ID == typeID {
+			return c.JSON(status, newRelationshipTypeResponse(t))
+		}
+	}
+	return apiError(c, http.StatusNotFound, "not_found", "relationship type not found")
+}
+
+func (h *APIHandler) ListRelationshipTypes(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	types, err := h.Repo.GetRelationshipTypeCounts(userID)
+	if err != nil {
+		return apiRepoError(c, err, "relationship types")
+	}
+
+	res := make([]RelationshipTypeResponse, 0, len(types))
+	for _, t := range types {
+		res = append(res, newRelationshipTypeResponse(t))
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"relationship_types": res})
+}
+
+func (h *APIHandler) CreateRelationshipType(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var req RelationshipTypeRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+	if req.Name == nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", "name is required")
+	}
+
+	name, err := validateRelationshipTypeName(*req.Name)
+	if err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+	if taken, err := relationshipTypeNameTaken(h.Repo, userID, 0, name); err != nil {
+		return apiRepoError(c, err, "relationship type")
+	} else if taken {
+		return apiError(c, http.StatusConflict, "already_exists", "a relationship type with this name already exists")
+	}
+
+	t := entity.RelationshipType{UserID: userID, Name: name}
+	if err := h.Repo.CreateRelationshipType(&t); err != nil {
+		return apiRepoError(c, err, "relationship type")
+	}
+	if req.Position != nil && *req.Position != t.Position {
+		t.Position = *req.Position
+		if err := h.Repo.UpdateRelationshipType(&t, actorFromContext(c)); err != nil {
+			return apiRepoError(c, err, "relationship type")
+		}
+	}
+	return h.relationshipTypeResponse(c, t.ID, userID, http.StatusCreated)
+}
+
+// UpdateRelationshipType renames or moves a relationship type. Its contacts
+// and the smart lists filtering on it follow a new name.
+func (h *APIHandler) UpdateRelationshipType(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	typeID, err := parseIDParam(c, "typeId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	t, err := h.Repo.GetRelationshipTypeByID(typeID, userID)
+	if err != nil {
+		return apiRepoError(c, err, "relationship type")
+	}
+
+	var req RelationshipTypeRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+	if req.Name != nil {
+		if t.Name, err = validateRelationshipTypeName(*req.Name); err != nil {
+			return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+		}
+		if taken, err := relationshipTypeNameTaken(h.Repo, userID, t.ID, t.Name); err != nil {
+			return apiRepoError(c, err, "relationship type")
+		} else if taken {
+			return apiError(c, http.StatusConflict, "already_exists", "a relationship type with this name already exists")
+		}
+	}
+	if req.Position != nil {
+		t.Position = *req.Position
+	}
+
+	if err := h.Repo.UpdateRelationshipType(&t, actorFromContext(c)); err != nil {
+		return apiRepoError(c, err, "relationship type")
+	}
+	return h.relationshipTypeResponse(c, t.ID, userID, http.StatusOK)
+}
+
+// DeleteRelationshipType deletes a relationship type and clears it from its contacts
+func (h *APIHandler) DeleteRelationshipType(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	typeID, err := parseIDParam(c, "typeId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	if err := h.Repo.DeleteRelationshipType(typeID, userID, actorFromContext(c)); err != nil {
+		return apiRepoError(c, err, "relationship type")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/labstack/echo/v4"
+)
+
+// SmartListResponse is the JSON representation of a smart list with the
+// number of contacts it currently matches
+type SmartListResponse struct {
+	SmartListInfo
+	Filters entity.SmartListFilters `json:"filters"`
+}
+
+func (h *APIHandler) newSmartListResponses(userID uint, lists []entity.SmartList) []SmartListResponse {
+	infos := smartListInfos(h.Repo, userID, lists)
+	res := make([]SmartListResponse, 0, len(lists))
+	for i, list := range lists {
+		res = append(res, SmartListResponse{SmartListInfo: infos[i], Filters: list.Filters})
+	}
+	return res
+}
+
+// SmartListRequest is the body accepted when creating or updating a smart
+// list. Fields left out keep their current value on update.
+type SmartListRequest struct {
+	Name    *string                  `json:"name"`
+	Pinned  *bool                    `json:"pinned"`
+	Filters *entity.SmartListFilters `json:"filters"`
+}
+
+func (r SmartListRequest) apply(list *entity.SmartList) {
+	if r.Name != nil {
+		list.Name = *r.Name
+	}
+	if r.Pinned != nil {
+		list.Pinned = *r.Pinned
+	}
+	if r.Filters != nil {
+		list.Filters = *r.Filters
+	}
+}
+
+func (h *APIHandler) ListSmartLists(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	lists, err := h.Repo.GetSmartLists(userID)
+	if err != nil {
+		return apiRepoError(c, err, "smart lists")
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"smart_lists": h.newSmartListResponses(userID, lists)})
+}
+
+func (h *APIHandler) GetSmartList(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	listID, err := parseIDParam(c, "listId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	list, err := h.Repo.GetSmartListByID(listID, userID)
+	if err != nil {
+		return apiRepoError(c, err, "smart list")
+	}
+	return c.JSON(http.StatusOK, h.newSmartListResponses(userID, []entity.SmartList{list})[0])
+}
+
+func (h *APIHandler) CreateSmartList(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var req SmartListRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+
+	list := entity.SmartList{UserID: userID}
+	req.apply(&list)
+	if err := validateSmartList(h.Repo, &list); err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+	if _, err := h.Repo.GetSmartListByName(list.Name, userID); err == nil {
+		return apiError(c, http.StatusConflict, "already_exists", "a smart list with this name already exists")
+	}
+
+	if err := h.Repo.CreateSmartList(&list); err != nil {
+		return apiRepoError(c, err, "smart list")
+	}
+	return c.JSON(http.StatusCreated, h.newSmartListResponses(userID, []entity.SmartList{list})[0])
+}
+
+// UpdateSmartList renames, pins or unpins a smart list or replaces its filters
+func (h *APIHandler) UpdateSmartList(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	listID, err := parseIDParam(c, "listId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	list, err := h.Repo.GetSmartListByID(listID, userID)
+	if err != nil {
+		return apiRepoError(c, err, "smart list")
+	}
+
+	var req SmartListRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+	req.apply(&list)
+	if err := validateSmartList(h.Repo, &list); err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+	if existing, err := h.Repo.GetSmartListByName(list.Name, userID); err == nil && existing.ID != list.ID {
+		return apiError(c, http.StatusConflict, "already_exists", "a smart list with this name already exists")
+	}
+
+	if err := h.Repo.UpdateSmartList(&list); err != nil {
+		return apiRepoError(c, err, "smart list")
+	}
+	return c.JSON(http.StatusOK, h.newSmartListResponses(userID, []entity.SmartList{list})[0])
+}
+
+func (h *APIHandler) DeleteSmartList(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	listID, err := parseIDParam(c, "listId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	if err := h.Repo.DeleteSmartList(listID, userID); err != nil {
+		return apiRepoError(c, err, "smart list")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
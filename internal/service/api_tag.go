@@ -0,0 +1,246 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/labstack/echo/v4"
+)
+
+// TagResponse is the JSON representation of a tag
+type TagResponse struct {
+	ID           uint   `json:"id"`
+	Name         string `json:"name"`
+	Color        string `json:"color"`
+	CadenceDays  int    `json:"cadence_days"`
+	ContactCount *int   `json:"contact_count,omitempty"`
+}
+
+func newTagResponses(tags []entity.Tag) []TagResponse {
+	res := make([]TagResponse, 0, len(tags))
+	for _, tag := range tags {
+		res = append(res, TagResponse{ID: tag.ID, Name: tag.Name, Color: tag.Color, CadenceDays: tag.CadenceDays})
+	}
+	return res
+}
+
+// TagRequest is the body accepted when creating or updating a tag
+type TagRequest struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+	// CadenceDays is the keep-in-touch cadence of the tagged contacts, 0 for none
+	CadenceDays *int `json:"cadence_days"`
+}
+
+// TagContactsRequest is the body of the bulk tagging endpoints. Either the
+// contacts are listed or they are the current contacts of a smart list.
+type TagContactsRequest struct {
+	ContactIDs  []uint `json:"contact_ids"`
+	SmartListID uint   `json:"smart_list_id"`
+}
+
+func (h *APIHandler) ListTags(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	tags, err := h.Repo.GetTags(userID)
+	if err != nil {
+		return apiRepoError(c, err, "tags")
+	}
+
+	res := make([]TagResponse, 0, len(tags))
+	for _, tag := range tags {
+		count := tag.ContactCount
+		res = append(res, TagResponse{ID: tag.ID, Name: tag.Name, Color: tag.Color, CadenceDays: tag.CadenceDays, ContactCount: &count})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"tags": res})
+}
+
+func (h *APIHandler) CreateTag(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var req TagRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+
+	tag := entity.Tag{UserID: userID, Name: req.Name, Color: req.Color}
+	if req.CadenceDays != nil {
+		tag.CadenceDays = *req.CadenceDays
+	}
+	if err := validateTag(&tag); err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+	if _, err := h.Repo.GetTagByName(tag.Name, userID); err == nil {
+		return apiError(c, http.StatusConflict, "already_exists", "a tag with this name already exists")
+	}
+
+	if err := h.Repo.CreateTag(&tag); err != nil {
+		return apiRepoError(c, err, "tag")
+	}
+	return c.JSON(http.StatusCreated, newTagResponses([]entity.Tag{tag})[0])
+}
+
+// UpdateTag renames or recolors a tag or changes its cadence. Fields left
+// empty keep their current value.
+func (h *APIHandler) UpdateTag(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	tagID, err := parseIDParam(c, "tagId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	tag, err := h.Repo.GetTagByID(tagID, userID)
+	if err != nil {
+		return apiRepoError(c, err, "tag")
+	}
+
+	var req TagRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+	if req.Name != "" {
+		tag.Name = req.Name
+	}
+	if req.Color != "" {
+		tag.Color = req.Color
+	}
+	if req.CadenceDays != nil {
+		tag.CadenceDays = *req.CadenceDays
+	}
+	if err := validateTag(&tag); err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+	if existing, err := h.Repo.GetTagByName(tag.Name, userID); err == nil && existing.ID != tag.ID {
+		return apiError(c, http.StatusConflict, "already_exists", "a tag with this name already exists")
+	}
+
+	if err := h.Repo.UpdateTag(&tag); err != nil {
+		return apiRepoError(c, err, "tag")
+	}
+	return c.JSON(http.StatusOK, newTagResponses([]entity.Tag{tag})[0])
+}
+
+func (h *APIHandler) DeleteTag(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	tagID, err := parseIDParam(c, "tagId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	if err := h.Repo.DeleteTag(tagID, userID); err != nil {
+		return apiRepoError(c, err, "tag")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// TagContacts adds a tag to every contact in the request body
+func (h *APIHandler) TagContacts(c echo.Context) error {
+	return h.bulkTag(c, h.Repo.AddTagToContacts, "tagged")
+}
+
+// UntagContacts removes a tag from every contact in the request body
+func (h *APIHandler) UntagContacts(c echo.Context) error {
+	return h.bulkTag(c, h.Repo.RemoveTagFromContacts, "untagged")
+}
+
+func (h *APIHandler) bulkTag(c echo.Context, apply func(tagID, userID uint, contactIDs []uint) (int64, error), result string) error {
+	userID := c.Get("user_id").(uint)
+	tagID, err := parseIDParam(c, "tagId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	var req TagContactsRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+	if req.SmartListID != 0 {
+		if len(req.ContactIDs) > 0 {
+			return apiError(c, http.StatusUnprocessableEntity, "validation_failed", "contact_ids and smart_list_id cannot be combined")
+		}
+		filters, err := smartListContactFilters(h.Repo, userID, req.SmartListID)
+		if err != nil {
+			return apiRepoError(c, err, "smart list")
+		}
+		contacts, err := h.Repo.SearchContactsAdvanced(userID, filters)
+		if err != nil {
+			return apiRepoError(c, err, "contacts")
+		}
+		if len(contacts) == 0 {
+			return c.JSON(http.StatusOK, map[string]int64{result: 0})
+		}
+		for _, contact := range contacts {
+			req.ContactIDs = append(req.ContactIDs, contact.ID)
+		}
+	}
+	if len(req.ContactIDs) == 0 {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", "contact_ids or smart_list_id is required")
+	}
+
+	n, err := apply(tagID, userID, req.ContactIDs)
+	if err != nil {
+		return apiRepoError(c, err, "tag")
+	}
+	return c.JSON(http.StatusOK, map[string]int64{result: n})
+}
+
+// AddContactTag tags a single contact by tag name, creating the tag if needed
+func (h *APIHandler) AddContactTag(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	var req TagRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+
+	contact, err := h.Repo.GetContact(fmt.Sprintf("%d", id), userID)
+	if err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+
+	tag, err := findOrCreateTag(h.Repo, userID, req.Name, req.Color)
+	if err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+	if _, err := h.Repo.AddTagToContacts(tag.ID, userID, []uint{id}); err != nil {
+		return apiRepoError(c, err, "tag")
+	}
+
+	return h.respondWithContact(c, contact.ID, userID)
+}
+
+func (h *APIHandler) RemoveContactTag(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+	tagID, err := parseIDParam(c, "tagId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	n, err := h.Repo.RemoveTagFromContacts(tagID, userID, []uint{id})
+	if err != nil {
+		return apiRepoError(c, err, "tag")
+	}
+	if n == 0 {
+		return apiError(c, http.StatusNotFound, "not_found", "contact does not have this tag")
+	}
+
+	return h.respondWithContact(c, id, userID)
+}
+
+// respondWithContact reloads a contact so the response reflects its current tags
+func (h *APIHandler) respondWithContact(c echo.Context, contactID, userID uint) error {
+	contact, err := h.Repo.GetContact(fmt.Sprintf("%d", contactID), userID)
+	if err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+	return c.JSON(http.StatusOK, newContactResponse(contact))
+}
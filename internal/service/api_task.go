@@ -0,0 +1,303 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// TaskResponse is the JSON representation of a task. CalendarError is set
+// when the task was saved but its Google Calendar mirror could not be updated.
+type TaskResponse struct {
+	ID                    uint       `json:"id"`
+	Title                 string     `json:"title"`
+	Notes                 string     `json:"notes"`
+	DueOn                 string     `json:"due_on"`
+	Priority              string     `json:"priority"`
+	Status                string     `json:"status"`
+	Overdue               bool       `json:"overdue"`
+	CompletedAt           *time.Time `json:"completed_at"`
+	ContactIDs            []uint     `json:"contact_ids"`
+	GoogleCalendarEventID string     `json:"google_calendar_event_id"`
+	CalendarError         string     `json:"calendar_error,omitempty"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+}
+
+func newTaskResponse(task entity.Task) TaskResponse {
+	contactIDs := make([]uint, 0, len(task.Contacts))
+	for _, contact := range task.Contacts {
+		contactIDs = append(contactIDs, contact.ID)
+	}
+	return TaskResponse{
+		ID:                    task.ID,
+		Title:                 task.Title,
+		Notes:                 task.Notes,
+		DueOn:                 task.DueOn.String(),
+		Priority:              string(task.Priority),
+		Status:                string(task.Status),
+		Overdue:               task.IsOverdue(entity.Today()),
+		CompletedAt:           task.CompletedAt,
+		ContactIDs:            contactIDs,
+		GoogleCalendarEventID: task.GoogleCalendarEventID,
+		CreatedAt:             task.CreatedAt,
+		UpdatedAt:             task.UpdatedAt,
+	}
+}
+
+// TaskRequest is the body accepted when creating or updating a task.
+// Calendar mirrors the task in Google Calendar on its due date; when it is
+// left out of an update the task keeps its current mirror. ContactIDs are
+// required on create and replace the task's contacts on update when given.
+type TaskRequest struct {
+	Title      string `json:"title"`
+	Notes      string `json:"notes"`
+	DueOn      string `json:"due_on"`
+	Priority   string `json:"priority"`
+	Status     string `json:"status"`
+	ContactIDs []uint `json:"contact_ids"`
+	Calendar   *bool  `json:"calendar"`
+}
+
+// taskFilters reads the status, contact_id and due_by query parameters of the task list
+func taskFilters(c echo.Context) (repository.TaskFilters, error) {
+	var filters repository.TaskFilters
+	if status := c.QueryParam("status"); status != "" {
+		filters.Status = entity.TaskStatus(status)
+		if !filters.Status.Valid() {
+			return filters, invalidParameterError("status must be one of open, done")
+		}
+	}
+	if contactID := c.QueryParam("contact_id"); contactID != "" {
+		id, err := strconv.ParseUint(contactID, 10, 32)
+		if err != nil || id == 0 {
+			return filters, invalidParameterError("invalid contact_id")
+		}
+		filters.ContactID = uint(id)
+	}
+	if dueBy := c.QueryParam("due_by"); dueBy != "" {
+		date, err := entity.ParseDate(dueBy)
+		if err != nil {
+			return filters, invalidParameterError("due_by must be a date in YYYY-MM-DD format")
+		}
+		filters.DueBy = date
+	}
+	return filters, nil
+}
+
+// respondTask returns a saved task after bringing its calendar mirror in line.
+// A calendar failure does not undo the change, it is reported in the response.
+func (h *APIHandler) respondTask(c echo.Context, status int, task entity.Task, mirror bool) error {
+	var calendarErr error
+	if mirror || task.GoogleCalendarEventID != "" {
+		calendarErr = syncTaskCalendar(h.CalendarService, &task, mirror)
+	}
+
+	res := newTaskResponse(task)
+	if calendarErr != nil {
+		c.Logger().Error("API task calendar sync failed: ", calendarErr)
+		res.CalendarError = "failed to update Google Calendar"
+		if isCalendarAuthError(calendarErr) {
+			res.CalendarError = "Google authentication expired, please login again"
+		}
+	}
+	return c.JSON(status, res)
+}
+
+// ListTasks returns the user's tasks, open ones first by due date
+func (h *APIHandler) ListTasks(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	filters, err := taskFilters(c)
+	if err != nil {
+		return apiParamError(c, err, "tasks")
+	}
+	return h.listTasks(c, userID, filters)
+}
+
+func (h *APIHandler) ListContactTasks(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	filters, err := taskFilters(c)
+	if err != nil {
+		return apiParamError(c, err, "tasks")
+	}
+	filters.ContactID = id
+	return h.listTasks(c, userID, filters)
+}
+
+func (h *APIHandler) listTasks(c echo.Context, userID uint, filters repository.TaskFilters) error {
+	tasks, err := h.Repo.GetTasks(userID, filters)
+	if err != nil {
+		return apiRepoError(c, err, "tasks")
+	}
+
+	res := make([]TaskResponse, 0, len(tasks))
+	for _, task := range tasks {
+		res = append(res, newTaskResponse(task))
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"tasks": res})
+}
+
+func (h *APIHandler) CreateTask(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var req TaskRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+
+	task, err := parseTask(userID, req.Title, req.Notes, req.DueOn, req.Priority)
+	if err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+	if len(req.ContactIDs) == 0 {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", "contact_ids must list at least one contact")
+	}
+	if req.Status != "" && req.Status != string(entity.TaskOpen) {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", "new tasks are open")
+	}
+	mirror := req.Calendar != nil && *req.Calendar
+	if mirror && task.DueOn.IsZero() {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", "a task needs a due_on date to be added to the calendar")
+	}
+
+	if err := h.Repo.CreateTask(task, req.ContactIDs); err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+	return h.respondTask(c, http.StatusCreated, *task, mirror)
+}
+
+func (h *APIHandler) GetTask(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	taskID, err := parseIDParam(c, "taskId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	task, err := h.Repo.GetTaskByID(taskID, userID)
+	if err != nil {
+		return apiRepoError(c, err, "task")
+	}
+	return c.JSON(http.StatusOK, newTaskResponse(task))
+}
+
+// UpdateTask replaces the fields of a task. The calendar mirror follows the
+// new due date and is removed when the task is done or has no due date.
+func (h *APIHandler) UpdateTask(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	taskID, err := parseIDParam(c, "taskId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	task, err := h.Repo.GetTaskByID(taskID, userID)
+	if err != nil {
+		return apiRepoError(c, err, "task")
+	}
+
+	var req TaskRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+	if err := applyTask(&task, req.Title, req.Notes, req.DueOn, req.Priority); err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+	if req.Status != "" {
+		status := entity.TaskStatus(req.Status)
+		if !status.Valid() {
+			return apiError(c, http.StatusUnprocessableEntity, "validation_failed", "status must be one of open, done")
+		}
+		setTaskStatus(&task, status)
+	}
+	if req.ContactIDs != nil && len(req.ContactIDs) == 0 {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", "contact_ids must list at least one contact")
+	}
+	mirror := task.GoogleCalendarEventID != ""
+	if req.Calendar != nil {
+		mirror = *req.Calendar
+	}
+
+	if err := h.Repo.UpdateTask(&task, req.ContactIDs); err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+	return h.respondTask(c, http.StatusOK, task, mirror)
+}
+
+// DeleteTask removes a task and its calendar mirror
+func (h *APIHandler) DeleteTask(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	taskID, err := parseIDParam(c, "taskId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	task, err := h.Repo.GetTaskByID(taskID, userID)
+	if err != nil {
+		return apiRepoError(c, err, "task")
+	}
+	if err := h.CalendarService.UnsyncTask(userID, &task); err != nil {
+		return apiCalendarError(c, err)
+	}
+
+	if err := h.Repo.DeleteTask(taskID, userID); err != nil {
+		return apiRepoError(c, err, "task")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// CompleteTask marks a task as done and removes its calendar mirror
+func (h *APIHandler) CompleteTask(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	taskID, err := parseIDParam(c, "taskId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	task, err := h.Repo.GetTaskByID(taskID, userID)
+	if err != nil {
+		return apiRepoError(c, err, "task")
+	}
+	setTaskStatus(&task, entity.TaskDone)
+
+	if err := h.Repo.UpdateTask(&task, nil); err != nil {
+		return apiRepoError(c, err, "task")
+	}
+	return h.respondTask(c, http.StatusOK, task, false)
+}
+
+// SnoozeTask moves the due date of a task, and of its calendar mirror, to the
+// end of the snooze
+func (h *APIHandler) SnoozeTask(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	taskID, err := parseIDParam(c, "taskId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	task, err := h.Repo.GetTaskByID(taskID, userID)
+	if err != nil {
+		return apiRepoError(c, err, "task")
+	}
+
+	var req SnoozeRequest
+	if err := c.Bind(&req); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "request body must be valid JSON")
+	}
+	if task.DueOn, err = req.until(); err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+
+	if err := h.Repo.UpdateTask(&task, nil); err != nil {
+		return apiRepoError(c, err, "task")
+	}
+	return h.respondTask(c, http.StatusOK, task, task.GoogleCalendarEventID != "")
+}
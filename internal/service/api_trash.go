@@ -0,0 +1,110 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// TrashedContactResponse is the JSON representation of a contact in the trash
+type TrashedContactResponse struct {
+	ID           uint      `json:"id"`
+	Name         string    `json:"name"`
+	Relationship string    `json:"relationship"`
+	Company      string    `json:"company"`
+	DeletedAt    time.Time `json:"deleted_at"`
+	PurgeOn      string    `json:"purge_on"`
+}
+
+// PurgeResponse reports how many contacts were permanently deleted
+type PurgeResponse struct {
+	Purged int `json:"purged"`
+}
+
+func (h *APIHandler) ListTrash(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	contacts, err := h.Repo.GetDeletedContacts(userID)
+	if err != nil {
+		return apiRepoError(c, err, "contacts")
+	}
+	res := make([]TrashedContactResponse, 0, len(contacts))
+	for _, contact := range contacts {
+		res = append(res, TrashedContactResponse{
+			ID:           contact.ID,
+			Name:         contact.Name,
+			Relationship: string(contact.Relationship),
+			Company:      contact.Company,
+			DeletedAt:    contact.DeletedAt.Time,
+			PurgeOn:      h.TrashService.purgeDate(contact.DeletedAt.Time).Format("2006-01-02"),
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"contacts":       res,
+		"retention_days": h.TrashService.RetentionDays,
+	})
+}
+
+// RestoreContact takes a contact out of the trash
+func (h *APIHandler) RestoreContact(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	n, err := h.Repo.RestoreContacts([]uint{id}, userID)
+	if err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+	if n == 0 {
+		return apiError(c, http.StatusNotFound, "not_found", "contact not found in the trash")
+	}
+
+	contact, err := h.Repo.GetContact(fmt.Sprintf("%d", id), userID)
+	if err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+	return c.JSON(http.StatusOK, newContactResponse(contact))
+}
+
+// PurgeContact permanently deletes a contact in the trash
+func (h *APIHandler) PurgeContact(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	purged, err := h.TrashService.Purge(c.Request().Context(), userID, []uint{id})
+	if err != nil {
+		if purged == nil {
+			return apiRepoError(c, err, "contact")
+		}
+		c.Logger().Warn("Contact purged but not cleaned up: ", err)
+	}
+	if len(purged) == 0 {
+		return apiError(c, http.StatusNotFound, "not_found", "contact not found in the trash")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// EmptyTrash permanently deletes every contact in the trash
+func (h *APIHandler) EmptyTrash(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	contacts, err := h.Repo.GetDeletedContacts(userID)
+	if err != nil {
+		return apiRepoError(c, err, "contacts")
+	}
+	purged, err := h.TrashService.Purge(c.Request().Context(), userID, contactIDsOf(contacts))
+	if err != nil {
+		if purged == nil {
+			return apiRepoError(c, err, "contacts")
+		}
+		c.Logger().Warn("Trash emptied but not cleaned up: ", err)
+	}
+	return c.JSON(http.StatusOK, PurgeResponse{Purged: len(purged)})
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/labstack/echo/v4"
+)
+
+// ImportPreviewResponse describes what importing one contact of a file would do
+type ImportPreviewResponse struct {
+	Index      int                 `json:"index"`
+	Name       string              `json:"name"`
+	Email      string              `json:"email"`
+	Phone      string              `json:"phone_number"`
+	Company    string              `json:"company"`
+	Tags       []string            `json:"tags"`
+	Error      string              `json:"error,omitempty"`
+	Duplicates []ImportDuplicateID `json:"duplicates"`
+	Action     string              `json:"action"`
+}
+
+// ImportDuplicateID is an existing contact an imported contact probably duplicates
+type ImportDuplicateID struct {
+	ID      uint     `json:"id"`
+	Name    string   `json:"name"`
+	Reasons []string `json:"reasons"`
+}
+
+// ImportVCard imports the .vcf file sent as the request body. on_duplicate
+// decides what happens to contacts matching an existing one: update (fill in
+// its empty fields, the default), skip or create. With dry_run=true nothing is
+// saved and the planned action for every contact is returned.
+func (h *APIHandler) ImportVCard(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	onDuplicate := c.QueryParam("on_duplicate")
+	switch onDuplicate {
+	case "":
+		onDuplicate = "update"
+	case "update", importSkip, importCreate:
+	default:
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", "on_duplicate must be update, skip or create")
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxImportSize+1))
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_body", "failed to read request body")
+	}
+	if len(body) > maxImportSize {
+		return apiError(c, http.StatusRequestEntityTooLarge, "too_large", fmt.Sprintf("the file is larger than %d MB", maxImportSize>>20))
+	}
+
+	candidates, err := planVCardImport(h.Repo, userID, body)
+	if err != nil {
+		return apiError(c, http.StatusUnprocessableEntity, "validation_failed", err.Error())
+	}
+
+	actions := map[int]string{}
+	for _, candidate := range candidates {
+		if len(candidate.Duplicates) > 0 && onDuplicate != "update" {
+			actions[candidate.Index] = onDuplicate
+		}
+	}
+
+	if c.QueryParam("dry_run") == "true" {
+		res := make([]ImportPreviewResponse, 0, len(candidates))
+		for _, candidate := range candidates {
+			action, ok := actions[candidate.Index]
+			if !ok || candidate.Error != "" {
+				action = candidate.defaultAction()
+			}
+			duplicates := make([]ImportDuplicateID, 0, len(candidate.Duplicates))
+			for _, duplicate := range candidate.Duplicates {
+				duplicates = append(duplicates, ImportDuplicateID{ID: duplicate.Contact.ID, Name: duplicate.Contact.Name, Reasons: duplicate.Reasons})
+			}
+			res = append(res, ImportPreviewResponse{
+				Index:      candidate.Index,
+				Name:       candidate.Contact.Name,
+				Email:      candidate.Contact.Email,
+				Phone:      candidate.Contact.PhoneNumber,
+				Company:    candidate.Contact.Company,
+				Tags:       append([]string{}, candidate.Tags...),
+				Error:      candidate.Error,
+				Duplicates: duplicates,
+				Action:     action,
+			})
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{"contacts": res})
+	}
+
+	result, err := applyImport(h.Repo, userID, candidates, actions, apiActor(c))
+	if err != nil {
+		return apiRepoError(c, err, "contacts")
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// ExportContactVCard returns a single contact as a .vcf file
+func (h *APIHandler) ExportContactVCard(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := parseIDParam(c, "id")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid_parameter", err.Error())
+	}
+
+	contact, err := h.Repo.GetContact(fmt.Sprintf("%d", id), userID)
+	if err != nil {
+		return apiRepoError(c, err, "contact")
+	}
+	return writeVCards(c, vcardFilename(contact.Name), []entity.Contact{contact})
+}
+
+// ExportVCard returns the contacts matching the same filters as ListContacts,
+// plus repeatable id parameters, as one .vcf file
+func (h *APIHandler) ExportVCard(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	filters, err := h.contactFilters(c, userID)
+	if err != nil {
+		return apiParamError(c, err, "contacts")
+	}
+	if ids := c.QueryParams()["id"]; len(ids) > 0 {
+		filters.IDs = parseContactIDs(ids)
+		if len(filters.IDs) != len(ids) {
+			return apiError(c, http.StatusBadRequest, "invalid_parameter", "id must be a contact id")
+		}
+	}
+
+	contacts, err := h.Repo.SearchContactsAdvanced(userID, filters)
+	if err != nil {
+		return apiRepoError(c, err, "contacts")
+	}
+	return writeVCards(c, "contacts.vcf", contacts)
+}
@@ -0,0 +1,367 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/La002/personal-crm/pkg/storage"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// defaultMaxUploadMB is the upload limit when none is configured
+const defaultMaxUploadMB = 10
+
+// attachmentTypes are the accepted file types, as detected from the content
+// of the file rather than the name or the type sent by the browser
+var attachmentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"text/plain":      true,
+}
+
+// Upload errors, reported to the client as they are
+var (
+	errAttachmentTooLarge = errors.New("file too large")
+	errAttachmentType     = errors.New("unsupported file type")
+	errNotAPhoto          = errors.New("the photo must be a JPEG, PNG or GIF image")
+)
+
+type AttachmentService struct {
+	Repo    repository.ContactDao
+	Storage storage.Storage
+	// MaxSize is the largest accepted upload in bytes
+	MaxSize int64
+}
+
+func NewAttachmentService(repo repository.ContactDao, store storage.Storage, maxUploadMB int) *AttachmentService {
+	if maxUploadMB <= 0 {
+		maxUploadMB = defaultMaxUploadMB
+	}
+	return &AttachmentService{
+		Repo:    repo,
+		Storage: store,
+		MaxSize: int64(maxUploadMB) << 20,
+	}
+}
+
+// attachmentError wraps an upload error with the detail shown to the user
+type attachmentError struct {
+	kind   error
+	detail string
+}
+
+func (e attachmentError) Error() string { return e.detail }
+func (e attachmentError) Unwrap() error { return e.kind }
+
+// Store checks an uploaded file and saves it as an attachment of the contact.
+// Images get a thumbnail; with asPhoto the attachment becomes the contact's
+// photo, which requires a thumbnail.
+func (s *AttachmentService) Store(ctx context.Context, userID, contactID uint, fileHeader *multipart.FileHeader, asPhoto bool) (entity.Attachment, error) {
+	if fileHeader.Size > s.MaxSize {
+		return entity.Attachment{}, attachmentError{errAttachmentTooLarge, fmt.Sprintf("the file is larger than %d MB", s.MaxSize>>20)}
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return entity.Attachment{}, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, s.MaxSize+1))
+	if err != nil {
+		return entity.Attachment{}, err
+	}
+	if int64(len(data)) > s.MaxSize {
+		return entity.Attachment{}, attachmentError{errAttachmentTooLarge, fmt.Sprintf("the file is larger than %d MB", s.MaxSize>>20)}
+	}
+
+	contentType := http.DetectContentType(data)
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if !attachmentTypes[mediaType] {
+		return entity.Attachment{}, attachmentError{errAttachmentType, "only images (JPEG, PNG, GIF, WebP), PDFs and text files can be attached"}
+	}
+
+	key := fmt.Sprintf("users/%d/contacts/%d/%s", userID, contactID, uuid.NewString())
+	attachment := entity.Attachment{
+		UserID:      userID,
+		ContactID:   contactID,
+		FileName:    attachmentFileName(fileHeader.Filename),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		StorageKey:  key,
+	}
+
+	// Images the thumbnailer cannot read, like WebP, are kept without thumbnail
+	var thumbnail []byte
+	if attachment.IsImage() {
+		if thumbnail, err = makeThumbnail(data); err == nil {
+			attachment.ThumbnailKey = key + "-thumb.jpg"
+		}
+	}
+	if asPhoto && attachment.ThumbnailKey == "" {
+		return entity.Attachment{}, attachmentError{errNotAPhoto, errNotAPhoto.Error()}
+	}
+
+	if err := s.Storage.Put(ctx, attachment.StorageKey, contentType, data); err != nil {
+		return entity.Attachment{}, err
+	}
+	if attachment.ThumbnailKey != "" {
+		if err := s.Storage.Put(ctx, attachment.ThumbnailKey, "image/jpeg", thumbnail); err != nil {
+			s.removeFiles(ctx, attachment)
+			return entity.Attachment{}, err
+		}
+	}
+
+	if err := s.Repo.CreateAttachment(&attachment, asPhoto); err != nil {
+		s.removeFiles(ctx, attachment)
+		return entity.Attachment{}, err
+	}
+	return attachment, nil
+}
+
+// Remove deletes an attachment and its files
+func (s *AttachmentService) Remove(ctx context.Context, attachment entity.Attachment) error {
+	if err := s.Repo.DeleteAttachment(attachment.ID, attachment.UserID); err != nil {
+		return err
+	}
+	// The record is gone, a file left behind is only wasted space
+	s.removeFiles(ctx, attachment)
+	return nil
+}
+
+func (s *AttachmentService) removeFiles(ctx context.Context, attachment entity.Attachment) {
+	for _, key := range []string{attachment.StorageKey, attachment.ThumbnailKey} {
+		if key != "" {
+			_ = s.Storage.Delete(ctx, key)
+		}
+	}
+}
+
+// serve sends the file stored under key. Images are shown inline, other
+// files are downloaded.
+func (s *AttachmentService) serve(c echo.Context, key, contentType, fileName string) error {
+	file, err := s.Storage.Get(c.Request().Context(), key)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	disposition := "attachment"
+	if strings.HasPrefix(contentType, "image/") {
+		disposition = "inline"
+	}
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentDisposition, mime.FormatMediaType(disposition, map[string]string{"filename": fileName}))
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Cache-Control", "private, max-age=86400")
+	return c.Stream(http.StatusOK, contentType, file)
+}
+
+// attachmentFileName keeps the base name of an uploaded file, which some
+// browsers send with its path. The name comes from the client: invalid UTF-8
+// and control characters are dropped, the templates escape the rest.
+func attachmentFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, strings.ToValidUTF8(name, ""))
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "" || name == "." || name == ".." || name == "/" {
+		return "attachment"
+	}
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// UploadAttachment attaches a file from the contact detail page. When it is
+// uploaded as the contact's photo the page is reloaded to show it.
+func (s *AttachmentService) UploadAttachment(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var contactID uint
+	if _, err := fmt.Sscan(c.Param("id"), &contactID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid contact ID")
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Choose a file to upload")
+	}
+	asPhoto := c.FormValue("photo") != ""
+
+	attachment, err := s.Store(c.Request().Context(), userID, contactID, fileHeader, asPhoto)
+	if err != nil {
+		var uploadErr attachmentError
+		if errors.As(err, &uploadErr) {
+			return c.String(http.StatusBadRequest, uploadErr.Error())
+		}
+		if isNotFoundError(err) {
+			return c.String(http.StatusNotFound, "Contact not found")
+		}
+		c.Logger().Error("Failed to store attachment: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to upload file")
+	}
+
+	if asPhoto {
+		c.Response().Header().Set("HX-Redirect", fmt.Sprintf("/contacts/%d", contactID))
+		return c.NoContent(http.StatusOK)
+	}
+	data := map[string]interface{}{
+		"Attachment": getAttachmentMap(attachment, false),
+	}
+	return c.Render(http.StatusOK, "attachment-created", data)
+}
+
+// DownloadAttachment sends the file of an attachment, or its thumbnail when
+// the thumbnail query parameter is set
+func (s *AttachmentService) DownloadAttachment(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var attachmentID uint
+	if _, err := fmt.Sscan(c.Param("attachmentId"), &attachmentID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid attachment ID")
+	}
+	attachment, err := s.Repo.GetAttachmentByID(attachmentID, userID)
+	if err != nil {
+		return c.String(http.StatusNotFound, "Attachment not found")
+	}
+
+	key, contentType := attachment.StorageKey, attachment.ContentType
+	if c.QueryParam("thumbnail") != "" && attachment.ThumbnailKey != "" {
+		key, contentType = attachment.ThumbnailKey, "image/jpeg"
+	}
+	if err := s.serve(c, key, contentType, attachment.FileName); err != nil {
+		c.Logger().Error("Failed to read attachment: ", err)
+		return c.String(http.StatusNotFound, "File not found")
+	}
+	return nil
+}
+
+// DeleteAttachment removes an attachment. Deleting the contact's photo
+// reloads the page.
+func (s *AttachmentService) DeleteAttachment(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var attachmentID uint
+	if _, err := fmt.Sscan(c.Param("attachmentId"), &attachmentID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid attachment ID")
+	}
+	attachment, err := s.Repo.GetAttachmentByID(attachmentID, userID)
+	if err != nil {
+		return c.String(http.StatusNotFound, "Attachment not found")
+	}
+	photo, photoErr := s.Repo.GetContactPhoto(attachment.ContactID, userID)
+
+	if err := s.Remove(c.Request().Context(), attachment); err != nil {
+		c.Logger().Error("Failed to delete attachment: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to delete attachment")
+	}
+
+	if photoErr == nil && photo.ID == attachment.ID {
+		c.Response().Header().Set("HX-Redirect", fmt.Sprintf("/contacts/%d", attachment.ContactID))
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// SetPhoto makes one of the contact's image attachments its photo
+func (s *AttachmentService) SetPhoto(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var contactID, attachmentID uint
+	if _, err := fmt.Sscan(c.Param("id"), &contactID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid contact ID")
+	}
+	if _, err := fmt.Sscan(c.FormValue("attachment_id"), &attachmentID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid attachment ID")
+	}
+	attachment, err := s.Repo.GetAttachmentByID(attachmentID, userID)
+	if err != nil || attachment.ContactID != contactID {
+		return c.String(http.StatusNotFound, "Attachment not found")
+	}
+	if attachment.ThumbnailKey == "" {
+		return c.String(http.StatusBadRequest, errNotAPhoto.Error())
+	}
+
+	if err := s.Repo.SetContactPhoto(contactID, userID, &attachment.ID); err != nil {
+		c.Logger().Error("Failed to set photo: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to set photo")
+	}
+	c.Response().Header().Set("HX-Redirect", fmt.Sprintf("/contacts/%d", contactID))
+	return c.NoContent(http.StatusOK)
+}
+
+// RemovePhoto stops showing a photo for the contact, the attachment is kept
+func (s *AttachmentService) RemovePhoto(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var contactID uint
+	if _, err := fmt.Sscan(c.Param("id"), &contactID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid contact ID")
+	}
+	if err := s.Repo.SetContactPhoto(contactID, userID, nil); err != nil {
+		c.Logger().Error("Failed to remove photo: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to remove photo")
+	}
+	c.Response().Header().Set("HX-Redirect", fmt.Sprintf("/contacts/%d", contactID))
+	return c.NoContent(http.StatusOK)
+}
+
+// ContactPhoto sends the avatar thumbnail of a contact
+func (s *AttachmentService) ContactPhoto(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var contactID uint
+	if _, err := fmt.Sscan(c.Param("id"), &contactID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid contact ID")
+	}
+	photo, err := s.Repo.GetContactPhoto(contactID, userID)
+	if err != nil {
+		return c.String(http.StatusNotFound, "No photo")
+	}
+	if err := s.serve(c, photo.ThumbnailKey, "image/jpeg", photo.FileName); err != nil {
+		c.Logger().Error("Failed to read photo: ", err)
+		return c.String(http.StatusNotFound, "No photo")
+	}
+	return nil
+}
+
+// getAttachmentMap prepares an attachment for display on its contact's page
+func getAttachmentMap(attachment entity.Attachment, isPhoto bool) map[string]interface{} {
+	return map[string]interface{}{
+		"Id":           attachment.ID,
+		"ContactId":    attachment.ContactID,
+		"FileName":     attachment.FileName,
+		"Size":         formatFileSize(attachment.Size),
+		"HasThumbnail": attachment.ThumbnailKey != "",
+		"IsPhoto":      isPhoto,
+		"CreatedAt":    attachment.CreatedAt.Format("2006-01-02"),
+	}
+}
+
+// formatFileSize writes a size in bytes for people, e.g. "1.5 MB"
+func formatFileSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.0f KB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", size)
+}
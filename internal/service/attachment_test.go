@@ -0,0 +1,31 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAttachmentFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"report.pdf", "report.pdf"},
+		{"  report.pdf ", "report.pdf"},
+		{"C:\\Users\\jane\\report.pdf", "report.pdf"},
+		{"/home/jane/report.pdf", "report.pdf"},
+		{"re\nport\x00.pdf", "report.pdf"},
+		{"caf\xe9.txt", "caf.txt"},
+		{"<img src=x onerror=alert(1)>.png", "<img src=x onerror=alert(1)>.png"},
+		{"", "attachment"},
+		{"/", "attachment"},
+		{"..", "attachment"},
+		{"\t\r\n", "attachment"},
+		{strings.Repeat("é", 200), strings.Repeat("é", 127)},
+	}
+	for _, tt := range tests {
+		if got := attachmentFileName(tt.name); got != tt.want {
+			t.Errorf("attachmentFileName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Bulk actions on the selected contacts
+const (
+	BulkDelete       = "delete"
+	BulkVip          = "vip"
+	BulkUnvip        = "unvip"
+	BulkRelationship = "relationship"
+	BulkTag          = "tag"
+	BulkUntag        = "untag"
+	BulkSyncCalendar = "sync_calendar"
+)
+
+// BulkRequest is a bulk action on several contacts. Relationship is the new
+// relationship of the relationship action, Tag the tag name of the tag and
+// untag actions.
+type BulkRequest struct {
+	Action       string `json:"action"`
+	ContactIDs   []uint `json:"contact_ids"`
+	Relationship string `json:"relationship,omitempty"`
+	Tag          string `json:"tag,omitempty"`
+}
+
+// bulkResult is the outcome of a bulk action for one contact
+type bulkResult struct {
+	ContactID uint   `json:"contact_id"`
+	Name      string `json:"name,omitempty"`
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+}
+
+// bulkSummary is the outcome of a bulk action, with a result for every
+// requested contact in the order they were given
+type bulkSummary struct {
+	Action    string       `json:"action"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Results   []bulkResult `json:"results"`
+}
+
+// validate checks the request and puts the relationship and tag in their
+// stored form. The relationship must be one of the user's relationship types.
+func (r *BulkRequest) validate(repo repository.ContactDao, userID uint) error {
+	if len(r.ContactIDs) == 0 {
+		return fmt.Errorf("select at least one contact")
+	}
+	switch r.Action {
+	case BulkDelete, BulkVip, BulkUnvip, BulkSyncCalendar:
+	case BulkRelationship:
+		if strings.TrimSpace(r.Relationship) == "" {
+			return fmt.Errorf("relationship is required")
+		}
+		relationship, err := resolveRelationship(repo, userID, r.Relationship)
+		if err != nil {
+			return err
+		}
+		r.Relationship = string(relationship)
+	case BulkTag, BulkUntag:
+		tag := entity.Tag{Name: r.Tag}
+		if err := validateTag(&tag); err != nil {
+			return err
+		}
+		r.Tag = tag.Name
+	default:
+		return fmt.Errorf("unknown action %q", r.Action)
+	}
+	return nil
+}
+
+// runBulkAction applies a validated bulk request. Database changes are made
+// in one transaction; calendar syncing talks to Google for every contact, so
+// each contact succeeds or fails on its own.
+func runBulkAction(repo repository.ContactDao, calendarService *CalendarService, userID uint, req BulkRequest, actor string) (bulkSummary, error) {
+	// The contacts found for the request, the ones in errs failed
+	var (
+		found []entity.Contact
+		errs  map[uint]string
+		err   error
+	)
+	switch req.Action {
+	case BulkDelete:
+		found, err = repo.DeleteContacts(req.ContactIDs, userID)
+	case BulkVip:
+		found, err = repo.UpdateContacts(req.ContactIDs, userID, map[string]interface{}{"vip": true}, actor)
+	case BulkUnvip:
+		// Like a single edit, the last update is only kept for VIPs
+		found, err = repo.UpdateContacts(req.ContactIDs, userID, map[string]interface{}{"vip": false, "last_update": nil}, actor)
+	case BulkRelationship:
+		found, err = repo.UpdateContacts(req.ContactIDs, userID, map[string]interface{}{"relationship": req.Relationship}, actor)
+	case BulkTag, BulkUntag:
+		found, errs, err = bulkTag(repo, userID, req)
+	case BulkSyncCalendar:
+		found, errs, err = bulkSyncCalendar(repo, calendarService, userID, req.ContactIDs)
+	}
+	if err != nil {
+		return bulkSummary{}, err
+	}
+
+	names := make(map[uint]string, len(found))
+	for _, contact := range found {
+		names[contact.ID] = contact.Name
+	}
+	summary := bulkSummary{Action: req.Action, Results: make([]bulkResult, 0, len(req.ContactIDs))}
+	for _, id := range req.ContactIDs {
+		name, ok := names[id]
+		result := bulkResult{ContactID: id, Name: name}
+		switch {
+		case !ok:
+			result.Error = "contact not found"
+		case errs[id] != "":
+			result.Error = errs[id]
+		default:
+			result.OK = true
+		}
+		if result.OK {
+			summary.Succeeded++
+		} else {
+			summary.Failed++
+		}
+		summary.Results = append(summary.Results, result)
+	}
+	return summary, nil
+}
+
+// bulkTag adds the tag of the request to the contacts, creating it if
+// needed, or removes it from them. It returns the contacts found and the ones
+// that failed.
+func bulkTag(repo repository.ContactDao, userID uint, req BulkRequest) ([]entity.Contact, map[uint]string, error) {
+	contacts, err := repo.SearchContactsAdvanced(userID, repository.ContactSearchFilters{IDs: req.ContactIDs})
+	if err != nil || len(contacts) == 0 {
+		return contacts, nil, err
+	}
+	ids := contactIDsOf(contacts)
+
+	if req.Action == BulkTag {
+		tag, err := findOrCreateTag(repo, userID, req.Tag, "")
+		if err != nil {
+			return nil, nil, err
+		}
+		_, err = repo.AddTagToContacts(tag.ID, userID, ids)
+		return contacts, nil, err
+	}
+
+	tag, err := repo.GetTagByName(req.Tag, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errs := map[uint]string{}
+		for _, id := range ids {
+			errs[id] = "unknown tag"
+		}
+		return contacts, errs, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = repo.RemoveTagFromContacts(tag.ID, userID, ids)
+	return contacts, nil, err
+}
+
+// bulkSyncCalendar adds the birthday of every contact to Google Calendar. It
+// returns the contacts found and why the ones that failed were not synced.
+// Once the Google authentication turns out to be expired the remaining
+// contacts are not tried.
+func bulkSyncCalendar(repo repository.ContactDao, calendarService *CalendarService, userID uint, contactIDs []uint) ([]entity.Contact, map[uint]string, error) {
+	contacts, err := repo.SearchContactsAdvanced(userID, repository.ContactSearchFilters{IDs: contactIDs})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	errs := map[uint]string{}
+	authExpired := false
+	for _, contact := range contacts {
+		switch {
+		case authExpired:
+			errs[contact.ID] = "Google authentication expired, please login again"
+		case contact.Birthday.IsZero():
+			errs[contact.ID] = "no birthday"
+		case contact.CalendarSyncEnabled:
+			errs[contact.ID] = "already synced"
+		default:
+			if err := calendarService.CreateBirthdayReminder(userID, fmt.Sprintf("%d", contact.ID)); err != nil {
+				errs[contact.ID] = "failed to sync calendar"
+				if isCalendarAuthError(err) {
+					authExpired = true
+					errs[contact.ID] = "Google authentication expired, please login again"
+				}
+			}
+		}
+	}
+	return contacts, errs, nil
+}
+
+type BulkService struct {
+	Repo            repository.ContactDao
+	CalendarService *CalendarService
+}
+
+func NewBulkService(repo repository.ContactDao, calendarService *CalendarService) *BulkService {
+	return &BulkService{
+		Repo:            repo,
+		CalendarService: calendarService,
+	}
+}
+
+// BulkContacts applies the action of the bulk bar to the selected contacts
+// and renders the per-contact summary. The contacts table reloads itself on
+// the contactsChanged event.
+func (s *BulkService) BulkContacts(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	form, err := c.FormParams()
+	if err != nil {
+		return err
+	}
+	req := BulkRequest{
+		Action:       c.FormValue("action"),
+		ContactIDs:   parseContactIDs(form["contact_ids"]),
+		Relationship: c.FormValue("relationship"),
+		Tag:          c.FormValue("tag"),
+	}
+	if err := req.validate(s.Repo, userID); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	summary, err := runBulkAction(s.Repo, s.CalendarService, userID, req, actorFromContext(c))
+	if err != nil {
+		c.Logger().Error("Bulk action failed: ", err)
+		return c.String(http.StatusInternalServerError, "Bulk action failed")
+	}
+
+	// The tag filter bar picks up newly created tags
+	trigger := "contactsChanged, tagsChanged"
+	if req.Action == BulkDelete && summary.Succeeded > 0 {
+		var ids []uint
+		name := ""
+		for _, result := range summary.Results {
+			if result.OK {
+				ids = append(ids, result.ContactID)
+				name = result.Name
+			}
+		}
+		if len(ids) > 1 {
+			name = ""
+		}
+		trigger = contactsDeletedTrigger(ids, name, "contactsChanged", "tagsChanged")
+	}
+	c.Response().Header().Set("HX-Trigger", trigger)
+	return c.Render(http.StatusOK, "bulk-result", summary)
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// defaultSnoozeDays is how long the queue's snooze button postpones a contact
+const defaultSnoozeDays = 7
+
+type CadenceService struct {
+	Repo repository.ContactDao
+}
+
+func NewCadenceService(repo repository.ContactDao) *CadenceService {
+	return &CadenceService{
+		Repo: repo,
+	}
+}
+
+// OverdueInfo is a contact in the keep-in-touch queue
+type OverdueInfo struct {
+	ID            uint   `json:"id"`
+	Name          string `json:"name"`
+	Company       string `json:"company"`
+	LastContacted string `json:"last_contacted"`
+	DaysSince     int    `json:"days_since"` // 0 when never contacted
+	CadenceDays   int    `json:"cadence_days"`
+	Cadence       string `json:"cadence"`
+	CadenceSource string `json:"cadence_source"` // "contact", "tag <name>" or "vip"
+	NextDue       string `json:"next_due"`
+	DaysOverdue   int    `json:"days_overdue"`
+}
+
+// keepInTouchQueue returns the contacts whose next due date is today or
+// earlier, the longest overdue first
+func keepInTouchQueue(repo repository.ContactDao, userID uint) ([]OverdueInfo, error) {
+	contacts, err := repo.GetContactsWithCadence(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	today := entity.Today()
+	queue := []OverdueInfo{}
+	for _, contact := range contacts {
+		due := contact.NextDue()
+		if due.IsZero() || today.Before(due) {
+			continue
+		}
+		days, source := contact.Cadence()
+		info := OverdueInfo{
+			ID:            contact.ID,
+			Name:          contact.Name,
+			Company:       contact.Company,
+			LastContacted: contact.LastContacted.String(),
+			CadenceDays:   days,
+			Cadence:       entity.CadenceLabel(days),
+			CadenceSource: source,
+			NextDue:       due.String(),
+			DaysOverdue:   due.DaysUntil(today),
+		}
+		if !contact.LastContacted.IsZero() {
+			info.DaysSince = contact.LastContacted.DaysUntil(today)
+		}
+		queue = append(queue, info)
+	}
+
+	sort.SliceStable(queue, func(i, j int) bool {
+		if queue[i].DaysOverdue != queue[j].DaysOverdue {
+			return queue[i].DaysOverdue > queue[j].DaysOverdue
+		}
+		return queue[i].Name < queue[j].Name
+	})
+	return queue, nil
+}
+
+// cadenceOptions lists the choices of a cadence select with the current value
+// selected. A value that is not a preset, e.g. set through the API, is kept
+// as an extra option. noneLabel names the empty choice.
+func cadenceOptions(current int, noneLabel string) []map[string]interface{} {
+	options := []map[string]interface{}{{"Value": 0, "Label": noneLabel, "Selected": current == 0}}
+	found := current == 0
+	for _, preset := range entity.CadencePresets {
+		if !found && current < preset.Days {
+			options = append(options, map[string]interface{}{"Value": current, "Label": entity.CadenceLabel(current), "Selected": true})
+			found = true
+		}
+		options = append(options, map[string]interface{}{"Value": preset.Days, "Label": preset.Label, "Selected": current == preset.Days})
+		found = found || current == preset.Days
+	}
+	if !found {
+		options = append(options, map[string]interface{}{"Value": current, "Label": entity.CadenceLabel(current), "Selected": true})
+	}
+	return options
+}
+
+// snoozeUntil reads the days form or query value of a snooze, defaulting to
+// defaultSnoozeDays, and returns the date the snooze ends
+func snoozeUntil(value string) (entity.Date, error) {
+	days := defaultSnoozeDays
+	if value != "" {
+		var err error
+		if days, err = strconv.Atoi(value); err != nil || days < 1 || days > entity.MaxCadenceDays {
+			return entity.Date{}, fmt.Errorf("days must be between 1 and %d", entity.MaxCadenceDays)
+		}
+	}
+	return entity.Today().AddDays(days), nil
+}
+
+// keepInTouchInteraction is the interaction logged when a contact in the
+// keep-in-touch queue is marked as done: today, a message unless another type
+// is given. It makes the contact due again one cadence from now.
+func keepInTouchInteraction(userID uint, interactionType, notes string) (*entity.Interaction, error) {
+	if interactionType == "" {
+		interactionType = string(entity.Message)
+	}
+	return parseInteraction(userID, interactionType, time.Now().Format(entity.DateLayout), notes)
+}
+
+// renderQueue re-renders the keep-in-touch widget of the dashboard
+func (s *CadenceService) renderQueue(c echo.Context, userID uint) error {
+	queue, err := keepInTouchQueue(s.Repo, userID)
+	if err != nil {
+		c.Logger().Error("Failed to get keep-in-touch queue: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to load the keep-in-touch queue")
+	}
+	return c.Render(http.StatusOK, "keep-in-touch", queue)
+}
+
+// SnoozeContact takes a contact off the keep-in-touch queue for a few days
+func (s *CadenceService) SnoozeContact(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var contactID uint
+	if _, err := fmt.Sscan(c.Param("id"), &contactID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid contact ID")
+	}
+	until, err := snoozeUntil(c.FormValue("days"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if err := s.Repo.SnoozeContact(contactID, userID, until); err != nil {
+		c.Logger().Error("Failed to snooze contact: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to snooze contact")
+	}
+	return s.renderQueue(c, userID)
+}
+
+// CompleteContact marks a contact in the keep-in-touch queue as done
+func (s *CadenceService) CompleteContact(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var contactID uint
+	if _, err := fmt.Sscan(c.Param("id"), &contactID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid contact ID")
+	}
+
+	interaction, err := keepInTouchInteraction(userID, c.FormValue("type"), c.FormValue("notes"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if err := s.Repo.CreateInteraction(interaction, []uint{contactID}); err != nil {
+		c.Logger().Error("Failed to log interaction: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to log interaction")
+	}
+	return s.renderQueue(c, userID)
+}
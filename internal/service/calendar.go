@@ -2,13 +2,17 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 
 	"github.com/La002/personal-crm/pkg/entity"
 	"github.com/La002/personal-crm/pkg/repository"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 )
 
@@ -58,13 +62,18 @@ func (s *CalendarService) CreateBirthdayReminder(userID uint, contactID string)
 		return fmt.Errorf("failed to fetch client")
 	}
 
+	// A birthday without year starts on its next occurrence and repeats from there
+	birthday := contact.Birthday
+	if !birthday.HasYear() {
+		birthday = birthday.Next(entity.Today())
+	}
 	event := &calendar.Event{
 		Summary: fmt.Sprintf("%s's Birthday", contact.Name),
 		Start: &calendar.EventDateTime{
-			Date: contact.Birthday,
+			Date: birthday.String(),
 		},
 		End: &calendar.EventDateTime{
-			Date: contact.Birthday,
+			Date: birthday.String(),
 		},
 		Recurrence: []string{"RRULE:FREQ=YEARLY"},
 	}
@@ -136,34 +145,94 @@ func (s *CalendarService) GetEventStatus(userID uint, contactID string) (bool, e
 }
 
 // Custom event methods
-func (s *CalendarService) CreateCustomEvent(userID, contactID uint, title, eventDate, recurrence string) error {
+func (s *CalendarService) CreateCustomEvent(userID, contactID uint, title string, eventDate entity.Date, recurrence string) (*entity.Event, error) {
 	user, err := s.UserRepo.GetUserByID(userID)
 	if err != nil {
-		return fmt.Errorf("failed to fetch user")
+		return nil, fmt.Errorf("failed to fetch user")
 	}
 
 	// Get contact to prefix name
 	contact, err := s.ContactRepo.GetContact(fmt.Sprintf("%d", contactID), userID)
 	if err != nil {
-		return fmt.Errorf("failed to fetch contact")
+		return nil, fmt.Errorf("failed to fetch contact")
 	}
 
 	client, err := s.getCalendarClient(&user)
 	if err != nil {
-		return fmt.Errorf("failed to get calendar client")
+		return nil, fmt.Errorf("failed to get calendar client")
 	}
 
-	// Prefix contact's name to event title
-	fullTitle := fmt.Sprintf("%s - %s", contact.Name, title)
+	createdEvent, err := client.Events.Insert("primary", customCalendarEvent(contact.Name, title, eventDate, recurrence)).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create calendar event: %w", err)
+	}
+
+	// Save event to database
+	event := &entity.Event{
+		UserID:                userID,
+		ContactID:             contactID,
+		Title:                 title, // Store original title without prefix
+		EventDate:             eventDate,
+		Recurrence:            recurrence,
+		GoogleCalendarEventID: createdEvent.Id,
+	}
+
+	if err := s.ContactRepo.CreateEvent(event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func (s *CalendarService) UpdateCustomEvent(userID, eventID uint, title string, eventDate entity.Date, recurrence string) (*entity.Event, error) {
+	event, err := s.ContactRepo.GetEventByID(eventID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	event.Title = title
+	event.EventDate = eventDate
+	event.Recurrence = recurrence
+
+	if event.GoogleCalendarEventID != "" {
+		user, err := s.UserRepo.GetUserByID(userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch user")
+		}
+
+		contact, err := s.ContactRepo.GetContact(fmt.Sprintf("%d", event.ContactID), userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch contact")
+		}
 
-	// Create event in Google Calendar
+		client, err := s.getCalendarClient(&user)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get calendar client")
+		}
+
+		calEvent := customCalendarEvent(contact.Name, title, eventDate, recurrence)
+		// An empty recurrence has to be sent explicitly to clear a previous rule
+		calEvent.ForceSendFields = []string{"Recurrence"}
+		if _, err := client.Events.Patch("primary", event.GoogleCalendarEventID, calEvent).Do(); err != nil {
+			return nil, fmt.Errorf("failed to update calendar event: %w", err)
+		}
+	}
+
+	if err := s.ContactRepo.SaveEvent(&event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// customCalendarEvent builds the Google Calendar representation of a custom event
+func customCalendarEvent(contactName, title string, eventDate entity.Date, recurrence string) *calendar.Event {
+	// Prefix contact's name to event title
 	calEvent := &calendar.Event{
-		Summary: fullTitle,
+		Summary: fmt.Sprintf("%s - %s", contactName, title),
 		Start: &calendar.EventDateTime{
-			Date: eventDate,
+			Date: eventDate.String(),
 		},
 		End: &calendar.EventDateTime{
-			Date: eventDate,
+			Date: eventDate.String(),
 		},
 	}
 
@@ -174,22 +243,7 @@ func (s *CalendarService) CreateCustomEvent(userID, contactID uint, title, event
 		calEvent.Recurrence = []string{"RRULE:FREQ=YEARLY"}
 	}
 
-	createdEvent, err := client.Events.Insert("primary", calEvent).Do()
-	if err != nil {
-		return fmt.Errorf("failed to create calendar event: %w", err)
-	}
-
-	// Save event to database
-	event := &entity.Event{
-		UserID:                userID,
-		ContactID:             contactID,
-		Title:                 title, // Store original title without prefix
-		EventDate:             eventDate,
-		Recurrence:            recurrence,
-		GoogleCalendarEventID: createdEvent.Id,
-	}
-
-	return s.ContactRepo.CreateEvent(event)
+	return calEvent
 }
 
 func (s *CalendarService) DeleteCustomEvent(userID, eventID uint) error {
@@ -223,6 +277,195 @@ func (s *CalendarService) DeleteCustomEvent(userID, eventID uint) error {
 	return s.ContactRepo.DeleteEvent(eventID, userID)
 }
 
+// Household event methods
+
+// CreateHouseholdEvent adds an event shared by the members of a household,
+// like an anniversary, to the calendar under the household's name
+func (s *CalendarService) CreateHouseholdEvent(userID, householdID uint, title string, eventDate entity.Date, recurrence string) (*entity.HouseholdEvent, error) {
+	user, err := s.UserRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user")
+	}
+
+	household, err := s.ContactRepo.GetHouseholdByID(householdID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch household")
+	}
+
+	client, err := s.getCalendarClient(&user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get calendar client")
+	}
+
+	createdEvent, err := client.Events.Insert("primary", customCalendarEvent(household.Name, title, eventDate, recurrence)).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create calendar event: %w", err)
+	}
+
+	event := &entity.HouseholdEvent{
+		UserID:                userID,
+		HouseholdID:           householdID,
+		Title:                 title,
+		EventDate:             eventDate,
+		Recurrence:            recurrence,
+		GoogleCalendarEventID: createdEvent.Id,
+	}
+	if err := s.ContactRepo.CreateHouseholdEvent(event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func (s *CalendarService) DeleteHouseholdEvent(userID, eventID uint) error {
+	event, err := s.ContactRepo.GetHouseholdEventByID(eventID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch event")
+	}
+
+	if err := s.DeleteCalendarEvents(userID, householdCalendarEventIDs([]entity.HouseholdEvent{event})); err != nil {
+		return err
+	}
+	return s.ContactRepo.DeleteHouseholdEvent(eventID, userID)
+}
+
+// DeleteHousehold deletes a household together with its events, which are
+// removed from the calendar first
+func (s *CalendarService) DeleteHousehold(userID, householdID uint) error {
+	household, err := s.ContactRepo.GetHouseholdByID(householdID, userID)
+	if err != nil {
+		return fmt.Errorf("no household found with id %d", householdID)
+	}
+
+	if err := s.DeleteCalendarEvents(userID, householdCalendarEventIDs(household.Events)); err != nil {
+		return err
+	}
+	return s.ContactRepo.DeleteHousehold(householdID, userID)
+}
+
+// householdCalendarEventIDs returns the calendar events of household events
+func householdCalendarEventIDs(events []entity.HouseholdEvent) []string {
+	var ids []string
+	for _, event := range events {
+		if event.GoogleCalendarEventID != "" {
+			ids = append(ids, event.GoogleCalendarEventID)
+		}
+	}
+	return ids
+}
+
+// Task methods
+
+// SyncTask mirrors a task with a due date as an all-day event on its due
+// date, creating the event or updating the one the task already has. The
+// contacts of the task must be loaded, their names go into the event title.
+func (s *CalendarService) SyncTask(userID uint, task *entity.Task) error {
+	if task.DueOn.IsZero() {
+		return fmt.Errorf("task has no due date")
+	}
+	user, err := s.UserRepo.GetUserByID(userID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch user")
+	}
+	client, err := s.getCalendarClient(&user)
+	if err != nil {
+		return fmt.Errorf("failed to get calendar client: %w", err)
+	}
+
+	calEvent := taskCalendarEvent(*task)
+	if task.GoogleCalendarEventID != "" {
+		if _, err := client.Events.Patch("primary", task.GoogleCalendarEventID, calEvent).Do(); err != nil {
+			return fmt.Errorf("failed to update calendar event: %w", err)
+		}
+		return nil
+	}
+
+	createdEvent, err := client.Events.Insert("primary", calEvent).Do()
+	if err != nil {
+		return fmt.Errorf("failed to create calendar event: %w", err)
+	}
+	task.GoogleCalendarEventID = createdEvent.Id
+	return s.ContactRepo.UpdateTaskGoogleID(task.ID, userID, createdEvent.Id)
+}
+
+// UnsyncTask deletes the calendar event mirroring a task
+func (s *CalendarService) UnsyncTask(userID uint, task *entity.Task) error {
+	if task.GoogleCalendarEventID == "" {
+		return nil
+	}
+	user, err := s.UserRepo.GetUserByID(userID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch user")
+	}
+	client, err := s.getCalendarClient(&user)
+	if err != nil {
+		return fmt.Errorf("failed to get calendar client: %w", err)
+	}
+
+	if err := client.Events.Delete("primary", task.GoogleCalendarEventID).Do(); err != nil {
+		return fmt.Errorf("failed to delete calendar event: %w", err)
+	}
+	task.GoogleCalendarEventID = ""
+	return s.ContactRepo.UpdateTaskGoogleID(task.ID, userID, "")
+}
+
+// DeleteCalendarEvents removes events from the user's Google Calendar, such
+// as the events of a purged contact. Events that are already gone are skipped;
+// the other events are tried even when one fails.
+func (s *CalendarService) DeleteCalendarEvents(userID uint, eventIDs []string) error {
+	if len(eventIDs) == 0 {
+		return nil
+	}
+	user, err := s.UserRepo.GetUserByID(userID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch user")
+	}
+	client, err := s.getCalendarClient(&user)
+	if err != nil {
+		return fmt.Errorf("failed to get calendar client: %w", err)
+	}
+
+	var errs []error
+	for _, eventID := range eventIDs {
+		err := client.Events.Delete("primary", eventID).Do()
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && (apiErr.Code == http.StatusNotFound || apiErr.Code == http.StatusGone) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete calendar event %s: %w", eventID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// taskCalendarEvent builds the Google Calendar representation of a task
+func taskCalendarEvent(task entity.Task) *calendar.Event {
+	names := make([]string, 0, len(task.Contacts))
+	for _, contact := range task.Contacts {
+		names = append(names, contact.Name)
+	}
+	summary := "To do: " + task.Title
+	if len(names) > 0 {
+		summary = fmt.Sprintf("%s (%s)", summary, strings.Join(names, ", "))
+	}
+	return &calendar.Event{
+		Summary:     summary,
+		Description: task.Notes,
+		Start: &calendar.EventDateTime{
+			Date: task.DueOn.String(),
+		},
+		End: &calendar.EventDateTime{
+			Date: task.DueOn.String(),
+		},
+	}
+}
+
+// isCalendarAuthError reports whether err was caused by missing or expired Google credentials
+func isCalendarAuthError(err error) bool {
+	errMsg := err.Error()
+	return strings.Contains(errMsg, "must re-authenticate") || strings.Contains(errMsg, "refresh token")
+}
+
 func (s *CalendarService) getCalendarClient(user *entity.User) (*calendar.Service, error) {
 	ctx := context.Background()
 
@@ -3,8 +3,8 @@ package service
 import (
 	"fmt"
 	"net/http"
-	"strings"
 
+	"github.com/La002/personal-crm/pkg/entity"
 	"github.com/labstack/echo/v4"
 )
 
@@ -27,8 +27,7 @@ func (h *CalendarHandler) SyncBirthdayToCalendar(c echo.Context) error {
 		c.Logger().Error("Failed to create calendar reminder: ", err)
 
 		// Check if it's an authentication error
-		errMsg := err.Error()
-		if strings.Contains(errMsg, "must re-authenticate") || strings.Contains(errMsg, "refresh token") {
+		if isCalendarAuthError(err) {
 			return c.String(401, "Authentication expired. Please logout and login again.")
 		}
 
@@ -55,8 +54,7 @@ func (h *CalendarHandler) DeleteCalendarSync(c echo.Context) error {
 		c.Logger().Error("Failed to delete calendar reminder: ", err)
 
 		// Check if it's an authentication error
-		errMsg := err.Error()
-		if strings.Contains(errMsg, "must re-authenticate") || strings.Contains(errMsg, "refresh token") {
+		if isCalendarAuthError(err) {
 			return c.String(401, "Authentication expired. Please logout and login again.")
 		}
 
@@ -92,10 +90,13 @@ func (h *CalendarHandler) CreateCustomEvent(c echo.Context) error {
 	userID := c.Get("user_id").(uint)
 
 	title := c.FormValue("title")
-	eventDate := c.FormValue("event_date")
+	eventDate, err := entity.ParseDate(c.FormValue("event_date"))
 	recurrence := c.FormValue("recurrence")
 
-	if title == "" || eventDate == "" {
+	if err != nil {
+		return c.String(400, "Invalid event date: "+err.Error())
+	}
+	if title == "" || eventDate.IsZero() {
 		return c.String(400, "Title and event date are required")
 	}
 
@@ -106,33 +107,23 @@ func (h *CalendarHandler) CreateCustomEvent(c echo.Context) error {
 
 	// Parse contactID to uint
 	var cID uint
-	_, err := fmt.Sscan(contactID, &cID)
+	_, err = fmt.Sscan(contactID, &cID)
 	if err != nil {
 		return c.String(400, "Invalid contact ID")
 	}
 
-	err = h.CalendarService.CreateCustomEvent(userID, cID, title, eventDate, recurrence)
+	newEvent, err := h.CalendarService.CreateCustomEvent(userID, cID, title, eventDate, recurrence)
 	if err != nil {
 		c.Logger().Error("Failed to create custom event: ", err)
 
 		// Check if it's an authentication error
-		errMsg := err.Error()
-		if strings.Contains(errMsg, "must re-authenticate") || strings.Contains(errMsg, "refresh token") {
+		if isCalendarAuthError(err) {
 			return c.String(401, "Authentication expired. Please logout and login again.")
 		}
 
 		return c.String(500, "Failed to create event. Please try again.")
 	}
 
-	// Fetch the newly created event to render
-	events, err := h.CalendarService.ContactRepo.GetEventsByContact(cID, userID)
-	if err != nil || len(events) == 0 {
-		return c.String(500, "Failed to fetch created event")
-	}
-
-	// Get the last event (the one we just created)
-	newEvent := events[len(events)-1]
-
 	// Return just the event row HTML for HTMX to append
 	return c.Render(http.StatusOK, "event-row", newEvent)
 }
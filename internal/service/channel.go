@@ -0,0 +1,91 @@
+package service
+
+import (
+	"strconv"
+
+	"github.com/La002/personal-crm/pkg/entity"
+)
+
+// channelsFromForm reads the repeated rows of one channel kind from the edit
+// form, e.g. email_label and email_value. The email_primary radio button holds
+// the index of the primary row. ok is false when the form has no such rows.
+func channelsFromForm(form map[string][]string, kind entity.ChannelKind) (channels []entity.ContactChannel, ok bool) {
+	prefix := string(kind) + "_"
+	values, ok := form[prefix+"value"]
+	if !ok {
+		return nil, false
+	}
+	labels := form[prefix+"label"]
+	primary := formIndex(form, prefix+"primary")
+
+	channels = []entity.ContactChannel{}
+	for i, value := range values {
+		channel := entity.ContactChannel{Kind: kind, Value: value, Primary: i == primary}
+		if i < len(labels) {
+			channel.Label = labels[i]
+		}
+		channels = append(channels, channel)
+	}
+	return channels, true
+}
+
+// addressesFromForm reads the repeated address rows of the edit form. ok is
+// false when the form has no address rows.
+func addressesFromForm(form map[string][]string) (addresses []entity.ContactAddress, ok bool) {
+	streets, ok := form["address_street"]
+	if !ok {
+		return nil, false
+	}
+	primary := formIndex(form, "address_primary")
+	field := func(name string, i int) string {
+		if values := form["address_"+name]; i < len(values) {
+			return values[i]
+		}
+		return ""
+	}
+
+	addresses = []entity.ContactAddress{}
+	for i, street := range streets {
+		addresses = append(addresses, entity.ContactAddress{
+			Label:      field("label", i),
+			Street:     street,
+			City:       field("city", i),
+			Region:     field("region", i),
+			PostalCode: field("postal_code", i),
+			Country:    field("country", i),
+			Primary:    i == primary,
+		})
+	}
+	return addresses, true
+}
+
+// formIndex returns the row index submitted in a radio group, or -1
+func formIndex(form map[string][]string, name string) int {
+	if values := form[name]; len(values) > 0 {
+		if i, err := strconv.Atoi(values[0]); err == nil {
+			return i
+		}
+	}
+	return -1
+}
+
+// editableChannels adds the channel rows of the edit form to a contact map.
+// Every list gets at least one row, so there is always an input to fill in.
+func editableChannels(res map[string]interface{}, contact entity.Contact) {
+	emails := contact.ChannelsOf(entity.EmailChannel)
+	if len(emails) == 0 {
+		emails = []entity.ContactChannel{{Kind: entity.EmailChannel, Primary: true}}
+	}
+	phones := contact.ChannelsOf(entity.PhoneChannel)
+	if len(phones) == 0 {
+		phones = []entity.ContactChannel{{Kind: entity.PhoneChannel, Primary: true}}
+	}
+	addresses := contact.Addresses
+	if len(addresses) == 0 {
+		addresses = []entity.ContactAddress{{Primary: true}}
+	}
+	res["Emails"] = emails
+	res["Phones"] = phones
+	res["Addresses"] = addresses
+	res["ChannelLabels"] = entity.ChannelLabels
+}
@@ -1,14 +1,19 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"html"
+	"html/template"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/La002/personal-crm/pkg/entity"
 	"github.com/La002/personal-crm/pkg/repository"
 	"github.com/labstack/echo/v4"
-	"gorm.io/gorm"
 )
 
 type ContactService struct {
@@ -21,19 +26,21 @@ func NewContactService(repo repository.ContactDao) *ContactService {
 	}
 }
 
-// GetAllContacts retrieves all contacts and renders them as HTML for HTMX
+// GetAllContacts renders the contacts page with the first page of the list
 func (s *ContactService) GetAllContacts(c echo.Context) error {
-	var res []map[string]interface{}
 	userID := c.Get("user_id").(uint)
 
-	contacts, err := s.Repo.GetAllContacts(userID)
+	list, err := readContactList(c, s.Repo, userID)
 	if err != nil {
-		return err
+		return c.String(http.StatusBadRequest, err.Error())
 	}
-
-	for _, contact := range contacts {
-		mp := getContactMapShort(contact)
-		res = append(res, mp)
+	list.Page.Cursor = ""
+	table, err := contactTable(s.Repo, userID, list)
+	if isContactListError(err) {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return err
 	}
 
 	// Get user email from context (set by middleware)
@@ -42,100 +49,324 @@ func (s *ContactService) GetAllContacts(c echo.Context) error {
 		email, _ = emailVal.(string)
 	}
 
+	defs, err := s.Repo.GetCustomFieldDefinitions(userID)
+	if err != nil {
+		return err
+	}
+	relationships, err := relationshipNames(s.Repo, userID)
+	if err != nil {
+		return err
+	}
+
 	data := map[string]interface{}{
-		"Contacts": res,
-		"Email":    email,
+		"Table":         table,
+		"Email":         email,
+		"CustomFields":  customFieldViews(defs, nil),
+		"Relationships": relationships,
 	}
 	return c.Render(http.StatusOK, "contacts", data)
 }
 
+// SearchContacts renders the contacts table for a full-text search, or for a
+// filter, sort and page of the list. With a cursor only the rows of the next
+// page are rendered, for the infinite scroll of the table.
 func (s *ContactService) SearchContacts(c echo.Context) error {
-	filter := c.QueryParam("filter")
 	userID := c.Get("user_id").(uint)
 
-	var contacts []entity.Contact
-	var err error
-	switch filter {
-	case "vip":
-		contacts, err = s.Repo.FilterContactsByVipStatus(true, userID)
-	case "non-vip":
-		contacts, err = s.Repo.FilterContactsByVipStatus(false, userID)
-	case "Friend", "Family", "Colleague", "School", "Network", "Services":
-		contacts, err = s.Repo.SearchContactsByRelationship(filter, userID)
-	default:
-		contacts, err = s.Repo.GetAllContacts(userID)
+	// Free-text search, ranked with highlighted snippets
+	if q := strings.TrimSpace(c.QueryParam("q")); q != "" {
+		results, err := s.Repo.SearchContactsFullText(userID, q, repository.ContactSearchFilters{})
+		if err != nil {
+			return err
+		}
+
+		var res []map[string]interface{}
+		for _, result := range results {
+			mp := getContactMapShort(result.Contact)
+			mp["Snippet"] = template.HTML(highlightSnippet(result.Snippet))
+			res = append(res, mp)
+		}
+		relationships, err := relationshipNames(s.Repo, userID)
+		if err != nil {
+			return err
+		}
+		table := map[string]interface{}{
+			"Contacts":      res,
+			"Columns":       sortColumns(contactList{}),
+			"Relationships": relationships,
+		}
+		return c.Render(http.StatusOK, "table-content", table)
 	}
 
+	list, err := readContactList(c, s.Repo, userID)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	table, err := contactTable(s.Repo, userID, list)
+	if isContactListError(err) {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
 	if err != nil {
 		return err
 	}
+	if list.Page.Cursor != "" {
+		return c.Render(http.StatusOK, "contact-rows", table)
+	}
+	return c.Render(http.StatusOK, "table-content", table)
+}
+
+// contactList is a filter, order and page of the contacts table
+type contactList struct {
+	Filters repository.ContactSearchFilters
+	Page    repository.ContactPageRequest
+	query   url.Values // the filter parameters, repeated in the links of the table
+}
+
+// readContactList reads the list (a smart list id), filter (vip, non-vip or
+// a relationship), tag, field and value, sort, order and cursor query
+// parameters
+func readContactList(c echo.Context, repo repository.ContactDao, userID uint) (contactList, error) {
+	list := contactList{query: url.Values{}}
+
+	if id := c.QueryParam("list"); id != "" {
+		var listID uint
+		if _, err := fmt.Sscan(id, &listID); err != nil {
+			return list, fmt.Errorf("invalid smart list ID")
+		}
+		filters, err := smartListContactFilters(repo, userID, listID)
+		if err != nil {
+			return list, fmt.Errorf("smart list not found")
+		}
+		list.Filters = filters
+		list.query.Set("list", id)
+	}
+
+	switch filter := c.QueryParam("filter"); filter {
+	case "":
+	case "vip", "non-vip":
+		vipOnly := filter == "vip"
+		list.Filters.VipOnly = &vipOnly
+		list.query.Set("filter", filter)
+	default:
+		list.Filters.Relationship = &filter
+		list.query.Set("filter", filter)
+	}
+	if tag := c.QueryParam("tag"); tag != "" {
+		list.Filters.Tags = append(list.Filters.Tags, tag)
+		list.query.Set("tag", tag)
+	}
+	if field := c.QueryParam("field"); field != "" {
+		if list.Filters.CustomFields == nil {
+			list.Filters.CustomFields = map[string]string{}
+		}
+		list.Filters.CustomFields[field] = c.QueryParam("value")
+		list.query.Set("field", field)
+		list.query.Set("value", c.QueryParam("value"))
+	}
+
+	list.Page.Sort = c.QueryParam("sort")
+	if list.Page.Sort != "" && !repository.IsContactSort(list.Page.Sort) {
+		return list, fmt.Errorf("sort must be one of %s", strings.Join(repository.ContactSorts, ", "))
+	}
+	switch c.QueryParam("order") {
+	case "", "asc":
+	case "desc":
+		list.Page.Desc = true
+	default:
+		return list, fmt.Errorf("order must be asc or desc")
+	}
+	list.Page.Cursor = c.QueryParam("cursor")
+	return list, nil
+}
+
+// contactTable loads a page of the list for the table-content and
+// contact-rows templates, with the relationship types offered for a new contact
+func contactTable(repo repository.ContactDao, userID uint, list contactList) (map[string]interface{}, error) {
+	page, err := repo.ListContactsPage(userID, list.Filters, list.Page)
+	if err != nil {
+		return nil, err
+	}
+	relationships, err := relationshipNames(repo, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	var res []map[string]interface{}
-	for _, contact := range contacts {
-		mp := getContactMapShort(contact)
-		res = append(res, mp)
+	for _, contact := range page.Contacts {
+		res = append(res, getContactMapShort(contact))
 	}
 
-	return c.Render(http.StatusOK, "table-content", res)
+	nextURL := ""
+	if page.NextCursor != "" {
+		next := list.url(list.Page.Sort, list.Page.Desc)
+		nextURL = next + "&cursor=" + url.QueryEscape(page.NextCursor)
+	}
+	return map[string]interface{}{
+		"Contacts":      res,
+		"Columns":       sortColumns(list),
+		"NextURL":       nextURL,
+		"Relationships": relationships,
+	}, nil
+}
+
+// isContactListError reports whether err comes from the requested list rather
+// than the server: a stale cursor, or a value that does not fit the custom field
+func isContactListError(err error) bool {
+	return errors.Is(err, repository.ErrInvalidCursor) || errors.Is(err, repository.ErrInvalidFilter)
+}
+
+// url links to the list with the same filter in another order
+func (list contactList) url(sort string, desc bool) string {
+	values := url.Values{}
+	for key, value := range list.query {
+		values[key] = value
+	}
+	if sort != "" {
+		values.Set("sort", sort)
+	}
+	if desc {
+		values.Set("order", "desc")
+	}
+	return "/contacts/search?" + values.Encode()
+}
+
+// sortLink is the link of a sortable column header of the contacts table
+type sortLink struct {
+	URL   string
+	Arrow string // the direction, on the column the table is sorted by
+}
+
+// sortColumns links every sortable column of the table. The sorted column
+// switches direction, the others start ascending except the newest first
+// creation date and the strongest first relationship strength.
+func sortColumns(list contactList) map[string]sortLink {
+	current := list.Page.Sort
+	if current == "" {
+		current = repository.ContactSorts[0]
+	}
+	columns := map[string]sortLink{}
+	for _, sort := range repository.ContactSorts {
+		switch {
+		case sort == current && list.Page.Desc:
+			columns[sort] = sortLink{URL: list.url(sort, false), Arrow: "▼"}
+		case sort == current:
+			columns[sort] = sortLink{URL: list.url(sort, true), Arrow: "▲"}
+		default:
+			columns[sort] = sortLink{URL: list.url(sort, sort == "created" || sort == "strength")}
+		}
+	}
+	return columns
+}
+
+// contactOptionsLimit is the number of contacts offered at once by a contact
+// select, the others are found with its search box
+const contactOptionsLimit = 50
+
+// ContactOptions renders the options of a contact select for the contacts
+// whose name contains q. Contacts already picked in a "with" field stay first
+// and selected.
+func (s *ContactService) ContactOptions(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	selected := parseContactIDs(c.QueryParams()["with"])
+	exclude := append(parseContactIDs(c.QueryParams()["exclude"]), selected...)
+
+	names, err := s.Repo.SearchContactNames(userID, c.QueryParam("q"), exclude, contactOptionsLimit)
+	if err != nil {
+		c.Logger().Error("Failed to search contact names: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to search contacts")
+	}
+
+	var options []map[string]interface{}
+	if len(selected) > 0 {
+		picked, err := s.Repo.SearchContactsAdvanced(userID, repository.ContactSearchFilters{IDs: selected})
+		if err != nil {
+			c.Logger().Error("Failed to fetch selected contacts: ", err)
+			return c.String(http.StatusInternalServerError, "Failed to search contacts")
+		}
+		for _, contact := range picked {
+			options = append(options, map[string]interface{}{"Id": contact.ID, "Name": contact.Name, "Selected": true})
+		}
+	}
+	options = append(options, contactOptions(names)...)
+	return c.Render(http.StatusOK, "contact-options", options)
+}
+
+// contactOptions is the template data of the options of a contact select
+func contactOptions(names []repository.ContactName) []map[string]interface{} {
+	var options []map[string]interface{}
+	for _, name := range names {
+		options = append(options, map[string]interface{}{"Id": name.ID, "Name": name.Name})
+	}
+	return options
+}
+
+// contactOptionsURL searches the options of a contact select, leaving out
+// the contacts in exclude
+func contactOptionsURL(exclude []uint) string {
+	values := url.Values{}
+	for _, id := range exclude {
+		values.Add("exclude", strconv.FormatUint(uint64(id), 10))
+	}
+	return "/contacts/options?" + values.Encode()
 }
 
 func (s *ContactService) AddContact(c echo.Context) error {
 	vipValue := c.FormValue("vip") == "on"
 	userID := c.Get("user_id").(uint)
 
+	birthday, err := entity.ParseBirthday(c.FormValue("birthday"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Invalid birthday: "+err.Error())
+	}
+	relationship, err := resolveRelationship(s.Repo, userID, c.FormValue("relationship"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
 	newContact := &entity.Contact{
 		Name:         c.FormValue("name"),
-		Relationship: entity.Relation(c.FormValue("relationship")),
+		Relationship: relationship,
 		Industry:     c.FormValue("industry"),
 		Company:      c.FormValue("company"),
-		Birthday:     c.FormValue("birthday"),
+		Birthday:     birthday,
 		Vip:          vipValue,
 		UserID:       userID,
 	}
 	if vipValue {
-		newContact.LastContacted = c.FormValue("last_contacted")
-		newContact.LastMet = c.FormValue("last_met")
-		newContact.LastUpdate = c.FormValue("last_update")
+		if newContact.LastUpdate, err = entity.ParseDate(c.FormValue("last_update")); err != nil {
+			return c.String(http.StatusBadRequest, "Invalid last update: "+err.Error())
+		}
 	}
 
 	if err := s.Repo.CreateContact(newContact); err != nil {
 		return err
 	}
 
-	var res []map[string]interface{}
-
-	contacts, err := s.Repo.GetAllContacts(userID)
+	table, err := contactTable(s.Repo, userID, contactList{})
 	if err != nil {
 		return err
 	}
-
-	for _, contact := range contacts {
-		mp := getContactMapShort(contact)
-		res = append(res, mp)
-	}
-
-	return c.Render(http.StatusOK, "table-content", res)
+	return c.Render(http.StatusOK, "table-content", table)
 }
 
+// DeleteContact moves a contact to the trash, the contacts page offers to undo it
 func (s *ContactService) DeleteContact(c echo.Context) error {
 	id := c.Param("id")
 	userID := c.Get("user_id").(uint)
 
+	contact, err := s.Repo.GetContact(id, userID)
+	if err != nil {
+		return err
+	}
 	if err := s.Repo.DeleteContact(id, userID); err != nil {
 		return err
 	}
 
-	contacts, err := s.Repo.GetAllContacts(userID)
+	table, err := contactTable(s.Repo, userID, contactList{})
 	if err != nil {
 		return err
 	}
-
-	var res []map[string]interface{}
-	for _, contact := range contacts {
-		mp := getContactMapShort(contact)
-		res = append(res, mp)
-	}
-
-	return c.Render(http.StatusOK, "table-content", res)
+	c.Response().Header().Set("HX-Trigger", contactsDeletedTrigger([]uint{contact.ID}, contact.Name))
+	return c.Render(http.StatusOK, "table-content", table)
 }
 
 func (s *ContactService) GetContact(c echo.Context) error {
@@ -154,8 +385,94 @@ func (s *ContactService) GetContact(c echo.Context) error {
 		events = []entity.Event{} // Empty events on error
 	}
 
+	interactions, err := s.Repo.GetInteractionsByContact(contact.ID, userID)
+	if err != nil {
+		c.Logger().Error("Failed to fetch interactions: ", err)
+		interactions = []entity.Interaction{}
+	}
+	var interactionRows []map[string]interface{}
+	for _, interaction := range interactions {
+		interactionRows = append(interactionRows, getInteractionMap(interaction, contact.ID))
+	}
+
+	tasks, err := s.Repo.GetTasks(userID, repository.TaskFilters{ContactID: contact.ID})
+	if err != nil {
+		c.Logger().Error("Failed to fetch tasks: ", err)
+		tasks = []entity.Task{}
+	}
+	var taskRows []map[string]interface{}
+	for _, task := range tasks {
+		taskRows = append(taskRows, getTaskMap(task, contact.ID))
+	}
+
+	// First other contacts that can be linked or added to an interaction or
+	// a task, the others are found with the search box of the selects
+	others, err := s.Repo.SearchContactNames(userID, "", []uint{contact.ID}, contactOptionsLimit)
+	if err != nil {
+		return err
+	}
+
 	res := getContactMapLong(contact)
 	res["Events"] = events
+	res["Interactions"] = interactionRows
+	res["InteractionTypes"] = entity.InteractionTypes
+	res["OtherContacts"] = contactOptions(others)
+	res["ContactOptionsURL"] = contactOptionsURL([]uint{contact.ID})
+	res["Tasks"] = taskRows
+	res["TaskPriorities"] = entity.TaskPriorities
+
+	var noteRows []map[string]interface{}
+	for _, note := range contact.Notes {
+		noteRows = append(noteRows, getNoteMap(note))
+	}
+	res["Notes"] = noteRows
+
+	attachments, err := s.Repo.GetAttachmentsByContact(contact.ID, userID)
+	if err != nil {
+		c.Logger().Error("Failed to fetch attachments: ", err)
+		attachments = []entity.Attachment{}
+	}
+	var attachmentRows []map[string]interface{}
+	for _, attachment := range attachments {
+		attachmentRows = append(attachmentRows, getAttachmentMap(attachment, attachment.ID == contactPhotoID(contact)))
+	}
+	res["Attachments"] = attachmentRows
+
+	links, err := s.Repo.GetContactLinks(contact.ID, userID)
+	if err != nil {
+		return err
+	}
+	var linkRows []map[string]interface{}
+	for _, link := range links {
+		linkRows = append(linkRows, getLinkMap(link, contact.ID))
+	}
+	res["Links"] = linkRows
+
+	households, err := s.Repo.GetHouseholdsByContact(contact.ID, userID)
+	if err != nil {
+		c.Logger().Error("Failed to fetch households: ", err)
+		households = []entity.Household{}
+	}
+	res["Households"] = getHouseholdMaps(households)
+
+	linkTypes, err := s.Repo.GetLinkTypes()
+	if err != nil {
+		return err
+	}
+	res["LinkTypes"] = linkTypeOptions(linkTypes)
+	res["Today"] = time.Now().Format("2006-01-02")
+
+	allTags, err := s.Repo.GetTags(userID)
+	if err != nil {
+		return err
+	}
+	res["AllTags"] = getTagMaps(allTags)
+
+	defs, err := s.Repo.GetCustomFieldDefinitions(userID)
+	if err != nil {
+		return err
+	}
+	res["CustomFields"] = customFieldViews(defs, contact.CustomFields)
 	return c.Render(http.StatusOK, "detail", res)
 }
 
@@ -167,7 +484,20 @@ func (s *ContactService) EditContact(c echo.Context) error {
 		return err
 	}
 
+	defs, err := s.Repo.GetCustomFieldDefinitions(userID)
+	if err != nil {
+		return err
+	}
+
+	relationships, err := relationshipNames(s.Repo, userID)
+	if err != nil {
+		return err
+	}
+
 	res := getContactMapLong(contact)
+	res["CustomFields"] = customFieldViews(defs, contact.CustomFields)
+	res["Relationships"] = relationships
+	editableChannels(res, contact)
 	return c.Render(http.StatusOK, "edit", res)
 }
 
@@ -175,58 +505,127 @@ func (s *ContactService) EditContact(c echo.Context) error {
 func (s *ContactService) UpdateContact(c echo.Context) error {
 	id := c.Param("id")
 	userID := c.Get("user_id").(uint)
-	vipValue := c.FormValue("vip") == "on"
 	c.Logger().Info("update id : ", id)
 
-	idUint, err := strconv.ParseUint(id, 10, 32)
-	if err != nil {
+	if _, err := strconv.ParseUint(id, 10, 32); err != nil {
 		return fmt.Errorf("invalid id: %v", err)
 	}
-	contact := &entity.Contact{
-		Model: gorm.Model{
-			ID: uint(idUint),
-		},
-		UserID:       userID,
-		Name:         c.FormValue("name"),
-		Relationship: entity.Relation(c.FormValue("relationship")),
-		Industry:     c.FormValue("industry"),
-		Company:      c.FormValue("company"),
-		Birthday:     c.FormValue("birthday"),
-		Vip:          vipValue,
-		DetailInfo: entity.DetailInfo{
-			FamilyDetails: entity.FamilyDetails{
-				Spouse:   c.FormValue("spouse"),
-				Children: c.FormValue("children"),
-			},
-			ContactInfo: entity.ContactInfo{
-				Location:    c.FormValue("location"),
-				PhoneNumber: c.FormValue("phone"),
-				Email:       c.FormValue("email"),
-				LinkedIn:    c.FormValue("linkedin"),
-				Instagram:   c.FormValue("instagram"),
-				X:           c.FormValue("x"),
-			},
-			Notes: c.FormValue("notes"),
-		},
+
+	contact, err := s.Repo.GetContact(id, userID)
+	if err != nil {
+		return err
+	}
+
+	form, err := c.FormParams()
+	if err != nil {
+		return err
+	}
+
+	// Only fields present in the form are updated, everything else is kept
+	setField := func(dst *string, key string) {
+		if values, ok := form[key]; ok && len(values) > 0 {
+			*dst = values[0]
+		}
+	}
+	setDate := func(dst *entity.Date, key string, parse func(string) (entity.Date, error)) error {
+		if values, ok := form[key]; ok && len(values) > 0 {
+			date, err := parse(strings.TrimSpace(values[0]))
+			if err != nil {
+				return err
+			}
+			*dst = date
+		}
+		return nil
+	}
+
+	setField(&contact.Name, "name")
+	if values, ok := form["relationship"]; ok && len(values) > 0 {
+		if contact.Relationship, err = resolveRelationship(s.Repo, userID, values[0]); err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+	}
+	setField(&contact.Industry, "industry")
+	setField(&contact.Company, "company")
+	if err := setDate(&contact.Birthday, "birthday", entity.ParseBirthday); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid birthday: "+err.Error())
+	}
+	setField(&contact.Spouse, "spouse")
+	setField(&contact.Children, "children")
+	setField(&contact.LinkedIn, "linkedin")
+	setField(&contact.Instagram, "instagram")
+	setField(&contact.X, "x")
+	if values, ok := form["cadence_days"]; ok && len(values) > 0 {
+		cadence, err := entity.ParseCadence(values[0])
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+		contact.CadenceDays = cadence
 	}
 
+	// Unchecked checkboxes are not submitted
+	contact.Vip = c.FormValue("vip") == "on"
+	// Last met and last contacted are derived from interactions and never edited here
 	if contact.Vip {
-		contact.VipInfo = entity.VipInfo{
-			LastContacted: c.FormValue("last_contacted"),
-			LastMet:       c.FormValue("last_met"),
-			LastUpdate:    c.FormValue("last_update"),
+		if err := setDate(&contact.LastUpdate, "last_update", entity.ParseDate); err != nil {
+			return c.String(http.StatusBadRequest, "Invalid last update: "+err.Error())
+		}
+	} else {
+		contact.LastUpdate = entity.Date{}
+	}
+
+	defs, err := s.Repo.GetCustomFieldDefinitions(userID)
+	if err != nil {
+		return err
+	}
+	contact.CustomFields, err = entity.ApplyCustomFields(defs, contact.CustomFields, customFieldsFromForm(form))
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	// Emails, phone numbers and addresses are replaced per list present in the form
+	channels := []entity.ContactChannel{}
+	for _, kind := range []entity.ChannelKind{entity.EmailChannel, entity.PhoneChannel} {
+		if submitted, ok := channelsFromForm(form, kind); ok {
+			channels = append(channels, submitted...)
+		} else {
+			channels = append(channels, contact.ChannelsOf(kind)...)
 		}
 	}
+	contact.Channels = channels
+	if addresses, ok := addressesFromForm(form); ok {
+		contact.Addresses = addresses
+	}
 
-	if err := s.Repo.SaveContact(contact); err != nil {
+	if err := s.Repo.SaveContactWithChannels(&contact, actorFromContext(c)); err != nil {
 		return err
 	}
 
-	res := getContactMapLong(*contact)
+	// Lets the history panel refresh itself
+	c.Response().Header().Set("HX-Trigger", "contactUpdated")
+	res := getContactMapLong(contact)
+	res["CustomFields"] = customFieldViews(defs, contact.CustomFields)
 
 	return c.Render(http.StatusOK, "blocks", res)
 }
 
+// highlightSnippet escapes a search snippet and turns the highlight markers into <mark> tags
+func highlightSnippet(snippet string) string {
+	if snippet == "" {
+		return ""
+	}
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, repository.HighlightStart, `<mark class="bg-yellow-200 rounded px-0.5">`)
+	return strings.ReplaceAll(snippet, repository.HighlightStop, "</mark>")
+}
+
+// actorFromContext returns the name recorded as the author of contact changes
+func actorFromContext(c echo.Context) string {
+	if email, ok := c.Get("user_email").(string); ok && email != "" {
+		return email
+	}
+	return fmt.Sprintf("user %d", c.Get("user_id").(uint))
+}
+
 func getContactMapShort(contact entity.Contact) map[string]interface{} {
 	return map[string]interface{}{
 		"Id":                    contact.ID,
@@ -234,13 +633,18 @@ func getContactMapShort(contact entity.Contact) map[string]interface{} {
 		"Relationship":          contact.Relationship,
 		"Industry":              contact.Industry,
 		"Company":               contact.Company,
-		"Birthday":              contact.Birthday,
+		"Birthday":              contact.Birthday.String(),
 		"Vip":                   contact.Vip,
-		"LastContacted":         contact.LastContacted,
-		"LastMet":               contact.LastMet,
-		"LastUpdate":            contact.LastUpdate,
+		"LastContacted":         contact.LastContacted.String(),
+		"LastMet":               contact.LastMet.String(),
+		"LastUpdate":            contact.LastUpdate.String(),
 		"CalendarSyncEnabled":   contact.CalendarSyncEnabled,
 		"GoogleCalendarEventID": contact.GoogleCalendarEventID,
+		"Tags":                  getContactTagMaps(contact.Tags),
+		"PhotoId":               contactPhotoID(contact),
+		"CreatedAt":             contact.CreatedAt.Format("2006-01-02"),
+		"StrengthScore":         contact.StrengthScore,
+		"Drifting":              !contact.DriftingSince.IsZero(),
 	}
 }
 
@@ -251,7 +655,7 @@ func getContactMapLong(contact entity.Contact) map[string]interface{} {
 		"Relationship":          contact.Relationship,
 		"Industry":              contact.Industry,
 		"Company":               contact.Company,
-		"Birthday":              contact.Birthday,
+		"Birthday":              contact.Birthday.String(),
 		"Vip":                   contact.Vip,
 		"Spouse":                contact.Spouse,
 		"Children":              contact.Children,
@@ -261,12 +665,43 @@ func getContactMapLong(contact entity.Contact) map[string]interface{} {
 		"Instagram":             contact.Instagram,
 		"X":                     contact.X,
 		"Location":              contact.Location,
-		"Notes":                 contact.Notes,
-		"LastContacted":         contact.LastContacted,
-		"LastMet":               contact.LastMet,
-		"LastUpdate":            contact.LastUpdate,
+		"Emails":                contact.ChannelsOf(entity.EmailChannel),
+		"Phones":                contact.ChannelsOf(entity.PhoneChannel),
+		"Addresses":             contact.Addresses,
+		"LastContacted":         contact.LastContacted.String(),
+		"LastMet":               contact.LastMet.String(),
+		"LastUpdate":            contact.LastUpdate.String(),
 		"CalendarSyncEnabled":   contact.CalendarSyncEnabled,
 		"GoogleCalendarEventID": contact.GoogleCalendarEventID,
+		"Tags":                  getContactTagMaps(contact.Tags),
+		"Cadence":               contactCadence(contact),
+		"Cadences":              cadenceOptions(contact.CadenceDays, "From tags / VIP"),
+		"NextDue":               contact.NextDue().String(),
+		"SnoozedUntil":          contact.SnoozedUntil.String(),
+		"PhotoId":               contactPhotoID(contact),
 	}
 	return res
 }
+
+// contactPhotoID is the attachment shown as the contact's avatar, 0 without one
+func contactPhotoID(contact entity.Contact) uint {
+	if contact.PhotoID == nil {
+		return 0
+	}
+	return *contact.PhotoID
+}
+
+// contactCadence describes the effective cadence of a contact and where it
+// comes from, e.g. "Every quarter (tag Investors)"
+func contactCadence(contact entity.Contact) string {
+	days, source := contact.Cadence()
+	switch {
+	case days == 0:
+		return ""
+	case source == "contact":
+		return entity.CadenceLabel(days)
+	case source == "vip":
+		return entity.CadenceLabel(days) + " (VIP)"
+	}
+	return fmt.Sprintf("%s (%s)", entity.CadenceLabel(days), source)
+}
@@ -0,0 +1,616 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/labstack/echo/v4"
+)
+
+type CSVImportService struct {
+	Repo repository.ContactDao
+}
+
+func NewCSVImportService(repo repository.ContactDao) *CSVImportService {
+	return &CSVImportService{
+		Repo: repo,
+	}
+}
+
+// csvTagsTarget maps a column to the contact's tags, separated by commas or semicolons
+const csvTagsTarget = "tags"
+
+// csvCustomFieldTarget prefixes the targets of custom fields, e.g. "cf.diet"
+const csvCustomFieldTarget = "cf."
+
+// csvPreviewRows is the number of rows shown in the dry run preview
+const csvPreviewRows = 20
+
+// csvChannelTargets are the fields that take several values, one per column
+var csvChannelTargets = map[string]entity.ChannelKind{
+	"email":        entity.EmailChannel,
+	"phone_number": entity.PhoneChannel,
+}
+
+// csvHeaderAliases lists the normalized header names detected per field. Several
+// columns may map onto the same field, e.g. "First Name" and "Last Name".
+var csvHeaderAliases = map[string][]string{
+	"name":         {"name", "fullname", "contactname", "displayname", "firstname", "givenname", "lastname", "surname", "familyname"},
+	"relationship": {"relationship", "relation"},
+	"industry":     {"industry", "sector"},
+	"company":      {"company", "organization", "organisation", "organizationname", "employer"},
+	"birthday":     {"birthday", "birthdate", "dateofbirth", "dob"},
+	"vip":          {"vip", "important", "favorite", "starred"},
+	"spouse":       {"spouse", "partner"},
+	"children":     {"children", "kids"},
+	"location":     {"location", "city", "address"},
+	"phone_number": {"phone", "phonenumber", "mobile", "mobilephone", "cell", "telephone", "phone1value", "phone2value", "phone3value", "workphone", "homephone"},
+	"email":        {"email", "emailaddress", "email1value", "email2value", "email3value", "mail", "workemail", "homeemail"},
+	"linked_in":    {"linkedin", "linkedinurl"},
+	"instagram":    {"instagram"},
+	"x":            {"x", "twitter"},
+	"notes":        {"notes", "note", "comments", "description"},
+	"status":       {"status"},
+	"last_update":  {"lastupdate"},
+	"cadence_days": {"cadence", "keepintouch", "frequency"},
+	csvTagsTarget:  {"tags", "labels", "categories"},
+}
+
+var nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+
+func normalizeHeader(header string) string {
+	return nonAlphanumeric.ReplaceAllString(strings.ToLower(header), "")
+}
+
+// csvTable is a parsed CSV file
+type csvTable struct {
+	Headers   []string
+	Rows      [][]string
+	HasHeader bool
+}
+
+// line returns the line number of a data row in the file, for error messages
+func (t csvTable) line(row int) int {
+	if t.HasHeader {
+		return row + 2
+	}
+	return row + 1
+}
+
+// parseCSV reads a CSV file, guessing the delimiter from the first line. The
+// first row is taken as the header when one of its cells is a known header.
+func parseCSV(data []byte, defs []entity.CustomFieldDefinition) (csvTable, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	for _, delimiter := range []rune{';', '\t'} {
+		if bytes.Count(firstLine, []byte(string(delimiter))) > bytes.Count(firstLine, []byte(",")) {
+			reader.Comma = delimiter
+		}
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return csvTable{}, fmt.Errorf("invalid CSV file: %w", err)
+	}
+
+	// Skip empty lines
+	var rows [][]string
+	width := 0
+	for _, record := range records {
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		rows = append(rows, record)
+		width = max(width, len(record))
+	}
+	if len(rows) == 0 {
+		return csvTable{}, fmt.Errorf("the file contains no rows")
+	}
+
+	table := csvTable{}
+	for _, cell := range rows[0] {
+		if detectCSVTarget(cell, defs) != "" {
+			table.HasHeader = true
+			break
+		}
+	}
+	if table.HasHeader {
+		table.Headers, rows = rows[0], rows[1:]
+	}
+	for i := len(table.Headers); i < width; i++ {
+		table.Headers = append(table.Headers, fmt.Sprintf("Column %d", i+1))
+	}
+	if len(rows) > maxImportContacts {
+		return csvTable{}, fmt.Errorf("the file contains %d rows, at most %d can be imported at once", len(rows), maxImportContacts)
+	}
+	table.Rows = rows
+	return table, nil
+}
+
+// detectCSVTarget returns the field a header maps to, or "" when unknown
+func detectCSVTarget(header string, defs []entity.CustomFieldDefinition) string {
+	normalized := normalizeHeader(header)
+	if normalized == "" {
+		return ""
+	}
+	for target, aliases := range csvHeaderAliases {
+		if slices.Contains(aliases, normalized) {
+			return target
+		}
+	}
+	for _, def := range defs {
+		if normalized == normalizeHeader(def.Key) || normalized == normalizeHeader(def.Label) {
+			return csvCustomFieldTarget + def.Key
+		}
+	}
+	return ""
+}
+
+// csvTargets lists the fields a column can be mapped to, in display order
+func csvTargets(defs []entity.CustomFieldDefinition) []map[string]interface{} {
+	var targets []map[string]interface{}
+	for _, field := range repository.TrackedContactFields {
+		targets = append(targets, map[string]interface{}{"Value": field.Column, "Label": field.Label})
+	}
+	targets = append(targets, map[string]interface{}{"Value": csvTagsTarget, "Label": "Tags"})
+	for _, def := range defs {
+		targets = append(targets, map[string]interface{}{"Value": csvCustomFieldTarget + def.Key, "Label": def.Label})
+	}
+	return targets
+}
+
+// validCSVMapping checks that every target exists and the name is mapped
+func validCSVMapping(mapping []string, defs []entity.CustomFieldDefinition) error {
+	known := map[string]bool{csvTagsTarget: true}
+	for _, field := range repository.TrackedContactFields {
+		known[field.Column] = true
+	}
+	for _, def := range defs {
+		known[csvCustomFieldTarget+def.Key] = true
+	}
+
+	hasName := false
+	for _, target := range mapping {
+		if target != "" && !known[target] {
+			return fmt.Errorf("unknown field %q", target)
+		}
+		hasName = hasName || target == "name"
+	}
+	if !hasName {
+		return fmt.Errorf("map a column to Name")
+	}
+	return nil
+}
+
+// csvRow is a data row converted to a contact
+type csvRow struct {
+	Line    int
+	Values  []string
+	Import  repository.ContactImport
+	Errors  []string
+	Skipped string // set when a valid row is not imported, e.g. a duplicate
+}
+
+// buildCSVRows converts and validates every row with the given mapping. With
+// skipDuplicates, rows whose email or phone number already exists are skipped.
+func buildCSVRows(repo repository.ContactDao, userID uint, table csvTable, mapping []string, defs []entity.CustomFieldDefinition, skipDuplicates bool) ([]csvRow, error) {
+	types, err := repo.GetRelationshipTypes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	emails := map[string]int{}
+	rows := make([]csvRow, 0, len(table.Rows))
+	for i, values := range table.Rows {
+		row := csvRow{Line: table.line(i), Values: values}
+		row.Import, row.Errors = csvRowContact(values, mapping, defs, types)
+		row.Import.Contact.UserID = userID
+
+		// The same email twice in one file is almost always a mistake
+		if email := strings.ToLower(row.Import.Contact.Email); email != "" && len(row.Errors) == 0 {
+			if line, ok := emails[email]; ok {
+				row.Errors = append(row.Errors, fmt.Sprintf("email %s is already used on line %d", row.Import.Contact.Email, line))
+			} else {
+				emails[email] = row.Line
+			}
+		}
+
+		if skipDuplicates && len(row.Errors) == 0 {
+			duplicates, err := repo.FindDuplicatesOf(row.Import.Contact)
+			if err != nil {
+				return nil, err
+			}
+			if len(duplicates) > 0 {
+				row.Skipped = fmt.Sprintf("already exists as %s (%s)", duplicates[0].Contact.Name, strings.Join(duplicates[0].Reasons, ", "))
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// csvRowContact converts the cells of a row into a contact and its tags. The
+// relationship must be one of types, ignoring case.
+func csvRowContact(values []string, mapping []string, defs []entity.CustomFieldDefinition, types []entity.RelationshipType) (repository.ContactImport, []string) {
+	// Collect the cells per field first, several columns can share a field
+	cells := map[string][]string{}
+	var order []string
+	for i, target := range mapping {
+		if target == "" || i >= len(values) {
+			continue
+		}
+		value := strings.TrimSpace(values[i])
+		if value == "" {
+			continue
+		}
+		if _, ok := cells[target]; !ok {
+			order = append(order, target)
+		}
+		cells[target] = append(cells[target], value)
+	}
+
+	imp := repository.ContactImport{Contact: entity.Contact{CustomFields: entity.CustomFields{}}}
+	var errs []string
+	customFields := map[string]string{}
+
+	for _, target := range order {
+		// Each email or phone column is a value of its own, the first one primary
+		if kind, ok := csvChannelTargets[target]; ok {
+			for i, cell := range cells[target] {
+				value, err := normalizeCSVValue(target, cell)
+				if err != nil {
+					errs = append(errs, err.Error())
+					continue
+				}
+				imp.Contact.Channels = append(imp.Contact.Channels, entity.ContactChannel{Kind: kind, Value: value, Primary: i == 0})
+			}
+			if values := imp.Contact.ChannelValues(kind); len(values) > 0 {
+				if kind == entity.EmailChannel {
+					imp.Contact.Email = values[0]
+				} else {
+					imp.Contact.PhoneNumber = values[0]
+				}
+			}
+			continue
+		}
+
+		separator := " "
+		if target == "notes" {
+			separator = "\n"
+		}
+		value := strings.Join(cells[target], separator)
+
+		switch {
+		case target == csvTagsTarget:
+			for _, name := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }) {
+				if name = strings.TrimSpace(name); name == "" {
+					continue
+				}
+				if len(name) > 64 {
+					errs = append(errs, fmt.Sprintf("tag %q is longer than 64 characters", name))
+					continue
+				}
+				imp.Tags = append(imp.Tags, name)
+			}
+			continue
+		case strings.HasPrefix(target, csvCustomFieldTarget):
+			customFields[strings.TrimPrefix(target, csvCustomFieldTarget)] = value
+			continue
+		case target == "notes":
+			imp.Contact.Notes = []entity.Note{{Body: value}}
+			continue
+		case target == "relationship":
+			if t, ok := entity.FindRelationshipType(types, value); ok {
+				imp.Contact.Relationship = entity.Relation(t.Name)
+			} else {
+				errs = append(errs, unknownRelationshipError(types, value).Error())
+			}
+			continue
+		}
+
+		value, err := normalizeCSVValue(target, value)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		for _, field := range repository.TrackedContactFields {
+			if field.Column == target {
+				field.Set(&imp.Contact, value)
+			}
+		}
+	}
+
+	if imp.Contact.Name == "" {
+		errs = append(errs, "name is required")
+	}
+	if len(customFields) > 0 {
+		values, err := entity.ApplyCustomFields(defs, nil, customFields)
+		if err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			imp.Contact.CustomFields = values
+		}
+	}
+	return imp, errs
+}
+
+// csvBirthdayLayouts are the date formats accepted for birthdays. Day and month
+// orders like 02/01/2006 are ambiguous and therefore not accepted.
+var csvBirthdayLayouts = []string{"2006-01-02", "2006/01/02", "2006.01.02", "Jan 2, 2006", "January 2, 2006", "2 Jan 2006", "2 January 2006"}
+
+// csvPartialBirthdayLayouts are the accepted formats of birthdays without year,
+// next to --MM-DD
+var csvPartialBirthdayLayouts = []string{"Jan 2", "January 2", "2 Jan", "2 January"}
+
+// normalizeCSVValue validates a cell for a contact column and returns it in its stored form
+func normalizeCSVValue(column, value string) (string, error) {
+	switch column {
+	case "vip":
+		switch strings.ToLower(value) {
+		case "true", "yes", "y", "1", "x", "vip":
+			return "true", nil
+		case "false", "no", "n", "0":
+			return "false", nil
+		}
+		return "", fmt.Errorf("vip %q must be yes or no", value)
+	case "birthday":
+		for _, layout := range csvBirthdayLayouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t.Format("2006-01-02"), nil
+			}
+		}
+		if date, err := entity.ParseBirthday(value); err == nil {
+			return date.String(), nil
+		}
+		for _, layout := range csvPartialBirthdayLayouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t.Format("--01-02"), nil
+			}
+		}
+		return "", fmt.Errorf("birthday %q must be a date like 1990-04-15, or --04-15 without year", value)
+	case "email":
+		address, err := mail.ParseAddress(value)
+		if err != nil {
+			return "", fmt.Errorf("email %q is not a valid address", value)
+		}
+		return address.Address, nil
+	case "last_update":
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return "", fmt.Errorf("last update %q must be a date in YYYY-MM-DD format", value)
+		}
+	case "cadence_days":
+		days, err := entity.ParseCadence(value)
+		if err != nil {
+			return "", err
+		}
+		return entity.CadenceLabel(days), nil
+	}
+	return value, nil
+}
+
+// csvErrorReport returns the rows that were not imported as CSV, with the
+// original cells followed by the line number and the reasons
+func csvErrorReport(table csvTable, rows []csvRow) []byte {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write(append(append([]string{}, table.Headers...), "line", "errors"))
+	for _, row := range rows {
+		reasons := row.Errors
+		if len(reasons) == 0 && row.Skipped != "" {
+			reasons = []string{"skipped: " + row.Skipped}
+		}
+		if len(reasons) == 0 {
+			continue
+		}
+		cells := make([]string, len(table.Headers))
+		copy(cells, row.Values)
+		writer.Write(append(cells, strconv.Itoa(row.Line), strings.Join(reasons, "; ")))
+	}
+	writer.Flush()
+	return buf.Bytes()
+}
+
+// csvImportSummary counts the rows of a dry run or import
+type csvImportSummary struct {
+	Total   int
+	Valid   int
+	Invalid int
+	Skipped int
+}
+
+func summarizeCSVRows(rows []csvRow) csvImportSummary {
+	summary := csvImportSummary{Total: len(rows)}
+	for _, row := range rows {
+		switch {
+		case len(row.Errors) > 0:
+			summary.Invalid++
+		case row.Skipped != "":
+			summary.Skipped++
+		default:
+			summary.Valid++
+		}
+	}
+	return summary
+}
+
+// importableCSVRows returns the contacts of the rows without errors that are not skipped
+func importableCSVRows(rows []csvRow) []repository.ContactImport {
+	var imports []repository.ContactImport
+	for _, row := range rows {
+		if len(row.Errors) == 0 && row.Skipped == "" {
+			imports = append(imports, row.Import)
+		}
+	}
+	return imports
+}
+
+// csvReportURL embeds an error report in a data URL for a download link. The
+// templates only keep http(s) links, the report is marked as a trusted URL.
+func csvReportURL(report []byte) template.URL {
+	return template.URL("data:text/csv;charset=utf-8," + url.PathEscape(string(report)))
+}
+
+// UploadCSV reads an uploaded CSV file and renders the column mapping step
+func (s *CSVImportService) UploadCSV(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	file, err := readImportFile(c, "file")
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	defs, err := s.Repo.GetCustomFieldDefinitions(userID)
+	if err != nil {
+		return err
+	}
+	table, err := parseCSV(file, defs)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	var columns []map[string]interface{}
+	for i, header := range table.Headers {
+		var samples []string
+		for _, row := range table.Rows {
+			if i < len(row) && strings.TrimSpace(row[i]) != "" {
+				samples = append(samples, strings.TrimSpace(row[i]))
+			}
+			if len(samples) == 3 {
+				break
+			}
+		}
+		target := ""
+		if table.HasHeader {
+			target = detectCSVTarget(header, defs)
+		}
+		columns = append(columns, map[string]interface{}{
+			"Index":   i,
+			"Header":  header,
+			"Samples": strings.Join(samples, ", "),
+			"Target":  target,
+		})
+	}
+
+	data := map[string]interface{}{
+		"Columns":   columns,
+		"Targets":   csvTargets(defs),
+		"Rows":      len(table.Rows),
+		"HasHeader": table.HasHeader,
+		// The file is sent back with every step in a hidden textarea
+		"CSV": string(file),
+	}
+	return c.Render(http.StatusOK, "csv-mapping", data)
+}
+
+// PreviewCSVImport is the dry run: it validates every row with the chosen
+// mapping and renders the outcome without saving anything
+func (s *CSVImportService) PreviewCSVImport(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	table, rows, err := s.csvRowsFromForm(c, userID)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	var preview []map[string]interface{}
+	var errs []map[string]interface{}
+	for _, row := range rows {
+		contact := row.Import.Contact
+		if len(preview) < csvPreviewRows {
+			preview = append(preview, map[string]interface{}{
+				"Line":         row.Line,
+				"Name":         contact.Name,
+				"Email":        contact.Email,
+				"Phone":        contact.PhoneNumber,
+				"Company":      contact.Company,
+				"Relationship": string(contact.Relationship),
+				"Vip":          contact.Vip,
+				"Tags":         strings.Join(row.Import.Tags, ", "),
+				"Invalid":      len(row.Errors) > 0,
+				"Skipped":      row.Skipped,
+			})
+		}
+		if len(row.Errors) > 0 {
+			errs = append(errs, map[string]interface{}{"Line": row.Line, "Errors": strings.Join(row.Errors, "; ")})
+		}
+	}
+
+	summary := summarizeCSVRows(rows)
+	data := map[string]interface{}{
+		"Summary":   summary,
+		"Preview":   preview,
+		"More":      len(rows) - len(preview),
+		"Errors":    errs,
+		"ReportURL": "",
+	}
+	if summary.Invalid+summary.Skipped > 0 {
+		data["ReportURL"] = csvReportURL(csvErrorReport(table, rows))
+	}
+	return c.Render(http.StatusOK, "csv-preview", data)
+}
+
+// ImportCSV imports the valid rows in a single transaction
+func (s *CSVImportService) ImportCSV(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	table, rows, err := s.csvRowsFromForm(c, userID)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	imports := importableCSVRows(rows)
+	if err := s.Repo.ImportContacts(userID, imports); err != nil {
+		c.Logger().Error("Failed to import CSV: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to import contacts, nothing was imported")
+	}
+
+	summary := summarizeCSVRows(rows)
+	data := map[string]interface{}{
+		"Created":   len(imports),
+		"Invalid":   summary.Invalid,
+		"Skipped":   summary.Skipped,
+		"ReportURL": "",
+	}
+	if summary.Invalid+summary.Skipped > 0 {
+		data["ReportURL"] = csvReportURL(csvErrorReport(table, rows))
+	}
+	return c.Render(http.StatusOK, "csv-import-result", data)
+}
+
+// csvRowsFromForm re-reads the file and the map_<column> fields of the mapping form
+func (s *CSVImportService) csvRowsFromForm(c echo.Context, userID uint) (csvTable, []csvRow, error) {
+	defs, err := s.Repo.GetCustomFieldDefinitions(userID)
+	if err != nil {
+		return csvTable{}, nil, err
+	}
+	table, err := parseCSV([]byte(c.FormValue("csv")), defs)
+	if err != nil {
+		return csvTable{}, nil, err
+	}
+
+	mapping := make([]string, len(table.Headers))
+	for i := range mapping {
+		mapping[i] = c.FormValue(fmt.Sprintf("map_%d", i))
+	}
+	if err := validCSVMapping(mapping, defs); err != nil {
+		return csvTable{}, nil, err
+	}
+
+	rows, err := buildCSVRows(s.Repo, userID, table, mapping, defs, c.FormValue("skip_duplicates") == "on")
+	return table, rows, err
+}
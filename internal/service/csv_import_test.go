@@ -0,0 +1,252 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/entity"
+)
+
+var testCSVFields = []entity.CustomFieldDefinition{
+	{Key: "diet", Label: "Diet", Type: entity.SelectField, Options: entity.StringList{"Vegan", "Omnivore"}},
+	{Key: "shoe_size", Label: "Shoe Size", Type: entity.NumberField},
+}
+
+var testRelationshipTypes = []entity.RelationshipType{{Name: "Friend"}, {Name: "Colleague"}}
+
+func TestDetectCSVTarget(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"Name", "name"},
+		{"First Name", "name"},
+		{"E-mail Address", "email"},
+		{"E-mail 2 - Value", "email"},
+		{"Mobile Phone", "phone_number"},
+		{"Organization Name", "company"},
+		{"Date of Birth", "birthday"},
+		{"LinkedIn URL", "linked_in"},
+		{"Keep in touch", "cadence_days"},
+		{"Labels", "tags"},
+		{"diet", "cf.diet"},
+		{"Shoe size", "cf.shoe_size"},
+		{"shoe_size", "cf.shoe_size"},
+		{"Favourite colour", ""},
+		{"---", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := detectCSVTarget(tt.header, testCSVFields); got != tt.want {
+			t.Errorf("detectCSVTarget(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    csvTable
+		wantErr string
+	}{
+		{
+			name: "header with a byte order mark",
+			data: "\ufeffName,Email\nJane,jane@example.test\n",
+			want: csvTable{Headers: []string{"Name", "Email"}, Rows: [][]string{{"Jane", "jane@example.test"}}, HasHeader: true},
+		},
+		{
+			name: "semicolons and a blank line",
+			data: "Name;Company\r\nJane;ACME, Inc\r\n;\r\nJohn;\r\n",
+			want: csvTable{Headers: []string{"Name", "Company"}, Rows: [][]string{{"Jane", "ACME, Inc"}, {"John", ""}}, HasHeader: true},
+		},
+		{
+			name: "tabs",
+			data: "Name\tPhone\nJane\t+1 555\n",
+			want: csvTable{Headers: []string{"Name", "Phone"}, Rows: [][]string{{"Jane", "+1 555"}}, HasHeader: true},
+		},
+		{
+			name: "no header",
+			data: "Jane,jane@example.test\nJohn,john@example.test,extra\n",
+			want: csvTable{
+				Headers: []string{"Column 1", "Column 2", "Column 3"},
+				Rows:    [][]string{{"Jane", "jane@example.test"}, {"John", "john@example.test", "extra"}},
+			},
+		},
+		{
+			name: "more cells than headers",
+			data: "Name\nJane,x\n",
+			want: csvTable{Headers: []string{"Name", "Column 2"}, Rows: [][]string{{"Jane", "x"}}, HasHeader: true},
+		},
+		{
+			name:    "empty",
+			data:    "\n,,\n",
+			wantErr: "the file contains no rows",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCSV([]byte(tt.data), testCSVFields)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("parseCSV error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCSV: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCSV = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVTableLine(t *testing.T) {
+	if got := (csvTable{HasHeader: true}).line(0); got != 2 {
+		t.Errorf("line(0) with a header = %d, want 2", got)
+	}
+	if got := (csvTable{}).line(0); got != 1 {
+		t.Errorf("line(0) without a header = %d, want 1", got)
+	}
+}
+
+func TestValidCSVMapping(t *testing.T) {
+	tests := []struct {
+		mapping []string
+		wantErr string
+	}{
+		{[]string{"name", "email", ""}, ""},
+		{[]string{"name", "name", "tags", "cf.diet"}, ""},
+		{[]string{"email"}, "map a column to Name"},
+		{[]string{"name", "cf.unknown"}, `unknown field "cf.unknown"`},
+		{[]string{"name", "password"}, `unknown field "password"`},
+	}
+	for _, tt := range tests {
+		err := validCSVMapping(tt.mapping, testCSVFields)
+		if (tt.wantErr == "" && err != nil) || (tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr)) {
+			t.Errorf("validCSVMapping(%v) = %v, want %q", tt.mapping, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNormalizeCSVValue(t *testing.T) {
+	tests := []struct {
+		column  string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"vip", "Yes", "true", false},
+		{"vip", "x", "true", false},
+		{"vip", "0", "false", false},
+		{"vip", "maybe", "", true},
+		{"birthday", "1990-04-15", "1990-04-15", false},
+		{"birthday", "1990/04/15", "1990-04-15", false},
+		{"birthday", "April 15, 1990", "1990-04-15", false},
+		{"birthday", "15 Apr 1990", "1990-04-15", false},
+		{"birthday", "--04-15", "--04-15", false},
+		{"birthday", "April 15", "--04-15", false},
+		{"birthday", "15 April", "--04-15", false},
+		{"birthday", "04/15/1990", "", true},
+		{"birthday", "1990-02-30", "", true},
+		{"email", "Jane Doe <jane@example.test>", "jane@example.test", false},
+		{"email", "not an email", "", true},
+		{"last_update", "2024-01-02", "2024-01-02", false},
+		{"last_update", "yesterday", "", true},
+		{"cadence_days", "monthly", "Every month", false},
+		{"cadence_days", "10", "Every 10 days", false},
+		{"cadence_days", "often", "", true},
+		{"company", "ACME", "ACME", false},
+	}
+	for _, tt := range tests {
+		got, err := normalizeCSVValue(tt.column, tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("normalizeCSVValue(%q, %q) = %q, %v, want %q, error %v", tt.column, tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCSVRowContact(t *testing.T) {
+	mapping := []string{"name", "name", "email", "email", "phone_number", "relationship", "birthday", "vip", "tags", "notes", "notes", "cf.diet", "cadence_days", ""}
+	values := []string{"Jane", "Doe", "jane@example.test", " jane@work.test ", "+1 555", "friend", "--04-15", "yes", "family; book club,,", "Likes tea", "Met in Paris", "vegan", "quarterly", "ignored"}
+
+	imp, errs := csvRowContact(values, mapping, testCSVFields, testRelationshipTypes)
+	if len(errs) != 0 {
+		t.Fatalf("csvRowContact errors: %v", errs)
+	}
+	c := imp.Contact
+	if c.Name != "Jane Doe" || c.Relationship != "Friend" || c.Birthday != (entity.Date{Month: time.April, Day: 15}) || !c.Vip || c.CadenceDays != 91 {
+		t.Errorf("csvRowContact = %+v", c)
+	}
+	if c.Email != "jane@example.test" || c.PhoneNumber != "+1 555" {
+		t.Errorf("primary email and phone = %q, %q", c.Email, c.PhoneNumber)
+	}
+	wantChannels := []entity.ContactChannel{
+		{Kind: entity.EmailChannel, Value: "jane@example.test", Primary: true},
+		{Kind: entity.EmailChannel, Value: "jane@work.test"},
+		{Kind: entity.PhoneChannel, Value: "+1 555", Primary: true},
+	}
+	if !reflect.DeepEqual(c.Channels, wantChannels) {
+		t.Errorf("channels = %+v, want %+v", c.Channels, wantChannels)
+	}
+	if !reflect.DeepEqual(imp.Tags, []string{"family", "book club"}) {
+		t.Errorf("tags = %q", imp.Tags)
+	}
+	if len(c.Notes) != 1 || c.Notes[0].Body != "Likes tea\nMet in Paris" {
+		t.Errorf("notes = %+v", c.Notes)
+	}
+	if c.CustomFields["diet"] != "Vegan" {
+		t.Errorf("custom fields = %v", c.CustomFields)
+	}
+}
+
+func TestCSVRowContactErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping []string
+		values  []string
+		want    []string
+	}{
+		{
+			name:    "missing name",
+			mapping: []string{"name", "company"},
+			values:  []string{" ", "ACME"},
+			want:    []string{"name is required"},
+		},
+		{
+			name:    "short row",
+			mapping: []string{"company", "name"},
+			values:  []string{"ACME"},
+			want:    []string{"name is required"},
+		},
+		{
+			name:    "invalid cells",
+			mapping: []string{"name", "email", "vip", "relationship", "tags"},
+			values:  []string{"Jane", "nope", "maybe", "Enemy", strings.Repeat("t", 65)},
+			want: []string{
+				`email "nope" is not a valid address`,
+				`vip "maybe" must be yes or no`,
+				`relationship "Enemy" must be one of Friend, Colleague`,
+				`tag "` + strings.Repeat("t", 65) + `" is longer than 64 characters`,
+			},
+		},
+		{
+			name:    "custom field value",
+			mapping: []string{"name", "cf.shoe_size"},
+			values:  []string{"Jane", "big"},
+			want:    []string{"Shoe Size must be a number"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, errs := csvRowContact(tt.values, tt.mapping, testCSVFields, testRelationshipTypes)
+			if !reflect.DeepEqual(errs, tt.want) {
+				t.Errorf("csvRowContact errors = %q, want %q", errs, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/labstack/echo/v4"
+)
+
+type CustomFieldService struct {
+	Repo repository.ContactDao
+}
+
+func NewCustomFieldService(repo repository.ContactDao) *CustomFieldService {
+	return &CustomFieldService{
+		Repo: repo,
+	}
+}
+
+// customFieldFormPrefix prefixes the names of custom field inputs in the contact edit form
+const customFieldFormPrefix = "cf_"
+
+// newCustomFieldDefinition validates a field definition and derives its key from the label
+func newCustomFieldDefinition(userID uint, label, fieldType string, options []string) (*entity.CustomFieldDefinition, error) {
+	def := &entity.CustomFieldDefinition{
+		UserID: userID,
+		Label:  strings.TrimSpace(label),
+		Type:   entity.CustomFieldType(fieldType),
+	}
+	if def.Label == "" {
+		return nil, fmt.Errorf("label is required")
+	}
+	def.Key = entity.CustomFieldKey(def.Label)
+	if def.Key == "" {
+		return nil, fmt.Errorf("label must contain at least one letter or digit")
+	}
+	if len(def.Key) > 64 {
+		return nil, fmt.Errorf("label is too long")
+	}
+	if !def.Type.Valid() {
+		return nil, fmt.Errorf("type must be one of text, date, number, select, url")
+	}
+
+	if err := setCustomFieldOptions(def, options); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+// setCustomFieldOptions trims and de-duplicates the options of a select field
+func setCustomFieldOptions(def *entity.CustomFieldDefinition, options []string) error {
+	def.Options = entity.StringList{}
+	if def.Type != entity.SelectField {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	for _, option := range options {
+		option = strings.TrimSpace(option)
+		if option == "" || seen[strings.ToLower(option)] {
+			continue
+		}
+		seen[strings.ToLower(option)] = true
+		def.Options = append(def.Options, option)
+	}
+	if len(def.Options) == 0 {
+		return fmt.Errorf("select fields need at least one option")
+	}
+	return nil
+}
+
+// GetCustomFields renders the custom field management page
+func (s *CustomFieldService) GetCustomFields(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	data, err := s.customFieldListData(userID)
+	if err != nil {
+		return err
+	}
+	return c.Render(http.StatusOK, "custom-fields", data)
+}
+
+func (s *CustomFieldService) CreateCustomField(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	// Options are entered as a comma separated list
+	def, err := newCustomFieldDefinition(userID, c.FormValue("label"), c.FormValue("type"), strings.Split(c.FormValue("options"), ","))
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if exists, err := customFieldKeyExists(s.Repo, userID, def.Key); err != nil {
+		return err
+	} else if exists {
+		return c.String(http.StatusConflict, "A field with this name already exists")
+	}
+
+	if err := s.Repo.CreateCustomFieldDefinition(def); err != nil {
+		c.Logger().Error("Failed to create custom field: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to create field")
+	}
+
+	data, err := s.customFieldListData(userID)
+	if err != nil {
+		return err
+	}
+	return c.Render(http.StatusOK, "custom-field-list", data)
+}
+
+func (s *CustomFieldService) DeleteCustomField(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var fieldID uint
+	if _, err := fmt.Sscan(c.Param("fieldId"), &fieldID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid field ID")
+	}
+
+	if err := s.Repo.DeleteCustomFieldDefinition(fieldID, userID); err != nil {
+		c.Logger().Error("Failed to delete custom field: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to delete field")
+	}
+
+	data, err := s.customFieldListData(userID)
+	if err != nil {
+		return err
+	}
+	return c.Render(http.StatusOK, "custom-field-list", data)
+}
+
+func (s *CustomFieldService) customFieldListData(userID uint) (map[string]interface{}, error) {
+	defs, err := s.Repo.GetCustomFieldDefinitions(userID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"Fields": customFieldViews(defs, nil),
+		"Types":  entity.CustomFieldTypes,
+	}, nil
+}
+
+func customFieldKeyExists(repo repository.ContactDao, userID uint, key string) (bool, error) {
+	defs, err := repo.GetCustomFieldDefinitions(userID)
+	if err != nil {
+		return false, err
+	}
+	for _, def := range defs {
+		if def.Key == key {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// customFieldsFromForm collects the custom field inputs present in a submitted form
+func customFieldsFromForm(form map[string][]string) map[string]string {
+	values := map[string]string{}
+	for name, value := range form {
+		if key, ok := strings.CutPrefix(name, customFieldFormPrefix); ok && len(value) > 0 {
+			values[key] = value[0]
+		}
+	}
+	return values
+}
+
+// customFieldViews prepares the user's fields and a contact's values for display
+func customFieldViews(defs []entity.CustomFieldDefinition, values entity.CustomFields) []map[string]interface{} {
+	var res []map[string]interface{}
+	for _, def := range defs {
+		inputType := string(def.Type)
+		if def.Type == entity.TextField || def.Type == entity.SelectField {
+			inputType = "text"
+		}
+		res = append(res, map[string]interface{}{
+			"Id":        def.ID,
+			"Key":       def.Key,
+			"Name":      customFieldFormPrefix + def.Key,
+			"Label":     def.Label,
+			"Type":      string(def.Type),
+			"InputType": inputType,
+			"Options":   []string(def.Options),
+			"Value":     values[def.Key],
+		})
+	}
+	return res
+}
@@ -6,6 +6,7 @@ import (
 	"sort"
 	"time"
 
+	"github.com/La002/personal-crm/pkg/entity"
 	"github.com/La002/personal-crm/pkg/repository"
 	"github.com/labstack/echo/v4"
 )
@@ -21,42 +22,46 @@ func NewDashboardService(repo *repository.ContactRepo) *DashboardService {
 }
 
 type DashboardData struct {
-	UpcomingEvents []EventInfo
-	NeedsAttention []AttentionInfo
-	RecentActivity []ActivityInfo
+	UpcomingEvents []EventInfo     `json:"upcoming_events"`
+	KeepInTouch    []OverdueInfo   `json:"keep_in_touch"`
+	Drifting       []DriftingInfo  `json:"drifting"`
+	DueTasks       []TaskInfo      `json:"due_tasks"`
+	SmartLists     []SmartListInfo `json:"smart_lists"` // the pinned ones
+	RecentActivity []ActivityInfo  `json:"recent_activity"`
 }
 
 type EventInfo struct {
-	ID          uint
-	ContactID   uint
-	Name        string
-	EventType   string // "Birthday" or "Custom"
-	Title       string // Event title (for custom events)
-	EventDate   string
-	DaysUntil   int
-	DisplayDate string
-}
-
-type AttentionInfo struct {
-	ID            uint
-	Name          string
-	Company       string
-	LastContacted string
-	DaysSince     int
+	ID          uint   `json:"id"`
+	ContactID   uint   `json:"contact_id"`
+	HouseholdID uint   `json:"household_id,omitempty"` // set on household events, instead of ContactID
+	Name        string `json:"name"`
+	EventType   string `json:"event_type"` // "Birthday", "Custom" or "Household"
+	Title       string `json:"title"`      // Event title (for custom events)
+	EventDate   string `json:"event_date"`
+	DaysUntil   int    `json:"days_until"`
+	DisplayDate string `json:"display_date"`
+	Age         int    `json:"age,omitempty"` // age reached on a birthday, when the year is known
 }
 
 type ActivityInfo struct {
-	ID        uint
-	Name      string
-	Company   string
-	Action    string
-	Timestamp string
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	Company   string `json:"company"`
+	Action    string `json:"action"`
+	Timestamp string `json:"timestamp"`
 }
 
 func (s *DashboardService) GetDashboard(c echo.Context) error {
+	return c.Render(http.StatusOK, "dashboard", s.buildDashboard(c))
+}
+
+// buildDashboard collects the dashboard aggregates for the current user.
+// Failures of individual widgets are logged and leave that widget empty.
+func (s *DashboardService) buildDashboard(c echo.Context) DashboardData {
 	userID := c.Get("user_id").(uint)
 
 	now := time.Now()
+	today := entity.Today()
 	allEvents := []EventInfo{}
 
 	// Get upcoming birthdays (next 30 days)
@@ -66,33 +71,21 @@ func (s *DashboardService) GetDashboard(c echo.Context) error {
 	}
 
 	for _, contact := range birthdayContacts {
-		if contact.Birthday == "" {
-			continue
-		}
-
-		bday, err := time.Parse("2006-01-02", contact.Birthday)
-		if err != nil {
-			continue
-		}
-
-		// Calculate next birthday
-		nextBday := time.Date(now.Year(), bday.Month(), bday.Day(), 0, 0, 0, 0, now.Location())
-		if nextBday.Before(now) {
-			nextBday = nextBday.AddDate(1, 0, 0)
-		}
-
-		daysUntil := int(nextBday.Sub(now).Hours() / 24)
-
-		allEvents = append(allEvents, EventInfo{
+		nextBday := contact.Birthday.Next(today)
+		info := EventInfo{
 			ID:          contact.ID,
 			ContactID:   contact.ID,
 			Name:        contact.Name,
 			EventType:   "Birthday",
 			Title:       "",
-			EventDate:   contact.Birthday,
-			DaysUntil:   daysUntil,
-			DisplayDate: nextBday.Format("Jan 2"),
-		})
+			EventDate:   contact.Birthday.String(),
+			DaysUntil:   today.DaysUntil(nextBday),
+			DisplayDate: nextBday.Time(time.UTC).Format("Jan 2"),
+		}
+		if age, ok := contact.Birthday.AgeOn(nextBday); ok {
+			info.Age = age
+		}
+		allEvents = append(allEvents, info)
 	}
 
 	// Get custom events
@@ -102,13 +95,9 @@ func (s *DashboardService) GetDashboard(c echo.Context) error {
 	}
 
 	for _, event := range customEvents {
-		eventDate, err := time.Parse("2006-01-02", event.EventDate)
-		if err != nil {
-			continue
-		}
-
-		// Calculate days until event
-		daysUntil := int(eventDate.Sub(now).Hours() / 24)
+		// Repeating events are shown on their next occurrence
+		next := entity.NextOccurrence(event.EventDate, event.Recurrence, today)
+		daysUntil := today.DaysUntil(next)
 
 		// Only include events in the next 30 days
 		if daysUntil < 0 || daysUntil > 30 {
@@ -127,35 +116,47 @@ func (s *DashboardService) GetDashboard(c echo.Context) error {
 			Name:        contact.Name,
 			EventType:   "Custom",
 			Title:       event.Title,
-			EventDate:   event.EventDate,
+			EventDate:   event.EventDate.String(),
 			DaysUntil:   daysUntil,
-			DisplayDate: eventDate.Format("Jan 2"),
+			DisplayDate: next.Time(time.UTC).Format("Jan 2"),
 		})
 	}
 
-	// Get contacts needing attention (60+ days)
-	attentionContacts, err := s.Repo.GetNeedsAttention(userID, 60)
+	// Get the events shared by households
+	householdEvents, err := upcomingHouseholdEvents(s.Repo, userID, today)
 	if err != nil {
-		c.Logger().Error("Failed to get needs attention: ", err)
+		c.Logger().Error("Failed to get household events: ", err)
 	}
+	allEvents = append(allEvents, householdEvents...)
 
-	attention := []AttentionInfo{}
-	for _, contact := range attentionContacts {
-		daysSince := 0
-		if contact.LastContacted != "" {
-			lastContact, err := time.Parse("2006-01-02", contact.LastContacted)
-			if err == nil {
-				daysSince = int(now.Sub(lastContact).Hours() / 24)
-			}
-		}
+	// Get the contacts that are due to be contacted according to their cadence
+	keepInTouch, err := keepInTouchQueue(s.Repo, userID)
+	if err != nil {
+		c.Logger().Error("Failed to get keep-in-touch queue: ", err)
+		keepInTouch = []OverdueInfo{}
+	}
 
-		attention = append(attention, AttentionInfo{
-			ID:            contact.ID,
-			Name:          contact.Name,
-			Company:       contact.Company,
-			LastContacted: contact.LastContacted,
-			DaysSince:     daysSince,
-		})
+	// Get the relationships that are fading, by their strength score
+	drifting, err := driftingContacts(s.Repo, userID, driftingLimit)
+	if err != nil {
+		c.Logger().Error("Failed to get drifting contacts: ", err)
+		drifting = []DriftingInfo{}
+	}
+
+	// Get the open tasks that are overdue or due soon
+	tasks, err := dueTasks(s.Repo, userID)
+	if err != nil {
+		c.Logger().Error("Failed to get due tasks: ", err)
+		tasks = []TaskInfo{}
+	}
+
+	// Get the pinned smart lists with their current counts
+	smartLists, err := pinnedSmartLists(s.Repo, userID)
+	if err != nil {
+		c.Logger().Error("Failed to get smart lists: ", err)
+	}
+	if smartLists == nil {
+		smartLists = []SmartListInfo{}
 	}
 
 	// Get recent activity
@@ -206,11 +207,12 @@ func (s *DashboardService) GetDashboard(c echo.Context) error {
 		return allEvents[i].DaysUntil < allEvents[j].DaysUntil
 	})
 
-	data := DashboardData{
+	return DashboardData{
 		UpcomingEvents: allEvents,
-		NeedsAttention: attention,
+		KeepInTouch:    keepInTouch,
+		Drifting:       drifting,
+		DueTasks:       tasks,
+		SmartLists:     smartLists,
 		RecentActivity: activity,
 	}
-
-	return c.Render(http.StatusOK, "dashboard", data)
 }
@@ -0,0 +1,309 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/archive"
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/labstack/echo/v4"
+)
+
+type ExportService struct {
+	Repo repository.ContactDao
+}
+
+func NewExportService(repo repository.ContactDao) *ExportService {
+	return &ExportService{
+		Repo: repo,
+	}
+}
+
+// ExportAccount downloads all data of the signed in user as a zip archive
+func (s *ExportService) ExportAccount(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	if err := writeAccountArchive(c, s.Repo, userID); err != nil {
+		c.Logger().Error("Failed to export account: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to export your data")
+	}
+	return nil
+}
+
+// writeAccountArchive builds the archive in memory first, so a failure can
+// still be reported instead of sending a truncated zip
+func writeAccountArchive(c echo.Context, repo repository.ContactDao, userID uint) error {
+	data, err := repo.GetAccountData(userID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	var buf bytes.Buffer
+	if err := archive.Write(&buf, accountArchive(data, now)); err != nil {
+		return err
+	}
+
+	filename := fmt.Sprintf("personal-crm-export-%s.zip", now.Format("2006-01-02"))
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	return c.Blob(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// accountArchive converts the stored records into the archive format
+func accountArchive(data repository.AccountData, exportedAt time.Time) archive.Archive {
+	a := archive.Archive{
+		ExportedAt: exportedAt,
+		User: archive.User{
+			ID:        data.User.ID,
+			Email:     data.User.Email,
+			Picture:   data.User.Picture,
+			CreatedAt: data.User.CreatedAt,
+		},
+		Calendar: archive.CalendarSync{
+			Enabled:         data.User.CalendarSyncEnabled,
+			LastSync:        optionalTime(data.User.LastCalendarSync),
+			Contacts:        []archive.ContactCalendar{},
+			Events:          []archive.EventCalendar{},
+			HouseholdEvents: []archive.EventCalendar{},
+		},
+	}
+
+	for _, contact := range data.Contacts {
+		tags := make([]string, 0, len(contact.Tags))
+		for _, tag := range contact.Tags {
+			tags = append(tags, tag.Name)
+		}
+		customFields := map[string]string{}
+		for key, value := range contact.CustomFields {
+			customFields[key] = value
+		}
+		emails, phones := []archive.Channel{}, []archive.Channel{}
+		for _, channel := range contact.Channels {
+			value := archive.Channel{Value: channel.Value, Label: channel.Label, Primary: channel.Primary}
+			if channel.Kind == entity.EmailChannel {
+				emails = append(emails, value)
+			} else {
+				phones = append(phones, value)
+			}
+		}
+		addresses := []archive.Address{}
+		for _, address := range contact.Addresses {
+			addresses = append(addresses, archive.Address{
+				Label:      address.Label,
+				Street:     address.Street,
+				City:       address.City,
+				Region:     address.Region,
+				PostalCode: address.PostalCode,
+				Country:    address.Country,
+				Primary:    address.Primary,
+			})
+		}
+		a.Contacts = append(a.Contacts, archive.Contact{
+			ID:           contact.ID,
+			Name:         contact.Name,
+			Relationship: string(contact.Relationship),
+			Industry:     contact.Industry,
+			Company:      contact.Company,
+			Birthday:     contact.Birthday.String(),
+			Vip:          contact.Vip,
+			Spouse:       contact.Spouse,
+			Children:     contact.Children,
+			Location:     contact.Location,
+			PhoneNumber:  contact.PhoneNumber,
+			Email:        contact.Email,
+			Emails:       emails,
+			Phones:       phones,
+			Addresses:    addresses,
+			LinkedIn:     contact.LinkedIn,
+			Instagram:    contact.Instagram,
+			X:            contact.X,
+			LastMet:      contact.LastMet.String(),
+			LastContact:  contact.LastContacted.String(),
+			LastUpdate:   contact.LastUpdate.String(),
+			CadenceDays:  contact.CadenceDays,
+			SnoozedUntil: contact.SnoozedUntil.String(),
+			Status:       contact.Status,
+			Tags:         tags,
+			CustomFields: customFields,
+			CreatedAt:    contact.CreatedAt,
+			UpdatedAt:    contact.UpdatedAt,
+		})
+		for _, note := range contact.Notes {
+			a.Notes = append(a.Notes, archive.Note{
+				ID:        note.ID,
+				ContactID: note.ContactID,
+				Body:      note.Body,
+				Pinned:    note.Pinned,
+				CreatedAt: note.CreatedAt,
+				EditedAt:  note.EditedAt,
+			})
+		}
+		if contact.GoogleCalendarEventID != "" || contact.CalendarSyncEnabled {
+			a.Calendar.Contacts = append(a.Calendar.Contacts, archive.ContactCalendar{
+				ContactID:     contact.ID,
+				GoogleEventID: contact.GoogleCalendarEventID,
+				SyncEnabled:   contact.CalendarSyncEnabled,
+				SyncedAt:      optionalTime(contact.CalendarSyncedAt),
+			})
+		}
+	}
+
+	for _, event := range data.Events {
+		a.Events = append(a.Events, archive.Event{
+			ID:         event.ID,
+			ContactID:  event.ContactID,
+			Title:      event.Title,
+			EventDate:  event.EventDate.String(),
+			Recurrence: event.Recurrence,
+		})
+		if event.GoogleCalendarEventID != "" {
+			a.Calendar.Events = append(a.Calendar.Events, archive.EventCalendar{EventID: event.ID, GoogleEventID: event.GoogleCalendarEventID})
+		}
+	}
+
+	for _, interaction := range data.Interactions {
+		contactIDs := make([]uint, 0, len(interaction.Contacts))
+		for _, contact := range interaction.Contacts {
+			contactIDs = append(contactIDs, contact.ID)
+		}
+		a.Interactions = append(a.Interactions, archive.Interaction{
+			ID:         interaction.ID,
+			Type:       string(interaction.Type),
+			OccurredOn: interaction.OccurredOn.String(),
+			Notes:      interaction.Notes,
+			ContactIDs: contactIDs,
+		})
+	}
+
+	for _, task := range data.Tasks {
+		contactIDs := make([]uint, 0, len(task.Contacts))
+		for _, contact := range task.Contacts {
+			contactIDs = append(contactIDs, contact.ID)
+		}
+		a.Tasks = append(a.Tasks, archive.Task{
+			ID:          task.ID,
+			Title:       task.Title,
+			Notes:       task.Notes,
+			DueOn:       task.DueOn.String(),
+			Priority:    string(task.Priority),
+			Status:      string(task.Status),
+			CompletedAt: task.CompletedAt,
+			ContactIDs:  contactIDs,
+			CreatedAt:   task.CreatedAt,
+		})
+		if task.GoogleCalendarEventID != "" {
+			a.Calendar.Tasks = append(a.Calendar.Tasks, archive.TaskCalendar{TaskID: task.ID, GoogleEventID: task.GoogleCalendarEventID})
+		}
+	}
+
+	for _, tag := range data.Tags {
+		a.Tags = append(a.Tags, archive.Tag{ID: tag.ID, Name: tag.Name, Color: tag.Color, CadenceDays: tag.CadenceDays})
+	}
+
+	for _, def := range data.CustomFields {
+		a.CustomFields = append(a.CustomFields, archive.CustomField{
+			Key:      def.Key,
+			Label:    def.Label,
+			Type:     string(def.Type),
+			Options:  append([]string{}, def.Options...),
+			Position: def.Position,
+		})
+	}
+
+	a.RelationshipTypes = entity.RelationshipTypeNames(data.RelationshipTypes)
+
+	for _, household := range data.Households {
+		h := archive.Household{
+			ID:         household.ID,
+			Name:       household.Name,
+			Greeting:   household.Greeting,
+			Street:     household.Street,
+			City:       household.City,
+			Region:     household.Region,
+			PostalCode: household.PostalCode,
+			Country:    household.Country,
+			ContactIDs: make([]uint, 0, len(household.Members)),
+			Events:     make([]archive.HouseholdEvent, 0, len(household.Events)),
+		}
+		for _, member := range household.Members {
+			h.ContactIDs = append(h.ContactIDs, member.ID)
+		}
+		for _, event := range household.Events {
+			h.Events = append(h.Events, archive.HouseholdEvent{
+				ID:         event.ID,
+				Title:      event.Title,
+				EventDate:  event.EventDate.String(),
+				Recurrence: event.Recurrence,
+			})
+			if event.GoogleCalendarEventID != "" {
+				a.Calendar.HouseholdEvents = append(a.Calendar.HouseholdEvents, archive.EventCalendar{EventID: event.ID, GoogleEventID: event.GoogleCalendarEventID})
+			}
+		}
+		a.Households = append(a.Households, h)
+	}
+
+	for _, change := range data.History {
+		a.History = append(a.History, archive.Change{
+			ContactID: change.PersonId,
+			Revision:  change.Revision.String(),
+			Field:     change.ChangedField,
+			OldValue:  change.OldValue,
+			NewValue:  change.NewValue,
+			Actor:     change.Actor,
+			ChangedAt: change.ChangedAt,
+		})
+	}
+
+	for _, merge := range data.Merges {
+		a.Merges = append(a.Merges, archive.Merge{
+			SurvivorID:  merge.SurvivorID,
+			MergedID:    merge.MergedID,
+			MergedName:  merge.MergedName,
+			Snapshot:    merge.Snapshot,
+			TakenFields: append([]string{}, merge.TakenFields...),
+			Actor:       merge.Actor,
+			MergedAt:    merge.CreatedAt,
+		})
+	}
+
+	photos := make(map[uint]bool)
+	for _, contact := range data.Contacts {
+		if contact.PhotoID != nil {
+			photos[*contact.PhotoID] = true
+		}
+	}
+	for _, attachment := range data.Attachments {
+		a.Attachments = append(a.Attachments, archive.Attachment{
+			ID:          attachment.ID,
+			ContactID:   attachment.ContactID,
+			FileName:    attachment.FileName,
+			ContentType: attachment.ContentType,
+			Size:        attachment.Size,
+			Photo:       photos[attachment.ID],
+			CreatedAt:   attachment.CreatedAt,
+		})
+	}
+
+	for _, link := range data.Links {
+		a.Links = append(a.Links, archive.Link{
+			ID:            link.ID,
+			FromContactID: link.FromContactID,
+			ToContactID:   link.ToContactID,
+			Type:          link.LinkType.Key,
+			Note:          link.Note,
+			CreatedAt:     link.CreatedAt,
+		})
+	}
+	return a
+}
+
+// optionalTime returns nil for the zero time, which means "never" in the database
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
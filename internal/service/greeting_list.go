@@ -0,0 +1,98 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/labstack/echo/v4"
+)
+
+// GreetingEntry is one addressee of a greeting list, a contact or a household
+type GreetingEntry struct {
+	HouseholdID uint                  `json:"household_id,omitempty"`
+	ContactIDs  []uint                `json:"contact_ids"`
+	Name        string                `json:"name"`
+	Greeting    string                `json:"greeting"`
+	Address     entity.ContactAddress `json:"address"`
+	Members     []string              `json:"members"`
+}
+
+// buildGreetingList returns one entry per contact, in the order of contacts.
+// When perHousehold is set, contacts that belong to a household are replaced
+// by one entry for the household, at the place of its first contact. A
+// contact in several households is addressed with the first one by name.
+func buildGreetingList(contacts []entity.Contact, households []entity.Household, perHousehold bool) []GreetingEntry {
+	householdOf := map[uint]*entity.Household{}
+	if perHousehold {
+		for i := range households {
+			for _, member := range households[i].Members {
+				if _, ok := householdOf[member.ID]; !ok {
+					householdOf[member.ID] = &households[i]
+				}
+			}
+		}
+	}
+
+	entries := make([]GreetingEntry, 0, len(contacts))
+	added := map[uint]bool{}
+	for _, contact := range contacts {
+		household, ok := householdOf[contact.ID]
+		if !ok {
+			address, _ := contact.PrimaryAddress()
+			entries = append(entries, GreetingEntry{
+				ContactIDs: []uint{contact.ID},
+				Name:       contact.Name,
+				Greeting:   contactSalutation(contact),
+				Address:    address,
+				Members:    []string{contact.Name},
+			})
+			continue
+		}
+		if added[household.ID] {
+			continue
+		}
+		added[household.ID] = true
+
+		entry := GreetingEntry{
+			HouseholdID: household.ID,
+			Name:        household.Name,
+			Greeting:    household.Salutation(),
+		}
+		entry.Address, _ = household.MailingAddress()
+		for _, member := range household.Members {
+			entry.ContactIDs = append(entry.ContactIDs, member.ID)
+			entry.Members = append(entry.Members, member.Name)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// contactSalutation addresses a single contact by first name, e.g. "Dear Anna"
+func contactSalutation(contact entity.Contact) string {
+	if fields := strings.Fields(contact.Name); len(fields) > 0 {
+		return "Dear " + fields[0]
+	}
+	return "Dear " + contact.Name
+}
+
+// writeGreetingList downloads a greeting list as a CSV file, e.g. for mail
+// merging cards or printing address labels
+func writeGreetingList(c echo.Context, entries []GreetingEntry) error {
+	rows := [][]string{{"Name", "Greeting", "Street", "Postal Code", "City", "Region", "Country", "Address", "Members"}}
+	for _, e := range entries {
+		rows = append(rows, []string{e.Name, e.Greeting, e.Address.Street, e.Address.PostalCode, e.Address.City,
+			e.Address.Region, e.Address.Country, strings.Join(e.Address.Lines(), "\n"), strings.Join(e.Members, ", ")})
+	}
+
+	var buf bytes.Buffer
+	if err := csv.NewWriter(&buf).WriteAll(rows); err != nil {
+		return err
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", "greetings.csv"))
+	return c.Blob(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// HistoryRevision groups the field changes made by a single save
+type HistoryRevision struct {
+	Revision  string          `json:"revision"`
+	ChangedAt string          `json:"changed_at"`
+	Actor     string          `json:"actor"`
+	Current   bool            `json:"current"`
+	Changes   []HistoryChange `json:"changes"`
+}
+
+type HistoryChange struct {
+	Field    string `json:"field"`
+	Label    string `json:"label"`
+	OldValue string `json:"old_value"`
+	NewValue string `json:"new_value"`
+}
+
+// groupRevisions turns change rows ordered newest first into revisions
+func groupRevisions(changes []entity.DetailChanges) []HistoryRevision {
+	revisions := []HistoryRevision{}
+	index := map[uuid.UUID]int{}
+
+	for _, change := range changes {
+		i, ok := index[change.Revision]
+		if !ok {
+			i = len(revisions)
+			index[change.Revision] = i
+			revisions = append(revisions, HistoryRevision{
+				Revision:  change.Revision.String(),
+				ChangedAt: change.ChangedAt.Format("Jan 2, 2006 15:04"),
+				Actor:     change.Actor,
+				Current:   i == 0,
+			})
+		}
+
+		revisions[i].Changes = append(revisions[i].Changes, HistoryChange{
+			Field:    change.ChangedField,
+			Label:    repository.ContactFieldLabel(change.ChangedField),
+			OldValue: change.OldValue,
+			NewValue: change.NewValue,
+		})
+	}
+	return revisions
+}
+
+// GetContactHistory renders the change history panel of a contact
+func (s *ContactService) GetContactHistory(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var contactID uint
+	if _, err := fmt.Sscan(c.Param("id"), &contactID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid contact ID")
+	}
+
+	changes, err := s.Repo.GetContactHistory(contactID, userID)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{
+		"Id":        contactID,
+		"Revisions": groupRevisions(changes),
+	}
+	return c.Render(http.StatusOK, "history", data)
+}
+
+// RestoreContactRevision restores a contact to the version saved by a revision
+func (s *ContactService) RestoreContactRevision(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var contactID uint
+	if _, err := fmt.Sscan(c.Param("id"), &contactID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid contact ID")
+	}
+
+	revision, err := uuid.Parse(c.Param("revision"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Invalid revision")
+	}
+
+	if _, err := s.Repo.RestoreContactRevision(contactID, userID, revision, actorFromContext(c)); err != nil {
+		c.Logger().Error("Failed to restore revision: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to restore this version")
+	}
+
+	// Reload the detail page so every block shows the restored values
+	c.Response().Header().Set("HX-Redirect", fmt.Sprintf("/contacts/%d", contactID))
+	return c.NoContent(http.StatusOK)
+}
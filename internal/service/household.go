@@ -0,0 +1,388 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type HouseholdService struct {
+	Repo            repository.ContactDao
+	CalendarService *CalendarService
+}
+
+func NewHouseholdService(repo repository.ContactDao, calendarService *CalendarService) *HouseholdService {
+	return &HouseholdService{
+		Repo:            repo,
+		CalendarService: calendarService,
+	}
+}
+
+// validateHousehold trims the name, greeting and address of a household and
+// checks their lengths
+func validateHousehold(household *entity.Household) error {
+	household.Name = strings.TrimSpace(household.Name)
+	household.Greeting = strings.TrimSpace(household.Greeting)
+	household.SetAddress(household.Address())
+	if household.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(household.Name) > 64 {
+		return fmt.Errorf("name must be at most 64 characters")
+	}
+	if len(household.Greeting) > 255 {
+		return fmt.Errorf("greeting must be at most 255 characters")
+	}
+	if len(household.Street) > 255 || len(household.City) > 255 || len(household.Region) > 255 || len(household.Country) > 255 {
+		return fmt.Errorf("address parts must be at most 255 characters")
+	}
+	if len(household.PostalCode) > 32 {
+		return fmt.Errorf("postal code must be at most 32 characters")
+	}
+	return nil
+}
+
+// householdNameTaken reports whether another household of the user than
+// householdID is named name
+func householdNameTaken(repo repository.ContactDao, userID, householdID uint, name string) (bool, error) {
+	existing, err := repo.GetHouseholdByName(name, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return existing.ID != householdID, nil
+}
+
+// householdFromForm reads the name, greeting and shared address of a household
+func householdFromForm(c echo.Context, household *entity.Household) {
+	household.Name = c.FormValue("name")
+	household.Greeting = c.FormValue("greeting")
+	household.SetAddress(entity.ContactAddress{
+		Street:     c.FormValue("street"),
+		City:       c.FormValue("city"),
+		Region:     c.FormValue("region"),
+		PostalCode: c.FormValue("postal_code"),
+		Country:    c.FormValue("country"),
+	})
+}
+
+// parseHouseholdID reads the householdId path parameter
+func parseHouseholdID(c echo.Context) (uint, error) {
+	var householdID uint
+	if _, err := fmt.Sscan(c.Param("householdId"), &householdID); err != nil {
+		return 0, fmt.Errorf("invalid household ID")
+	}
+	return householdID, nil
+}
+
+// GetHouseholds renders the household overview
+func (s *HouseholdService) GetHouseholds(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	households, err := s.Repo.GetHouseholds(userID)
+	if err != nil {
+		return err
+	}
+	return c.Render(http.StatusOK, "households", map[string]interface{}{"Households": getHouseholdMaps(households)})
+}
+
+// CreateHousehold creates a household and opens its page
+func (s *HouseholdService) CreateHousehold(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	household := entity.Household{UserID: userID}
+	householdFromForm(c, &household)
+	if err := validateHousehold(&household); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if taken, err := householdNameTaken(s.Repo, userID, 0, household.Name); err != nil {
+		return err
+	} else if taken {
+		return c.String(http.StatusConflict, "A household with this name already exists")
+	}
+
+	if err := s.Repo.CreateHousehold(&household); err != nil {
+		c.Logger().Error("Failed to create household: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to create household")
+	}
+	c.Response().Header().Set("HX-Redirect", fmt.Sprintf("/households/%d", household.ID))
+	return c.NoContent(http.StatusCreated)
+}
+
+// GetHousehold renders the page of a household with its members, shared
+// address and events
+func (s *HouseholdService) GetHousehold(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	householdID, err := parseHouseholdID(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Invalid household ID")
+	}
+
+	household, err := s.Repo.GetHouseholdByID(householdID, userID)
+	if err != nil {
+		return c.String(http.StatusNotFound, "Household not found")
+	}
+
+	res := getHouseholdMap(household)
+	res["Events"] = household.Events
+	members, err := s.householdMembersData(household)
+	if err != nil {
+		return err
+	}
+	for key, value := range members {
+		res[key] = value
+	}
+	return c.Render(http.StatusOK, "household", res)
+}
+
+// UpdateHousehold saves the name, greeting and shared address of a household
+func (s *HouseholdService) UpdateHousehold(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	householdID, err := parseHouseholdID(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Invalid household ID")
+	}
+
+	household := entity.Household{ID: householdID, UserID: userID}
+	householdFromForm(c, &household)
+	if err := validateHousehold(&household); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if taken, err := householdNameTaken(s.Repo, userID, householdID, household.Name); err != nil {
+		return err
+	} else if taken {
+		return c.String(http.StatusConflict, "A household with this name already exists")
+	}
+
+	if err := s.Repo.UpdateHousehold(&household); err != nil {
+		c.Logger().Error("Failed to update household: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to update household")
+	}
+	c.Response().Header().Set("HX-Redirect", fmt.Sprintf("/households/%d", householdID))
+	return c.NoContent(http.StatusOK)
+}
+
+// DeleteHousehold deletes a household and its events, its members are kept
+func (s *HouseholdService) DeleteHousehold(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	householdID, err := parseHouseholdID(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Invalid household ID")
+	}
+
+	if err := s.CalendarService.DeleteHousehold(userID, householdID); err != nil {
+		c.Logger().Error("Failed to delete household: ", err)
+		if isCalendarAuthError(err) {
+			return c.String(http.StatusUnauthorized, "Authentication expired. Please logout and login again.")
+		}
+		return c.String(http.StatusInternalServerError, "Failed to delete household")
+	}
+	c.Response().Header().Set("HX-Redirect", "/households")
+	return c.NoContent(http.StatusOK)
+}
+
+// AddHouseholdMembers adds the contacts in contact_ids to a household
+func (s *HouseholdService) AddHouseholdMembers(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	householdID, err := parseHouseholdID(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Invalid household ID")
+	}
+	form, err := c.FormParams()
+	if err != nil {
+		return err
+	}
+	contactIDs := parseContactIDs(form["contact_ids"])
+	if len(contactIDs) == 0 {
+		return c.String(http.StatusBadRequest, "Select at least one contact")
+	}
+
+	if _, err := s.Repo.AddHouseholdMembers(householdID, userID, contactIDs); err != nil {
+		c.Logger().Error("Failed to add household members: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to add members")
+	}
+	return s.renderHouseholdMembers(c, householdID, userID)
+}
+
+// RemoveHouseholdMember removes a contact from a household
+func (s *HouseholdService) RemoveHouseholdMember(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	householdID, err := parseHouseholdID(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Invalid household ID")
+	}
+	var contactID uint
+	if _, err := fmt.Sscan(c.Param("contactId"), &contactID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid contact ID")
+	}
+
+	if _, err := s.Repo.RemoveHouseholdMembers(householdID, userID, []uint{contactID}); err != nil {
+		c.Logger().Error("Failed to remove household member: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to remove member")
+	}
+	return s.renderHouseholdMembers(c, householdID, userID)
+}
+
+// CreateHouseholdEvent adds an event shared by the household, like an
+// anniversary, to the calendar
+func (s *HouseholdService) CreateHouseholdEvent(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	householdID, err := parseHouseholdID(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Invalid household ID")
+	}
+
+	title := strings.TrimSpace(c.FormValue("title"))
+	eventDate, err := entity.ParseDate(c.FormValue("event_date"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Invalid event date: "+err.Error())
+	}
+	if title == "" || eventDate.IsZero() {
+		return c.String(http.StatusBadRequest, "Title and event date are required")
+	}
+	recurrence := c.FormValue("recurrence")
+	if recurrence == "" {
+		recurrence = "none"
+	}
+
+	event, err := s.CalendarService.CreateHouseholdEvent(userID, householdID, title, eventDate, recurrence)
+	if err != nil {
+		c.Logger().Error("Failed to create household event: ", err)
+		if isCalendarAuthError(err) {
+			return c.String(http.StatusUnauthorized, "Authentication expired. Please logout and login again.")
+		}
+		return c.String(http.StatusInternalServerError, "Failed to create event. Please try again.")
+	}
+	return c.Render(http.StatusOK, "household-event-row", event)
+}
+
+func (s *HouseholdService) DeleteHouseholdEvent(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	var eventID uint
+	if _, err := fmt.Sscan(c.Param("eventId"), &eventID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid event ID")
+	}
+
+	if err := s.CalendarService.DeleteHouseholdEvent(userID, eventID); err != nil {
+		c.Logger().Error("Failed to delete household event: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to delete event")
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *HouseholdService) renderHouseholdMembers(c echo.Context, householdID, userID uint) error {
+	household, err := s.Repo.GetHouseholdByID(householdID, userID)
+	if err != nil {
+		return c.String(http.StatusNotFound, "Household not found")
+	}
+	data, err := s.householdMembersData(household)
+	if err != nil {
+		return err
+	}
+	return c.Render(http.StatusOK, "household-members", data)
+}
+
+// householdMembersData lists the members of a household and the first
+// contacts that can be added to it
+func (s *HouseholdService) householdMembersData(household entity.Household) (map[string]interface{}, error) {
+	var memberIDs []uint
+	var members []map[string]interface{}
+	for _, member := range household.Members {
+		memberIDs = append(memberIDs, member.ID)
+		address, _ := member.PrimaryAddress()
+		members = append(members, map[string]interface{}{
+			"Id":      member.ID,
+			"Name":    member.Name,
+			"Email":   member.Email,
+			"Address": address.Summary(),
+		})
+	}
+	candidates, err := s.Repo.SearchContactNames(household.UserID, "", memberIDs, contactOptionsLimit)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"HouseholdId":       household.ID,
+		"Members":           members,
+		"Candidates":        contactOptions(candidates),
+		"ContactOptionsURL": contactOptionsURL(memberIDs),
+	}, nil
+}
+
+// getHouseholdMap is the template data of a household
+func getHouseholdMap(household entity.Household) map[string]interface{} {
+	var names []string
+	for _, member := range household.Members {
+		names = append(names, member.Name)
+	}
+	address, _ := household.MailingAddress()
+	return map[string]interface{}{
+		"Id":            household.ID,
+		"Name":          household.Name,
+		"Greeting":      household.Greeting,
+		"Salutation":    household.Salutation(),
+		"Street":        household.Street,
+		"City":          household.City,
+		"Region":        household.Region,
+		"PostalCode":    household.PostalCode,
+		"Country":       household.Country,
+		"AddressLines":  address.Lines(),
+		"SharedAddress": !household.Address().Empty(),
+		"MemberNames":   strings.Join(names, ", "),
+		"MemberCount":   len(household.Members),
+	}
+}
+
+func getHouseholdMaps(households []entity.Household) []map[string]interface{} {
+	var res []map[string]interface{}
+	for _, household := range households {
+		res = append(res, getHouseholdMap(household))
+	}
+	return res
+}
+
+// upcomingHouseholdEvents returns the household events of the next 30 days
+// for the dashboard. Repeating events are shown on their next occurrence.
+func upcomingHouseholdEvents(repo repository.ContactDao, userID uint, today entity.Date) ([]EventInfo, error) {
+	events, err := repo.GetHouseholdEvents(userID)
+	if err != nil || len(events) == 0 {
+		return nil, err
+	}
+	households, err := repo.GetHouseholds(userID)
+	if err != nil {
+		return nil, err
+	}
+	names := map[uint]string{}
+	for _, household := range households {
+		names[household.ID] = household.Name
+	}
+
+	var res []EventInfo
+	for _, event := range events {
+		next := entity.NextOccurrence(event.EventDate, event.Recurrence, today)
+		daysUntil := today.DaysUntil(next)
+		if daysUntil < 0 || daysUntil > 30 {
+			continue
+		}
+		res = append(res, EventInfo{
+			ID:          event.ID,
+			HouseholdID: event.HouseholdID,
+			Name:        names[event.HouseholdID],
+			EventType:   "Household",
+			Title:       event.Title,
+			EventDate:   event.EventDate.String(),
+			DaysUntil:   daysUntil,
+			DisplayDate: next.Time(time.UTC).Format("Jan 2"),
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/labstack/echo/v4"
+)
+
+type InteractionService struct {
+	Repo repository.ContactDao
+}
+
+func NewInteractionService(repo repository.ContactDao) *InteractionService {
+	return &InteractionService{
+		Repo: repo,
+	}
+}
+
+// parseInteraction validates the submitted type and date of an interaction
+func parseInteraction(userID uint, interactionType, date, notes string) (*entity.Interaction, error) {
+	t := entity.InteractionType(interactionType)
+	if !t.Valid() {
+		return nil, fmt.Errorf("type must be one of call, meeting, message, email")
+	}
+
+	occurredOn, err := entity.ParseDate(date)
+	if err != nil || occurredOn.IsZero() {
+		return nil, fmt.Errorf("date must be a date in YYYY-MM-DD format")
+	}
+	if entity.Today().Before(occurredOn) {
+		return nil, fmt.Errorf("date cannot be in the future")
+	}
+
+	return &entity.Interaction{
+		UserID:     userID,
+		Type:       t,
+		OccurredOn: occurredOn,
+		Notes:      strings.TrimSpace(notes),
+	}, nil
+}
+
+// CreateInteraction logs an interaction from the contact detail page. Other
+// contacts that took part can be selected with the "with" field.
+func (s *InteractionService) CreateInteraction(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var contactID uint
+	if _, err := fmt.Sscan(c.Param("id"), &contactID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid contact ID")
+	}
+
+	interaction, err := parseInteraction(userID, c.FormValue("type"), c.FormValue("date"), c.FormValue("notes"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	form, err := c.FormParams()
+	if err != nil {
+		return err
+	}
+
+	contactIDs := []uint{contactID}
+	for _, value := range form["with"] {
+		var id uint
+		if _, err := fmt.Sscan(value, &id); err == nil && id != contactID {
+			contactIDs = append(contactIDs, id)
+		}
+	}
+
+	if err := s.Repo.CreateInteraction(interaction, contactIDs); err != nil {
+		c.Logger().Error("Failed to log interaction: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to log interaction")
+	}
+
+	// Fetch again so the response includes the participants and refreshed dates
+	created, err := s.Repo.GetInteractionByID(interaction.ID, userID)
+	if err != nil {
+		return err
+	}
+	contact, err := s.Repo.GetContact(fmt.Sprintf("%d", contactID), userID)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{
+		"Interaction": getInteractionMap(created, contactID),
+		"Contact":     getContactMapLong(contact),
+	}
+	return c.Render(http.StatusOK, "interaction-created", data)
+}
+
+func (s *InteractionService) DeleteInteraction(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var contactID, interactionID uint
+	if _, err := fmt.Sscan(c.Param("id"), &contactID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid contact ID")
+	}
+	if _, err := fmt.Sscan(c.Param("interactionId"), &interactionID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid interaction ID")
+	}
+
+	if err := s.Repo.DeleteInteraction(interactionID, userID); err != nil {
+		c.Logger().Error("Failed to delete interaction: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to delete interaction")
+	}
+
+	contact, err := s.Repo.GetContact(fmt.Sprintf("%d", contactID), userID)
+	if err != nil {
+		return err
+	}
+
+	// The row is removed by the swap, only the refreshed dates are sent out of band
+	return c.Render(http.StatusOK, "last-contact-oob", getContactMapLong(contact))
+}
+
+// getInteractionMap prepares an interaction for display on the page of contactID
+func getInteractionMap(interaction entity.Interaction, contactID uint) map[string]interface{} {
+	var others []map[string]interface{}
+	for _, contact := range interaction.Contacts {
+		if contact.ID == contactID {
+			continue
+		}
+		others = append(others, map[string]interface{}{
+			"Id":   contact.ID,
+			"Name": contact.Name,
+		})
+	}
+
+	return map[string]interface{}{
+		"Id":        interaction.ID,
+		"ContactId": contactID,
+		"Type":      string(interaction.Type),
+		"Date":      interaction.OccurredOn.String(),
+		"Notes":     interaction.Notes,
+		"With":      others,
+	}
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/entity"
+)
+
+func TestParseInteraction(t *testing.T) {
+	today := entity.Today()
+	tests := []struct {
+		typ     string
+		date    string
+		want    entity.Date
+		wantErr string
+	}{
+		{"call", "2024-02-29", entity.Date{Year: 2024, Month: time.February, Day: 29}, ""},
+		{"meeting", today.String(), today, ""},
+		{"visit", "2024-02-29", entity.Date{}, "type must be one of call, meeting, message, email"},
+		{"call", "", entity.Date{}, "date must be a date in YYYY-MM-DD format"},
+		{"call", "2023-02-29", entity.Date{}, "date must be a date in YYYY-MM-DD format"},
+		{"call", "29/02/2024", entity.Date{}, "date must be a date in YYYY-MM-DD format"},
+		{"call", today.AddDays(1).String(), entity.Date{}, "date cannot be in the future"},
+	}
+	for _, tt := range tests {
+		interaction, err := parseInteraction(1, tt.typ, tt.date, " notes ")
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("parseInteraction(%q, %q) error = %v, want %q", tt.typ, tt.date, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseInteraction(%q, %q): %v", tt.typ, tt.date, err)
+			continue
+		}
+		if interaction.OccurredOn != tt.want || interaction.Notes != "notes" {
+			t.Errorf("parseInteraction(%q, %q) = %v, %q, want %v, %q", tt.typ, tt.date, interaction.OccurredOn, interaction.Notes, tt.want, "notes")
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/labstack/echo/v4"
+)
+
+type LinkService struct {
+	Repo repository.ContactDao
+}
+
+func NewLinkService(repo repository.ContactDao) *LinkService {
+	return &LinkService{
+		Repo: repo,
+	}
+}
+
+// inverseLinkSuffix marks a link type chosen in its inverse direction in the
+// link form, e.g. "manager:inverse" for "Reports to"
+const inverseLinkSuffix = ":inverse"
+
+// CreateLink links the contact in the path to another contact
+func (s *LinkService) CreateLink(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var contactID, otherID uint
+	if _, err := fmt.Sscan(c.Param("id"), &contactID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid contact ID")
+	}
+	if _, err := fmt.Sscan(c.FormValue("contact_id"), &otherID); err != nil {
+		return c.String(http.StatusBadRequest, "Choose a contact to link")
+	}
+
+	key, inverse := strings.CutSuffix(c.FormValue("type"), inverseLinkSuffix)
+	linkType, err := s.Repo.GetLinkTypeByKey(key)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Invalid link type")
+	}
+
+	link := &entity.ContactLink{
+		UserID:        userID,
+		FromContactID: contactID,
+		ToContactID:   otherID,
+		LinkTypeID:    linkType.ID,
+		Note:          strings.TrimSpace(c.FormValue("note")),
+	}
+	if inverse {
+		link.FromContactID, link.ToContactID = otherID, contactID
+	}
+	if err := s.Repo.CreateContactLink(link); err != nil {
+		if errors.Is(err, repository.ErrLinkExists) {
+			return c.String(http.StatusConflict, "These contacts are already linked this way")
+		}
+		c.Logger().Error("Failed to link contacts: ", err)
+		return c.String(http.StatusBadRequest, "Failed to link contacts")
+	}
+
+	links, err := s.Repo.GetContactLinks(contactID, userID)
+	if err != nil {
+		return err
+	}
+	for _, linked := range links {
+		if linked.LinkID == link.ID {
+			return c.Render(http.StatusOK, "link-created", getLinkMap(linked, contactID))
+		}
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *LinkService) DeleteLink(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var linkID uint
+	if _, err := fmt.Sscan(c.Param("linkId"), &linkID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid link ID")
+	}
+
+	if err := s.Repo.DeleteContactLink(linkID, userID); err != nil {
+		c.Logger().Error("Failed to delete link: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to delete link")
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// getLinkMap prepares a link for display on the page of contactID
+func getLinkMap(link repository.LinkedContact, contactID uint) map[string]interface{} {
+	return map[string]interface{}{
+		"Id":        link.LinkID,
+		"ContactId": contactID,
+		"Label":     link.Label,
+		"OtherId":   link.Contact.ID,
+		"OtherName": link.Contact.Name,
+		"Note":      link.Note,
+	}
+}
+
+// linkTypeOptions lists every link type in both directions for the link form.
+// Symmetric types read the same both ways and are offered once.
+func linkTypeOptions(types []entity.LinkType) []map[string]interface{} {
+	var options []map[string]interface{}
+	for _, linkType := range types {
+		options = append(options, map[string]interface{}{"Value": linkType.Key, "Label": linkType.Label})
+		if !linkType.Symmetric {
+			options = append(options, map[string]interface{}{"Value": linkType.Key + inverseLinkSuffix, "Label": linkType.InverseLabel})
+		}
+	}
+	return options
+}
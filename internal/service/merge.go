@@ -0,0 +1,171 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/labstack/echo/v4"
+)
+
+type MergeService struct {
+	Repo            repository.ContactDao
+	CalendarService *CalendarService
+}
+
+func NewMergeService(repo repository.ContactDao, calendarService *CalendarService) *MergeService {
+	return &MergeService{
+		Repo:            repo,
+		CalendarService: calendarService,
+	}
+}
+
+// mergeChoiceFormPrefix prefixes the names of the per field choices in the merge form
+const mergeChoiceFormPrefix = "choice_"
+
+// GetDuplicates renders the list of probable duplicate contacts
+func (s *MergeService) GetDuplicates(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	pairs, err := s.Repo.FindDuplicateContacts(userID)
+	if err != nil {
+		return err
+	}
+
+	var res []map[string]interface{}
+	for _, pair := range pairs {
+		res = append(res, map[string]interface{}{
+			"Id":         pair.Contact.ID,
+			"Name":       pair.Contact.Name,
+			"Email":      pair.Contact.Email,
+			"Phone":      pair.Contact.PhoneNumber,
+			"OtherId":    pair.Other.ID,
+			"OtherName":  pair.Other.Name,
+			"OtherEmail": pair.Other.Email,
+			"OtherPhone": pair.Other.PhoneNumber,
+			"Score":      fmt.Sprintf("%.0f%%", pair.Score*100),
+			"Reasons":    strings.Join(pair.Reasons, ", "),
+		})
+	}
+	return c.Render(http.StatusOK, "duplicates", map[string]interface{}{"Pairs": res})
+}
+
+// GetMergePreview renders the field by field comparison of two contacts. The
+// contact in the path survives, otherId is merged into it.
+func (s *MergeService) GetMergePreview(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var survivorID, mergedID uint
+	if _, err := fmt.Sscan(c.Param("id"), &survivorID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid contact ID")
+	}
+	if _, err := fmt.Sscan(c.Param("otherId"), &mergedID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid contact ID")
+	}
+	if survivorID == mergedID {
+		return c.String(http.StatusBadRequest, "A contact cannot be merged into itself")
+	}
+
+	survivor, err := s.Repo.GetContact(fmt.Sprintf("%d", survivorID), userID)
+	if err != nil {
+		return err
+	}
+	merged, err := s.Repo.GetContact(fmt.Sprintf("%d", mergedID), userID)
+	if err != nil {
+		return err
+	}
+
+	var fields []map[string]interface{}
+	for _, field := range repository.MergeFields(&survivor, &merged) {
+		if field.SurvivorValue == "" && field.MergedValue == "" {
+			continue
+		}
+		fields = append(fields, map[string]interface{}{
+			"Name":          mergeChoiceFormPrefix + field.Column,
+			"Label":         field.Label,
+			"SurvivorValue": field.SurvivorValue,
+			"MergedValue":   field.MergedValue,
+			"Same":          field.SurvivorValue == field.MergedValue,
+			"Default":       string(field.Default),
+		})
+	}
+
+	data := map[string]interface{}{
+		"Id":           survivor.ID,
+		"Name":         survivor.Name,
+		"OtherId":      merged.ID,
+		"OtherName":    merged.Name,
+		"Fields":       fields,
+		"BothCalendar": survivor.GoogleCalendarEventID != "" && merged.GoogleCalendarEventID != "",
+	}
+	return c.Render(http.StatusOK, "merge", data)
+}
+
+// MergeContacts merges otherId into the contact in the path and opens the survivor
+func (s *MergeService) MergeContacts(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var survivorID, mergedID uint
+	if _, err := fmt.Sscan(c.Param("id"), &survivorID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid contact ID")
+	}
+	if _, err := fmt.Sscan(c.Param("otherId"), &mergedID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid contact ID")
+	}
+
+	form, err := c.FormParams()
+	if err != nil {
+		return err
+	}
+	choices, err := mergeChoicesFromForm(form)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if _, err := mergeContacts(s.Repo, s.CalendarService, survivorID, mergedID, userID, choices, actorFromContext(c)); err != nil {
+		c.Logger().Error("Failed to merge contacts: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to merge contacts")
+	}
+
+	c.Response().Header().Set("HX-Redirect", fmt.Sprintf("/contacts/%d", survivorID))
+	return c.NoContent(http.StatusOK)
+}
+
+// mergeChoicesFromForm collects the choice_<column> values of the merge form
+func mergeChoicesFromForm(form map[string][]string) (map[string]entity.MergeSource, error) {
+	choices := map[string]entity.MergeSource{}
+	for name, value := range form {
+		column, ok := strings.CutPrefix(name, mergeChoiceFormPrefix)
+		if !ok || len(value) == 0 {
+			continue
+		}
+		source := entity.MergeSource(value[0])
+		if !source.Valid() {
+			return nil, fmt.Errorf("invalid choice %q for %s", value[0], repository.ContactFieldLabel(column))
+		}
+		choices[column] = source
+	}
+	return choices, nil
+}
+
+// mergeContacts merges two contacts. When both have a birthday reminder in
+// Google Calendar, the merged contact's reminder is deleted first so it is not
+// left behind; failing to reach Google does not block the merge.
+func mergeContacts(repo repository.ContactDao, calendar *CalendarService, survivorID, mergedID, userID uint, choices map[string]entity.MergeSource, actor string) (entity.Contact, error) {
+	survivor, err := repo.GetContact(fmt.Sprintf("%d", survivorID), userID)
+	if err != nil {
+		return entity.Contact{}, err
+	}
+	merged, err := repo.GetContact(fmt.Sprintf("%d", mergedID), userID)
+	if err != nil {
+		return entity.Contact{}, err
+	}
+
+	if calendar != nil && survivor.GoogleCalendarEventID != "" && merged.GoogleCalendarEventID != "" {
+		_ = calendar.DeleteBirthdayReminder(userID, fmt.Sprintf("%d", mergedID))
+	}
+
+	return repo.MergeContacts(survivorID, mergedID, userID, choices, actor)
+}
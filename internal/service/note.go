@@ -0,0 +1,153 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/labstack/echo/v4"
+)
+
+var errEmptyNote = errors.New("body is required")
+
+type NoteService struct {
+	Repo repository.ContactDao
+}
+
+func NewNoteService(repo repository.ContactDao) *NoteService {
+	return &NoteService{
+		Repo: repo,
+	}
+}
+
+// CreateNote adds a note from the contact detail page
+func (s *NoteService) CreateNote(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var contactID uint
+	if _, err := fmt.Sscan(c.Param("id"), &contactID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid contact ID")
+	}
+
+	note := entity.Note{
+		UserID:    userID,
+		ContactID: contactID,
+		Body:      strings.TrimSpace(c.FormValue("body")),
+		Pinned:    c.FormValue("pinned") != "",
+	}
+	if note.Body == "" {
+		return c.String(http.StatusBadRequest, errEmptyNote.Error())
+	}
+
+	if err := s.Repo.CreateNote(&note); err != nil {
+		c.Logger().Error("Failed to create note: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to create note")
+	}
+
+	data := map[string]interface{}{
+		"Note": getNoteMap(note),
+	}
+	return c.Render(http.StatusOK, "note-created", data)
+}
+
+// EditNoteForm swaps a note for a form to edit its body
+func (s *NoteService) EditNoteForm(c echo.Context) error {
+	return s.renderNote(c, "note-edit")
+}
+
+// GetNote renders a note, used to cancel an edit
+func (s *NoteService) GetNote(c echo.Context) error {
+	return s.renderNote(c, "note-row")
+}
+
+// UpdateNote saves the edited body of a note
+func (s *NoteService) UpdateNote(c echo.Context) error {
+	return s.changeNote(c, func(note *entity.Note) error {
+		note.Body = strings.TrimSpace(c.FormValue("body"))
+		if note.Body == "" {
+			return errEmptyNote
+		}
+		return nil
+	})
+}
+
+// TogglePin pins or unpins a note. Pinned notes move to the top of the list
+// the next time the page is loaded.
+func (s *NoteService) TogglePin(c echo.Context) error {
+	return s.changeNote(c, func(note *entity.Note) error {
+		note.Pinned = !note.Pinned
+		return nil
+	})
+}
+
+// changeNote applies a change to the note in the URL and re-renders it
+func (s *NoteService) changeNote(c echo.Context, change func(note *entity.Note) error) error {
+	userID := c.Get("user_id").(uint)
+
+	var noteID uint
+	if _, err := fmt.Sscan(c.Param("noteId"), &noteID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid note ID")
+	}
+
+	note, err := s.Repo.GetNoteByID(noteID, userID)
+	if err != nil {
+		return c.String(http.StatusNotFound, "Note not found")
+	}
+	if err := change(&note); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if err := s.Repo.UpdateNote(&note); err != nil {
+		c.Logger().Error("Failed to update note: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to update note")
+	}
+	return c.Render(http.StatusOK, "note-row", getNoteMap(note))
+}
+
+// renderNote renders the note in the URL with the given template
+func (s *NoteService) renderNote(c echo.Context, name string) error {
+	userID := c.Get("user_id").(uint)
+
+	var noteID uint
+	if _, err := fmt.Sscan(c.Param("noteId"), &noteID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid note ID")
+	}
+
+	note, err := s.Repo.GetNoteByID(noteID, userID)
+	if err != nil {
+		return c.String(http.StatusNotFound, "Note not found")
+	}
+	return c.Render(http.StatusOK, name, getNoteMap(note))
+}
+
+func (s *NoteService) DeleteNote(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var noteID uint
+	if _, err := fmt.Sscan(c.Param("noteId"), &noteID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid note ID")
+	}
+
+	if err := s.Repo.DeleteNote(noteID, userID); err != nil {
+		c.Logger().Error("Failed to delete note: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to delete note")
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+func getNoteMap(note entity.Note) map[string]interface{} {
+	res := map[string]interface{}{
+		"Id":        note.ID,
+		"Body":      note.Body,
+		"Pinned":    note.Pinned,
+		"CreatedAt": note.CreatedAt.Format("2006-01-02 15:04"),
+		"EditedAt":  "",
+	}
+	if note.EditedAt != nil {
+		res["EditedAt"] = note.EditedAt.Format("2006-01-02 15:04")
+	}
+	return res
+}
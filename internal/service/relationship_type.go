@@ -0,0 +1,183 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type RelationshipTypeService struct {
+	Repo repository.ContactDao
+}
+
+func NewRelationshipTypeService(repo repository.ContactDao) *RelationshipTypeService {
+	return &RelationshipTypeService{
+		Repo: repo,
+	}
+}
+
+// validateRelationshipTypeName trims a relationship type name and checks it
+func validateRelationshipTypeName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("name is required")
+	}
+	if len(name) > 64 {
+		return "", fmt.Errorf("name must be at most 64 characters")
+	}
+	return name, nil
+}
+
+// relationshipTypeNameTaken reports whether another relationship type of the
+// user than typeID is named name
+func relationshipTypeNameTaken(repo repository.ContactDao, userID, typeID uint, name string) (bool, error) {
+	existing, err := repo.GetRelationshipTypeByName(name, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return existing.ID != typeID, nil
+}
+
+// resolveRelationship returns the relationship type of the user named name,
+// ignoring case, as it is stored on contacts. An empty name means no
+// relationship.
+func resolveRelationship(repo repository.ContactDao, userID uint, name string) (entity.Relation, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil
+	}
+	types, err := repo.GetRelationshipTypes(userID)
+	if err != nil {
+		return "", err
+	}
+	t, ok := entity.FindRelationshipType(types, name)
+	if !ok {
+		return "", unknownRelationshipError(types, name)
+	}
+	return entity.Relation(t.Name), nil
+}
+
+func unknownRelationshipError(types []entity.RelationshipType, name string) error {
+	if len(types) == 0 {
+		return fmt.Errorf("unknown relationship %q, no relationship types are defined", name)
+	}
+	return fmt.Errorf("relationship %q must be one of %s", name, strings.Join(entity.RelationshipTypeNames(types), ", "))
+}
+
+// relationshipNames returns the names of the user's relationship types for
+// the relationship filters and selects of the templates
+func relationshipNames(repo repository.ContactDao, userID uint) ([]string, error) {
+	types, err := repo.GetRelationshipTypes(userID)
+	if err != nil {
+		return nil, err
+	}
+	return entity.RelationshipTypeNames(types), nil
+}
+
+// GetRelationshipTypes renders the relationship type management page
+func (s *RelationshipTypeService) GetRelationshipTypes(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	data, err := s.relationshipTypeListData(userID)
+	if err != nil {
+		return err
+	}
+	return c.Render(http.StatusOK, "relationship-types", data)
+}
+
+func (s *RelationshipTypeService) CreateRelationshipType(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	name, err := validateRelationshipTypeName(c.FormValue("name"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if taken, err := relationshipTypeNameTaken(s.Repo, userID, 0, name); err != nil {
+		return err
+	} else if taken {
+		return c.String(http.StatusConflict, "A relationship type with this name already exists")
+	}
+
+	if err := s.Repo.CreateRelationshipType(&entity.RelationshipType{UserID: userID, Name: name}); err != nil {
+		c.Logger().Error("Failed to create relationship type: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to create relationship type")
+	}
+	return s.renderRelationshipTypes(c, userID)
+}
+
+// RenameRelationshipType renames a type, its contacts and smart lists follow
+func (s *RelationshipTypeService) RenameRelationshipType(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var typeID uint
+	if _, err := fmt.Sscan(c.Param("typeId"), &typeID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid relationship type ID")
+	}
+	t, err := s.Repo.GetRelationshipTypeByID(typeID, userID)
+	if err != nil {
+		return c.String(http.StatusNotFound, "Relationship type not found")
+	}
+
+	if t.Name, err = validateRelationshipTypeName(c.FormValue("name")); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if taken, err := relationshipTypeNameTaken(s.Repo, userID, t.ID, t.Name); err != nil {
+		return err
+	} else if taken {
+		return c.String(http.StatusConflict, "A relationship type with this name already exists")
+	}
+
+	if err := s.Repo.UpdateRelationshipType(&t, actorFromContext(c)); err != nil {
+		c.Logger().Error("Failed to rename relationship type: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to rename relationship type")
+	}
+	return s.renderRelationshipTypes(c, userID)
+}
+
+func (s *RelationshipTypeService) DeleteRelationshipType(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var typeID uint
+	if _, err := fmt.Sscan(c.Param("typeId"), &typeID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid relationship type ID")
+	}
+
+	if err := s.Repo.DeleteRelationshipType(typeID, userID, actorFromContext(c)); err != nil {
+		c.Logger().Error("Failed to delete relationship type: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to delete relationship type")
+	}
+	return s.renderRelationshipTypes(c, userID)
+}
+
+func (s *RelationshipTypeService) renderRelationshipTypes(c echo.Context, userID uint) error {
+	data, err := s.relationshipTypeListData(userID)
+	if err != nil {
+		return err
+	}
+	return c.Render(http.StatusOK, "relationship-type-list", data)
+}
+
+func (s *RelationshipTypeService) relationshipTypeListData(userID uint) (map[string]interface{}, error) {
+	types, err := s.Repo.GetRelationshipTypeCounts(userID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]map[string]interface{}, 0, len(types))
+	for _, t := range types {
+		res = append(res, map[string]interface{}{
+			"Id":           t.ID,
+			"Name":         t.Name,
+			"ContactCount": t.ContactCount,
+		})
+	}
+	return map[string]interface{}{"Types": res}, nil
+}
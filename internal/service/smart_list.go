@@ -0,0 +1,286 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/labstack/echo/v4"
+)
+
+type SmartListService struct {
+	Repo repository.ContactDao
+}
+
+func NewSmartListService(repo repository.ContactDao) *SmartListService {
+	return &SmartListService{
+		Repo: repo,
+	}
+}
+
+// SmartListInfo is a smart list with its current number of contacts
+type SmartListInfo struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Pinned      bool   `json:"pinned"`
+	Count       int64  `json:"count"`
+}
+
+// validateSmartList trims and checks the name and filters of a smart list.
+// The relationship must be one of the user's relationship types.
+func validateSmartList(repo repository.ContactDao, list *entity.SmartList) error {
+	list.Name = strings.TrimSpace(list.Name)
+	if list.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(list.Name) > 64 {
+		return fmt.Errorf("name must be at most 64 characters")
+	}
+	if err := list.Filters.Normalize(); err != nil {
+		return err
+	}
+	relationship, err := resolveRelationship(repo, list.UserID, list.Filters.Relationship)
+	if err != nil {
+		return err
+	}
+	list.Filters.Relationship = string(relationship)
+	return nil
+}
+
+// smartListContactFilters returns the search filters of one of the user's smart lists
+func smartListContactFilters(repo repository.ContactDao, userID, listID uint) (repository.ContactSearchFilters, error) {
+	list, err := repo.GetSmartListByID(listID, userID)
+	if err != nil {
+		return repository.ContactSearchFilters{}, err
+	}
+	return repository.SmartListFilters(list.Filters, time.Now()), nil
+}
+
+// smartListInfos counts the contacts of every list. A list whose filters no
+// longer apply, e.g. after its custom field was deleted, counts as empty.
+func smartListInfos(repo repository.ContactDao, userID uint, lists []entity.SmartList) []SmartListInfo {
+	res := make([]SmartListInfo, 0, len(lists))
+	for _, list := range lists {
+		count, _ := repo.CountContacts(userID, repository.SmartListFilters(list.Filters, time.Now()))
+		res = append(res, SmartListInfo{
+			ID:          list.ID,
+			Name:        list.Name,
+			Description: list.Filters.Describe(),
+			Pinned:      list.Pinned,
+			Count:       count,
+		})
+	}
+	return res
+}
+
+// pinnedSmartLists returns the pinned lists of the user with their counts
+func pinnedSmartLists(repo repository.ContactDao, userID uint) ([]SmartListInfo, error) {
+	lists, err := repo.GetSmartLists(userID)
+	if err != nil {
+		return nil, err
+	}
+	var pinned []entity.SmartList
+	for _, list := range lists {
+		if list.Pinned {
+			pinned = append(pinned, list)
+		}
+	}
+	return smartListInfos(repo, userID, pinned), nil
+}
+
+// GetSmartLists renders the smart lists page
+func (s *SmartListService) GetSmartLists(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	data, err := s.smartListData(userID)
+	if err != nil {
+		return err
+	}
+	defs, err := s.Repo.GetCustomFieldDefinitions(userID)
+	if err != nil {
+		return err
+	}
+	tags, err := s.Repo.GetTags(userID)
+	if err != nil {
+		return err
+	}
+	relationships, err := relationshipNames(s.Repo, userID)
+	if err != nil {
+		return err
+	}
+	data["CustomFields"] = customFieldViews(defs, nil)
+	data["Tags"] = getTagMaps(tags)
+	data["Relationships"] = relationships
+	return c.Render(http.StatusOK, "smart-lists", data)
+}
+
+// CreateSmartList saves the filter built in the form as a named list
+func (s *SmartListService) CreateSmartList(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	filters, err := smartListFiltersFromForm(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	list := entity.SmartList{
+		UserID:  userID,
+		Name:    c.FormValue("name"),
+		Pinned:  c.FormValue("pinned") != "",
+		Filters: filters,
+	}
+	if err := validateSmartList(s.Repo, &list); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if _, err := s.Repo.GetSmartListByName(list.Name, userID); err == nil {
+		return c.String(http.StatusConflict, "A smart list with this name already exists")
+	}
+	if err := s.Repo.CreateSmartList(&list); err != nil {
+		c.Logger().Error("Failed to create smart list: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to create smart list")
+	}
+	return s.renderSmartLists(c, userID)
+}
+
+// TogglePin pins a list to the contacts page and the dashboard, or unpins it
+func (s *SmartListService) TogglePin(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var listID uint
+	if _, err := fmt.Sscan(c.Param("listId"), &listID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid smart list ID")
+	}
+	list, err := s.Repo.GetSmartListByID(listID, userID)
+	if err != nil {
+		return c.String(http.StatusNotFound, "Smart list not found")
+	}
+	list.Pinned = !list.Pinned
+	if err := s.Repo.UpdateSmartList(&list); err != nil {
+		c.Logger().Error("Failed to update smart list: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to update smart list")
+	}
+	return s.renderSmartLists(c, userID)
+}
+
+func (s *SmartListService) DeleteSmartList(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var listID uint
+	if _, err := fmt.Sscan(c.Param("listId"), &listID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid smart list ID")
+	}
+	if err := s.Repo.DeleteSmartList(listID, userID); err != nil {
+		return c.String(http.StatusNotFound, "Smart list not found")
+	}
+	return s.renderSmartLists(c, userID)
+}
+
+// TagSmartList adds a tag to, or removes it from, every contact of a list
+func (s *SmartListService) TagSmartList(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var listID uint
+	if _, err := fmt.Sscan(c.Param("listId"), &listID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid smart list ID")
+	}
+	filters, err := smartListContactFilters(s.Repo, userID, listID)
+	if err != nil {
+		return c.String(http.StatusNotFound, "Smart list not found")
+	}
+	contacts, err := s.Repo.SearchContactsAdvanced(userID, filters)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	contactIDs := contactIDsOf(contacts)
+
+	name := strings.TrimSpace(c.FormValue("tag"))
+	var n int64
+	switch c.FormValue("action") {
+	case "add":
+		tag, err := findOrCreateTag(s.Repo, userID, name, "")
+		if err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+		if n, err = s.Repo.AddTagToContacts(tag.ID, userID, contactIDs); err != nil {
+			c.Logger().Error("Failed to tag smart list: ", err)
+			return c.String(http.StatusInternalServerError, "Failed to add tag")
+		}
+		return c.String(http.StatusOK, fmt.Sprintf("Tagged %d contact(s)", n))
+	case "remove":
+		tag, err := s.Repo.GetTagByName(name, userID)
+		if err != nil {
+			return c.String(http.StatusBadRequest, "Unknown tag")
+		}
+		if n, err = s.Repo.RemoveTagFromContacts(tag.ID, userID, contactIDs); err != nil {
+			c.Logger().Error("Failed to untag smart list: ", err)
+			return c.String(http.StatusInternalServerError, "Failed to remove tag")
+		}
+		return c.String(http.StatusOK, fmt.Sprintf("Untagged %d contact(s)", n))
+	}
+	return c.String(http.StatusBadRequest, "Invalid action")
+}
+
+// GetSmartListFilters renders the pinned list buttons of the contacts page
+func (s *SmartListService) GetSmartListFilters(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	lists, err := pinnedSmartLists(s.Repo, userID)
+	if err != nil {
+		return err
+	}
+	return c.Render(http.StatusOK, "smart-list-filters", lists)
+}
+
+// renderSmartLists re-renders the lists of the smart lists page and lets the
+// contacts page refresh its pinned lists
+func (s *SmartListService) renderSmartLists(c echo.Context, userID uint) error {
+	data, err := s.smartListData(userID)
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set("HX-Trigger", "smartListsChanged")
+	return c.Render(http.StatusOK, "smart-list-list", data)
+}
+
+func (s *SmartListService) smartListData(userID uint) (map[string]interface{}, error) {
+	lists, err := s.Repo.GetSmartLists(userID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"Lists": smartListInfos(s.Repo, userID, lists),
+	}, nil
+}
+
+// smartListFiltersFromForm reads the relationship, vip (true or false),
+// location, not_contacted_days, tags (comma separated) and field and value
+// fields of the smart list form
+func smartListFiltersFromForm(c echo.Context) (entity.SmartListFilters, error) {
+	filters := entity.SmartListFilters{
+		Relationship: c.FormValue("relationship"),
+		Location:     c.FormValue("location"),
+		Tags:         strings.Split(c.FormValue("tags"), ","),
+	}
+	if vip := c.FormValue("vip"); vip != "" {
+		vipOnly, err := strconv.ParseBool(vip)
+		if err != nil {
+			return filters, fmt.Errorf("invalid VIP filter")
+		}
+		filters.Vip = &vipOnly
+	}
+	if days := strings.TrimSpace(c.FormValue("not_contacted_days")); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return filters, fmt.Errorf("not contacted days must be a number")
+		}
+		filters.NotContactedDays = n
+	}
+	if field := c.FormValue("field"); field != "" {
+		filters.CustomFields = map[string]string{field: c.FormValue("value")}
+	}
+	return filters, nil
+}
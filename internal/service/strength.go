@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+)
+
+// driftingLimit is the number of contacts the drifting away widget shows
+const driftingLimit = 10
+
+type StrengthService struct {
+	Repo repository.ContactDao
+}
+
+func NewStrengthService(repo repository.ContactDao) *StrengthService {
+	return &StrengthService{
+		Repo: repo,
+	}
+}
+
+// RecomputeUser scores the relationships with the contacts of a user and
+// stores the scores that changed. It returns how many were updated.
+func (s *StrengthService) RecomputeUser(userID uint, today entity.Date) (int, error) {
+	contacts, inputs, err := s.Repo.GetStrengthInputs(userID, today)
+	if err != nil {
+		return 0, err
+	}
+
+	var changed []repository.ContactStrength
+	for _, contact := range contacts {
+		strength := entity.ComputeStrength(contact, inputs[contact.ID], today)
+		if strength.Score == contact.StrengthScore && strength.DriftingSince == contact.DriftingSince {
+			continue
+		}
+		changed = append(changed, repository.ContactStrength{
+			ContactID:     contact.ID,
+			Score:         strength.Score,
+			DriftingSince: strength.DriftingSince,
+		})
+	}
+	if err := s.Repo.UpdateContactStrengths(userID, changed); err != nil {
+		return 0, err
+	}
+	return len(changed), nil
+}
+
+// Recompute scores the relationships of every user and returns how many
+// scores changed
+func (s *StrengthService) Recompute(ctx context.Context) (int, error) {
+	userIDs, err := s.Repo.GetStrengthUserIDs()
+	if err != nil {
+		return 0, err
+	}
+
+	today := entity.Today()
+	n := 0
+	var errs []error
+	for _, userID := range userIDs {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+		updated, err := s.RecomputeUser(userID, today)
+		n += updated
+		if err != nil {
+			errs = append(errs, fmt.Errorf("user %d: %w", userID, err))
+		}
+	}
+	return n, errors.Join(errs...)
+}
+
+// RunRecompute scores the relationships now and then every interval until
+// ctx is done, reporting the outcome of each run
+func (s *StrengthService) RunRecompute(ctx context.Context, interval time.Duration, report func(updated int, err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		report(s.Recompute(ctx))
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DriftingInfo is a contact in the drifting away widget
+type DriftingInfo struct {
+	ID            uint   `json:"id"`
+	Name          string `json:"name"`
+	Company       string `json:"company"`
+	StrengthScore int    `json:"strength_score"`
+	LastContacted string `json:"last_contacted"`
+	DaysSince     int    `json:"days_since"`
+	DriftingSince string `json:"drifting_since"`
+	Cadence       string `json:"cadence"` // "" without a keep-in-touch cadence
+}
+
+// driftingContacts returns the contacts whose relationship is fading, the
+// weakest first, at most limit of them when limit > 0
+func driftingContacts(repo repository.ContactDao, userID uint, limit int) ([]DriftingInfo, error) {
+	contacts, err := repo.GetDriftingContacts(userID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	today := entity.Today()
+	drifting := []DriftingInfo{}
+	for _, contact := range contacts {
+		days, _ := contact.Cadence()
+		drifting = append(drifting, DriftingInfo{
+			ID:            contact.ID,
+			Name:          contact.Name,
+			Company:       contact.Company,
+			StrengthScore: contact.StrengthScore,
+			LastContacted: contact.LastContacted.String(),
+			DaysSince:     contact.LastContacted.DaysUntil(today),
+			DriftingSince: contact.DriftingSince.String(),
+			Cadence:       entity.CadenceLabel(days),
+		})
+	}
+	return drifting, nil
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type TagService struct {
+	Repo repository.ContactDao
+}
+
+func NewTagService(repo repository.ContactDao) *TagService {
+	return &TagService{
+		Repo: repo,
+	}
+}
+
+// validateTag trims the name, fills in the default color and checks the cadence
+func validateTag(tag *entity.Tag) error {
+	tag.Name = strings.TrimSpace(tag.Name)
+	if tag.Name == "" {
+		return fmt.Errorf("tag name is required")
+	}
+	if len(tag.Name) > 64 {
+		return fmt.Errorf("tag name must be at most 64 characters")
+	}
+	if tag.Color == "" {
+		tag.Color = entity.DefaultTagColor
+	}
+	if !entity.ValidTagColor(tag.Color) {
+		return fmt.Errorf("color must be a hex color like #6366f1")
+	}
+	return entity.ValidCadence(tag.CadenceDays)
+}
+
+// findOrCreateTag returns the user's tag with the given name, creating it if needed
+func findOrCreateTag(repo repository.ContactDao, userID uint, name, color string) (entity.Tag, error) {
+	tag, err := repo.GetTagByName(name, userID)
+	if err == nil {
+		return tag, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return entity.Tag{}, err
+	}
+
+	tag = entity.Tag{UserID: userID, Name: name, Color: color}
+	if err := validateTag(&tag); err != nil {
+		return entity.Tag{}, err
+	}
+	if err := repo.CreateTag(&tag); err != nil {
+		return entity.Tag{}, err
+	}
+	return tag, nil
+}
+
+// parseContactIDs reads the contact_ids form values, ignoring anything that is not an id
+func parseContactIDs(values []string) []uint {
+	var ids []uint
+	for _, value := range values {
+		var id uint
+		if _, err := fmt.Sscan(value, &id); err == nil && id > 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// GetTags renders the tag management page
+func (s *TagService) GetTags(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	data, err := s.tagListData(userID)
+	if err != nil {
+		return err
+	}
+	return c.Render(http.StatusOK, "tags", data)
+}
+
+func (s *TagService) CreateTag(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	cadence, err := entity.ParseCadence(c.FormValue("cadence_days"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	tag := entity.Tag{UserID: userID, Name: c.FormValue("name"), Color: c.FormValue("color"), CadenceDays: cadence}
+	if err := validateTag(&tag); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	if _, err := s.Repo.GetTagByName(tag.Name, userID); err == nil {
+		return c.String(http.StatusConflict, "A tag with this name already exists")
+	}
+	if err := s.Repo.CreateTag(&tag); err != nil {
+		c.Logger().Error("Failed to create tag: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to create tag")
+	}
+
+	data, err := s.tagListData(userID)
+	if err != nil {
+		return err
+	}
+	return c.Render(http.StatusOK, "tag-list", data)
+}
+
+// UpdateTagCadence sets the keep-in-touch cadence of the tag's contacts
+func (s *TagService) UpdateTagCadence(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var tagID uint
+	if _, err := fmt.Sscan(c.Param("tagId"), &tagID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid tag ID")
+	}
+	cadence, err := entity.ParseCadence(c.FormValue("cadence_days"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	tag, err := s.Repo.GetTagByID(tagID, userID)
+	if err != nil {
+		return c.String(http.StatusNotFound, "Tag not found")
+	}
+	tag.CadenceDays = cadence
+	if err := s.Repo.UpdateTag(&tag); err != nil {
+		c.Logger().Error("Failed to update tag: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to update tag")
+	}
+
+	data, err := s.tagListData(userID)
+	if err != nil {
+		return err
+	}
+	return c.Render(http.StatusOK, "tag-list", data)
+}
+
+func (s *TagService) DeleteTag(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var tagID uint
+	if _, err := fmt.Sscan(c.Param("tagId"), &tagID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid tag ID")
+	}
+
+	if err := s.Repo.DeleteTag(tagID, userID); err != nil {
+		c.Logger().Error("Failed to delete tag: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to delete tag")
+	}
+
+	data, err := s.tagListData(userID)
+	if err != nil {
+		return err
+	}
+	return c.Render(http.StatusOK, "tag-list", data)
+}
+
+// AddContactTag tags a contact from its detail page. Unknown tag names are created.
+func (s *TagService) AddContactTag(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var contactID uint
+	if _, err := fmt.Sscan(c.Param("id"), &contactID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid contact ID")
+	}
+
+	tag, err := findOrCreateTag(s.Repo, userID, strings.TrimSpace(c.FormValue("name")), c.FormValue("color"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if _, err := s.Repo.AddTagToContacts(tag.ID, userID, []uint{contactID}); err != nil {
+		c.Logger().Error("Failed to tag contact: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to add tag")
+	}
+	return s.renderContactTags(c, contactID, userID)
+}
+
+func (s *TagService) RemoveContactTag(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var contactID, tagID uint
+	if _, err := fmt.Sscan(c.Param("id"), &contactID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid contact ID")
+	}
+	if _, err := fmt.Sscan(c.Param("tagId"), &tagID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid tag ID")
+	}
+
+	if _, err := s.Repo.RemoveTagFromContacts(tagID, userID, []uint{contactID}); err != nil {
+		c.Logger().Error("Failed to untag contact: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to remove tag")
+	}
+	return s.renderContactTags(c, contactID, userID)
+}
+
+// GetTagFilters renders the tag filter buttons of the contacts page
+func (s *TagService) GetTagFilters(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	tags, err := s.Repo.GetTags(userID)
+	if err != nil {
+		return err
+	}
+	return c.Render(http.StatusOK, "tag-filters", getTagMaps(tags))
+}
+
+func (s *TagService) renderContactTags(c echo.Context, contactID, userID uint) error {
+	contact, err := s.Repo.GetContact(fmt.Sprintf("%d", contactID), userID)
+	if err != nil {
+		return err
+	}
+	allTags, err := s.Repo.GetTags(userID)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{
+		"Id":      contact.ID,
+		"Tags":    getContactTagMaps(contact.Tags),
+		"AllTags": getTagMaps(allTags),
+	}
+	return c.Render(http.StatusOK, "contact-tags", data)
+}
+
+func (s *TagService) tagListData(userID uint) (map[string]interface{}, error) {
+	tags, err := s.Repo.GetTags(userID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"Tags":     getTagMaps(tags),
+		"Colors":   entity.TagColors,
+		"Cadences": cadenceOptions(0, "No cadence"),
+	}, nil
+}
+
+func getTagMaps(tags []repository.TagWithCount) []map[string]interface{} {
+	var res []map[string]interface{}
+	for _, tag := range tags {
+		res = append(res, map[string]interface{}{
+			"Id":           tag.ID,
+			"Name":         tag.Name,
+			"Color":        tag.Color,
+			"ContactCount": tag.ContactCount,
+			"Cadence":      entity.CadenceLabel(tag.CadenceDays),
+			"Cadences":     cadenceOptions(tag.CadenceDays, "No cadence"),
+		})
+	}
+	return res
+}
+
+func getContactTagMaps(tags []entity.Tag) []map[string]interface{} {
+	var res []map[string]interface{}
+	for _, tag := range tags {
+		res = append(res, map[string]interface{}{
+			"Id":    tag.ID,
+			"Name":  tag.Name,
+			"Color": tag.Color,
+		})
+	}
+	return res
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// dueTaskDays is how far ahead the dashboard lists open tasks
+const dueTaskDays = 7
+
+type TaskService struct {
+	Repo            repository.ContactDao
+	CalendarService *CalendarService
+}
+
+func NewTaskService(repo repository.ContactDao, calendarService *CalendarService) *TaskService {
+	return &TaskService{
+		Repo:            repo,
+		CalendarService: calendarService,
+	}
+}
+
+// TaskInfo is an open task in the dashboard widget
+type TaskInfo struct {
+	ID        uint          `json:"id"`
+	Title     string        `json:"title"`
+	Priority  string        `json:"priority"`
+	DueOn     string        `json:"due_on"`
+	DaysUntil int           `json:"days_until"` // negative when overdue
+	Contacts  []TaskContact `json:"contacts"`
+}
+
+// TaskContact is a contact a task is about
+type TaskContact struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// dueTasks returns the open tasks that are overdue or due within dueTaskDays,
+// the earliest first
+func dueTasks(repo repository.ContactDao, userID uint) ([]TaskInfo, error) {
+	today := entity.Today()
+	tasks, err := repo.GetTasks(userID, repository.TaskFilters{
+		Status: entity.TaskOpen,
+		DueBy:  today.AddDays(dueTaskDays),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := []TaskInfo{}
+	for _, task := range tasks {
+		info := TaskInfo{
+			ID:        task.ID,
+			Title:     task.Title,
+			Priority:  string(task.Priority),
+			DueOn:     task.DueOn.String(),
+			DaysUntil: today.DaysUntil(task.DueOn),
+			Contacts:  []TaskContact{},
+		}
+		for _, contact := range task.Contacts {
+			info.Contacts = append(info.Contacts, TaskContact{ID: contact.ID, Name: contact.Name})
+		}
+		res = append(res, info)
+	}
+	return res, nil
+}
+
+// parseTask validates the submitted fields of a task. An empty priority is
+// normal and an empty due date leaves the task undated.
+func parseTask(userID uint, title, notes, dueOn, priority string) (*entity.Task, error) {
+	task := &entity.Task{UserID: userID, Status: entity.TaskOpen}
+	if err := applyTask(task, title, notes, dueOn, priority); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// applyTask validates and sets the editable fields of a task
+func applyTask(task *entity.Task, title, notes, dueOn, priority string) error {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return fmt.Errorf("title is required")
+	}
+
+	var due entity.Date
+	if dueOn = strings.TrimSpace(dueOn); dueOn != "" {
+		var err error
+		if due, err = entity.ParseDate(dueOn); err != nil {
+			return fmt.Errorf("due_on must be a date in YYYY-MM-DD format")
+		}
+	}
+
+	p := entity.TaskPriority(priority)
+	if priority == "" {
+		p = entity.NormalPriority
+	}
+	if !p.Valid() {
+		return fmt.Errorf("priority must be one of high, normal, low")
+	}
+
+	task.Title = title
+	task.Notes = strings.TrimSpace(notes)
+	task.DueOn = due
+	task.Priority = p
+	return nil
+}
+
+// setTaskStatus marks a task as open or done, recording when it was completed
+func setTaskStatus(task *entity.Task, status entity.TaskStatus) {
+	if task.Status == status {
+		return
+	}
+	task.Status = status
+	task.CompletedAt = nil
+	if status == entity.TaskDone {
+		now := time.Now()
+		task.CompletedAt = &now
+	}
+}
+
+// syncTaskCalendar brings the calendar mirror of a saved task in line with
+// it: an open task with a due date gets or keeps an event when mirror is
+// set, any other task loses the event it has
+func syncTaskCalendar(calendar *CalendarService, task *entity.Task, mirror bool) error {
+	if mirror && task.Status == entity.TaskOpen && !task.DueOn.IsZero() {
+		return calendar.SyncTask(task.UserID, task)
+	}
+	return calendar.UnsyncTask(task.UserID, task)
+}
+
+// CreateTask adds a task from the contact detail page. Other contacts it is
+// about can be selected with the "with" field.
+func (s *TaskService) CreateTask(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var contactID uint
+	if _, err := fmt.Sscan(c.Param("id"), &contactID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid contact ID")
+	}
+
+	task, err := parseTask(userID, c.FormValue("title"), c.FormValue("notes"), c.FormValue("due_on"), c.FormValue("priority"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	form, err := c.FormParams()
+	if err != nil {
+		return err
+	}
+
+	contactIDs := []uint{contactID}
+	for _, value := range form["with"] {
+		var id uint
+		if _, err := fmt.Sscan(value, &id); err == nil && id != contactID {
+			contactIDs = append(contactIDs, id)
+		}
+	}
+
+	if err := s.Repo.CreateTask(task, contactIDs); err != nil {
+		c.Logger().Error("Failed to create task: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to create task")
+	}
+
+	// The task is kept when the calendar cannot be reached, it is just not mirrored
+	if c.FormValue("calendar") != "" {
+		if err := syncTaskCalendar(s.CalendarService, task, true); err != nil {
+			c.Logger().Error("Failed to add task to calendar: ", err)
+		}
+	}
+
+	data := map[string]interface{}{
+		"Task": getTaskMap(*task, contactID),
+	}
+	return c.Render(http.StatusOK, "task-created", data)
+}
+
+// CompleteTask marks a task as done and removes it from the calendar
+func (s *TaskService) CompleteTask(c echo.Context) error {
+	return s.changeTask(c, func(task *entity.Task) error {
+		setTaskStatus(task, entity.TaskDone)
+		return nil
+	})
+}
+
+// SnoozeTask moves the due date of a task a few days from today
+func (s *TaskService) SnoozeTask(c echo.Context) error {
+	return s.changeTask(c, func(task *entity.Task) error {
+		until, err := snoozeUntil(c.FormValue("days"))
+		if err != nil {
+			return err
+		}
+		task.DueOn = until
+		return nil
+	})
+}
+
+// changeTask applies a change to the task in the URL, keeps its calendar
+// mirror in sync and re-renders it: the dashboard widget when the request
+// comes from the dashboard, else the task's row on the page of contact_id
+func (s *TaskService) changeTask(c echo.Context, change func(task *entity.Task) error) error {
+	userID := c.Get("user_id").(uint)
+
+	var taskID uint
+	if _, err := fmt.Sscan(c.Param("taskId"), &taskID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid task ID")
+	}
+
+	task, err := s.Repo.GetTaskByID(taskID, userID)
+	if err != nil {
+		return c.String(http.StatusNotFound, "Task not found")
+	}
+	if err := change(&task); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if err := s.Repo.UpdateTask(&task, nil); err != nil {
+		c.Logger().Error("Failed to update task: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to update task")
+	}
+	if err := syncTaskCalendar(s.CalendarService, &task, task.GoogleCalendarEventID != ""); err != nil {
+		c.Logger().Error("Failed to update task in calendar: ", err)
+	}
+
+	if c.FormValue("view") == "dashboard" {
+		return s.renderDueTasks(c, userID)
+	}
+	var contactID uint
+	fmt.Sscan(c.FormValue("contact_id"), &contactID)
+	return c.Render(http.StatusOK, "task-row", getTaskMap(task, contactID))
+}
+
+func (s *TaskService) DeleteTask(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var taskID uint
+	if _, err := fmt.Sscan(c.Param("taskId"), &taskID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid task ID")
+	}
+
+	task, err := s.Repo.GetTaskByID(taskID, userID)
+	if err != nil {
+		return c.String(http.StatusNotFound, "Task not found")
+	}
+	if err := s.CalendarService.UnsyncTask(userID, &task); err != nil {
+		c.Logger().Error("Failed to remove task from calendar: ", err)
+	}
+	if err := s.Repo.DeleteTask(taskID, userID); err != nil {
+		c.Logger().Error("Failed to delete task: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to delete task")
+	}
+
+	if c.FormValue("view") == "dashboard" {
+		return s.renderDueTasks(c, userID)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// renderDueTasks re-renders the due tasks widget of the dashboard
+func (s *TaskService) renderDueTasks(c echo.Context, userID uint) error {
+	tasks, err := dueTasks(s.Repo, userID)
+	if err != nil {
+		c.Logger().Error("Failed to get due tasks: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to load due tasks")
+	}
+	return c.Render(http.StatusOK, "due-tasks", tasks)
+}
+
+// getTaskMap prepares a task for display on the page of contactID
+func getTaskMap(task entity.Task, contactID uint) map[string]interface{} {
+	var others []map[string]interface{}
+	for _, contact := range task.Contacts {
+		if contact.ID == contactID {
+			continue
+		}
+		others = append(others, map[string]interface{}{
+			"Id":   contact.ID,
+			"Name": contact.Name,
+		})
+	}
+
+	return map[string]interface{}{
+		"Id":        task.ID,
+		"ContactId": contactID,
+		"Title":     task.Title,
+		"Notes":     task.Notes,
+		"DueOn":     task.DueOn.String(),
+		"Priority":  string(task.Priority),
+		"Done":      task.Status == entity.TaskDone,
+		"Overdue":   task.IsOverdue(entity.Today()),
+		"Calendar":  task.GoogleCalendarEventID != "",
+		"With":      others,
+	}
+}
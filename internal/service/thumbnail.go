@@ -0,0 +1,74 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif" // register the decoders accepted for photos
+	"image/jpeg"
+	_ "image/png"
+)
+
+// thumbnailSize is the width and height of avatar thumbnails in pixels
+const thumbnailSize = 256
+
+// maxThumbnailPixels guards against small files that decode to huge images
+const maxThumbnailPixels = 50_000_000
+
+// makeThumbnail crops the center square of a JPEG, PNG or GIF image and
+// scales it down to a thumbnailSize JPEG. Smaller images are not enlarged and
+// transparent areas become white.
+func makeThumbnail(data []byte) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxThumbnailPixels {
+		return nil, fmt.Errorf("the image is too large (%dx%d pixels)", cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(
+		bounds.Min.X+(bounds.Dx()-side)/2,
+		bounds.Min.Y+(bounds.Dy()-side)/2,
+	))
+
+	size := min(side, thumbnailSize)
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	// Every target pixel is the average of the source pixels it covers
+	for y := 0; y < size; y++ {
+		y0 := crop.Min.Y + y*side/size
+		y1 := max(crop.Min.Y+(y+1)*side/size, y0+1)
+		for x := 0; x < size; x++ {
+			x0 := crop.Min.X + x*side/size
+			x1 := max(crop.Min.X+(x+1)*side/size, x0+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa), n+1
+				}
+			}
+			// The colors are premultiplied, so white fills what is transparent
+			white := 0xffff - a/n
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8((r/n + white) >> 8)
+			dst.Pix[i+1] = uint8((g/n + white) >> 8)
+			dst.Pix[i+2] = uint8((b/n + white) >> 8)
+			dst.Pix[i+3] = 0xff
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/La002/personal-crm/pkg/storage"
+	"github.com/labstack/echo/v4"
+)
+
+// DefaultTrashRetentionDays is how long deleted contacts stay in the trash
+// when the configuration does not say
+const DefaultTrashRetentionDays = 30
+
+type TrashService struct {
+	Repo            repository.ContactDao
+	CalendarService *CalendarService
+	Storage         storage.Storage
+	RetentionDays   int
+}
+
+func NewTrashService(repo repository.ContactDao, calendarService *CalendarService, store storage.Storage, retentionDays int) *TrashService {
+	if retentionDays <= 0 {
+		retentionDays = DefaultTrashRetentionDays
+	}
+	return &TrashService{
+		Repo:            repo,
+		CalendarService: calendarService,
+		Storage:         store,
+		RetentionDays:   retentionDays,
+	}
+}
+
+// purgeDate is the day a contact deleted at deletedAt is purged
+func (s *TrashService) purgeDate(deletedAt time.Time) time.Time {
+	return deletedAt.AddDate(0, 0, s.RetentionDays)
+}
+
+// Purge permanently deletes contacts of the user that are in the trash and
+// removes their Google Calendar events and files. The contacts are gone once
+// the database is updated; failing to clean up after them is reported but
+// does not bring them back.
+func (s *TrashService) Purge(ctx context.Context, userID uint, contactIDs []uint) ([]repository.PurgedContact, error) {
+	purged, err := s.Repo.PurgeContacts(contactIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, contact := range purged {
+		if err := s.CalendarService.DeleteCalendarEvents(userID, contact.CalendarEventIDs); err != nil {
+			errs = append(errs, fmt.Errorf("contact %d: %w", contact.ID, err))
+		}
+		for _, key := range contact.StorageKeys {
+			if err := s.Storage.Delete(ctx, key); err != nil {
+				errs = append(errs, fmt.Errorf("contact %d: failed to delete file %s: %w", contact.ID, key, err))
+			}
+		}
+	}
+	return purged, errors.Join(errs...)
+}
+
+// PurgeExpired purges the contacts of every user that were deleted longer
+// than the retention ago, and the events deleted before that. It returns the
+// number of purged contacts.
+func (s *TrashService) PurgeExpired(ctx context.Context) (int, error) {
+	cutoff := time.Now().AddDate(0, 0, -s.RetentionDays)
+	expired, err := s.Repo.GetExpiredContacts(cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	byUser := map[uint][]uint{}
+	for _, contact := range expired {
+		byUser[contact.UserID] = append(byUser[contact.UserID], contact.ID)
+	}
+
+	n := 0
+	var errs []error
+	for userID, contactIDs := range byUser {
+		purged, err := s.Purge(ctx, userID, contactIDs)
+		n += len(purged)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("user %d: %w", userID, err))
+		}
+	}
+	if _, err := s.Repo.PurgeDeletedEvents(cutoff); err != nil {
+		errs = append(errs, err)
+	}
+	return n, errors.Join(errs...)
+}
+
+// RunPurge purges expired contacts now and then every interval until ctx is
+// done, reporting the outcome of each run
+func (s *TrashService) RunPurge(ctx context.Context, interval time.Duration, report func(purged int, err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		report(s.PurgeExpired(ctx))
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// contactsDeletedTrigger is the HX-Trigger header sent after contacts were
+// moved to the trash, the contacts page shows an undo toast for them. name
+// is the name of a single deleted contact. Further events are sent as well.
+func contactsDeletedTrigger(ids []uint, name string, events ...string) string {
+	trigger := map[string]interface{}{
+		"contactsDeleted": map[string]interface{}{"ids": ids, "name": name},
+	}
+	for _, event := range events {
+		trigger[event] = nil
+	}
+	data, _ := json.Marshal(trigger)
+	return string(data)
+}
+
+// GetTrash renders the trash page
+func (s *TrashService) GetTrash(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	data, err := s.trashData(userID)
+	if err != nil {
+		return err
+	}
+	data["RetentionDays"] = s.RetentionDays
+	return c.Render(http.StatusOK, "trash", data)
+}
+
+// RestoreContact takes a contact out of the trash page
+func (s *TrashService) RestoreContact(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var contactID uint
+	if _, err := fmt.Sscan(c.Param("id"), &contactID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid contact ID")
+	}
+	n, err := s.Repo.RestoreContacts([]uint{contactID}, userID)
+	if err != nil {
+		c.Logger().Error("Failed to restore contact: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to restore contact")
+	}
+	if n == 0 {
+		return c.String(http.StatusNotFound, "Contact not found in the trash")
+	}
+	return s.renderTrash(c, userID)
+}
+
+// UndoDelete restores the contact_ids of the undo toast shown after deleting
+func (s *TrashService) UndoDelete(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	form, err := c.FormParams()
+	if err != nil {
+		return err
+	}
+	contactIDs := parseContactIDs(form["contact_ids"])
+	if len(contactIDs) == 0 {
+		return c.String(http.StatusBadRequest, "No contact to restore")
+	}
+	n, err := s.Repo.RestoreContacts(contactIDs, userID)
+	if err != nil {
+		c.Logger().Error("Failed to restore contacts: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to restore contacts")
+	}
+
+	c.Response().Header().Set("HX-Trigger", "contactsChanged")
+	return c.String(http.StatusOK, fmt.Sprintf("Restored %d contact(s)", n))
+}
+
+// PurgeContact permanently deletes a contact of the trash page
+func (s *TrashService) PurgeContact(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var contactID uint
+	if _, err := fmt.Sscan(c.Param("id"), &contactID); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid contact ID")
+	}
+	purged, err := s.Purge(c.Request().Context(), userID, []uint{contactID})
+	if err != nil {
+		if purged == nil {
+			c.Logger().Error("Failed to purge contact: ", err)
+			return c.String(http.StatusInternalServerError, "Failed to delete contact")
+		}
+		c.Logger().Warn("Contact purged but not cleaned up: ", err)
+	}
+	if len(purged) == 0 {
+		return c.String(http.StatusNotFound, "Contact not found in the trash")
+	}
+	return s.renderTrash(c, userID)
+}
+
+// EmptyTrash permanently deletes every contact in the trash
+func (s *TrashService) EmptyTrash(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	contacts, err := s.Repo.GetDeletedContacts(userID)
+	if err != nil {
+		return err
+	}
+	purged, err := s.Purge(c.Request().Context(), userID, contactIDsOf(contacts))
+	if err != nil {
+		if purged == nil {
+			c.Logger().Error("Failed to empty the trash: ", err)
+			return c.String(http.StatusInternalServerError, "Failed to empty the trash")
+		}
+		c.Logger().Warn("Trash emptied but not cleaned up: ", err)
+	}
+	return s.renderTrash(c, userID)
+}
+
+func (s *TrashService) renderTrash(c echo.Context, userID uint) error {
+	data, err := s.trashData(userID)
+	if err != nil {
+		return err
+	}
+	return c.Render(http.StatusOK, "trash-list", data)
+}
+
+func (s *TrashService) trashData(userID uint) (map[string]interface{}, error) {
+	contacts, err := s.Repo.GetDeletedContacts(userID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]map[string]interface{}, 0, len(contacts))
+	for _, contact := range contacts {
+		res = append(res, map[string]interface{}{
+			"Id":           contact.ID,
+			"Name":         contact.Name,
+			"Relationship": contact.Relationship,
+			"Company":      contact.Company,
+			"DeletedAt":    contact.DeletedAt.Time.Format("2006-01-02 15:04"),
+			"PurgeOn":      s.purgeDate(contact.DeletedAt.Time).Format("2006-01-02"),
+		})
+	}
+	return map[string]interface{}{"Contacts": res}, nil
+}
+
+// contactIDsOf returns the ids of contacts
+func contactIDsOf(contacts []entity.Contact) []uint {
+	ids := make([]uint, 0, len(contacts))
+	for _, contact := range contacts {
+		ids = append(ids, contact.ID)
+	}
+	return ids
+}
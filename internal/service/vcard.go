@@ -0,0 +1,589 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/La002/personal-crm/pkg/repository"
+	"github.com/La002/personal-crm/pkg/vcard"
+	"github.com/labstack/echo/v4"
+)
+
+type VCardService struct {
+	Repo repository.ContactDao
+}
+
+func NewVCardService(repo repository.ContactDao) *VCardService {
+	return &VCardService{
+		Repo: repo,
+	}
+}
+
+const (
+	// maxImportSize limits uploaded import files
+	maxImportSize = 5 << 20
+	// maxImportContacts limits the number of contacts imported at once
+	maxImportContacts = 2000
+)
+
+// Import actions chosen per card on the preview
+const (
+	importCreate = "create"
+	importSkip   = "skip"
+	// importUpdate is followed by the id of the existing contact, e.g. "update:12"
+	importUpdate = "update:"
+)
+
+// importCandidate is a contact read from an import file, before it is saved
+type importCandidate struct {
+	Index      int
+	Contact    entity.Contact
+	Tags       []string
+	Duplicates []repository.DuplicatePair
+	// Error is set when the contact cannot be imported, e.g. it has no name
+	Error string
+}
+
+// defaultAction creates new contacts and updates the existing contact of a duplicate
+func (c importCandidate) defaultAction() string {
+	if c.Error != "" {
+		return importSkip
+	}
+	if len(c.Duplicates) > 0 {
+		return fmt.Sprintf("%s%d", importUpdate, c.Duplicates[0].Contact.ID)
+	}
+	return importCreate
+}
+
+// importResult counts what an import did
+type importResult struct {
+	Created int      `json:"created"`
+	Updated int      `json:"updated"`
+	Skipped int      `json:"skipped"`
+	Errors  []string `json:"errors"`
+}
+
+// contactFromCard maps a vCard onto a contact. All phone numbers, emails and
+// addresses are kept, the preferred ones as primary; other values that have no
+// field of their own are kept in a note.
+func contactFromCard(card vcard.Card, userID uint) entity.Contact {
+	contact := entity.Contact{
+		UserID:       userID,
+		Name:         strings.TrimSpace(card.DisplayName()),
+		Company:      card.Org,
+		CustomFields: entity.CustomFields{},
+	}
+	var extra []string
+
+	if value, ok := card.BirthdayDate(); ok {
+		contact.Birthday, _ = entity.ParseBirthday(value)
+	} else if card.Birthday != "" {
+		extra = append(extra, "Birthday: "+card.Birthday)
+	}
+
+	// Every phone number, email and address is kept, the preferred one as primary
+	for _, tel := range card.Tels {
+		contact.Channels = append(contact.Channels, entity.ContactChannel{
+			Kind: entity.PhoneChannel, Value: tel.Text, Label: vcardLabel(tel.Types), Primary: tel.Pref,
+		})
+	}
+	for _, email := range card.Emails {
+		contact.Channels = append(contact.Channels, entity.ContactChannel{
+			Kind: entity.EmailChannel, Value: email.Text, Label: vcardLabel(email.Types), Primary: email.Pref,
+		})
+	}
+	for _, adr := range card.Addresses {
+		contact.Addresses = append(contact.Addresses, entity.ContactAddress{
+			Label:      vcardLabel(adr.Types),
+			Street:     strings.Join(nonEmpty(adr.POBox, adr.Extended, adr.Street), "\n"),
+			City:       adr.Locality,
+			Region:     adr.Region,
+			PostalCode: adr.PostalCode,
+			Country:    adr.Country,
+			Primary:    adr.Pref,
+		})
+	}
+	if tel, ok := vcard.Preferred(card.Tels); ok {
+		contact.PhoneNumber = tel.Text
+	}
+	if email, ok := vcard.Preferred(card.Emails); ok {
+		contact.Email = email.Text
+	}
+	for i, adr := range card.Addresses {
+		if i == 0 || adr.Pref {
+			contact.Location = adr.Place()
+		}
+	}
+
+	for _, u := range card.URLs {
+		switch socialNetwork(u.Text) {
+		case "linkedin":
+			contact.LinkedIn = u.Text
+		case "instagram":
+			contact.Instagram = u.Text
+		case "x":
+			contact.X = u.Text
+		default:
+			extra = append(extra, "Website: "+u.Text)
+		}
+	}
+
+	if body := strings.Join(nonEmpty(card.Note, strings.Join(extra, "\n")), "\n"); body != "" {
+		contact.Notes = []entity.Note{{Body: body}}
+	}
+	return contact
+}
+
+// vcardBirthday writes a birthday without year in the vCard 4.0 form "--MMDD"
+func vcardBirthday(birthday entity.Date) string {
+	if !birthday.IsZero() && !birthday.HasYear() {
+		return fmt.Sprintf("--%02d%02d", birthday.Month, birthday.Day)
+	}
+	return birthday.String()
+}
+
+// cardFromContact maps a contact onto a vCard
+func cardFromContact(contact entity.Contact) vcard.Card {
+	card := vcard.Card{
+		FormattedName: contact.Name,
+		Org:           contact.Company,
+		Birthday:      vcardBirthday(contact.Birthday),
+		Note:          entity.JoinNotes(contact.Notes),
+	}
+
+	// The last word is taken as the family name, e.g. "Mary Ann Smith"
+	if words := strings.Fields(contact.Name); len(words) > 1 {
+		card.Name = vcard.Name{Family: words[len(words)-1], Given: strings.Join(words[:len(words)-1], " ")}
+	} else {
+		card.Name = vcard.Name{Given: contact.Name}
+	}
+
+	for _, channel := range contact.Channels {
+		value := vcard.Value{Text: channel.Value, Types: vcardTypes(channel.Label), Pref: channel.Primary}
+		if channel.Kind == entity.EmailChannel {
+			card.Emails = append(card.Emails, value)
+		} else {
+			card.Tels = append(card.Tels, value)
+		}
+	}
+	for _, address := range contact.Addresses {
+		card.Addresses = append(card.Addresses, vcard.Address{
+			Types:      vcardTypes(address.Label),
+			Pref:       address.Primary,
+			Street:     address.Street,
+			Locality:   address.City,
+			Region:     address.Region,
+			PostalCode: address.PostalCode,
+			Country:    address.Country,
+		})
+	}
+	for _, social := range []struct{ value, base string }{
+		{contact.LinkedIn, "https://www.linkedin.com/in/"},
+		{contact.Instagram, "https://www.instagram.com/"},
+		{contact.X, "https://x.com/"},
+	} {
+		if social.value != "" {
+			card.URLs = append(card.URLs, vcard.Value{Text: socialURL(social.base, social.value)})
+		}
+	}
+	for _, tag := range contact.Tags {
+		card.Categories = append(card.Categories, tag.Name)
+	}
+	return card
+}
+
+// socialNetwork recognises LinkedIn, Instagram and X profile URLs
+func socialNetwork(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	switch host {
+	case "linkedin.com":
+		return "linkedin"
+	case "instagram.com":
+		return "instagram"
+	case "x.com", "twitter.com":
+		return "x"
+	}
+	return ""
+}
+
+// socialURL turns a stored handle like "@jane" into a profile URL. Values that
+// already are URLs are returned unchanged.
+func socialURL(base, value string) string {
+	if strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") {
+		return value
+	}
+	return base + strings.TrimPrefix(value, "@")
+}
+
+// vcardLabel turns the TYPE parameters of a value into a channel label, e.g.
+// "cell" into "mobile". Types that only describe the kind of value are ignored.
+func vcardLabel(types []string) string {
+	for _, t := range types {
+		switch t = strings.ToLower(t); t {
+		case "pref", "voice", "internet", "x400", "text", "postal", "parcel", "dom", "intl":
+		case "cell":
+			return "mobile"
+		default:
+			return t
+		}
+	}
+	return ""
+}
+
+// vcardTypes is the TYPE parameter written for a channel label
+func vcardTypes(label string) []string {
+	switch label {
+	case "":
+		return nil
+	case "mobile":
+		return []string{"cell"}
+	}
+	return []string{label}
+}
+
+func nonEmpty(values ...string) []string {
+	var res []string
+	for _, value := range values {
+		if value != "" {
+			res = append(res, value)
+		}
+	}
+	return res
+}
+
+// planVCardImport parses a .vcf file and looks up existing contacts with the
+// same email or phone number for every card
+func planVCardImport(repo repository.ContactDao, userID uint, data []byte) ([]importCandidate, error) {
+	cards, err := vcard.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid vCard file: %w", err)
+	}
+	if len(cards) == 0 {
+		return nil, fmt.Errorf("the file contains no contacts")
+	}
+	if len(cards) > maxImportContacts {
+		return nil, fmt.Errorf("the file contains %d contacts, at most %d can be imported at once", len(cards), maxImportContacts)
+	}
+
+	candidates := make([]importCandidate, 0, len(cards))
+	for i, card := range cards {
+		candidate := importCandidate{Index: i, Contact: contactFromCard(card, userID), Tags: card.Categories}
+		if err := validateContact(candidate.Contact); err != nil {
+			candidate.Error = err.Error()
+		} else if candidate.Duplicates, err = repo.FindDuplicatesOf(candidate.Contact); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates, nil
+}
+
+// applyImport saves the candidates according to the chosen actions, keyed by
+// candidate index. Missing actions fall back to the candidate's default.
+func applyImport(repo repository.ContactDao, userID uint, candidates []importCandidate, actions map[int]string, actor string) (importResult, error) {
+	result := importResult{Errors: []string{}}
+	for _, candidate := range candidates {
+		action, ok := actions[candidate.Index]
+		if !ok {
+			action = candidate.defaultAction()
+		}
+		if candidate.Error != "" {
+			action = importSkip
+		}
+
+		var contactID uint
+		switch {
+		case action == importCreate:
+			contact := candidate.Contact
+			if err := repo.CreateContact(&contact); err != nil {
+				return result, err
+			}
+			contactID = contact.ID
+			result.Created++
+		case strings.HasPrefix(action, importUpdate):
+			existing, err := repo.GetContact(strings.TrimPrefix(action, importUpdate), userID)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: contact to update not found", candidate.Contact.Name))
+				result.Skipped++
+				continue
+			}
+			fillEmptyFields(&existing, candidate.Contact)
+			// New phone numbers, emails and addresses are added, the existing primary ones stay
+			existing.Channels = append(existing.Channels, candidate.Contact.Channels...)
+			existing.Addresses = append(existing.Addresses, candidate.Contact.Addresses...)
+			if err := repo.SaveContactWithChannels(&existing, actor); err != nil {
+				return result, err
+			}
+			if err := addNewNotes(repo, existing, candidate.Contact); err != nil {
+				return result, err
+			}
+			contactID = existing.ID
+			result.Updated++
+		default:
+			if candidate.Error != "" {
+				result.Errors = append(result.Errors, fmt.Sprintf("contact %d: %s", candidate.Index+1, candidate.Error))
+			}
+			result.Skipped++
+			continue
+		}
+
+		for _, name := range candidate.Tags {
+			tag, err := findOrCreateTag(repo, userID, strings.TrimSpace(name), "")
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: tag %q: %s", candidate.Contact.Name, name, err))
+				continue
+			}
+			if _, err := repo.AddTagToContacts(tag.ID, userID, []uint{contactID}); err != nil {
+				return result, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// fillEmptyFields copies the values of incoming into the fields of existing
+// that are empty
+func fillEmptyFields(existing *entity.Contact, incoming entity.Contact) {
+	for _, field := range repository.TrackedContactFields {
+		value := field.Get(&incoming)
+		if value == "" || value == "false" {
+			continue
+		}
+		if current := field.Get(existing); current == "" || current == "false" {
+			field.Set(existing, value)
+		}
+	}
+}
+
+// addNewNotes adds the notes of incoming to existing unless one of its notes
+// already contains the text
+func addNewNotes(repo repository.ContactDao, existing entity.Contact, incoming entity.Contact) error {
+	for _, note := range incoming.Notes {
+		known := false
+		for _, current := range existing.Notes {
+			if strings.Contains(current.Body, note.Body) {
+				known = true
+				break
+			}
+		}
+		if known {
+			continue
+		}
+		note.UserID = existing.UserID
+		note.ContactID = existing.ID
+		if err := repo.CreateNote(&note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readImportFile returns the contents of the uploaded file form field
+func readImportFile(c echo.Context, name string) ([]byte, error) {
+	fileHeader, err := c.FormFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("choose a file to import")
+	}
+	if fileHeader.Size > maxImportSize {
+		return nil, fmt.Errorf("the file is larger than %d MB", maxImportSize>>20)
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(io.LimitReader(file, maxImportSize))
+}
+
+// writeVCards sends contacts as a .vcf download
+func writeVCards(c echo.Context, filename string, contacts []entity.Contact) error {
+	cards := make([]vcard.Card, 0, len(contacts))
+	for _, contact := range contacts {
+		cards = append(cards, cardFromContact(contact))
+	}
+
+	var buf bytes.Buffer
+	if err := vcard.Encode(&buf, cards); err != nil {
+		return err
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	return c.Blob(http.StatusOK, "text/vcard; charset=utf-8", buf.Bytes())
+}
+
+// vcardFilename derives a download name from a contact name, e.g. "jane_doe.vcf"
+func vcardFilename(name string) string {
+	if key := entity.CustomFieldKey(name); key != "" {
+		return key + ".vcf"
+	}
+	return "contact.vcf"
+}
+
+// GetImport renders the import page
+func (s *VCardService) GetImport(c echo.Context) error {
+	return c.Render(http.StatusOK, "import", nil)
+}
+
+// PreviewVCardImport parses an uploaded .vcf file and renders what importing it would do
+func (s *VCardService) PreviewVCardImport(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	file, err := readImportFile(c, "file")
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	candidates, err := planVCardImport(s.Repo, userID, file)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	var rows []map[string]interface{}
+	for _, candidate := range candidates {
+		var duplicates []map[string]interface{}
+		for _, duplicate := range candidate.Duplicates {
+			duplicates = append(duplicates, map[string]interface{}{
+				"Action":  fmt.Sprintf("%s%d", importUpdate, duplicate.Contact.ID),
+				"Name":    duplicate.Contact.Name,
+				"Reasons": strings.Join(duplicate.Reasons, ", "),
+			})
+		}
+		rows = append(rows, map[string]interface{}{
+			"Index":      candidate.Index,
+			"Name":       candidate.Contact.Name,
+			"Email":      candidate.Contact.Email,
+			"Phone":      candidate.Contact.PhoneNumber,
+			"Company":    candidate.Contact.Company,
+			"Tags":       strings.Join(candidate.Tags, ", "),
+			"Error":      candidate.Error,
+			"Duplicates": duplicates,
+			"Default":    candidate.defaultAction(),
+		})
+	}
+
+	data := map[string]interface{}{
+		"Rows": rows,
+		// The file is sent back with the confirmation in a hidden textarea
+		"VCard": string(file),
+	}
+	return c.Render(http.StatusOK, "vcard-preview", data)
+}
+
+// ImportVCard imports the previewed file with the actions chosen per contact
+func (s *VCardService) ImportVCard(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	candidates, err := planVCardImport(s.Repo, userID, []byte(c.FormValue("vcard")))
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	actions := map[int]string{}
+	for _, candidate := range candidates {
+		if action := c.FormValue(fmt.Sprintf("action_%d", candidate.Index)); action != "" {
+			actions[candidate.Index] = action
+		}
+	}
+
+	result, err := applyImport(s.Repo, userID, candidates, actions, actorFromContext(c))
+	if err != nil {
+		c.Logger().Error("Failed to import vCard: ", err)
+		return c.String(http.StatusInternalServerError, "Failed to import contacts")
+	}
+	return c.Render(http.StatusOK, "import-result", result)
+}
+
+// ExportContactVCard downloads a single contact as a .vcf file
+func (s *VCardService) ExportContactVCard(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	contact, err := s.Repo.GetContact(c.Param("id"), userID)
+	if err != nil {
+		return err
+	}
+	return writeVCards(c, vcardFilename(contact.Name), []entity.Contact{contact})
+}
+
+// ExportVCard downloads contacts as one .vcf file. The list (a smart list
+// id), contact_ids, tag and filter (vip, non-vip or a relationship)
+// parameters narrow the export, without them every contact is exported.
+func (s *VCardService) ExportVCard(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	filters, status, err := exportFilters(c, s.Repo, userID)
+	if err != nil {
+		return c.String(status, err.Error())
+	}
+
+	contacts, err := s.Repo.SearchContactsAdvanced(userID, filters)
+	if err != nil {
+		return err
+	}
+	return writeVCards(c, "contacts.vcf", contacts)
+}
+
+// ExportGreetings downloads a greeting list of contacts as a CSV file, with
+// the same parameters as ExportVCard. With per_household=true the members of
+// a household are addressed together, on the household's shared address.
+func (s *VCardService) ExportGreetings(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	filters, status, err := exportFilters(c, s.Repo, userID)
+	if err != nil {
+		return c.String(status, err.Error())
+	}
+
+	contacts, err := s.Repo.SearchContactsAdvanced(userID, filters)
+	if err != nil {
+		return err
+	}
+	households, err := s.Repo.GetHouseholds(userID)
+	if err != nil {
+		return err
+	}
+	return writeGreetingList(c, buildGreetingList(contacts, households, c.QueryParam("per_household") == "true"))
+}
+
+// exportFilters reads the parameters narrowing the contact exports. On error
+// it also returns the status to answer with.
+func exportFilters(c echo.Context, repo repository.ContactDao, userID uint) (repository.ContactSearchFilters, int, error) {
+	filters := repository.ContactSearchFilters{}
+	if id := c.QueryParam("list"); id != "" {
+		var listID uint
+		if _, err := fmt.Sscan(id, &listID); err != nil {
+			return filters, http.StatusBadRequest, fmt.Errorf("Invalid smart list ID")
+		}
+		var err error
+		if filters, err = smartListContactFilters(repo, userID, listID); err != nil {
+			return filters, http.StatusNotFound, fmt.Errorf("Smart list not found")
+		}
+	}
+	if ids := c.QueryParams()["contact_ids"]; len(ids) > 0 {
+		// An empty selection must not widen the export to every contact
+		filters.IDs = append([]uint{}, parseContactIDs(ids)...)
+	}
+	if tag := c.QueryParam("tag"); tag != "" {
+		filters.Tags = []string{tag}
+	}
+	switch filter := c.QueryParam("filter"); filter {
+	case "":
+	case "vip", "non-vip":
+		vip := filter == "vip"
+		filters.VipOnly = &vip
+	default:
+		relationship, err := resolveRelationship(repo, userID, filter)
+		if err != nil {
+			return filters, http.StatusBadRequest, fmt.Errorf("Invalid filter: %w", err)
+		}
+		name := string(relationship)
+		filters.Relationship = &name
+	}
+	return filters, http.StatusOK, nil
+}
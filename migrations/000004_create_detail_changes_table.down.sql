@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_detail_changes_revision;
+DROP INDEX IF EXISTS idx_detail_changes_person_id_changed_at;
+DROP INDEX IF EXISTS idx_detail_changes_user_id;
+DROP TABLE IF EXISTS detail_changes;
@@ -0,0 +1,16 @@
+CREATE TABLE detail_changes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    person_id INTEGER NOT NULL REFERENCES contacts(id) ON DELETE CASCADE,
+    -- All fields changed by a single save share the same revision
+    revision UUID NOT NULL,
+    changed_field VARCHAR(255) NOT NULL,
+    old_value TEXT,
+    new_value TEXT,
+    actor VARCHAR(255) NOT NULL,
+    changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_detail_changes_user_id ON detail_changes(user_id);
+CREATE INDEX idx_detail_changes_person_id_changed_at ON detail_changes(person_id, changed_at);
+CREATE INDEX idx_detail_changes_revision ON detail_changes(revision);
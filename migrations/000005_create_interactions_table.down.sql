@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_interaction_contacts_contact_id;
+DROP INDEX IF EXISTS idx_interactions_deleted_at;
+DROP INDEX IF EXISTS idx_interactions_occurred_on;
+DROP INDEX IF EXISTS idx_interactions_user_id;
+DROP TABLE IF EXISTS interaction_contacts;
+DROP TABLE IF EXISTS interactions;
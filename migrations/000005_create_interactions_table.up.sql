@@ -0,0 +1,87 @@
+CREATE TABLE interactions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(32) NOT NULL CHECK (type IN ('call', 'meeting', 'message', 'email')),
+    occurred_on DATE NOT NULL,
+    notes TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE TABLE interaction_contacts (
+    interaction_id INTEGER NOT NULL REFERENCES interactions(id) ON DELETE CASCADE,
+    contact_id INTEGER NOT NULL REFERENCES contacts(id) ON DELETE CASCADE,
+    PRIMARY KEY (interaction_id, contact_id)
+);
+
+CREATE INDEX idx_interactions_user_id ON interactions(user_id);
+CREATE INDEX idx_interactions_occurred_on ON interactions(occurred_on);
+CREATE INDEX idx_interactions_deleted_at ON interactions(deleted_at);
+CREATE INDEX idx_interaction_contacts_contact_id ON interaction_contacts(contact_id);
+
+-- Turn the hand-typed dates into interactions so the derived values keep them.
+-- Deleted contacts are converted too, they can be restored. Values shaped like
+-- YYYY-MM-DD that are no valid date, e.g. 2023-02-30, are kept in the notes.
+DO $$
+DECLARE
+    c RECORD;
+    d DATE;
+    new_id INTEGER;
+    met_imported BOOLEAN;
+BEGIN
+    FOR c IN SELECT id, user_id, last_met, last_contacted FROM contacts LOOP
+        met_imported := FALSE;
+        IF c.last_met ~ '^\d{4}-\d{2}-\d{2}$' THEN
+            BEGIN
+                d := c.last_met::DATE;
+                INSERT INTO interactions (user_id, type, occurred_on, notes)
+                VALUES (c.user_id, 'meeting', d, 'Imported from Last Met')
+                RETURNING id INTO new_id;
+                INSERT INTO interaction_contacts (interaction_id, contact_id) VALUES (new_id, c.id);
+                met_imported := TRUE;
+            EXCEPTION WHEN invalid_datetime_format OR datetime_field_overflow THEN
+                UPDATE contacts SET notes = TRIM(BOTH E'\n' FROM COALESCE(notes, '') || E'\n' || 'Last met: ' || c.last_met)
+                WHERE id = c.id;
+            END;
+        END IF;
+
+        IF c.last_contacted ~ '^\d{4}-\d{2}-\d{2}$' AND NOT (met_imported AND c.last_contacted = c.last_met) THEN
+            BEGIN
+                d := c.last_contacted::DATE;
+                INSERT INTO interactions (user_id, type, occurred_on, notes)
+                VALUES (c.user_id, 'message', d, 'Imported from Last Contacted')
+                RETURNING id INTO new_id;
+                INSERT INTO interaction_contacts (interaction_id, contact_id) VALUES (new_id, c.id);
+            EXCEPTION WHEN invalid_datetime_format OR datetime_field_overflow THEN
+                UPDATE contacts SET notes = TRIM(BOTH E'\n' FROM COALESCE(notes, '') || E'\n' || 'Last contacted: ' || c.last_contacted)
+                WHERE id = c.id;
+            END;
+        END IF;
+    END LOOP;
+END $$;
+
+-- Other free text that could not be imported is kept in the notes as well,
+-- then both columns are derived from the interaction log like the
+-- application does.
+UPDATE contacts
+SET notes = TRIM(BOTH E'\n' FROM COALESCE(notes, '') || E'\n' ||
+        CONCAT_WS(E'\n',
+            CASE WHEN last_met <> '' AND last_met !~ '^\d{4}-\d{2}-\d{2}$' THEN 'Last met: ' || last_met END,
+            CASE WHEN last_contacted <> '' AND last_contacted !~ '^\d{4}-\d{2}-\d{2}$' THEN 'Last contacted: ' || last_contacted END))
+WHERE (last_met <> '' AND last_met !~ '^\d{4}-\d{2}-\d{2}$')
+   OR (last_contacted <> '' AND last_contacted !~ '^\d{4}-\d{2}-\d{2}$');
+
+UPDATE contacts SET
+    last_contacted = COALESCE((
+        SELECT to_char(MAX(i.occurred_on), 'YYYY-MM-DD')
+        FROM interactions i
+        JOIN interaction_contacts ic ON ic.interaction_id = i.id
+        WHERE ic.contact_id = contacts.id
+    ), ''),
+    last_met = COALESCE((
+        SELECT to_char(MAX(i.occurred_on), 'YYYY-MM-DD')
+        FROM interactions i
+        JOIN interaction_contacts ic ON ic.interaction_id = i.id
+        WHERE ic.contact_id = contacts.id AND i.type = 'meeting'
+    ), '');
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_contact_tags_tag_id;
+DROP INDEX IF EXISTS idx_tags_deleted_at;
+DROP INDEX IF EXISTS idx_tags_user_id_name;
+DROP TABLE IF EXISTS contact_tags;
+DROP TABLE IF EXISTS tags;
@@ -0,0 +1,20 @@
+CREATE TABLE tags (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(64) NOT NULL,
+    color VARCHAR(7) NOT NULL DEFAULT '#6366f1',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE TABLE contact_tags (
+    contact_id INTEGER NOT NULL REFERENCES contacts(id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (contact_id, tag_id)
+);
+
+-- Tag names are unique per user, ignoring case
+CREATE UNIQUE INDEX idx_tags_user_id_name ON tags(user_id, LOWER(name)) WHERE deleted_at IS NULL;
+CREATE INDEX idx_tags_deleted_at ON tags(deleted_at);
+CREATE INDEX idx_contact_tags_tag_id ON contact_tags(tag_id);
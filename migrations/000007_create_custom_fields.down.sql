@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_contacts_custom_fields;
+ALTER TABLE contacts DROP COLUMN IF EXISTS custom_fields;
+DROP INDEX IF EXISTS idx_custom_field_definitions_deleted_at;
+DROP INDEX IF EXISTS idx_custom_field_definitions_user_id_key;
+DROP TABLE IF EXISTS custom_field_definitions;
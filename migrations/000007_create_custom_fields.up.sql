@@ -0,0 +1,20 @@
+CREATE TABLE custom_field_definitions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    key VARCHAR(64) NOT NULL,
+    label VARCHAR(255) NOT NULL,
+    type VARCHAR(16) NOT NULL CHECK (type IN ('text', 'date', 'number', 'select', 'url')),
+    -- Allowed values of select fields
+    options JSONB NOT NULL DEFAULT '[]',
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_custom_field_definitions_user_id_key ON custom_field_definitions(user_id, key) WHERE deleted_at IS NULL;
+CREATE INDEX idx_custom_field_definitions_deleted_at ON custom_field_definitions(deleted_at);
+
+-- Values keyed by custom_field_definitions.key
+ALTER TABLE contacts ADD COLUMN custom_fields JSONB NOT NULL DEFAULT '{}';
+CREATE INDEX idx_contacts_custom_fields ON contacts USING GIN (custom_fields);
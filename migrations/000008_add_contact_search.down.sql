@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_events_title_search;
+DROP INDEX IF EXISTS idx_contacts_name_trgm;
+DROP INDEX IF EXISTS idx_contacts_search_vector;
+ALTER TABLE contacts DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,18 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Weighted document used by the q parameter of the contact search.
+-- Names rank highest, then company and industry, location, and notes last.
+ALTER TABLE contacts ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(company, '') || ' ' || coalesce(industry, '')), 'B') ||
+    setweight(to_tsvector('english', coalesce(location, '')), 'C') ||
+    setweight(to_tsvector('english', coalesce(notes, '')), 'D')
+) STORED;
+
+CREATE INDEX idx_contacts_search_vector ON contacts USING GIN (search_vector);
+
+-- Fuzzy name matching for typos, e.g. "jonh" finds "John"
+CREATE INDEX idx_contacts_name_trgm ON contacts USING GIN (name gin_trgm_ops);
+
+-- Event titles are matched through the events of a contact
+CREATE INDEX idx_events_title_search ON events USING GIN (to_tsvector('english', title));
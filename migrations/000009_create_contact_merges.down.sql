@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_contacts_phone_normalized;
+DROP INDEX IF EXISTS idx_contacts_email_normalized;
+DROP INDEX IF EXISTS idx_contact_merges_deleted_at;
+DROP INDEX IF EXISTS idx_contact_merges_survivor_id;
+DROP INDEX IF EXISTS idx_contact_merges_user_id;
+DROP TABLE IF EXISTS contact_merges;
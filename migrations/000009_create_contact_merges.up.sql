@@ -0,0 +1,22 @@
+CREATE TABLE contact_merges (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    survivor_id INTEGER NOT NULL REFERENCES contacts(id) ON DELETE CASCADE,
+    merged_id INTEGER NOT NULL REFERENCES contacts(id) ON DELETE CASCADE,
+    merged_name VARCHAR(255),
+    snapshot JSONB NOT NULL DEFAULT '{}',
+    taken_fields JSONB NOT NULL DEFAULT '[]',
+    actor VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX idx_contact_merges_user_id ON contact_merges(user_id);
+CREATE INDEX idx_contact_merges_survivor_id ON contact_merges(survivor_id);
+CREATE INDEX idx_contact_merges_deleted_at ON contact_merges(deleted_at);
+
+-- Duplicate detection compares emails ignoring case and phone numbers by their
+-- last 10 digits. The expressions must match the ones in pkg/repository/duplicates.go.
+CREATE INDEX idx_contacts_email_normalized ON contacts(user_id, LOWER(TRIM(email))) WHERE deleted_at IS NULL;
+CREATE INDEX idx_contacts_phone_normalized ON contacts(user_id, RIGHT(regexp_replace(phone_number, '\D', '', 'g'), 10)) WHERE deleted_at IS NULL;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_contact_links_user_id;
+DROP INDEX IF EXISTS idx_contact_links_to_contact_id;
+DROP INDEX IF EXISTS idx_contact_links_unique;
+DROP TABLE IF EXISTS contact_links;
+DROP TABLE IF EXISTS link_types;
@@ -0,0 +1,41 @@
+-- Catalogue of the ways two contacts can be linked. A link reads
+-- "<from> label <to>", and from the other contact "<to> inverse_label <from>".
+-- Symmetric types read the same from both sides.
+CREATE TABLE link_types (
+    id SERIAL PRIMARY KEY,
+    key VARCHAR(64) NOT NULL UNIQUE,
+    label VARCHAR(64) NOT NULL,
+    inverse_label VARCHAR(64) NOT NULL,
+    symmetric BOOLEAN NOT NULL DEFAULT FALSE,
+    position INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT INTO link_types (key, label, inverse_label, symmetric, position) VALUES
+    ('spouse', 'Spouse of', 'Spouse of', TRUE, 1),
+    ('partner', 'Partner of', 'Partner of', TRUE, 2),
+    ('parent', 'Parent of', 'Child of', FALSE, 3),
+    ('sibling', 'Sibling of', 'Sibling of', TRUE, 4),
+    ('relative', 'Relative of', 'Relative of', TRUE, 5),
+    ('friend', 'Friend of', 'Friend of', TRUE, 6),
+    ('introduced', 'Introduced me to', 'Was introduced to me by', FALSE, 7),
+    ('manager', 'Manager of', 'Reports to', FALSE, 8),
+    ('colleague', 'Colleague of', 'Colleague of', TRUE, 9),
+    ('mentor', 'Mentor of', 'Mentee of', FALSE, 10);
+
+CREATE TABLE contact_links (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    from_contact_id INTEGER NOT NULL REFERENCES contacts(id) ON DELETE CASCADE,
+    to_contact_id INTEGER NOT NULL REFERENCES contacts(id) ON DELETE CASCADE,
+    link_type_id INTEGER NOT NULL REFERENCES link_types(id),
+    note TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK (from_contact_id <> to_contact_id)
+);
+
+-- Symmetric links are stored with the lower contact id first, so this also
+-- prevents the same symmetric link being added from both contacts
+CREATE UNIQUE INDEX idx_contact_links_unique ON contact_links(from_contact_id, to_contact_id, link_type_id);
+CREATE INDEX idx_contact_links_to_contact_id ON contact_links(to_contact_id);
+CREATE INDEX idx_contact_links_user_id ON contact_links(user_id);
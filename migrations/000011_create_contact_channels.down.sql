@@ -0,0 +1,16 @@
+CREATE INDEX IF NOT EXISTS idx_contacts_email_normalized ON contacts(user_id, LOWER(TRIM(email))) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_contacts_phone_normalized ON contacts(user_id, RIGHT(regexp_replace(phone_number, '\D', '', 'g'), 10)) WHERE deleted_at IS NULL;
+
+UPDATE contacts SET email = '' WHERE email IS NULL;
+UPDATE contacts SET phone_number = '' WHERE phone_number IS NULL;
+ALTER TABLE contacts ALTER COLUMN email SET NOT NULL;
+ALTER TABLE contacts ALTER COLUMN phone_number SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_contact_addresses_search;
+DROP INDEX IF EXISTS idx_contact_addresses_primary;
+DROP INDEX IF EXISTS idx_contact_addresses_contact_id;
+DROP TABLE IF EXISTS contact_addresses;
+DROP INDEX IF EXISTS idx_contact_channels_primary;
+DROP INDEX IF EXISTS idx_contact_channels_normalized;
+DROP INDEX IF EXISTS idx_contact_channels_contact_id;
+DROP TABLE IF EXISTS contact_channels;
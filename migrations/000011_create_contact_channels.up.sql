@@ -0,0 +1,68 @@
+-- Emails and phone numbers of contacts. Each contact has at most one primary
+-- value per kind, which is mirrored into contacts.email and contacts.phone_number.
+CREATE TABLE contact_channels (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    contact_id INTEGER NOT NULL REFERENCES contacts(id) ON DELETE CASCADE,
+    kind VARCHAR(16) NOT NULL CHECK (kind IN ('email', 'phone')),
+    label VARCHAR(32) NOT NULL DEFAULT '',
+    value VARCHAR(255) NOT NULL,
+    -- Emails compare ignoring case, phone numbers by their last 10 digits. The
+    -- expressions must match normalizeEmail and normalizePhone in pkg/repository.
+    normalized VARCHAR(255) GENERATED ALWAYS AS (
+        CASE kind
+            WHEN 'email' THEN LOWER(TRIM(value))
+            ELSE RIGHT(regexp_replace(value, '\D', '', 'g'), 10)
+        END
+    ) STORED,
+    is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_contact_channels_contact_id ON contact_channels(contact_id);
+CREATE INDEX idx_contact_channels_normalized ON contact_channels(user_id, kind, normalized);
+CREATE UNIQUE INDEX idx_contact_channels_primary ON contact_channels(contact_id, kind) WHERE is_primary;
+
+-- Postal addresses of contacts. The primary address is summarized into
+-- contacts.location as "city, region, country".
+CREATE TABLE contact_addresses (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    contact_id INTEGER NOT NULL REFERENCES contacts(id) ON DELETE CASCADE,
+    label VARCHAR(32) NOT NULL DEFAULT '',
+    street VARCHAR(255) NOT NULL DEFAULT '',
+    city VARCHAR(255) NOT NULL DEFAULT '',
+    region VARCHAR(255) NOT NULL DEFAULT '',
+    postal_code VARCHAR(32) NOT NULL DEFAULT '',
+    country VARCHAR(255) NOT NULL DEFAULT '',
+    is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_contact_addresses_contact_id ON contact_addresses(contact_id);
+CREATE UNIQUE INDEX idx_contact_addresses_primary ON contact_addresses(contact_id) WHERE is_primary;
+-- Used by the location filter and the full-text search
+CREATE INDEX idx_contact_addresses_search ON contact_addresses
+    USING GIN (to_tsvector('simple', street || ' ' || city || ' ' || region || ' ' || postal_code || ' ' || country));
+
+-- Move the single values into the new tables. Free text locations become the city.
+INSERT INTO contact_channels (user_id, contact_id, kind, value, is_primary)
+SELECT user_id, id, 'email', TRIM(email), TRUE FROM contacts WHERE TRIM(COALESCE(email, '')) <> '';
+
+INSERT INTO contact_channels (user_id, contact_id, kind, value, is_primary)
+SELECT user_id, id, 'phone', TRIM(phone_number), TRUE FROM contacts WHERE TRIM(COALESCE(phone_number, '')) <> '';
+
+INSERT INTO contact_addresses (user_id, contact_id, city, is_primary)
+SELECT user_id, id, TRIM(location), TRUE FROM contacts WHERE TRIM(COALESCE(location, '')) <> '';
+
+-- The single columns now only mirror the primary values and may be empty
+ALTER TABLE contacts ALTER COLUMN email DROP NOT NULL;
+ALTER TABLE contacts ALTER COLUMN phone_number DROP NOT NULL;
+
+-- Duplicate detection matches on contact_channels.normalized instead
+DROP INDEX IF EXISTS idx_contacts_email_normalized;
+DROP INDEX IF EXISTS idx_contacts_phone_normalized;
@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS idx_contacts_birthday;
+
+ALTER TABLE events
+    ALTER COLUMN event_date TYPE VARCHAR(255) USING to_char(event_date, 'YYYY-MM-DD');
+
+ALTER TABLE contacts
+    ALTER COLUMN birthday TYPE VARCHAR(255) USING CASE
+        WHEN birthday IS NULL THEN ''
+        WHEN EXTRACT(YEAR FROM birthday) = 4 THEN to_char(birthday, '"--"MM-DD')
+        ELSE to_char(birthday, 'YYYY-MM-DD')
+    END,
+    ALTER COLUMN last_met TYPE VARCHAR(255) USING COALESCE(to_char(last_met, 'YYYY-MM-DD'), ''),
+    ALTER COLUMN last_contacted TYPE VARCHAR(255) USING COALESCE(to_char(last_contacted, 'YYYY-MM-DD'), ''),
+    ALTER COLUMN last_update TYPE VARCHAR(255) USING COALESCE(to_char(last_update, 'YYYY-MM-DD'), '');
@@ -0,0 +1,52 @@
+-- Dates were stored as free text. Valid YYYY-MM-DD values are converted,
+-- birthdays without year (--MM-DD, or --MMDD as in vCard) are stored in the
+-- year 0004, which must match noYear in pkg/entity. Everything else is kept
+-- in the notes, or in the title for events, and the column is cleared.
+CREATE FUNCTION pg_temp.try_date(value TEXT) RETURNS DATE AS $$
+BEGIN
+    IF TRIM(value) ~ '^\d{4}-\d{2}-\d{2}$' THEN
+        RETURN TRIM(value)::DATE;
+    END IF;
+    RETURN NULL;
+EXCEPTION WHEN invalid_datetime_format OR datetime_field_overflow THEN
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION pg_temp.try_birthday(value TEXT) RETURNS DATE AS $$
+BEGIN
+    IF TRIM(value) ~ '^--\d{2}-?\d{2}$' THEN
+        RETURN pg_temp.try_date('0004-' || SUBSTR(TRIM(value), 3, 2) || '-' || RIGHT(TRIM(value), 2));
+    END IF;
+    RETURN pg_temp.try_date(value);
+END;
+$$ LANGUAGE plpgsql;
+
+UPDATE contacts
+SET notes = TRIM(BOTH E'\n' FROM COALESCE(notes, '') || E'\n' ||
+        CONCAT_WS(E'\n',
+            CASE WHEN TRIM(birthday) <> '' AND pg_temp.try_birthday(birthday) IS NULL THEN 'Birthday: ' || birthday END,
+            CASE WHEN TRIM(last_met) <> '' AND pg_temp.try_date(last_met) IS NULL THEN 'Last met: ' || last_met END,
+            CASE WHEN TRIM(last_contacted) <> '' AND pg_temp.try_date(last_contacted) IS NULL THEN 'Last contacted: ' || last_contacted END,
+            CASE WHEN TRIM(last_update) <> '' AND pg_temp.try_date(last_update) IS NULL THEN 'Last update: ' || last_update END))
+WHERE (TRIM(birthday) <> '' AND pg_temp.try_birthday(birthday) IS NULL)
+   OR (TRIM(last_met) <> '' AND pg_temp.try_date(last_met) IS NULL)
+   OR (TRIM(last_contacted) <> '' AND pg_temp.try_date(last_contacted) IS NULL)
+   OR (TRIM(last_update) <> '' AND pg_temp.try_date(last_update) IS NULL);
+
+ALTER TABLE contacts
+    ALTER COLUMN birthday TYPE DATE USING pg_temp.try_birthday(birthday),
+    ALTER COLUMN last_met TYPE DATE USING pg_temp.try_date(last_met),
+    ALTER COLUMN last_contacted TYPE DATE USING pg_temp.try_date(last_contacted),
+    ALTER COLUMN last_update TYPE DATE USING pg_temp.try_date(last_update);
+
+-- Events need a date, the ones without a valid one fall back to the day they
+-- were created and keep the original text in the title
+UPDATE events
+SET title = LEFT(title || ' (' || event_date || ')', 255)
+WHERE pg_temp.try_date(event_date) IS NULL;
+
+ALTER TABLE events
+    ALTER COLUMN event_date TYPE DATE USING COALESCE(pg_temp.try_date(event_date), created_at::DATE, CURRENT_DATE);
+
+CREATE INDEX idx_contacts_birthday ON contacts(user_id, birthday) WHERE birthday IS NOT NULL AND deleted_at IS NULL;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_contacts_cadence;
+
+ALTER TABLE tags DROP COLUMN IF EXISTS cadence_days;
+ALTER TABLE contacts DROP COLUMN IF EXISTS snoozed_until;
+ALTER TABLE contacts DROP COLUMN IF EXISTS cadence_days;
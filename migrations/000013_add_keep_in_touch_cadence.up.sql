@@ -0,0 +1,7 @@
+-- Keep-in-touch cadence in days, 0 for none. Contacts without a cadence of
+-- their own use the shortest cadence of their tags, VIP contacts every 60 days.
+ALTER TABLE contacts ADD COLUMN cadence_days INTEGER NOT NULL DEFAULT 0 CHECK (cadence_days >= 0);
+ALTER TABLE contacts ADD COLUMN snoozed_until DATE;
+ALTER TABLE tags ADD COLUMN cadence_days INTEGER NOT NULL DEFAULT 0 CHECK (cadence_days >= 0);
+
+CREATE INDEX idx_contacts_cadence ON contacts(user_id) WHERE cadence_days > 0 AND deleted_at IS NULL;
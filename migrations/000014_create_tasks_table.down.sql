@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_task_contacts_contact_id;
+DROP INDEX IF EXISTS idx_tasks_open_due_on;
+DROP INDEX IF EXISTS idx_tasks_deleted_at;
+DROP INDEX IF EXISTS idx_tasks_user_id;
+DROP TABLE IF EXISTS task_contacts;
+DROP TABLE IF EXISTS tasks;
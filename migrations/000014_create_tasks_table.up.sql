@@ -0,0 +1,26 @@
+CREATE TABLE tasks (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    notes TEXT,
+    due_on DATE,
+    priority VARCHAR(16) NOT NULL DEFAULT 'normal' CHECK (priority IN ('low', 'normal', 'high')),
+    status VARCHAR(16) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'done')),
+    completed_at TIMESTAMP,
+    google_calendar_event_id VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE TABLE task_contacts (
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    contact_id INTEGER NOT NULL REFERENCES contacts(id) ON DELETE CASCADE,
+    PRIMARY KEY (task_id, contact_id)
+);
+
+CREATE INDEX idx_tasks_user_id ON tasks(user_id);
+CREATE INDEX idx_tasks_deleted_at ON tasks(deleted_at);
+-- Open tasks by due date, for the dashboard widget
+CREATE INDEX idx_tasks_open_due_on ON tasks(user_id, due_on) WHERE status = 'open' AND deleted_at IS NULL;
+CREATE INDEX idx_task_contacts_contact_id ON task_contacts(contact_id);
@@ -0,0 +1,25 @@
+ALTER TABLE contacts ADD COLUMN notes TEXT;
+
+-- Join the notes of every contact back into one text, oldest first, in the
+-- format the MCP server used to append them
+UPDATE contacts c SET notes = n.notes
+FROM (
+    SELECT contact_id,
+        string_agg('[' || to_char(created_at, 'YYYY-MM-DD HH24:MI:SS') || E']\n' || body, E'\n---\n' ORDER BY created_at, id) AS notes
+    FROM notes
+    WHERE deleted_at IS NULL
+    GROUP BY contact_id
+) n
+WHERE c.id = n.contact_id;
+
+DROP INDEX IF EXISTS idx_contacts_search_vector;
+ALTER TABLE contacts DROP COLUMN search_vector;
+ALTER TABLE contacts ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(company, '') || ' ' || coalesce(industry, '')), 'B') ||
+    setweight(to_tsvector('english', coalesce(location, '')), 'C') ||
+    setweight(to_tsvector('english', coalesce(notes, '')), 'D')
+) STORED;
+CREATE INDEX idx_contacts_search_vector ON contacts USING GIN (search_vector);
+
+DROP TABLE IF EXISTS notes;
@@ -0,0 +1,60 @@
+CREATE TABLE notes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    contact_id INTEGER NOT NULL REFERENCES contacts(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    pinned BOOLEAN NOT NULL DEFAULT false,
+    edited_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX idx_notes_user_id ON notes(user_id);
+CREATE INDEX idx_notes_contact_id ON notes(contact_id);
+CREATE INDEX idx_notes_deleted_at ON notes(deleted_at);
+-- Note bodies are matched by the contact search
+CREATE INDEX idx_notes_body_search ON notes USING GIN (to_tsvector('english', body));
+
+-- Split the notes of every contact into one note per entry. Entries appended
+-- through the MCP server are separated by "---" lines and start with a
+-- "[YYYY-MM-DD HH:MM:SS]" line, which becomes the time of the note. Other
+-- text, and entries whose stamp is not a real time, e.g. [2023-02-30 ...],
+-- are dated with the creation of the contact and keep their first line.
+CREATE FUNCTION pg_temp.try_note_stamp(chunk TEXT) RETURNS TIMESTAMP AS $$
+BEGIN
+    IF chunk ~ '^\[\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\]\n' THEN
+        RETURN substring(chunk FROM 2 FOR 19)::TIMESTAMP;
+    END IF;
+    RETURN NULL;
+EXCEPTION WHEN invalid_datetime_format OR datetime_field_overflow THEN
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+INSERT INTO notes (user_id, contact_id, body, created_at, updated_at)
+SELECT user_id, contact_id,
+    CASE WHEN stamp IS NOT NULL THEN btrim(substring(chunk FROM position(E'\n' IN chunk) + 1), E' \n\r\t') ELSE btrim(chunk, E' \n\r\t') END,
+    COALESCE(stamp, contact_created_at),
+    COALESCE(stamp, contact_created_at)
+FROM (
+    SELECT c.user_id, c.id AS contact_id, c.created_at AS contact_created_at, chunk.chunk, chunk.n,
+        pg_temp.try_note_stamp(chunk.chunk) AS stamp
+    FROM contacts c,
+        LATERAL regexp_split_to_table(btrim(c.notes, E' \n\r\t'), E'\n---\n') WITH ORDINALITY AS chunk(chunk, n)
+    WHERE c.notes IS NOT NULL AND btrim(c.notes, E' \n\r\t') <> ''
+) entries
+WHERE btrim(CASE WHEN stamp IS NOT NULL THEN substring(chunk FROM position(E'\n' IN chunk) + 1) ELSE chunk END, E' \n\r\t') <> ''
+ORDER BY contact_id, n;
+
+-- The search document of contacts no longer includes the notes
+DROP INDEX IF EXISTS idx_contacts_search_vector;
+ALTER TABLE contacts DROP COLUMN search_vector;
+ALTER TABLE contacts ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(company, '') || ' ' || coalesce(industry, '')), 'B') ||
+    setweight(to_tsvector('english', coalesce(location, '')), 'C')
+) STORED;
+CREATE INDEX idx_contacts_search_vector ON contacts USING GIN (search_vector);
+
+ALTER TABLE contacts DROP COLUMN notes;
@@ -0,0 +1,20 @@
+CREATE TABLE attachments (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    contact_id INTEGER NOT NULL REFERENCES contacts(id) ON DELETE CASCADE,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    storage_key VARCHAR(512) NOT NULL,
+    thumbnail_key VARCHAR(512),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX idx_attachments_user_id ON attachments(user_id);
+CREATE INDEX idx_attachments_contact_id ON attachments(contact_id);
+CREATE INDEX idx_attachments_deleted_at ON attachments(deleted_at);
+
+-- The avatar of a contact is one of its image attachments
+ALTER TABLE contacts ADD COLUMN photo_id INTEGER REFERENCES attachments(id) ON DELETE SET NULL;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_contacts_sort_created;
+DROP INDEX IF EXISTS idx_contacts_sort_last_contacted;
+DROP INDEX IF EXISTS idx_contacts_sort_company;
+DROP INDEX IF EXISTS idx_contacts_sort_name;
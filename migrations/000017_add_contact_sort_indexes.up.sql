@@ -0,0 +1,7 @@
+-- Indexes for the sort orders of the contact list. Each ends with the id so
+-- a page can continue right after the last row of the previous one. The next
+-- birthday order depends on today's date and is not indexed.
+CREATE INDEX idx_contacts_sort_name ON contacts(user_id, LOWER(name), id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_contacts_sort_company ON contacts(user_id, LOWER(COALESCE(company, '')), id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_contacts_sort_last_contacted ON contacts(user_id, COALESCE(last_contacted, DATE '0001-01-01'), id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_contacts_sort_created ON contacts(user_id, created_at, id) WHERE deleted_at IS NULL;
@@ -0,0 +1,14 @@
+CREATE TABLE smart_lists (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(64) NOT NULL,
+    pinned BOOLEAN NOT NULL DEFAULT FALSE,
+    filters JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+-- Smart list names are unique per user, ignoring case
+CREATE UNIQUE INDEX idx_smart_lists_user_id_name ON smart_lists(user_id, LOWER(name)) WHERE deleted_at IS NULL;
+CREATE INDEX idx_smart_lists_deleted_at ON smart_lists(deleted_at);
@@ -0,0 +1,9 @@
+-- Relationships outside the former enum cannot be kept
+CREATE TYPE relation AS ENUM ('Friend', 'Family', 'Colleague', 'School', 'Network', 'Services');
+UPDATE contacts SET relationship = NULL
+WHERE relationship NOT IN ('Friend', 'Family', 'Colleague', 'School', 'Network', 'Services');
+ALTER TABLE contacts ALTER COLUMN relationship TYPE relation USING relationship::relation;
+
+DROP INDEX IF EXISTS idx_relationship_types_deleted_at;
+DROP INDEX IF EXISTS idx_relationship_types_user_id_name;
+DROP TABLE IF EXISTS relationship_types;
@@ -0,0 +1,23 @@
+CREATE TABLE relationship_types (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(64) NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+-- Relationship type names are unique per user, ignoring case
+CREATE UNIQUE INDEX idx_relationship_types_user_id_name ON relationship_types(user_id, LOWER(name)) WHERE deleted_at IS NULL;
+CREATE INDEX idx_relationship_types_deleted_at ON relationship_types(deleted_at);
+
+-- Every existing user starts with the values of the former relation enum
+INSERT INTO relationship_types (user_id, name, position)
+SELECT u.id, d.name, d.position
+FROM users u
+CROSS JOIN (VALUES ('Friend', 0), ('Family', 1), ('Colleague', 2), ('School', 3), ('Network', 4), ('Services', 5)) AS d(name, position);
+
+-- Contacts keep the name of their relationship type
+ALTER TABLE contacts ALTER COLUMN relationship TYPE VARCHAR(64) USING relationship::TEXT;
+DROP TYPE relation;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_household_events_user_id;
+DROP INDEX IF EXISTS idx_household_events_household_id;
+DROP INDEX IF EXISTS idx_household_members_contact_id;
+DROP INDEX IF EXISTS idx_households_user_id_name;
+DROP TABLE IF EXISTS household_events;
+DROP TABLE IF EXISTS household_members;
+DROP TABLE IF EXISTS households;
@@ -0,0 +1,40 @@
+-- Households and other groups of contacts, e.g. a family, with a shared
+-- postal address and the greeting used when addressing them together
+CREATE TABLE households (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(64) NOT NULL,
+    greeting VARCHAR(255) NOT NULL DEFAULT '',
+    street VARCHAR(255) NOT NULL DEFAULT '',
+    city VARCHAR(255) NOT NULL DEFAULT '',
+    region VARCHAR(255) NOT NULL DEFAULT '',
+    postal_code VARCHAR(32) NOT NULL DEFAULT '',
+    country VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE household_members (
+    household_id INTEGER NOT NULL REFERENCES households(id) ON DELETE CASCADE,
+    contact_id INTEGER NOT NULL REFERENCES contacts(id) ON DELETE CASCADE,
+    PRIMARY KEY (household_id, contact_id)
+);
+
+-- Events shared by the members of a household, like an anniversary
+CREATE TABLE household_events (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    household_id INTEGER NOT NULL REFERENCES households(id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    event_date DATE NOT NULL,
+    recurrence VARCHAR(255),
+    google_calendar_event_id VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Household names are unique per user, ignoring case
+CREATE UNIQUE INDEX idx_households_user_id_name ON households(user_id, LOWER(name));
+CREATE INDEX idx_household_members_contact_id ON household_members(contact_id);
+CREATE INDEX idx_household_events_household_id ON household_events(household_id);
+CREATE INDEX idx_household_events_user_id ON household_events(user_id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_contacts_drifting;
+DROP INDEX IF EXISTS idx_contacts_sort_strength;
+
+ALTER TABLE contacts DROP COLUMN IF EXISTS drifting_since;
+ALTER TABLE contacts DROP COLUMN IF EXISTS strength_score;
@@ -0,0 +1,7 @@
+-- Relationship strength from 0 to 100 and the day the relationship started
+-- to fade, both recomputed by a background job from the interactions
+ALTER TABLE contacts ADD COLUMN strength_score INTEGER NOT NULL DEFAULT 0 CHECK (strength_score BETWEEN 0 AND 100);
+ALTER TABLE contacts ADD COLUMN drifting_since DATE;
+
+CREATE INDEX idx_contacts_sort_strength ON contacts(user_id, strength_score, id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_contacts_drifting ON contacts(user_id, strength_score) WHERE drifting_since IS NOT NULL AND deleted_at IS NULL;
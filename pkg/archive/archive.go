@@ -0,0 +1,457 @@
+// Package archive writes the account export: a zip of JSON files, one per kind
+// of record, plus CSV copies of the tabular ones and a manifest.
+//
+// The JSON files are the source of truth for re-importing into another
+// instance. Records keep their original IDs so references between files
+// (contact_id, contact_ids) stay intact; an importer assigns new IDs and maps
+// them. Any breaking change to a file or field must increase SchemaVersion.
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SchemaVersion is the version of the archive layout described in manifest.json
+const SchemaVersion = 2
+
+// Application identifies archives written by this CRM
+const Application = "personal-crm"
+
+// ManifestFile is the name of the manifest inside the archive
+const ManifestFile = "manifest.json"
+
+// Manifest describes the archive and every file in it
+type Manifest struct {
+	Application   string    `json:"application"`
+	SchemaVersion int       `json:"schema_version"`
+	ExportedAt    time.Time `json:"exported_at"`
+	Files         []File    `json:"files"`
+}
+
+// File is an entry of the manifest
+type File struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Records     int    `json:"records"`
+}
+
+// Archive is the content of an account export
+type Archive struct {
+	ExportedAt   time.Time
+	User         User
+	Contacts     []Contact
+	Events       []Event
+	Notes        []Note
+	Attachments  []Attachment
+	Interactions []Interaction
+	Tasks        []Task
+	Tags         []Tag
+	CustomFields []CustomField
+	// RelationshipTypes are the names contacts may have as relationship, in display order
+	RelationshipTypes []string
+	Households        []Household
+	History           []Change
+	Merges            []Merge
+	Links             []Link
+	Calendar          CalendarSync
+}
+
+// User is the account the data belongs to. OAuth tokens are never exported.
+type User struct {
+	ID        uint      `json:"id"`
+	Email     string    `json:"email"`
+	Picture   string    `json:"picture,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Contact is a contact with its detail and VIP information. Location,
+// PhoneNumber and Email repeat the primary address, phone number and email.
+type Contact struct {
+	ID           uint              `json:"id"`
+	Name         string            `json:"name"`
+	Relationship string            `json:"relationship"`
+	Industry     string            `json:"industry"`
+	Company      string            `json:"company"`
+	Birthday     string            `json:"birthday"`
+	Vip          bool              `json:"vip"`
+	Spouse       string            `json:"spouse"`
+	Children     string            `json:"children"`
+	Location     string            `json:"location"`
+	PhoneNumber  string            `json:"phone_number"`
+	Email        string            `json:"email"`
+	Emails       []Channel         `json:"emails"`
+	Phones       []Channel         `json:"phones"`
+	Addresses    []Address         `json:"addresses"`
+	LinkedIn     string            `json:"linked_in"`
+	Instagram    string            `json:"instagram"`
+	X            string            `json:"x"`
+	LastMet      string            `json:"last_met"`
+	LastContact  string            `json:"last_contacted"`
+	LastUpdate   string            `json:"last_update"`
+	CadenceDays  int               `json:"cadence_days"`
+	SnoozedUntil string            `json:"snoozed_until"`
+	Status       string            `json:"status"`
+	Tags         []string          `json:"tags"`
+	CustomFields map[string]string `json:"custom_fields"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+// Channel is an email address or phone number of a contact
+type Channel struct {
+	Value   string `json:"value"`
+	Label   string `json:"label"`
+	Primary bool   `json:"primary"`
+}
+
+// Address is a postal address of a contact
+type Address struct {
+	Label      string `json:"label"`
+	Street     string `json:"street"`
+	City       string `json:"city"`
+	Region     string `json:"region"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+	Primary    bool   `json:"primary"`
+}
+
+// Event is a recurring or one-off date of a contact
+type Event struct {
+	ID         uint   `json:"id"`
+	ContactID  uint   `json:"contact_id"`
+	Title      string `json:"title"`
+	EventDate  string `json:"event_date"`
+	Recurrence string `json:"recurrence"`
+}
+
+// Note is a note about a contact. EditedAt is set when the body was changed
+// after the note was written.
+type Note struct {
+	ID        uint       `json:"id"`
+	ContactID uint       `json:"contact_id"`
+	Body      string     `json:"body"`
+	Pinned    bool       `json:"pinned"`
+	CreatedAt time.Time  `json:"created_at"`
+	EditedAt  *time.Time `json:"edited_at"`
+}
+
+// Attachment describes a file attached to a contact. Photo is set for the
+// image shown as the contact's avatar. The files themselves are not exported.
+type Attachment struct {
+	ID          uint      `json:"id"`
+	ContactID   uint      `json:"contact_id"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Photo       bool      `json:"photo"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Interaction is a logged touchpoint with one or more contacts
+type Interaction struct {
+	ID         uint   `json:"id"`
+	Type       string `json:"type"`
+	OccurredOn string `json:"occurred_on"`
+	Notes      string `json:"notes"`
+	ContactIDs []uint `json:"contact_ids"`
+}
+
+// Task is a follow-up to-do about one or more contacts
+type Task struct {
+	ID          uint       `json:"id"`
+	Title       string     `json:"title"`
+	Notes       string     `json:"notes"`
+	DueOn       string     `json:"due_on"`
+	Priority    string     `json:"priority"`
+	Status      string     `json:"status"`
+	CompletedAt *time.Time `json:"completed_at"`
+	ContactIDs  []uint     `json:"contact_ids"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Tag is a user defined label, contacts refer to tags by name
+type Tag struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	CadenceDays int    `json:"cadence_days"`
+}
+
+// CustomField is the definition of a custom field, contacts refer to it by key
+type CustomField struct {
+	Key      string   `json:"key"`
+	Label    string   `json:"label"`
+	Type     string   `json:"type"`
+	Options  []string `json:"options"`
+	Position int      `json:"position"`
+}
+
+// Household is a group of contacts addressed together, with a shared address
+// and events. An empty greeting is derived from the members' first names.
+type Household struct {
+	ID         uint             `json:"id"`
+	Name       string           `json:"name"`
+	Greeting   string           `json:"greeting"`
+	Street     string           `json:"street"`
+	City       string           `json:"city"`
+	Region     string           `json:"region"`
+	PostalCode string           `json:"postal_code"`
+	Country    string           `json:"country"`
+	ContactIDs []uint           `json:"contact_ids"`
+	Events     []HouseholdEvent `json:"events"`
+}
+
+// HouseholdEvent is a recurring or one-off date shared by a household
+type HouseholdEvent struct {
+	ID         uint   `json:"id"`
+	Title      string `json:"title"`
+	EventDate  string `json:"event_date"`
+	Recurrence string `json:"recurrence"`
+}
+
+// Change is one entry of a contact's change history
+type Change struct {
+	ContactID uint      `json:"contact_id"`
+	Revision  string    `json:"revision"`
+	Field     string    `json:"field"`
+	OldValue  string    `json:"old_value"`
+	NewValue  string    `json:"new_value"`
+	Actor     string    `json:"actor"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// Merge records that a contact, which no longer exists, was merged into another one
+type Merge struct {
+	SurvivorID  uint              `json:"survivor_id"`
+	MergedID    uint              `json:"merged_id"`
+	MergedName  string            `json:"merged_name"`
+	Snapshot    map[string]string `json:"snapshot"`
+	TakenFields []string          `json:"taken_fields"`
+	Actor       string            `json:"actor"`
+	MergedAt    time.Time         `json:"merged_at"`
+}
+
+// Link is a typed link between two contacts, reading "from type to". Type is
+// the key of a link type, e.g. "manager" for "from manages to".
+type Link struct {
+	ID            uint      `json:"id"`
+	FromContactID uint      `json:"from_contact_id"`
+	ToContactID   uint      `json:"to_contact_id"`
+	Type          string    `json:"type"`
+	Note          string    `json:"note"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// CalendarSync is the Google Calendar sync state. The IDs refer to events in the
+// user's Google Calendar and are only meaningful for the same Google account.
+type CalendarSync struct {
+	Enabled  bool              `json:"enabled"`
+	LastSync *time.Time        `json:"last_sync"`
+	Contacts []ContactCalendar `json:"contacts"`
+	Events   []EventCalendar   `json:"events"`
+	Tasks    []TaskCalendar    `json:"tasks"`
+	// HouseholdEvents are the Google Calendar events of household events
+	HouseholdEvents []EventCalendar `json:"household_events"`
+}
+
+// ContactCalendar is the birthday reminder of a contact
+type ContactCalendar struct {
+	ContactID     uint       `json:"contact_id"`
+	GoogleEventID string     `json:"google_event_id"`
+	SyncEnabled   bool       `json:"sync_enabled"`
+	SyncedAt      *time.Time `json:"synced_at"`
+}
+
+// EventCalendar is the Google Calendar event of an event
+type EventCalendar struct {
+	EventID       uint   `json:"event_id"`
+	GoogleEventID string `json:"google_event_id"`
+}
+
+// TaskCalendar is the Google Calendar event mirroring a task
+type TaskCalendar struct {
+	TaskID        uint   `json:"task_id"`
+	GoogleEventID string `json:"google_event_id"`
+}
+
+// entry is a file to be written to the archive
+type entry struct {
+	file File
+	data []byte
+}
+
+// builder encodes the files of an archive, keeping the first error
+type builder struct {
+	entries []entry
+	err     error
+}
+
+func (b *builder) addJSON(name, description string, records int, v interface{}) {
+	if b.err != nil {
+		return
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		b.err = fmt.Errorf("encode %s: %w", name, err)
+		return
+	}
+	b.entries = append(b.entries, entry{File{name, description, records}, data})
+}
+
+// addCSV adds a CSV file, the first row is the header
+func (b *builder) addCSV(name, description string, rows [][]string) {
+	if b.err != nil {
+		return
+	}
+	var buf bytes.Buffer
+	if err := csv.NewWriter(&buf).WriteAll(rows); err != nil {
+		b.err = fmt.Errorf("encode %s: %w", name, err)
+		return
+	}
+	b.entries = append(b.entries, entry{File{name, description, len(rows) - 1}, buf.Bytes()})
+}
+
+// Write writes the archive as a zip file, starting with the manifest
+func Write(w io.Writer, a Archive) error {
+	b := &builder{}
+	b.addJSON("user.json", "The account", 1, a.User)
+	b.addJSON("contacts.json", "Contacts with their details, VIP information, tags and custom fields", len(a.Contacts), nonNil(a.Contacts))
+	b.addCSV("contacts.csv", "Contacts as a spreadsheet, can be imported with the CSV import", contactRows(a))
+	b.addJSON("events.json", "Events of contacts", len(a.Events), nonNil(a.Events))
+	b.addCSV("events.csv", "Events as a spreadsheet", eventRows(a.Events))
+	b.addJSON("notes.json", "Notes about contacts", len(a.Notes), nonNil(a.Notes))
+	b.addCSV("notes.csv", "Notes as a spreadsheet", noteRows(a.Notes))
+	b.addJSON("attachments.json", "Files attached to contacts, details only: the files are not included", len(a.Attachments), nonNil(a.Attachments))
+	b.addJSON("interactions.json", "Logged interactions and the contacts they involved", len(a.Interactions), nonNil(a.Interactions))
+	b.addCSV("interactions.csv", "Interactions as a spreadsheet", interactionRows(a.Interactions))
+	b.addJSON("tasks.json", "Tasks and the contacts they are about", len(a.Tasks), nonNil(a.Tasks))
+	b.addCSV("tasks.csv", "Tasks as a spreadsheet", taskRows(a.Tasks))
+	b.addJSON("tags.json", "Tags", len(a.Tags), nonNil(a.Tags))
+	b.addJSON("custom_fields.json", "Custom field definitions", len(a.CustomFields), nonNil(a.CustomFields))
+	b.addJSON("relationship_types.json", "Relationship types, contacts refer to them by name", len(a.RelationshipTypes), nonNil(a.RelationshipTypes))
+	b.addJSON("households.json", "Households with their members, shared address and events", len(a.Households), nonNil(a.Households))
+	b.addJSON("history.json", "Change history of contacts", len(a.History), nonNil(a.History))
+	b.addJSON("merges.json", "Contacts merged into other contacts", len(a.Merges), nonNil(a.Merges))
+	b.addJSON("links.json", "Links between contacts", len(a.Links), nonNil(a.Links))
+	b.addJSON("calendar.json", "Google Calendar sync state", len(a.Calendar.Contacts)+len(a.Calendar.Events)+len(a.Calendar.Tasks)+len(a.Calendar.HouseholdEvents), a.Calendar)
+
+	manifest := Manifest{Application: Application, SchemaVersion: SchemaVersion, ExportedAt: a.ExportedAt}
+	for _, e := range b.entries {
+		manifest.Files = append(manifest.Files, e.file)
+	}
+	b.addJSON(ManifestFile, "", 0, manifest)
+	if b.err != nil {
+		return b.err
+	}
+	// The manifest goes first so readers can check the version before anything else
+	entries := append(b.entries[len(b.entries)-1:], b.entries[:len(b.entries)-1]...)
+
+	zw := zip.NewWriter(w)
+	for _, e := range entries {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: e.file.Name, Method: zip.Deflate, Modified: a.ExportedAt})
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(e.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// nonNil makes empty lists encode as [] instead of null
+func nonNil[T any](records []T) []T {
+	if records == nil {
+		return []T{}
+	}
+	return records
+}
+
+// contactRows returns the contacts as CSV rows. The headers match the field
+// names the CSV import detects; custom fields get a column per definition.
+// The notes of a contact are joined into one column, separated by blank lines.
+func contactRows(a Archive) [][]string {
+	notes := map[uint][]string{}
+	for _, n := range a.Notes {
+		notes[n.ContactID] = append(notes[n.ContactID], n.Body)
+	}
+
+	header := []string{"ID", "Name", "Relationship", "Industry", "Company", "Birthday", "VIP", "Spouse", "Children",
+		"Location", "Phone", "Email", "LinkedIn", "Instagram", "X", "Notes", "Last Met", "Last Contacted", "Last Update", "Cadence", "Status", "Tags"}
+	for _, field := range a.CustomFields {
+		header = append(header, field.Label)
+	}
+
+	rows := [][]string{header}
+	for _, c := range a.Contacts {
+		cadence := ""
+		if c.CadenceDays > 0 {
+			cadence = strconv.Itoa(c.CadenceDays)
+		}
+		row := []string{strconv.FormatUint(uint64(c.ID), 10), c.Name, c.Relationship, c.Industry, c.Company, c.Birthday,
+			strconv.FormatBool(c.Vip), c.Spouse, c.Children, c.Location, c.PhoneNumber, c.Email, c.LinkedIn, c.Instagram,
+			c.X, strings.Join(notes[c.ID], "\n\n"), c.LastMet, c.LastContact, c.LastUpdate, cadence, c.Status, strings.Join(c.Tags, ", ")}
+		for _, field := range a.CustomFields {
+			row = append(row, c.CustomFields[field.Key])
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func eventRows(events []Event) [][]string {
+	rows := [][]string{{"ID", "Contact ID", "Title", "Date", "Recurrence"}}
+	for _, e := range events {
+		rows = append(rows, []string{strconv.FormatUint(uint64(e.ID), 10), strconv.FormatUint(uint64(e.ContactID), 10), e.Title, e.EventDate, e.Recurrence})
+	}
+	return rows
+}
+
+func noteRows(notes []Note) [][]string {
+	rows := [][]string{{"ID", "Contact ID", "Body", "Pinned", "Created", "Edited"}}
+	for _, n := range notes {
+		edited := ""
+		if n.EditedAt != nil {
+			edited = n.EditedAt.Format(time.RFC3339)
+		}
+		rows = append(rows, []string{strconv.FormatUint(uint64(n.ID), 10), strconv.FormatUint(uint64(n.ContactID), 10), n.Body,
+			strconv.FormatBool(n.Pinned), n.CreatedAt.Format(time.RFC3339), edited})
+	}
+	return rows
+}
+
+func interactionRows(interactions []Interaction) [][]string {
+	rows := [][]string{{"ID", "Type", "Date", "Notes", "Contact IDs"}}
+	for _, i := range interactions {
+		ids := make([]string, len(i.ContactIDs))
+		for n, id := range i.ContactIDs {
+			ids[n] = strconv.FormatUint(uint64(id), 10)
+		}
+		rows = append(rows, []string{strconv.FormatUint(uint64(i.ID), 10), i.Type, i.OccurredOn, i.Notes, strings.Join(ids, " ")})
+	}
+	return rows
+}
+
+func taskRows(tasks []Task) [][]string {
+	rows := [][]string{{"ID", "Title", "Notes", "Due", "Priority", "Status", "Completed", "Contact IDs"}}
+	for _, t := range tasks {
+		ids := make([]string, len(t.ContactIDs))
+		for n, id := range t.ContactIDs {
+			ids[n] = strconv.FormatUint(uint64(id), 10)
+		}
+		completed := ""
+		if t.CompletedAt != nil {
+			completed = t.CompletedAt.Format(time.RFC3339)
+		}
+		rows = append(rows, []string{strconv.FormatUint(uint64(t.ID), 10), t.Title, t.Notes, t.DueOn, t.Priority, t.Status, completed, strings.Join(ids, " ")})
+	}
+	return rows
+}
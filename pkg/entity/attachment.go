@@ -0,0 +1,26 @@
+package entity
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Attachment is a file kept with a contact, such as a scanned business card
+// or a shared PDF. The file itself is in the storage backend under
+// StorageKey; images also get a square JPEG thumbnail under ThumbnailKey.
+type Attachment struct {
+	gorm.Model
+	UserID       uint   `json:"user_id" gorm:"not null;index"`
+	ContactID    uint   `json:"contact_id" gorm:"not null;index"`
+	FileName     string `json:"file_name" gorm:"not null"`
+	ContentType  string `json:"content_type" gorm:"not null"`
+	Size         int64  `json:"size" gorm:"not null"`
+	StorageKey   string `json:"-" gorm:"not null"`
+	ThumbnailKey string `json:"-"`
+}
+
+// IsImage reports whether the attachment is a picture
+func (a Attachment) IsImage() bool {
+	return strings.HasPrefix(a.ContentType, "image/")
+}
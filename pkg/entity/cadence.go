@@ -0,0 +1,115 @@
+package entity
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// VipCadenceDays is the keep-in-touch cadence of VIP contacts that have no
+// cadence of their own or from a tag
+const VipCadenceDays = 60
+
+// MaxCadenceDays is the longest cadence that can be set, a bit over two years
+const MaxCadenceDays = 800
+
+// CadencePreset is a named keep-in-touch cadence offered in the forms
+type CadencePreset struct {
+	Name  string
+	Label string
+	Days  int
+}
+
+// CadencePresets lists the named cadences, shortest first
+var CadencePresets = []CadencePreset{
+	{"weekly", "Every week", 7},
+	{"biweekly", "Every 2 weeks", 14},
+	{"monthly", "Every month", 30},
+	{"quarterly", "Every quarter", 91},
+	{"biannually", "Every 6 months", 182},
+	{"yearly", "Every year", 365},
+}
+
+// ParseCadence reads a cadence given as a number of days or a preset name,
+// e.g. "14" or "quarterly". An empty string or "none" is no cadence.
+func ParseCadence(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" || s == "none" {
+		return 0, nil
+	}
+	for _, preset := range CadencePresets {
+		if s == preset.Name {
+			return preset.Days, nil
+		}
+	}
+	days, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("cadence %q must be a number of days or one of weekly, biweekly, monthly, quarterly, biannually, yearly", s)
+	}
+	if err := ValidCadence(days); err != nil {
+		return 0, err
+	}
+	return days, nil
+}
+
+// ValidCadence checks a cadence in days, 0 being no cadence
+func ValidCadence(days int) error {
+	if days < 0 || days > MaxCadenceDays {
+		return fmt.Errorf("cadence must be between 1 and %d days, or 0 for none", MaxCadenceDays)
+	}
+	return nil
+}
+
+// CadenceLabel describes a cadence, e.g. "Every quarter" or "Every 10 days"
+func CadenceLabel(days int) string {
+	for _, preset := range CadencePresets {
+		if days == preset.Days {
+			return preset.Label
+		}
+	}
+	switch days {
+	case 0:
+		return ""
+	case 1:
+		return "Every day"
+	}
+	return fmt.Sprintf("Every %d days", days)
+}
+
+// Cadence returns how often the contact should be kept in touch with, in
+// days, and where that comes from: the contact's own cadence, else the
+// shortest cadence of its tags, else VipCadenceDays for VIP contacts. The tags
+// must be loaded. days is 0 when the contact has no cadence.
+func (c Contact) Cadence() (days int, source string) {
+	if c.CadenceDays > 0 {
+		return c.CadenceDays, "contact"
+	}
+	for _, tag := range c.Tags {
+		if tag.CadenceDays > 0 && (days == 0 || tag.CadenceDays < days) {
+			days, source = tag.CadenceDays, "tag "+tag.Name
+		}
+	}
+	if days == 0 && c.Vip {
+		return VipCadenceDays, "vip"
+	}
+	return days, source
+}
+
+// NextDue returns the date the contact should next be contacted: one cadence
+// after the last interaction, or after it was added when there was none yet,
+// but not before the end of a snooze. It is the zero Date without a cadence.
+func (c Contact) NextDue() Date {
+	days, _ := c.Cadence()
+	if days == 0 {
+		return Date{}
+	}
+	from := c.LastContacted
+	if from.IsZero() {
+		from = NewDate(c.CreatedAt)
+	}
+	due := from.AddDays(days)
+	if !c.SnoozedUntil.IsZero() && due.Before(c.SnoozedUntil) {
+		due = c.SnoozedUntil
+	}
+	return due
+}
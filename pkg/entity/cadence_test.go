@@ -0,0 +1,101 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCadence(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    int
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"none", 0, false},
+		{" None ", 0, false},
+		{"weekly", 7, false},
+		{"Quarterly", 91, false},
+		{"yearly", 365, false},
+		{"14", 14, false},
+		{"0", 0, false},
+		{"800", 800, false},
+		{"801", 0, true},
+		{"-1", 0, true},
+		{"fortnightly", 0, true},
+		{"2 weeks", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCadence(tt.s)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseCadence(%q) = %d, %v, want %d, error %v", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCadenceLabel(t *testing.T) {
+	tests := []struct {
+		days int
+		want string
+	}{
+		{0, ""},
+		{1, "Every day"},
+		{7, "Every week"},
+		{91, "Every quarter"},
+		{10, "Every 10 days"},
+	}
+	for _, tt := range tests {
+		if got := CadenceLabel(tt.days); got != tt.want {
+			t.Errorf("CadenceLabel(%d) = %q, want %q", tt.days, got, tt.want)
+		}
+	}
+}
+
+func TestContactCadence(t *testing.T) {
+	tests := []struct {
+		name    string
+		cadence int
+		vip     bool
+		tags    []Tag
+		days    int
+		source  string
+	}{
+		{"none", 0, false, nil, 0, ""},
+		{"own cadence wins", 14, true, []Tag{{Name: "family", CadenceDays: 7}}, 14, "contact"},
+		{"shortest tag", 0, true, []Tag{{Name: "work", CadenceDays: 30}, {Name: "family", CadenceDays: 7}, {Name: "misc"}}, 7, "tag family"},
+		{"tags without cadence", 0, false, []Tag{{Name: "misc"}}, 0, ""},
+		{"vip", 0, true, []Tag{{Name: "misc"}}, VipCadenceDays, "vip"},
+	}
+	for _, tt := range tests {
+		c := Contact{CadenceDays: tt.cadence, Vip: tt.vip, Tags: tt.tags}
+		days, source := c.Cadence()
+		if days != tt.days || source != tt.source {
+			t.Errorf("%s: Cadence() = %d, %q, want %d, %q", tt.name, days, source, tt.days, tt.source)
+		}
+	}
+}
+
+func TestContactNextDue(t *testing.T) {
+	created := time.Date(2024, time.January, 10, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		cadence       int
+		lastContacted Date
+		snoozedUntil  Date
+		want          Date
+	}{
+		{"no cadence", 0, Date{2024, time.March, 1}, Date{}, Date{}},
+		{"after the last contact", 30, Date{2024, time.March, 1}, Date{}, Date{2024, time.March, 31}},
+		{"never contacted", 30, Date{}, Date{}, Date{2024, time.February, 9}},
+		{"snoozed", 30, Date{2024, time.March, 1}, Date{2024, time.April, 15}, Date{2024, time.April, 15}},
+		{"snooze already over", 30, Date{2024, time.March, 1}, Date{2024, time.March, 5}, Date{2024, time.March, 31}},
+	}
+	for _, tt := range tests {
+		c := Contact{CadenceDays: tt.cadence, SnoozedUntil: tt.snoozedUntil}
+		c.CreatedAt = created
+		c.LastContacted = tt.lastContacted
+		if got := c.NextDue(); got != tt.want {
+			t.Errorf("%s: NextDue() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package entity
+
+import (
+	"strings"
+	"time"
+)
+
+// ChannelKind is the kind of a contact channel
+type ChannelKind string
+
+const (
+	EmailChannel ChannelKind = "email"
+	PhoneChannel ChannelKind = "phone"
+)
+
+// ChannelLabels are the labels suggested for channels and addresses. Any other
+// short label is accepted too.
+var ChannelLabels = []string{"personal", "work", "mobile", "home", "other"}
+
+// ContactChannel is one email address or phone number of a contact. The primary
+// value of each kind is mirrored into Contact.Email and Contact.PhoneNumber.
+type ContactChannel struct {
+	ID        uint        `json:"-" gorm:"primaryKey"`
+	CreatedAt time.Time   `json:"-"`
+	UpdatedAt time.Time   `json:"-"`
+	UserID    uint        `json:"-" gorm:"not null"`
+	ContactID uint        `json:"-" gorm:"not null;index"`
+	Kind      ChannelKind `json:"-" gorm:"varchar(16);not null"`
+	Label     string      `json:"label" gorm:"varchar(32);not null"`
+	Value     string      `json:"value" gorm:"varchar(255);not null"`
+	// Normalized is computed by the database and only read
+	Normalized string `json:"-" gorm:"->"`
+	Primary    bool   `json:"primary" gorm:"column:is_primary;not null"`
+	Position   int    `json:"-"`
+}
+
+// ContactAddress is a postal address of a contact. The primary address is
+// summarized into Contact.Location.
+type ContactAddress struct {
+	ID         uint      `json:"-" gorm:"primaryKey"`
+	CreatedAt  time.Time `json:"-"`
+	UpdatedAt  time.Time `json:"-"`
+	UserID     uint      `json:"-" gorm:"not null"`
+	ContactID  uint      `json:"-" gorm:"not null;index"`
+	Label      string    `json:"label" gorm:"varchar(32);not null"`
+	Street     string    `json:"street" gorm:"varchar(255);not null"`
+	City       string    `json:"city" gorm:"varchar(255);not null"`
+	Region     string    `json:"region" gorm:"varchar(255);not null"`
+	PostalCode string    `json:"postal_code" gorm:"varchar(32);not null"`
+	Country    string    `json:"country" gorm:"varchar(255);not null"`
+	Primary    bool      `json:"primary" gorm:"column:is_primary;not null"`
+	Position   int       `json:"-"`
+}
+
+// Summary is the short form of the address stored in Contact.Location, e.g.
+// "Berlin, Germany". It falls back to the street when there is no city,
+// region or country.
+func (a ContactAddress) Summary() string {
+	if summary := joinNonEmpty(", ", a.City, a.Region, a.Country); summary != "" {
+		return summary
+	}
+	return strings.TrimSpace(a.Street)
+}
+
+// Lines returns the address formatted for display or a mailing label
+func (a ContactAddress) Lines() []string {
+	var lines []string
+	for _, line := range strings.Split(a.Street, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if line := joinNonEmpty(" ", a.PostalCode, joinNonEmpty(", ", a.City, a.Region)); line != "" {
+		lines = append(lines, line)
+	}
+	if country := strings.TrimSpace(a.Country); country != "" {
+		lines = append(lines, country)
+	}
+	return lines
+}
+
+// Empty reports whether no part of the address is filled in
+func (a ContactAddress) Empty() bool {
+	return len(a.Lines()) == 0
+}
+
+func joinNonEmpty(separator string, parts ...string) string {
+	var res []string
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			res = append(res, part)
+		}
+	}
+	return strings.Join(res, separator)
+}
+
+// ChannelValues returns the values of the contact's channels of one kind, primary first
+func (c Contact) ChannelValues(kind ChannelKind) []string {
+	var primary, others []string
+	for _, channel := range c.Channels {
+		if channel.Kind != kind {
+			continue
+		}
+		if channel.Primary {
+			primary = append(primary, channel.Value)
+		} else {
+			others = append(others, channel.Value)
+		}
+	}
+	return append(primary, others...)
+}
+
+// ChannelsOf returns the contact's channels of one kind in their stored order
+func (c Contact) ChannelsOf(kind ChannelKind) []ContactChannel {
+	var res []ContactChannel
+	for _, channel := range c.Channels {
+		if channel.Kind == kind {
+			res = append(res, channel)
+		}
+	}
+	return res
+}
+
+// PrimaryAddress returns the contact's primary address, or else its first one
+func (c Contact) PrimaryAddress() (ContactAddress, bool) {
+	for _, address := range c.Addresses {
+		if address.Primary {
+			return address, true
+		}
+	}
+	if len(c.Addresses) > 0 {
+		return c.Addresses[0], true
+	}
+	return ContactAddress{}, false
+}
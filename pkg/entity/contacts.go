@@ -1,44 +1,62 @@
 package entity
 
 import (
+	"database/sql/driver"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// Relation is the name of one of the user's relationship types
 type Relation string
 
-const (
-	Friend    Relation = "Friend"
-	Family    Relation = "Family"
-	Colleague Relation = "Colleague"
-	School    Relation = "School"
-	Network   Relation = "Network"
-	Services  Relation = "Services"
-)
+// Value stores an empty relation as NULL
+func (r Relation) Value() (driver.Value, error) {
+	if r == "" {
+		return nil, nil
+	}
+	return string(r), nil
+}
 
 type Contact struct {
 	gorm.Model
 	UserID       uint     `json:"user_id" gorm:"not null;index"`
 	User         User     `json:"-" gorm:"foreignKey:UserID"`
 	Name         string   `json:"name" gorm:"varchar(255);not null"`
-	Relationship Relation `json:"relationship" gorm:"type:relation"`
+	Relationship Relation `json:"relationship" gorm:"varchar(64)"`
 	Industry     string   `json:"industry"`
 	Company      string   `json:"company"`
-	Birthday     string   `json:"birthday"`
+	Birthday     Date     `json:"birthday"` // may be without year
 	Vip          bool     `json:"vip" gorm:"default:false" gorm:"column:vip"`
 	DetailInfo
 	VipInfo
-	GoogleCalendarEventID string    `json:"google_calendar_event_id" gorm:"varchar(255)"`
-	CalendarSyncEnabled   bool      `json:"calendar_sync_enabled" gorm:"default:false"`
-	CalendarSyncedAt      time.Time `json:"calendar_synced_at"`
+	GoogleCalendarEventID string       `json:"google_calendar_event_id" gorm:"varchar(255)"`
+	CalendarSyncEnabled   bool         `json:"calendar_sync_enabled" gorm:"default:false"`
+	CalendarSyncedAt      time.Time    `json:"calendar_synced_at"`
+	Tags                  []Tag        `json:"tags" gorm:"many2many:contact_tags;"`
+	CustomFields          CustomFields `json:"custom_fields" gorm:"type:jsonb;not null;default:'{}'"`
+	// CadenceDays is how often to keep in touch, 0 to use the tags' or none.
+	// SnoozedUntil postpones the next due date until the next interaction.
+	CadenceDays  int  `json:"cadence_days" gorm:"not null;default:0"`
+	SnoozedUntil Date `json:"snoozed_until"`
+	// StrengthScore rates the relationship from 0 to 100 and DriftingSince is
+	// the day it started to fade. Both are recomputed by a background job.
+	StrengthScore int  `json:"strength_score" gorm:"not null;default:0"`
+	DriftingSince Date `json:"drifting_since"`
+	// Channels holds every email and phone number, Addresses every postal
+	// address. The primary ones are mirrored into Email, PhoneNumber and Location.
+	Channels  []ContactChannel `json:"channels" gorm:"foreignKey:ContactID"`
+	Addresses []ContactAddress `json:"addresses" gorm:"foreignKey:ContactID"`
+	// Notes are loaded pinned first, then newest first
+	Notes []Note `json:"notes" gorm:"foreignKey:ContactID"`
+	// PhotoID is the image attachment shown as the contact's avatar
+	PhotoID *uint `json:"photo_id"`
 }
 
 type DetailInfo struct {
 	FamilyDetails
 	ContactInfo
-	Notes string `json:"notes"`
 }
 
 type FamilyDetails struct {
@@ -48,24 +66,30 @@ type FamilyDetails struct {
 
 type ContactInfo struct {
 	Location    string `json:"location"`
-	PhoneNumber string `json:"phone_number" gorm:"varchar(255);not null"`
-	Email       string `json:"email" gorm:"varchar(255);not null"`
+	PhoneNumber string `json:"phone_number" gorm:"varchar(255)"`
+	Email       string `json:"email" gorm:"varchar(255)"`
 	LinkedIn    string `json:"linked_in"`
 	Instagram   string `json:"instagram"`
 	X           string `json:"x"`
 }
 
 type VipInfo struct {
-	LastMet       string `json:"last_met"`
-	LastContacted string `json:"last_contacted"`
-	LastUpdate    string `json:"last_update"`
+	LastMet       Date   `json:"last_met"`
+	LastContacted Date   `json:"last_contacted"`
+	LastUpdate    Date   `json:"last_update"`
 	Status        string `json:"status"`
 }
 
+// DetailChanges records the change of a single contact field. All fields changed
+// by one save share a Revision, which is the unit a contact can be restored to.
 type DetailChanges struct {
-	Id           uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key"`
-	PersonId     uint      `json:"person_id"`
+	Id           uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primary_key"`
+	UserID       uint      `json:"user_id" gorm:"not null;index"`
+	PersonId     uint      `json:"person_id" gorm:"not null"`
+	Revision     uuid.UUID `json:"revision" gorm:"type:uuid;not null"`
 	ChangedField string    `json:"changed_field"`
 	OldValue     string    `json:"old_value"`
 	NewValue     string    `json:"new_value"`
+	Actor        string    `json:"actor"`
+	ChangedAt    time.Time `json:"changed_at"`
 }
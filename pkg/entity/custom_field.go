@@ -0,0 +1,182 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type CustomFieldType string
+
+const (
+	TextField   CustomFieldType = "text"
+	DateField   CustomFieldType = "date"
+	NumberField CustomFieldType = "number"
+	SelectField CustomFieldType = "select"
+	URLField    CustomFieldType = "url"
+)
+
+// CustomFieldTypes lists the custom field types in display order
+var CustomFieldTypes = []CustomFieldType{TextField, DateField, NumberField, SelectField, URLField}
+
+// Valid reports whether t is a known custom field type
+func (t CustomFieldType) Valid() bool {
+	for _, known := range CustomFieldTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// CustomFieldDefinition describes a field a user added to all of their contacts.
+// Values are stored on the contact under Key.
+type CustomFieldDefinition struct {
+	gorm.Model
+	UserID   uint            `json:"user_id" gorm:"not null;index"`
+	Key      string          `json:"key" gorm:"varchar(64);not null"`
+	Label    string          `json:"label" gorm:"varchar(255);not null"`
+	Type     CustomFieldType `json:"type" gorm:"not null"`
+	Options  StringList      `json:"options" gorm:"type:jsonb"`
+	Position int             `json:"position"`
+}
+
+var nonKeyChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// CustomFieldKey derives the storage key of a field from its label, e.g.
+// "T-Shirt Size" becomes "t_shirt_size"
+func CustomFieldKey(label string) string {
+	return strings.Trim(nonKeyChars.ReplaceAllString(strings.ToLower(label), "_"), "_")
+}
+
+// Normalize validates a value against the field type and returns it in its
+// stored form. An empty value is always accepted and means "not set".
+func (d CustomFieldDefinition) Normalize(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+
+	switch d.Type {
+	case DateField:
+		if _, err := ParseDate(value); err != nil {
+			return "", fmt.Errorf("%s must be a date in YYYY-MM-DD format", d.Label)
+		}
+	case NumberField:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return "", fmt.Errorf("%s must be a number", d.Label)
+		}
+		value = strconv.FormatFloat(n, 'f', -1, 64)
+	case SelectField:
+		for _, option := range d.Options {
+			if strings.EqualFold(option, value) {
+				return option, nil
+			}
+		}
+		return "", fmt.Errorf("%s must be one of %s", d.Label, strings.Join(d.Options, ", "))
+	case URLField:
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", fmt.Errorf("%s must be an http or https URL", d.Label)
+		}
+	default:
+		if len(value) > 1000 {
+			return "", fmt.Errorf("%s must be at most 1000 characters", d.Label)
+		}
+	}
+	return value, nil
+}
+
+// ApplyCustomFields validates values keyed by field key and merges them into a
+// copy of current. Empty values remove the field, unknown keys are rejected.
+func ApplyCustomFields(defs []CustomFieldDefinition, current CustomFields, values map[string]string) (CustomFields, error) {
+	byKey := map[string]CustomFieldDefinition{}
+	for _, def := range defs {
+		byKey[def.Key] = def
+	}
+
+	res := CustomFields{}
+	for key, value := range current {
+		res[key] = value
+	}
+
+	for key, value := range values {
+		def, ok := byKey[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown custom field %q", key)
+		}
+		normalized, err := def.Normalize(value)
+		if err != nil {
+			return nil, err
+		}
+		if normalized == "" {
+			delete(res, key)
+		} else {
+			res[key] = normalized
+		}
+	}
+	return res, nil
+}
+
+// CustomFields holds the custom field values of a contact, stored as a JSONB object
+type CustomFields map[string]string
+
+// Value stores a nil map as an empty object, the column is not nullable
+func (f CustomFields) Value() (driver.Value, error) {
+	if f == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(f)
+	return string(b), err
+}
+
+func (f *CustomFields) Scan(value interface{}) error {
+	return scanJSON(value, f)
+}
+
+// Keys returns the keys of the set fields in sorted order
+func (f CustomFields) Keys() []string {
+	keys := make([]string, 0, len(f))
+	for key := range f {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// StringList is a list of strings stored as a JSONB array
+type StringList []string
+
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(l)
+	return string(b), err
+}
+
+func (l *StringList) Scan(value interface{}) error {
+	return scanJSON(value, l)
+}
+
+func scanJSON(value interface{}, dst interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, dst)
+	case string:
+		return json.Unmarshal([]byte(v), dst)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", value, dst)
+	}
+}
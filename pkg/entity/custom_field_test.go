@@ -0,0 +1,31 @@
+package entity
+
+import "testing"
+
+func TestCustomFieldNormalize(t *testing.T) {
+	tests := []struct {
+		typ     CustomFieldType
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{NumberField, " 7.0 ", "7", false},
+		{NumberField, "-1.5e3", "-1500", false},
+		{NumberField, "NaN", "", true},
+		{NumberField, "Inf", "", true},
+		{NumberField, "-infinity", "", true},
+		{NumberField, "1e400", "", true},
+		{NumberField, "seven", "", true},
+		{DateField, "2024-02-29", "2024-02-29", false},
+		{DateField, "2023-02-29", "", true},
+		{DateField, "29.02.2024", "", true},
+		{TextField, "", "", false},
+	}
+	for _, tt := range tests {
+		d := CustomFieldDefinition{Label: "Field", Type: tt.typ}
+		got, err := d.Normalize(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Normalize(%s, %q) = %q, %v, want %q, error %v", tt.typ, tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
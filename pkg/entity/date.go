@@ -0,0 +1,218 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Date is a calendar date without a time of day, stored in a DATE column. The
+// zero Date means no date and is stored as NULL. Birthdays may be known
+// without the year, such a Date has Year 0 and is written as "--MM-DD", the
+// notation vCard uses.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// DateLayout is the format dates are written in, in forms, JSON and exports
+const DateLayout = "2006-01-02"
+
+// noYear is the year stored for dates without one. It is a leap year, so
+// 29 February can be stored, and far before any real date. Migration 000012
+// relies on the same value.
+const noYear = 4
+
+// NewDate returns the date of t in t's location
+func NewDate(t time.Time) Date {
+	return Date{Year: t.Year(), Month: t.Month(), Day: t.Day()}
+}
+
+// Today returns the current date in the server's location
+func Today() Date {
+	return NewDate(time.Now())
+}
+
+// ParseDate parses a YYYY-MM-DD date. An empty string is the zero Date.
+func ParseDate(s string) (Date, error) {
+	if s == "" {
+		return Date{}, nil
+	}
+	t, err := time.Parse(DateLayout, s)
+	if err != nil {
+		return Date{}, fmt.Errorf("%q is not a date in YYYY-MM-DD format", s)
+	}
+	return NewDate(t), nil
+}
+
+// ParseBirthday parses a YYYY-MM-DD date or a --MM-DD date without year. An
+// empty string is the zero Date.
+func ParseBirthday(s string) (Date, error) {
+	if len(s) != len("--01-02") || s[:2] != "--" {
+		return ParseDate(s)
+	}
+	t, err := time.Parse(DateLayout, fmt.Sprintf("%04d%s", noYear, s[1:]))
+	if err != nil {
+		return Date{}, fmt.Errorf("%q is not a date in --MM-DD format", s)
+	}
+	return Date{Month: t.Month(), Day: t.Day()}, nil
+}
+
+// IsZero reports whether d is no date
+func (d Date) IsZero() bool {
+	return d == Date{}
+}
+
+// HasYear reports whether the year of d is known
+func (d Date) HasYear() bool {
+	return d.Year != 0
+}
+
+// String formats d as YYYY-MM-DD, --MM-DD without year, or "" for no date
+func (d Date) String() string {
+	switch {
+	case d.IsZero():
+		return ""
+	case !d.HasYear():
+		return fmt.Sprintf("--%02d-%02d", d.Month, d.Day)
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// Time returns midnight of d in loc. Dates without year must use In instead.
+func (d Date) Time(loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, loc)
+}
+
+// In returns the date's anniversary in the given year. 29 February falls on
+// 28 February in years that are not leap years.
+func (d Date) In(year int) Date {
+	res := Date{Year: year, Month: d.Month, Day: d.Day}
+	if d.Month == time.February && d.Day == 29 && !isLeap(year) {
+		res.Day = 28
+	}
+	return res
+}
+
+// AddDays returns the date n days after d, or before it for a negative n
+func (d Date) AddDays(n int) Date {
+	return NewDate(d.Time(time.UTC).AddDate(0, 0, n))
+}
+
+// Next returns the first anniversary of d on or after from
+func (d Date) Next(from Date) Date {
+	next := d.In(from.Year)
+	if next.Before(from) {
+		next = d.In(from.Year + 1)
+	}
+	return next
+}
+
+// Before reports whether d is before other. Both must have a year.
+func (d Date) Before(other Date) bool {
+	if d.Year != other.Year {
+		return d.Year < other.Year
+	}
+	if d.Month != other.Month {
+		return d.Month < other.Month
+	}
+	return d.Day < other.Day
+}
+
+// DaysUntil returns the number of days from d to other, negative when other is earlier
+func (d Date) DaysUntil(other Date) int {
+	return int(other.Time(time.UTC).Sub(d.Time(time.UTC)).Hours() / 24)
+}
+
+// AgeOn returns the age in years reached on the given date. ok is false when
+// the year of d is unknown.
+func (d Date) AgeOn(on Date) (age int, ok bool) {
+	if !d.HasYear() {
+		return 0, false
+	}
+	age = on.Year - d.Year
+	if on.Before(d.In(on.Year)) {
+		age--
+	}
+	return age, true
+}
+
+func isLeap(year int) bool {
+	return year%4 == 0 && (year%100 != 0 || year%400 == 0)
+}
+
+// Value stores no date as NULL and dates without year in year noYear
+func (d Date) Value() (driver.Value, error) {
+	if d.IsZero() {
+		return nil, nil
+	}
+	year := d.Year
+	if !d.HasYear() {
+		year = noYear
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", year, d.Month, d.Day), nil
+}
+
+// Scan reads a DATE column
+func (d *Date) Scan(src interface{}) error {
+	var t time.Time
+	switch v := src.(type) {
+	case nil:
+		*d = Date{}
+		return nil
+	case time.Time:
+		t = v
+	case string:
+		return d.scanString(v)
+	case []byte:
+		return d.scanString(string(v))
+	default:
+		return fmt.Errorf("cannot scan %T into a date", src)
+	}
+	*d = NewDate(t)
+	if d.Year == noYear {
+		d.Year = 0
+	}
+	return nil
+}
+
+func (d *Date) scanString(s string) error {
+	if len(s) > len(DateLayout) {
+		s = s[:len(DateLayout)]
+	}
+	t, err := time.Parse(DateLayout, s)
+	if err != nil {
+		return err
+	}
+	return d.Scan(t)
+}
+
+// GormDataType makes migrations and AutoMigrate use a DATE column
+func (Date) GormDataType() string {
+	return "date"
+}
+
+// MarshalJSON writes d as a string, "" for no date
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads a date written by MarshalJSON; null is no date
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == nil {
+		*d = Date{}
+		return nil
+	}
+	parsed, err := ParseBirthday(*s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
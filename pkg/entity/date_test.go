@@ -0,0 +1,228 @@
+package entity
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseBirthday(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Date
+		wantErr bool
+	}{
+		{"", Date{}, false},
+		{"1985-04-15", Date{1985, time.April, 15}, false},
+		{"2024-02-29", Date{2024, time.February, 29}, false},
+		{"--04-15", Date{0, time.April, 15}, false},
+		{"--02-29", Date{0, time.February, 29}, false},
+		{"2023-02-29", Date{}, true},
+		{"2023-02-30", Date{}, true},
+		{"--02-30", Date{}, true},
+		{"--13-01", Date{}, true},
+		{"--0415", Date{}, true},
+		{"15/04/1985", Date{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseBirthday(tt.s)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseBirthday(%q) = %v, %v, want %v, error %v", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Date
+		wantErr bool
+	}{
+		{"", Date{}, false},
+		{"1985-04-15", Date{1985, time.April, 15}, false},
+		{"--04-15", Date{}, true},
+		{"2023-02-30", Date{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDate(tt.s)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseDate(%q) = %v, %v, want %v, error %v", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestDateString(t *testing.T) {
+	tests := []struct {
+		d    Date
+		want string
+	}{
+		{Date{}, ""},
+		{Date{1985, time.April, 15}, "1985-04-15"},
+		{Date{0, time.April, 5}, "--04-05"},
+		{Date{33, time.January, 2}, "0033-01-02"},
+	}
+	for _, tt := range tests {
+		if got := tt.d.String(); got != tt.want {
+			t.Errorf("%#v.String() = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+// Birthdays without year are stored in year 0004, which migration 000012
+// relies on, and read back without year
+func TestDateValueScan(t *testing.T) {
+	tests := []struct {
+		name   string
+		d      Date
+		stored interface{}
+	}{
+		{"no date", Date{}, nil},
+		{"with year", Date{1985, time.April, 15}, "1985-04-15"},
+		{"without year", Date{0, time.April, 15}, "0004-04-15"},
+		{"29 February without year", Date{0, time.February, 29}, "0004-02-29"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored, err := tt.d.Value()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored != tt.stored {
+				t.Errorf("Value() = %v, want %v", stored, tt.stored)
+			}
+
+			// The driver returns a time for DATE columns, some return text
+			var sources []interface{}
+			if s, ok := stored.(string); ok {
+				parsed, _ := time.Parse(DateLayout, s)
+				sources = []interface{}{parsed, s, []byte(s + "T00:00:00Z")}
+			} else {
+				sources = []interface{}{nil}
+			}
+			for _, src := range sources {
+				got := Date{Year: 1, Month: 1, Day: 1}
+				if err := got.Scan(src); err != nil {
+					t.Fatalf("Scan(%#v): %v", src, err)
+				}
+				if got != tt.d {
+					t.Errorf("Scan(%#v) = %#v, want %#v", src, got, tt.d)
+				}
+			}
+		})
+	}
+
+	var d Date
+	if err := d.Scan(42); err == nil {
+		t.Error("Scan(42) succeeded")
+	}
+}
+
+func TestDateJSON(t *testing.T) {
+	tests := []struct {
+		d    Date
+		json string
+	}{
+		{Date{}, `""`},
+		{Date{1985, time.April, 15}, `"1985-04-15"`},
+		{Date{0, time.April, 15}, `"--04-15"`},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.d)
+		if err != nil || string(data) != tt.json {
+			t.Errorf("Marshal(%v) = %s, %v, want %s", tt.d, data, err, tt.json)
+		}
+		var got Date
+		if err := json.Unmarshal([]byte(tt.json), &got); err != nil || got != tt.d {
+			t.Errorf("Unmarshal(%s) = %v, %v, want %v", tt.json, got, err, tt.d)
+		}
+	}
+
+	got := Date{1985, time.April, 15}
+	if err := json.Unmarshal([]byte("null"), &got); err != nil || !got.IsZero() {
+		t.Errorf("Unmarshal(null) = %v, %v, want no date", got, err)
+	}
+	if err := json.Unmarshal([]byte(`"2023-02-30"`), &got); err == nil {
+		t.Error("Unmarshal of an invalid date succeeded")
+	}
+}
+
+func TestDateIn(t *testing.T) {
+	tests := []struct {
+		d    Date
+		year int
+		want Date
+	}{
+		{Date{1985, time.April, 15}, 2024, Date{2024, time.April, 15}},
+		{Date{0, time.February, 29}, 2024, Date{2024, time.February, 29}},
+		{Date{0, time.February, 29}, 2023, Date{2023, time.February, 28}},
+		{Date{0, time.February, 29}, 2100, Date{2100, time.February, 28}},
+		{Date{0, time.February, 29}, 2000, Date{2000, time.February, 29}},
+	}
+	for _, tt := range tests {
+		if got := tt.d.In(tt.year); got != tt.want {
+			t.Errorf("%v.In(%d) = %v, want %v", tt.d, tt.year, got, tt.want)
+		}
+	}
+}
+
+func TestDateNext(t *testing.T) {
+	tests := []struct {
+		d    Date
+		from Date
+		want Date
+	}{
+		{Date{0, time.April, 15}, Date{2024, time.April, 1}, Date{2024, time.April, 15}},
+		{Date{0, time.April, 15}, Date{2024, time.April, 15}, Date{2024, time.April, 15}},
+		{Date{0, time.April, 15}, Date{2024, time.April, 16}, Date{2025, time.April, 15}},
+		{Date{1990, time.January, 1}, Date{2024, time.December, 31}, Date{2025, time.January, 1}},
+		{Date{0, time.February, 29}, Date{2023, time.March, 1}, Date{2024, time.February, 29}},
+	}
+	for _, tt := range tests {
+		if got := tt.d.Next(tt.from); got != tt.want {
+			t.Errorf("%v.Next(%v) = %v, want %v", tt.d, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestDateAgeOn(t *testing.T) {
+	tests := []struct {
+		d   Date
+		on  Date
+		age int
+		ok  bool
+	}{
+		{Date{1985, time.April, 15}, Date{2024, time.April, 14}, 38, true},
+		{Date{1985, time.April, 15}, Date{2024, time.April, 15}, 39, true},
+		{Date{2000, time.February, 29}, Date{2023, time.February, 28}, 23, true},
+		{Date{2000, time.February, 29}, Date{2023, time.February, 27}, 22, true},
+		{Date{0, time.April, 15}, Date{2024, time.April, 15}, 0, false},
+	}
+	for _, tt := range tests {
+		age, ok := tt.d.AgeOn(tt.on)
+		if age != tt.age || ok != tt.ok {
+			t.Errorf("%v.AgeOn(%v) = %d, %v, want %d, %v", tt.d, tt.on, age, ok, tt.age, tt.ok)
+		}
+	}
+}
+
+func TestDateDays(t *testing.T) {
+	tests := []struct {
+		d    Date
+		n    int
+		want Date
+	}{
+		{Date{2024, time.February, 28}, 1, Date{2024, time.February, 29}},
+		{Date{2023, time.February, 28}, 1, Date{2023, time.March, 1}},
+		{Date{2024, time.January, 1}, -1, Date{2023, time.December, 31}},
+		{Date{2024, time.January, 1}, 366, Date{2025, time.January, 1}},
+	}
+	for _, tt := range tests {
+		got := tt.d.AddDays(tt.n)
+		if got != tt.want {
+			t.Errorf("%v.AddDays(%d) = %v, want %v", tt.d, tt.n, got, tt.want)
+		}
+		if days := tt.d.DaysUntil(got); days != tt.n {
+			t.Errorf("%v.DaysUntil(%v) = %d, want %d", tt.d, got, days, tt.n)
+		}
+	}
+}
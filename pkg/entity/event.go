@@ -1,13 +1,47 @@
 package entity
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Event struct {
 	gorm.Model
 	UserID                uint   `gorm:"not null;index"`
 	ContactID             uint   `gorm:"not null;index"`
 	Title                 string `gorm:"not null"`
-	EventDate             string `gorm:"not null"` // Date of the event (YYYY-MM-DD)
+	EventDate             Date   `gorm:"not null"`
 	Recurrence            string // "none", "monthly", "yearly"
 	GoogleCalendarEventID string
 }
+
+// NextOccurrence returns the first occurrence on or after today of an event
+// on date repeating by recurrence, or date itself when it is not repeating or
+// not past. Monthly events on a day a month does not have fall on its last
+// day, e.g. 31 January repeats on 29 February and 31 March.
+func NextOccurrence(date Date, recurrence string, today Date) Date {
+	if !date.Before(today) {
+		return date
+	}
+	switch recurrence {
+	case "yearly":
+		return date.Next(today)
+	case "monthly":
+		months := (today.Year-date.Year)*12 + int(today.Month) - int(date.Month)
+		next := date.addMonths(months)
+		if next.Before(today) {
+			next = date.addMonths(months + 1)
+		}
+		return next
+	}
+	return date
+}
+
+// addMonths returns the date n months after d, on the last day of that month
+// when it is shorter than d's day
+func (d Date) addMonths(n int) Date {
+	first := time.Date(d.Year, d.Month+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1).Day()
+	return Date{Year: first.Year(), Month: first.Month(), Day: min(d.Day, last)}
+}
@@ -0,0 +1,52 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextOccurrence(t *testing.T) {
+	today := Date{2024, time.April, 10}
+	tests := []struct {
+		name       string
+		date       Date
+		recurrence string
+		want       Date
+	}{
+		{"future", Date{2024, time.May, 1}, "yearly", Date{2024, time.May, 1}},
+		{"today", today, "monthly", today},
+		{"past, not repeating", Date{2023, time.May, 1}, "none", Date{2023, time.May, 1}},
+		{"yearly, later this year", Date{2019, time.May, 1}, "yearly", Date{2024, time.May, 1}},
+		{"yearly, next year", Date{2019, time.March, 1}, "yearly", Date{2025, time.March, 1}},
+		{"yearly on 29 February", Date{2020, time.February, 29}, "yearly", Date{2025, time.February, 28}},
+		{"monthly, later this month", Date{2023, time.June, 15}, "monthly", Date{2024, time.April, 15}},
+		{"monthly, next month", Date{2023, time.June, 5}, "monthly", Date{2024, time.May, 5}},
+		{"monthly on the 31st", Date{2024, time.January, 31}, "monthly", Date{2024, time.April, 30}},
+	}
+	for _, tt := range tests {
+		if got := NextOccurrence(tt.date, tt.recurrence, today); got != tt.want {
+			t.Errorf("%s: NextOccurrence(%v, %q) = %v, want %v", tt.name, tt.date, tt.recurrence, got, tt.want)
+		}
+	}
+}
+
+// Monthly events on a late day fall on the last day of shorter months and
+// come back to their day afterwards
+func TestNextOccurrenceMonthEnd(t *testing.T) {
+	date := Date{2024, time.January, 31}
+	tests := []struct {
+		today Date
+		want  Date
+	}{
+		{Date{2024, time.February, 1}, Date{2024, time.February, 29}},
+		{Date{2024, time.March, 1}, Date{2024, time.March, 31}},
+		{Date{2025, time.February, 1}, Date{2025, time.February, 28}},
+		{Date{2024, time.December, 31}, Date{2024, time.December, 31}},
+		{Date{2025, time.January, 1}, Date{2025, time.January, 31}},
+	}
+	for _, tt := range tests {
+		if got := NextOccurrence(date, "monthly", tt.today); got != tt.want {
+			t.Errorf("NextOccurrence(%v, monthly) on %v = %v, want %v", date, tt.today, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package entity
+
+import (
+	"strings"
+	"time"
+)
+
+// Household is a group of contacts addressed together, e.g. a family or a
+// couple. Names are unique per user, ignoring case. The address is shared by
+// the members, and Greeting is how the household is addressed in greeting
+// lists; when empty it is derived from the members' first names.
+type Household struct {
+	ID         uint             `json:"id" gorm:"primaryKey"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+	UserID     uint             `json:"user_id" gorm:"not null;index"`
+	Name       string           `json:"name" gorm:"varchar(64);not null"`
+	Greeting   string           `json:"greeting" gorm:"varchar(255);not null"`
+	Street     string           `json:"street" gorm:"varchar(255);not null"`
+	City       string           `json:"city" gorm:"varchar(255);not null"`
+	Region     string           `json:"region" gorm:"varchar(255);not null"`
+	PostalCode string           `json:"postal_code" gorm:"varchar(32);not null"`
+	Country    string           `json:"country" gorm:"varchar(255);not null"`
+	Members    []Contact        `json:"members" gorm:"many2many:household_members;"`
+	Events     []HouseholdEvent `json:"events" gorm:"foreignKey:HouseholdID"`
+}
+
+// HouseholdEvent is an event shared by the members of a household, like an
+// anniversary. It is mirrored into the user's Google Calendar like the
+// custom events of a contact.
+type HouseholdEvent struct {
+	ID                    uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+	UserID                uint      `json:"user_id" gorm:"not null;index"`
+	HouseholdID           uint      `json:"household_id" gorm:"not null;index"`
+	Title                 string    `json:"title" gorm:"not null"`
+	EventDate             Date      `json:"event_date" gorm:"not null"`
+	Recurrence            string    `json:"recurrence"` // "none", "monthly", "yearly"
+	GoogleCalendarEventID string    `json:"google_calendar_event_id"`
+}
+
+// Address returns the shared address of the household
+func (h Household) Address() ContactAddress {
+	return ContactAddress{
+		Street:     h.Street,
+		City:       h.City,
+		Region:     h.Region,
+		PostalCode: h.PostalCode,
+		Country:    h.Country,
+	}
+}
+
+// SetAddress replaces the shared address of the household
+func (h *Household) SetAddress(a ContactAddress) {
+	h.Street = strings.TrimSpace(a.Street)
+	h.City = strings.TrimSpace(a.City)
+	h.Region = strings.TrimSpace(a.Region)
+	h.PostalCode = strings.TrimSpace(a.PostalCode)
+	h.Country = strings.TrimSpace(a.Country)
+}
+
+// MailingAddress is the shared address of the household, or else the
+// primary address of the first member having one. The members must be loaded.
+func (h Household) MailingAddress() (ContactAddress, bool) {
+	if address := h.Address(); !address.Empty() {
+		return address, true
+	}
+	for _, member := range h.Members {
+		if address, ok := member.PrimaryAddress(); ok && !address.Empty() {
+			return address, true
+		}
+	}
+	return ContactAddress{}, false
+}
+
+// Salutation is how the household is addressed, its Greeting or else
+// "Dear" followed by the first names of its members, e.g. "Dear Anna and Ben".
+// The members must be loaded.
+func (h Household) Salutation() string {
+	if greeting := strings.TrimSpace(h.Greeting); greeting != "" {
+		return greeting
+	}
+	var names []string
+	for _, member := range h.Members {
+		if fields := strings.Fields(member.Name); len(fields) > 0 {
+			names = append(names, fields[0])
+		}
+	}
+	if len(names) == 0 {
+		return "Dear " + h.Name
+	}
+	return "Dear " + joinNames(names)
+}
+
+// joinNames joins names as "Anna", "Anna and Ben" or "Anna, Ben and Carl"
+func joinNames(names []string) string {
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
@@ -0,0 +1,36 @@
+package entity
+
+import "gorm.io/gorm"
+
+type InteractionType string
+
+const (
+	Call    InteractionType = "call"
+	Meeting InteractionType = "meeting"
+	Message InteractionType = "message"
+	Email   InteractionType = "email"
+)
+
+// InteractionTypes lists the interaction types in display order
+var InteractionTypes = []InteractionType{Call, Meeting, Message, Email}
+
+// Valid reports whether t is a known interaction type
+func (t InteractionType) Valid() bool {
+	for _, known := range InteractionTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Interaction is a logged touchpoint with one or more contacts. The contacts'
+// LastContacted and LastMet fields are derived from their newest interactions.
+type Interaction struct {
+	gorm.Model
+	UserID     uint            `gorm:"not null;index"`
+	Type       InteractionType `gorm:"not null"`
+	OccurredOn Date            `gorm:"not null"`
+	Notes      string
+	Contacts   []Contact `gorm:"many2many:interaction_contacts;"`
+}
@@ -0,0 +1,38 @@
+package entity
+
+import "time"
+
+// LinkType is an entry of the catalogue of links between contacts. A link reads
+// "from Label to" and, seen from the other contact, "to InverseLabel from".
+type LinkType struct {
+	ID           uint   `json:"id" gorm:"primaryKey"`
+	Key          string `json:"key" gorm:"varchar(64);not null;uniqueIndex"`
+	Label        string `json:"label" gorm:"varchar(64);not null"`
+	InverseLabel string `json:"inverse_label" gorm:"varchar(64);not null"`
+	Symmetric    bool   `json:"symmetric"`
+	Position     int    `json:"-"`
+}
+
+// LabelFrom returns how a link of this type reads from one of its contacts
+func (t LinkType) LabelFrom(outgoing bool) string {
+	if outgoing || t.Symmetric {
+		return t.Label
+	}
+	return t.InverseLabel
+}
+
+// ContactLink is a typed link between two contacts of the same user, e.g.
+// "Alice introduced me to Bob". Links are removed with either contact.
+type ContactLink struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	UserID        uint      `json:"user_id" gorm:"not null;index"`
+	FromContactID uint      `json:"from_contact_id" gorm:"not null"`
+	FromContact   Contact   `json:"-" gorm:"foreignKey:FromContactID"`
+	ToContactID   uint      `json:"to_contact_id" gorm:"not null"`
+	ToContact     Contact   `json:"-" gorm:"foreignKey:ToContactID"`
+	LinkTypeID    uint      `json:"link_type_id" gorm:"not null"`
+	LinkType      LinkType  `json:"link_type"`
+	Note          string    `json:"note"`
+}
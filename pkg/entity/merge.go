@@ -0,0 +1,50 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+// MergeSource says which contact a field value is taken from when two contacts are merged
+type MergeSource string
+
+const (
+	KeepSurvivor MergeSource = "survivor"
+	TakeMerged   MergeSource = "merged"
+)
+
+// Valid reports whether s is one of the sources a field can be taken from
+func (s MergeSource) Valid() bool {
+	return s == KeepSurvivor || s == TakeMerged
+}
+
+// ContactMerge records that a contact was merged into another one. The merged
+// contact is soft deleted; its field values at the time are kept in Snapshot.
+type ContactMerge struct {
+	gorm.Model
+	UserID     uint        `json:"user_id" gorm:"not null;index"`
+	SurvivorID uint        `json:"survivor_id" gorm:"not null;index"`
+	MergedID   uint        `json:"merged_id" gorm:"not null"`
+	MergedName string      `json:"merged_name"`
+	Snapshot   FieldValues `json:"snapshot" gorm:"type:jsonb;not null"`
+	// TakenFields lists the columns whose value came, at least partly, from the merged contact
+	TakenFields StringList `json:"taken_fields" gorm:"type:jsonb;not null"`
+	Actor       string     `json:"actor"`
+}
+
+// FieldValues holds contact values keyed by column, stored as a JSONB object
+type FieldValues map[string]string
+
+func (v FieldValues) Value() (driver.Value, error) {
+	if v == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func (v *FieldValues) Scan(value interface{}) error {
+	return scanJSON(value, v)
+}
@@ -0,0 +1,30 @@
+package entity
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Note is a timestamped note about a contact. EditedAt is set when the body
+// is changed after the note was written; pinning does not count as an edit.
+// Pinned notes are listed before the others.
+type Note struct {
+	gorm.Model
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	ContactID uint       `json:"contact_id" gorm:"not null;index"`
+	Body      string     `json:"body" gorm:"not null"`
+	Pinned    bool       `json:"pinned" gorm:"not null;default:false"`
+	EditedAt  *time.Time `json:"edited_at"`
+}
+
+// JoinNotes joins the bodies of notes with blank lines, for formats that
+// hold a single notes field such as vCard and CSV
+func JoinNotes(notes []Note) string {
+	bodies := make([]string, 0, len(notes))
+	for _, note := range notes {
+		bodies = append(bodies, note.Body)
+	}
+	return strings.Join(bodies, "\n\n")
+}
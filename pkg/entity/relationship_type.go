@@ -0,0 +1,40 @@
+package entity
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// DefaultRelationshipTypes are the relationship types every new user starts with
+var DefaultRelationshipTypes = []string{"Friend", "Family", "Colleague", "School", "Network", "Services"}
+
+// RelationshipType is a relationship a user can give their contacts, e.g.
+// Friend or Mentor. Names are unique per user, ignoring case, and contacts
+// refer to their type by name.
+type RelationshipType struct {
+	gorm.Model
+	UserID   uint   `json:"user_id" gorm:"not null;index"`
+	Name     string `json:"name" gorm:"varchar(64);not null"`
+	Position int    `json:"position"`
+}
+
+// FindRelationshipType returns the type named name, ignoring case
+func FindRelationshipType(types []RelationshipType, name string) (RelationshipType, bool) {
+	name = strings.TrimSpace(name)
+	for _, t := range types {
+		if strings.EqualFold(t.Name, name) {
+			return t, true
+		}
+	}
+	return RelationshipType{}, false
+}
+
+// RelationshipTypeNames returns the names of types in their order
+func RelationshipTypeNames(types []RelationshipType) []string {
+	names := make([]string, 0, len(types))
+	for _, t := range types {
+		names = append(names, t.Name)
+	}
+	return names
+}
@@ -0,0 +1,111 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SmartList is a named, saved contact filter. Its contacts are looked up
+// whenever the list is used, so they follow every change to the contacts.
+// Pinned lists are offered on the contacts page and on the dashboard.
+type SmartList struct {
+	gorm.Model
+	UserID  uint             `json:"user_id" gorm:"not null;index"`
+	Name    string           `json:"name" gorm:"varchar(64);not null"`
+	Pinned  bool             `json:"pinned" gorm:"not null;default:false"`
+	Filters SmartListFilters `json:"filters" gorm:"type:jsonb;not null;default:'{}'"`
+}
+
+// SmartListFilters are the conditions of a smart list, a contact must meet
+// every one that is set. NotContactedDays matches contacts not contacted in
+// that many days, or never, counted from the day the list is used.
+type SmartListFilters struct {
+	Relationship     string            `json:"relationship,omitempty"`
+	Vip              *bool             `json:"vip,omitempty"`
+	Location         string            `json:"location,omitempty"`
+	NotContactedDays int               `json:"not_contacted_days,omitempty"`
+	Tags             []string          `json:"tags,omitempty"`
+	CustomFields     map[string]string `json:"custom_fields,omitempty"`
+}
+
+// Normalize trims the filters and checks the day count. The relationship is
+// checked against the user's relationship types by the caller.
+func (f *SmartListFilters) Normalize() error {
+	f.Relationship = strings.TrimSpace(f.Relationship)
+	f.Location = strings.TrimSpace(f.Location)
+	if f.NotContactedDays < 0 {
+		return fmt.Errorf("not contacted days cannot be negative")
+	}
+
+	var tags []string
+	for _, tag := range f.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	f.Tags = tags
+	for key, value := range f.CustomFields {
+		if strings.TrimSpace(value) == "" {
+			delete(f.CustomFields, key)
+		}
+	}
+	if len(f.CustomFields) == 0 {
+		f.CustomFields = nil
+	}
+	return nil
+}
+
+// NotContactedSince is the date before which a contact was last contacted to
+// match NotContactedDays, nil when the condition is not set
+func (f SmartListFilters) NotContactedSince(today time.Time) *time.Time {
+	if f.NotContactedDays == 0 {
+		return nil
+	}
+	since := today.AddDate(0, 0, -f.NotContactedDays)
+	return &since
+}
+
+// Describe lists the conditions for people, e.g. "VIP, Friend, tag Investors"
+func (f SmartListFilters) Describe() string {
+	var parts []string
+	if f.Vip != nil {
+		if *f.Vip {
+			parts = append(parts, "VIP")
+		} else {
+			parts = append(parts, "not VIP")
+		}
+	}
+	if f.Relationship != "" {
+		parts = append(parts, f.Relationship)
+	}
+	if f.Location != "" {
+		parts = append(parts, "in "+f.Location)
+	}
+	if f.NotContactedDays > 0 {
+		parts = append(parts, fmt.Sprintf("not contacted in %d days", f.NotContactedDays))
+	}
+	for _, tag := range f.Tags {
+		parts = append(parts, "tag "+tag)
+	}
+	for _, key := range CustomFields(f.CustomFields).Keys() {
+		parts = append(parts, fmt.Sprintf("%s: %s", key, f.CustomFields[key]))
+	}
+	if len(parts) == 0 {
+		return "All contacts"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (f SmartListFilters) Value() (driver.Value, error) {
+	b, err := json.Marshal(f)
+	return string(b), err
+}
+
+func (f *SmartListFilters) Scan(value interface{}) error {
+	return scanJSON(value, f)
+}
@@ -0,0 +1,118 @@
+package entity
+
+import "math"
+
+const (
+	// StrengthWindowDays is how far back interactions count towards the
+	// frequency of a relationship
+	StrengthWindowDays = 365
+	// RhythmWindowDays is how far back interactions set the usual rhythm of
+	// a relationship without a cadence
+	RhythmWindowDays = 730
+	// DriftingScore is the score below which a relationship can be drifting
+	DriftingScore = 50
+	// driftingFactor is how many usual gaps without contact make a
+	// relationship drift away
+	driftingFactor = 1.5
+	// defaultHalfLifeDays is how fast the recency of a contact without a
+	// known rhythm fades: half of its points are gone after this many days.
+	// Otherwise the rhythm is the half-life, within the bounds below.
+	defaultHalfLifeDays = 90
+	minHalfLifeDays     = 14
+	maxHalfLifeDays     = 180
+	// monthlyInteractions is the number of interactions in the window that
+	// earns all frequency points, about one a month
+	monthlyInteractions = 12
+)
+
+// The points each part of the strength score is worth. Contacts without a
+// cadence have no cadence points, their score is scaled from the others.
+const (
+	recencyPoints   = 35
+	frequencyPoints = 25
+	eventPoints     = 10
+	vipPoints       = 10
+	cadencePoints   = 20
+)
+
+// StrengthInputs are the interaction and event counts of a contact the
+// strength is computed from, besides the contact itself
+type StrengthInputs struct {
+	// RecentInteractions are the interactions of the last StrengthWindowDays
+	RecentInteractions int
+	// Interactions and FirstInteraction are the number and oldest of the
+	// interactions of the last RhythmWindowDays
+	Interactions     int
+	FirstInteraction Date
+	Events           int
+}
+
+// Strength is how strong a relationship is, with the parts of its score
+type Strength struct {
+	Score     int     `json:"score"` // 0 to 100
+	Recency   float64 `json:"recency"`
+	Frequency float64 `json:"frequency"`
+	Events    float64 `json:"events"`
+	Vip       float64 `json:"vip"`
+	Cadence   float64 `json:"cadence"`
+	// RhythmDays is the usual number of days between interactions: the
+	// cadence, else the average gap of the rhythm window. 0 when unknown.
+	RhythmDays int `json:"rhythm_days"`
+	// DriftingSince is the day the relationship started to fade, when the
+	// time without contact grew well beyond its rhythm. Zero when it is not.
+	DriftingSince Date `json:"drifting_since"`
+}
+
+// ComputeStrength rates the relationship with a contact on today from how
+// recent and frequent the interactions are, its events, VIP status and how
+// well its keep-in-touch cadence is kept. The tags of the contact must be
+// loaded for its cadence.
+func ComputeStrength(c Contact, in StrengthInputs, today Date) Strength {
+	var s Strength
+	cadence, _ := c.Cadence()
+	daysSince := -1
+	if !c.LastContacted.IsZero() {
+		daysSince = max(c.LastContacted.DaysUntil(today), 0)
+	}
+
+	s.RhythmDays = cadence
+	if s.RhythmDays == 0 && in.Interactions > 1 && !in.FirstInteraction.IsZero() {
+		s.RhythmDays = max(in.FirstInteraction.DaysUntil(c.LastContacted)/(in.Interactions-1), 1)
+	}
+
+	if daysSince >= 0 {
+		halfLife := defaultHalfLifeDays
+		if s.RhythmDays > 0 {
+			halfLife = min(max(s.RhythmDays, minHalfLifeDays), maxHalfLifeDays)
+		}
+		s.Recency = recencyPoints * math.Pow(0.5, float64(daysSince)/float64(halfLife))
+	}
+	s.Frequency = frequencyPoints * math.Min(float64(in.RecentInteractions)/monthlyInteractions, 1)
+	events := in.Events * 3
+	if !c.Birthday.IsZero() {
+		events += 4
+	}
+	s.Events = float64(min(events, eventPoints))
+	if c.Vip {
+		s.Vip = vipPoints
+	}
+
+	total := float64(recencyPoints + frequencyPoints + eventPoints + vipPoints)
+	if cadence > 0 {
+		total += cadencePoints
+		overdue := 0
+		if due := c.NextDue(); due.Before(today) {
+			overdue = due.DaysUntil(today)
+		}
+		s.Cadence = cadencePoints * math.Max(1-float64(overdue)/float64(cadence), 0)
+	}
+	s.Score = int(math.Round(100 * (s.Recency + s.Frequency + s.Events + s.Vip + s.Cadence) / total))
+
+	if s.RhythmDays > 0 && daysSince >= 0 && s.Score < DriftingScore {
+		since := c.LastContacted.AddDays(int(math.Ceil(driftingFactor * float64(s.RhythmDays))))
+		if !today.Before(since) {
+			s.DriftingSince = since
+		}
+	}
+	return s
+}
@@ -0,0 +1,116 @@
+package entity
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestComputeStrength(t *testing.T) {
+	today := Date{2024, time.June, 1}
+	tests := []struct {
+		name          string
+		cadence       int
+		vip           bool
+		birthday      Date
+		daysSince     int // -1 when never contacted
+		in            StrengthInputs
+		score         int
+		rhythmDays    int
+		driftingSince Date
+	}{
+		{
+			name:      "never contacted",
+			daysSince: -1,
+		},
+		{
+			name:      "contacted today, VIP with events",
+			vip:       true,
+			birthday:  Date{0, time.March, 3},
+			daysSince: 0,
+			in:        StrengthInputs{RecentInteractions: 12, Interactions: 12, FirstInteraction: today.AddDays(-330), Events: 2},
+			// 35 recency, 25 frequency, 10 events, 10 VIP, 20 cadence of 100
+			score:      100,
+			rhythmDays: VipCadenceDays,
+		},
+		{
+			name:      "usual rhythm kept",
+			daysSince: 30,
+			in:        StrengthInputs{RecentInteractions: 5, Interactions: 5, FirstInteraction: today.AddDays(-150)},
+			// 17.5 recency after one half-life, 10.4 frequency of 80
+			score:      35,
+			rhythmDays: 30,
+		},
+		{
+			name:      "usual rhythm broken",
+			daysSince: 50,
+			in:        StrengthInputs{RecentInteractions: 5, Interactions: 5, FirstInteraction: today.AddDays(-170)},
+			// 11.0 recency, 10.4 frequency of 80
+			score:         27,
+			rhythmDays:    30,
+			driftingSince: today.AddDays(-5),
+		},
+		{
+			name:      "weekly cadence silent for 60 days",
+			cadence:   7,
+			daysSince: 60,
+			in:        StrengthInputs{RecentInteractions: 6, Interactions: 6, FirstInteraction: today.AddDays(-300)},
+			// 1.8 recency with a half-life of 14 days, 12.5 frequency, no cadence points of 100
+			score:         14,
+			rhythmDays:    7,
+			driftingSince: today.AddDays(-49),
+		},
+		{
+			name:      "weak without a known rhythm",
+			daysSince: 400,
+			in:        StrengthInputs{Interactions: 1, FirstInteraction: today.AddDays(-400)},
+			score:     2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Contact{CadenceDays: tt.cadence, Vip: tt.vip, Birthday: tt.birthday}
+			c.CreatedAt = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+			if tt.daysSince >= 0 {
+				c.LastContacted = today.AddDays(-tt.daysSince)
+			}
+
+			s := ComputeStrength(c, tt.in, today)
+			if s.Score != tt.score || s.RhythmDays != tt.rhythmDays || s.DriftingSince != tt.driftingSince {
+				t.Errorf("ComputeStrength = score %d, rhythm %d, drifting since %v, want %d, %d, %v (%+v)",
+					s.Score, s.RhythmDays, s.DriftingSince, tt.score, tt.rhythmDays, tt.driftingSince, s)
+			}
+			if s.Score < 0 || s.Score > 100 {
+				t.Errorf("score %d is out of range", s.Score)
+			}
+		})
+	}
+}
+
+func TestComputeStrengthRecency(t *testing.T) {
+	today := Date{2024, time.June, 1}
+	tests := []struct {
+		name      string
+		daysSince int
+		rhythm    int
+		want      float64
+	}{
+		{"today", 0, 0, recencyPoints},
+		{"one default half-life", defaultHalfLifeDays, 0, recencyPoints / 2.0},
+		{"short rhythms fade no faster than the minimum half-life", minHalfLifeDays, 2, recencyPoints / 2.0},
+		{"long rhythms fade no slower than the maximum half-life", maxHalfLifeDays, 300, recencyPoints / 2.0},
+		{"future contact counts as today", -3, 0, recencyPoints},
+	}
+	for _, tt := range tests {
+		c := Contact{}
+		c.LastContacted = today.AddDays(-tt.daysSince)
+		in := StrengthInputs{}
+		if tt.rhythm > 0 {
+			in.Interactions = 2
+			in.FirstInteraction = c.LastContacted.AddDays(-tt.rhythm)
+		}
+		if got := ComputeStrength(c, in, today).Recency; math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: Recency = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
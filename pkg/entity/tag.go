@@ -0,0 +1,31 @@
+package entity
+
+import (
+	"regexp"
+
+	"gorm.io/gorm"
+)
+
+// DefaultTagColor is used when a tag is created without a color
+const DefaultTagColor = "#6366f1"
+
+// TagColors is the palette offered when creating a tag
+var TagColors = []string{"#6366f1", "#ef4444", "#f59e0b", "#10b981", "#0ea5e9", "#ec4899", "#8b5cf6", "#64748b"}
+
+var tagColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// ValidTagColor reports whether color is a hex color like #1a2b3c
+func ValidTagColor(color string) bool {
+	return tagColorPattern.MatchString(color)
+}
+
+// Tag is a user defined label. Tag names are unique per user, ignoring case.
+// CadenceDays is the keep-in-touch cadence of the tagged contacts, 0 for none.
+type Tag struct {
+	gorm.Model
+	UserID      uint      `json:"user_id" gorm:"not null;index"`
+	Name        string    `json:"name" gorm:"varchar(64);not null"`
+	Color       string    `json:"color" gorm:"varchar(7);not null"`
+	CadenceDays int       `json:"cadence_days" gorm:"not null;default:0"`
+	Contacts    []Contact `json:"-" gorm:"many2many:contact_tags;"`
+}
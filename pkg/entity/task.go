@@ -0,0 +1,62 @@
+package entity
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TaskPriority string
+
+const (
+	LowPriority    TaskPriority = "low"
+	NormalPriority TaskPriority = "normal"
+	HighPriority   TaskPriority = "high"
+)
+
+// TaskPriorities lists the task priorities from highest to lowest
+var TaskPriorities = []TaskPriority{HighPriority, NormalPriority, LowPriority}
+
+// Valid reports whether p is a known task priority
+func (p TaskPriority) Valid() bool {
+	for _, known := range TaskPriorities {
+		if p == known {
+			return true
+		}
+	}
+	return false
+}
+
+type TaskStatus string
+
+const (
+	TaskOpen TaskStatus = "open"
+	TaskDone TaskStatus = "done"
+)
+
+// Valid reports whether s is a known task status
+func (s TaskStatus) Valid() bool {
+	return s == TaskOpen || s == TaskDone
+}
+
+// Task is a follow-up to-do about one or more contacts, e.g. sending an
+// article after a coffee chat. A task with a due date can be mirrored as an
+// all-day event in the user's Google Calendar. DueOn is zero when the task
+// has no due date.
+type Task struct {
+	gorm.Model
+	UserID                uint   `gorm:"not null;index"`
+	Title                 string `gorm:"not null"`
+	Notes                 string
+	DueOn                 Date
+	Priority              TaskPriority `gorm:"not null;default:normal"`
+	Status                TaskStatus   `gorm:"not null;default:open"`
+	CompletedAt           *time.Time
+	GoogleCalendarEventID string
+	Contacts              []Contact `gorm:"many2many:task_contacts;"`
+}
+
+// IsOverdue reports whether the open task was due before today
+func (t Task) IsOverdue(today Date) bool {
+	return t.Status == TaskOpen && !t.DueOn.IsZero() && t.DueOn.Before(today)
+}
@@ -3,6 +3,7 @@ package logger
 import (
 	"fmt"
 	"github.com/rs/zerolog"
+	"io"
 	"os"
 	"strings"
 )
@@ -22,6 +23,11 @@ type Logger struct {
 var _ Log = (*Logger)(nil)
 
 func New(level string) *Logger {
+	return NewWithOutput(level, os.Stdout)
+}
+
+// NewWithOutput creates a logger writing to w, e.g. stderr for processes that own stdout
+func NewWithOutput(level string, w io.Writer) *Logger {
 	var l zerolog.Level
 
 	switch strings.ToLower(level) {
@@ -41,7 +47,7 @@ func New(level string) *Logger {
 	zerolog.SetGlobalLevel(l)
 
 	skipFrameCount := 3
-	logger := zerolog.New(os.Stdout).With().Timestamp().CallerWithSkipFrameCount(zerolog.CallerSkipFrameCount + skipFrameCount).Logger()
+	logger := zerolog.New(w).With().Timestamp().CallerWithSkipFrameCount(zerolog.CallerSkipFrameCount + skipFrameCount).Logger()
 	return &Logger{
 		logger: &logger,
 	}
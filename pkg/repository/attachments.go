@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"gorm.io/gorm"
+)
+
+// Attachment methods
+
+// CreateAttachment records a stored file of a contact of the attachment's
+// user, making it the contact's photo when asPhoto is set
+func (r *ContactRepo) CreateAttachment(attachment *entity.Attachment, asPhoto bool) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&entity.Contact{}).
+			Where("id = ? AND user_id = ?", attachment.ContactID, attachment.UserID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("no contact found with id %d", attachment.ContactID)
+		}
+
+		if err := tx.Create(attachment).Error; err != nil {
+			return err
+		}
+		if !asPhoto {
+			return nil
+		}
+		return tx.Model(&entity.Contact{}).
+			Where("id = ? AND user_id = ?", attachment.ContactID, attachment.UserID).
+			Update("photo_id", attachment.ID).Error
+	})
+}
+
+func (r *ContactRepo) GetAttachmentByID(attachmentID, userID uint) (entity.Attachment, error) {
+	var attachment entity.Attachment
+	err := r.DB.Where("id = ? AND user_id = ?", attachmentID, userID).First(&attachment).Error
+	return attachment, err
+}
+
+// GetAttachmentsByContact returns the attachments of a contact, the newest first
+func (r *ContactRepo) GetAttachmentsByContact(contactID, userID uint) ([]entity.Attachment, error) {
+	var attachments []entity.Attachment
+	err := r.DB.Where("contact_id = ? AND user_id = ?", contactID, userID).
+		Order("created_at DESC, id DESC").
+		Find(&attachments).Error
+	return attachments, err
+}
+
+// DeleteAttachment removes the record of an attachment, and the contact's
+// photo when it was the photo. The stored files are removed by the caller.
+func (r *ContactRepo) DeleteAttachment(attachmentID, userID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&entity.Contact{}).
+			Where("photo_id = ? AND user_id = ?", attachmentID, userID).
+			Update("photo_id", nil).Error; err != nil {
+			return err
+		}
+
+		// The files are gone once the record is deleted, so it is not kept soft-deleted
+		result := tx.Unscoped().Where("id = ? AND user_id = ?", attachmentID, userID).Delete(&entity.Attachment{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("no attachment found with id %d", attachmentID)
+		}
+		return nil
+	})
+}
+
+// SetContactPhoto makes an attachment of the contact its photo, or removes
+// the photo when attachmentID is nil. The attachment itself is kept.
+func (r *ContactRepo) SetContactPhoto(contactID, userID uint, attachmentID *uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if attachmentID != nil {
+			var attachment entity.Attachment
+			if err := tx.Where("id = ? AND contact_id = ? AND user_id = ?", *attachmentID, contactID, userID).
+				First(&attachment).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("no attachment found with id %d", *attachmentID)
+				}
+				return err
+			}
+		}
+
+		result := tx.Model(&entity.Contact{}).
+			Where("id = ? AND user_id = ?", contactID, userID).
+			Update("photo_id", attachmentID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("no contact found with id %d", contactID)
+		}
+		return nil
+	})
+}
+
+// GetContactPhoto returns the attachment that is the contact's photo
+func (r *ContactRepo) GetContactPhoto(contactID, userID uint) (entity.Attachment, error) {
+	var attachment entity.Attachment
+	err := r.DB.Joins("JOIN contacts ON contacts.photo_id = attachments.id").
+		Where("contacts.id = ? AND contacts.user_id = ? AND contacts.deleted_at IS NULL", contactID, userID).
+		First(&attachment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return attachment, fmt.Errorf("no photo found for contact %d", contactID)
+	}
+	return attachment, err
+}
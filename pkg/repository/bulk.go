@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"github.com/La002/personal-crm/pkg/entity"
+	"gorm.io/gorm"
+)
+
+// Bulk contact methods
+
+// DeleteContacts moves the given contacts of the user to the trash in one
+// transaction. It returns the contacts that were deleted, ids that do not
+// belong to the user are left out.
+func (r *ContactRepo) DeleteContacts(contactIDs []uint, userID uint) ([]entity.Contact, error) {
+	var contacts []entity.Contact
+	if len(contactIDs) == 0 {
+		return contacts, nil
+	}
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("id", "name").Where("id IN ? AND user_id = ?", contactIDs, userID).Find(&contacts).Error; err != nil {
+			return err
+		}
+		if len(contacts) == 0 {
+			return nil
+		}
+		return tx.Where("id IN ? AND user_id = ?", contactIDsOf(contacts), userID).Delete(&entity.Contact{}).Error
+	})
+	return contacts, err
+}
+
+// UpdateContacts applies the same column updates to the given contacts of the
+// user in one transaction, recording a revision in the history of every
+// contact that changed. It returns the updated contacts, ids that do not
+// belong to the user are left out.
+func (r *ContactRepo) UpdateContacts(contactIDs []uint, userID uint, updates map[string]interface{}, actor string) ([]entity.Contact, error) {
+	var after []entity.Contact
+	if len(contactIDs) == 0 {
+		return after, nil
+	}
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var before []entity.Contact
+		if err := tx.Where("id IN ? AND user_id = ?", contactIDs, userID).Find(&before).Error; err != nil {
+			return err
+		}
+		if len(before) == 0 {
+			return nil
+		}
+		ids := contactIDsOf(before)
+
+		if err := tx.Model(&entity.Contact{}).Where("id IN ? AND user_id = ?", ids, userID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id IN ? AND user_id = ?", ids, userID).Find(&after).Error; err != nil {
+			return err
+		}
+
+		old := make(map[uint]*entity.Contact, len(before))
+		for i := range before {
+			old[before[i].ID] = &before[i]
+		}
+		var changes []entity.DetailChanges
+		for i := range after {
+			changes = append(changes, diffContacts(old[after[i].ID], &after[i], actor)...)
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+		return tx.Create(&changes).Error
+	})
+	return after, err
+}
+
+func contactIDsOf(contacts []entity.Contact) []uint {
+	ids := make([]uint, 0, len(contacts))
+	for _, contact := range contacts {
+		ids = append(ids, contact.ID)
+	}
+	return ids
+}
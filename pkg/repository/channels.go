@@ -0,0 +1,378 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"gorm.io/gorm"
+)
+
+// Channel methods
+
+// maxChannelLabel is the length of the label columns
+const maxChannelLabel = 32
+
+// preloadChannels loads the emails, phone numbers and addresses of contacts, primary first
+func preloadChannels(query *gorm.DB) *gorm.DB {
+	order := func(db *gorm.DB) *gorm.DB {
+		return db.Order("is_primary DESC, position, id")
+	}
+	return query.Preload("Channels", order).Preload("Addresses", order)
+}
+
+// channelKey is the value two channels of the same kind are compared by
+func channelKey(kind entity.ChannelKind, value string) string {
+	if kind == entity.EmailChannel {
+		return normalizeEmail(value)
+	}
+	if phone := normalizePhone(value); phone != "" {
+		return phone
+	}
+	return strings.TrimSpace(value)
+}
+
+// addressKey is the value two addresses are compared by
+func addressKey(address entity.ContactAddress) string {
+	return strings.ToLower(strings.Join(address.Lines(), "\n"))
+}
+
+func channelLabel(label string) string {
+	label = strings.ToLower(strings.TrimSpace(label))
+	if len(label) > maxChannelLabel {
+		label = label[:maxChannelLabel]
+	}
+	return label
+}
+
+// normalizeChannels cleans up the channels and addresses of a contact before
+// they are stored: values are trimmed, empty and repeated ones dropped, and
+// each kind gets exactly one primary value, the first one unless another is
+// marked. The primary values are mirrored into Email, PhoneNumber and Location.
+func normalizeChannels(contact *entity.Contact) {
+	var channels []entity.ContactChannel
+	seen := map[string]bool{}
+	positions := map[entity.ChannelKind]int{}
+	primaries := map[entity.ChannelKind]int{}
+	for _, channel := range contact.Channels {
+		channel.Value = strings.TrimSpace(channel.Value)
+		if channel.Value == "" || (channel.Kind != entity.EmailChannel && channel.Kind != entity.PhoneChannel) {
+			continue
+		}
+		key := string(channel.Kind) + ":" + channelKey(channel.Kind, channel.Value)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		channel.ID = 0
+		channel.UserID = contact.UserID
+		channel.ContactID = contact.ID
+		channel.Label = channelLabel(channel.Label)
+		channel.Position = positions[channel.Kind]
+		positions[channel.Kind]++
+		if _, ok := primaries[channel.Kind]; !ok && channel.Primary {
+			primaries[channel.Kind] = len(channels)
+		}
+		channels = append(channels, channel)
+	}
+	for _, kind := range []entity.ChannelKind{entity.EmailChannel, entity.PhoneChannel} {
+		if _, ok := primaries[kind]; ok {
+			continue
+		}
+		for i := range channels {
+			if channels[i].Kind == kind {
+				primaries[kind] = i
+				break
+			}
+		}
+	}
+	contact.Email, contact.PhoneNumber = "", ""
+	for i := range channels {
+		channels[i].Primary = primaries[channels[i].Kind] == i
+		if !channels[i].Primary {
+			continue
+		}
+		if channels[i].Kind == entity.EmailChannel {
+			contact.Email = channels[i].Value
+		} else {
+			contact.PhoneNumber = channels[i].Value
+		}
+	}
+	contact.Channels = channels
+
+	var addresses []entity.ContactAddress
+	seen = map[string]bool{}
+	primary := -1
+	for _, address := range contact.Addresses {
+		address.Street = strings.TrimSpace(address.Street)
+		address.City = strings.TrimSpace(address.City)
+		address.Region = strings.TrimSpace(address.Region)
+		address.PostalCode = strings.TrimSpace(address.PostalCode)
+		address.Country = strings.TrimSpace(address.Country)
+		if address.Empty() || seen[addressKey(address)] {
+			continue
+		}
+		seen[addressKey(address)] = true
+
+		address.ID = 0
+		address.UserID = contact.UserID
+		address.ContactID = contact.ID
+		address.Label = channelLabel(address.Label)
+		address.Position = len(addresses)
+		if primary < 0 && address.Primary {
+			primary = len(addresses)
+		}
+		addresses = append(addresses, address)
+	}
+	if primary < 0 {
+		primary = 0
+	}
+	contact.Location = ""
+	for i := range addresses {
+		addresses[i].Primary = i == primary
+		if addresses[i].Primary {
+			contact.Location = addresses[i].Summary()
+		}
+	}
+	contact.Addresses = addresses
+}
+
+// prepareNewContact sets up the channels of a contact about to be created.
+// Contacts created with only the single email, phone and location fields get
+// their channels and address from those.
+func prepareNewContact(contact *entity.Contact) {
+	if len(contact.Channels) == 0 {
+		contact.Channels = []entity.ContactChannel{
+			{Kind: entity.EmailChannel, Value: contact.Email, Primary: true},
+			{Kind: entity.PhoneChannel, Value: contact.PhoneNumber, Primary: true},
+		}
+	}
+	if len(contact.Addresses) == 0 && strings.TrimSpace(contact.Location) != "" {
+		contact.Addresses = []entity.ContactAddress{{City: contact.Location, Primary: true}}
+	}
+	normalizeChannels(contact)
+	for i := range contact.Notes {
+		contact.Notes[i].UserID = contact.UserID
+	}
+}
+
+// SaveContactWithChannels saves an existing contact with history and replaces
+// all of its emails, phone numbers and addresses with the given ones
+func (r *ContactRepo) SaveContactWithChannels(contact *entity.Contact, actor string) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND user_id = ?", contact.ID, contact.UserID).First(&entity.Contact{}).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("no contact found with id %d", contact.ID)
+			}
+			return err
+		}
+
+		normalizeChannels(contact)
+		if err := tx.Where("contact_id = ?", contact.ID).Delete(&entity.ContactChannel{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("contact_id = ?", contact.ID).Delete(&entity.ContactAddress{}).Error; err != nil {
+			return err
+		}
+		if len(contact.Channels) > 0 {
+			if err := tx.Create(&contact.Channels).Error; err != nil {
+				return err
+			}
+		}
+		if len(contact.Addresses) > 0 {
+			if err := tx.Create(&contact.Addresses).Error; err != nil {
+				return err
+			}
+		}
+		return saveContactWithHistory(tx, contact, actor)
+	})
+}
+
+// syncContactChannels brings the channels in line with the mirrored columns
+// after those were written directly, e.g. by the API, the MCP tools or a merge.
+// A changed email or phone number replaces the primary value, unless the
+// contact already has it, which is then made primary. A cleared one removes
+// the primary value and promotes the next. A changed location becomes the new
+// primary address and the previous one is kept.
+func syncContactChannels(tx *gorm.DB, contact *entity.Contact) error {
+	var channels []entity.ContactChannel
+	if err := tx.Where("contact_id = ?", contact.ID).Order("is_primary DESC, position, id").Find(&channels).Error; err != nil {
+		return err
+	}
+	var addresses []entity.ContactAddress
+	if err := tx.Where("contact_id = ?", contact.ID).Order("is_primary DESC, position, id").Find(&addresses).Error; err != nil {
+		return err
+	}
+
+	mirrors := map[entity.ChannelKind]*string{
+		entity.EmailChannel: &contact.Email,
+		entity.PhoneChannel: &contact.PhoneNumber,
+	}
+	columns := map[entity.ChannelKind]string{entity.EmailChannel: "email", entity.PhoneChannel: "phone_number"}
+	promoted := map[string]interface{}{}
+	for _, kind := range []entity.ChannelKind{entity.EmailChannel, entity.PhoneChannel} {
+		value := strings.TrimSpace(*mirrors[kind])
+		var primary, match, next *entity.ContactChannel
+		position := 0
+		for i := range channels {
+			channel := &channels[i]
+			if channel.Kind != kind {
+				continue
+			}
+			position = max(position, channel.Position+1)
+			switch {
+			case channel.Primary:
+				primary = channel
+			case match == nil && value != "" && channelKey(kind, channel.Value) == channelKey(kind, value):
+				match = channel
+			case next == nil:
+				next = channel
+			}
+		}
+		if primary != nil && primary.Value == value {
+			continue
+		}
+
+		switch {
+		case value == "" && primary != nil:
+			if err := tx.Delete(primary).Error; err != nil {
+				return err
+			}
+			if next != nil {
+				if err := tx.Model(next).Update("is_primary", true).Error; err != nil {
+					return err
+				}
+				*mirrors[kind] = next.Value
+				promoted[columns[kind]] = next.Value
+			}
+		case value == "":
+		case match != nil:
+			if primary != nil {
+				if err := tx.Model(primary).Update("is_primary", false).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(match).Update("is_primary", true).Error; err != nil {
+				return err
+			}
+		case primary != nil:
+			if err := tx.Model(primary).Update("value", value).Error; err != nil {
+				return err
+			}
+		default:
+			channel := entity.ContactChannel{UserID: contact.UserID, ContactID: contact.ID, Kind: kind, Value: value, Primary: true, Position: position}
+			if err := tx.Create(&channel).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	location := strings.TrimSpace(contact.Location)
+	var primary, match, next *entity.ContactAddress
+	for i := range addresses {
+		address := &addresses[i]
+		switch {
+		case address.Primary:
+			primary = address
+		case match == nil && location != "" && strings.EqualFold(address.Summary(), location):
+			match = address
+		case next == nil:
+			next = address
+		}
+	}
+	if primary == nil || primary.Summary() != location {
+		switch {
+		case location == "" && primary != nil:
+			if err := tx.Delete(primary).Error; err != nil {
+				return err
+			}
+			if next != nil {
+				if err := tx.Model(next).Update("is_primary", true).Error; err != nil {
+					return err
+				}
+				contact.Location = next.Summary()
+				promoted["location"] = contact.Location
+			}
+		case location == "":
+		default:
+			if primary != nil {
+				if err := tx.Model(primary).Update("is_primary", false).Error; err != nil {
+					return err
+				}
+			}
+			if match != nil {
+				if err := tx.Model(match).Update("is_primary", true).Error; err != nil {
+					return err
+				}
+				break
+			}
+			address := entity.ContactAddress{UserID: contact.UserID, ContactID: contact.ID, City: location, Primary: true, Position: len(addresses)}
+			if err := tx.Create(&address).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(promoted) == 0 {
+		return nil
+	}
+	return tx.Model(&entity.Contact{}).Where("id = ?", contact.ID).UpdateColumns(promoted).Error
+}
+
+// moveContactChannels gives the survivor of a merge the emails, phone numbers
+// and addresses of the merged contact it does not have yet, as non primary
+// values. The ones it already has are deleted with the merged contact.
+func moveContactChannels(tx *gorm.DB, survivorID, mergedID uint) error {
+	var survivor entity.Contact
+	if err := preloadChannels(tx).First(&survivor, survivorID).Error; err != nil {
+		return err
+	}
+	var merged entity.Contact
+	if err := preloadChannels(tx).First(&merged, mergedID).Error; err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	for _, channel := range survivor.Channels {
+		seen[string(channel.Kind)+":"+channelKey(channel.Kind, channel.Value)] = true
+	}
+	for _, channel := range merged.Channels {
+		key := string(channel.Kind) + ":" + channelKey(channel.Kind, channel.Value)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if err := tx.Model(&channel).Updates(map[string]interface{}{
+			"contact_id": survivorID,
+			"is_primary": false,
+			"position":   len(survivor.Channels) + channel.Position,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	seen = map[string]bool{}
+	for _, address := range survivor.Addresses {
+		seen[addressKey(address)] = true
+	}
+	for _, address := range merged.Addresses {
+		if seen[addressKey(address)] {
+			continue
+		}
+		seen[addressKey(address)] = true
+		if err := tx.Model(&address).Updates(map[string]interface{}{
+			"contact_id": survivorID,
+			"is_primary": false,
+			"position":   len(survivor.Addresses) + address.Position,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Where("contact_id = ?", mergedID).Delete(&entity.ContactChannel{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("contact_id = ?", mergedID).Delete(&entity.ContactAddress{}).Error
+}
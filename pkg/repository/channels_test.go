@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/La002/personal-crm/pkg/entity"
+)
+
+func testEmail(value string, primary bool) entity.ContactChannel {
+	return entity.ContactChannel{Kind: entity.EmailChannel, Value: value, Primary: primary}
+}
+
+func testPhone(value string, primary bool) entity.ContactChannel {
+	return entity.ContactChannel{Kind: entity.PhoneChannel, Value: value, Primary: primary}
+}
+
+func TestNormalizeChannels(t *testing.T) {
+	// channel is the kind, value, position and primary flag of a stored channel
+	type channel struct {
+		kind     entity.ChannelKind
+		value    string
+		position int
+		primary  bool
+	}
+	tests := []struct {
+		name      string
+		channels  []entity.ContactChannel
+		want      []channel
+		wantEmail string
+		wantPhone string
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:     "trimmed, empty values and unknown kinds dropped",
+			channels: []entity.ContactChannel{testEmail(" ", true), testEmail(" jane@example.test ", false), {Kind: "fax", Value: "555 1234"}},
+			want: []channel{
+				{entity.EmailChannel, "jane@example.test", 0, true},
+			},
+			wantEmail: "jane@example.test",
+		},
+		{
+			name: "repeated values dropped",
+			channels: []entity.ContactChannel{
+				testEmail("Jane@Example.test", false), testEmail("jane@example.test", false),
+				testPhone("+1 (555) 123-4567", false), testPhone("555.123.4567", false), testPhone("123", false), testPhone(" 123", false),
+			},
+			want: []channel{
+				{entity.EmailChannel, "Jane@Example.test", 0, true},
+				{entity.PhoneChannel, "+1 (555) 123-4567", 0, true},
+				{entity.PhoneChannel, "123", 1, false},
+			},
+			wantEmail: "Jane@Example.test",
+			wantPhone: "+1 (555) 123-4567",
+		},
+		{
+			name: "the first marked value of each kind is primary",
+			channels: []entity.ContactChannel{
+				testEmail("home@example.test", false), testEmail("work@example.test", true), testEmail("old@example.test", true),
+				testPhone("555 000 1111", false), testPhone("555 000 2222", false),
+			},
+			want: []channel{
+				{entity.EmailChannel, "home@example.test", 0, false},
+				{entity.EmailChannel, "work@example.test", 1, true},
+				{entity.EmailChannel, "old@example.test", 2, false},
+				{entity.PhoneChannel, "555 000 1111", 0, true},
+				{entity.PhoneChannel, "555 000 2222", 1, false},
+			},
+			wantEmail: "work@example.test",
+			wantPhone: "555 000 1111",
+		},
+		{
+			name:     "cleared primary value promotes the next one",
+			channels: []entity.ContactChannel{testEmail("", true), testEmail("work@example.test", false), testPhone("  ", true)},
+			want: []channel{
+				{entity.EmailChannel, "work@example.test", 0, true},
+			},
+			wantEmail: "work@example.test",
+		},
+		{
+			name:     "repeated primary value keeps the first one primary",
+			channels: []entity.ContactChannel{testEmail("jane@example.test", false), testEmail("JANE@example.test", true)},
+			want: []channel{
+				{entity.EmailChannel, "jane@example.test", 0, true},
+			},
+			wantEmail: "jane@example.test",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contact := entity.Contact{UserID: 3, Channels: tt.channels}
+			contact.ID = 9
+			contact.Email, contact.PhoneNumber = "stale@example.test", "555 999 9999"
+			normalizeChannels(&contact)
+
+			var got []channel
+			for _, c := range contact.Channels {
+				got = append(got, channel{c.Kind, c.Value, c.Position, c.Primary})
+				if c.UserID != 3 || c.ContactID != 9 {
+					t.Errorf("channel %q belongs to user %d and contact %d, want 3 and 9", c.Value, c.UserID, c.ContactID)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("channels = %v, want %v", got, tt.want)
+			}
+			if contact.Email != tt.wantEmail || contact.PhoneNumber != tt.wantPhone {
+				t.Errorf("Email, PhoneNumber = %q, %q, want %q, %q", contact.Email, contact.PhoneNumber, tt.wantEmail, tt.wantPhone)
+			}
+		})
+	}
+}
+
+func TestNormalizeChannelsAddresses(t *testing.T) {
+	contact := entity.Contact{
+		Addresses: []entity.ContactAddress{
+			{Label: " Home ", City: " ", Country: ""},
+			{Label: " Home ", Street: "1 Main St", City: " Springfield ", Country: "USA"},
+			{Street: "1 MAIN ST", City: "springfield", Country: "usa", Primary: true},
+			{Label: "Work", City: "Shelbyville", Primary: true},
+		},
+	}
+	contact.Location = "Stale"
+	normalizeChannels(&contact)
+
+	want := []entity.ContactAddress{
+		{Label: "home", Street: "1 Main St", City: "Springfield", Country: "USA", Position: 0},
+		{Label: "work", City: "Shelbyville", Position: 1, Primary: true},
+	}
+	if !reflect.DeepEqual(contact.Addresses, want) {
+		t.Errorf("addresses = %+v, want %+v", contact.Addresses, want)
+	}
+	if contact.Location != "Shelbyville" {
+		t.Errorf("Location = %q, want %q", contact.Location, "Shelbyville")
+	}
+
+	contact.Addresses[1].City = ""
+	normalizeChannels(&contact)
+	if len(contact.Addresses) != 1 || !contact.Addresses[0].Primary || contact.Location != "Springfield, USA" {
+		t.Errorf("after clearing the primary address: addresses = %+v, Location = %q", contact.Addresses, contact.Location)
+	}
+}
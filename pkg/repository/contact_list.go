@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/entity"
+)
+
+// ContactSorts are the orders the contact list can be sorted in, the first
+// one is the default
+var ContactSorts = []string{"name", "company", "birthday", "last_contacted", "created", "strength"}
+
+const (
+	// DefaultContactPageSize is the number of contacts on a page when no limit is given
+	DefaultContactPageSize = 50
+	// MaxContactPageSize is the largest page that can be asked for
+	MaxContactPageSize = 200
+)
+
+// ErrInvalidCursor is returned for a cursor that was not issued for the
+// requested sort and direction
+var ErrInvalidCursor = errors.New("the cursor is invalid or belongs to another sort order")
+
+// contactSort is an order of the contact list. Only these expressions are
+// ever put into the query, the requested sort just picks one of them. The
+// expressions never return NULL, so rows compare as (expr, id) and a page
+// continues exactly after the last row of the previous one.
+type contactSort struct {
+	expr string
+	typ  string // SQL type of expr, the cursor keeps its value as text
+}
+
+var contactSorts = map[string]contactSort{
+	"name":    {"LOWER(contacts.name)", "TEXT"},
+	"company": {"LOWER(COALESCE(contacts.company, ''))", "TEXT"},
+	// The next birthday from today: the rest of this year first, then the ones
+	// that already passed, then contacts without a birthday. It depends on the
+	// date so it cannot be indexed, which is fine for a personal contact list.
+	"birthday": {`CASE
+		WHEN contacts.birthday IS NULL THEN '2'
+		WHEN to_char(contacts.birthday, 'MMDD') >= to_char(CURRENT_DATE, 'MMDD') THEN '0' || to_char(contacts.birthday, 'MMDD')
+		ELSE '1' || to_char(contacts.birthday, 'MMDD') END`, "TEXT"},
+	"last_contacted": {"COALESCE(contacts.last_contacted, DATE '0001-01-01')", "DATE"},
+	"created":        {"contacts.created_at", "TIMESTAMP"},
+	"strength":       {"contacts.strength_score", "INTEGER"},
+}
+
+// ContactPageRequest selects a page of the contact list
+type ContactPageRequest struct {
+	Sort   string // one of ContactSorts, the first one when empty
+	Desc   bool
+	Cursor string // NextCursor of the previous page, empty for the first page
+	Limit  int    // DefaultContactPageSize when 0, at most MaxContactPageSize
+}
+
+// ContactPage is a page of the contact list
+type ContactPage struct {
+	Contacts   []entity.Contact
+	NextCursor string // empty on the last page
+}
+
+// contactCursor is the position after the last contact of a page
+type contactCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d,omitempty"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+func (c contactCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeContactCursor reads a cursor issued for the sort and direction of
+// the requested page. Its value must parse as the type of the sort, the
+// query casts it to that type.
+func decodeContactCursor(s string, sort string, desc bool) (contactCursor, error) {
+	var cursor contactCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if cursor.Sort != sort || cursor.Desc != desc {
+		return cursor, ErrInvalidCursor
+	}
+	contactSort, ok := contactSorts[sort]
+	if !ok || !contactSort.validValue(cursor.Value) {
+		return cursor, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// cursorTimeLayouts are the ways Postgres prints a timestamp as text
+var cursorTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07",
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// validValue reports whether value can be cast to the type of the sort
+func (s contactSort) validValue(value string) bool {
+	switch s.typ {
+	case "DATE":
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil
+	case "TIMESTAMP":
+		for _, layout := range cursorTimeLayouts {
+			if _, err := time.Parse(layout, value); err == nil {
+				return true
+			}
+		}
+		return false
+	case "INTEGER":
+		_, err := strconv.ParseInt(value, 10, 32)
+		return err == nil
+	}
+	return true
+}
+
+// IsContactSort reports whether the contact list can be sorted by sort
+func IsContactSort(sort string) bool {
+	_, ok := contactSorts[sort]
+	return ok
+}
+
+// ListContactsPage returns a page of the contacts matching filters, except
+// their limit, in the requested order. Pages are read with keyset pagination:
+// the cursor holds the sort value and id of the last contact, so a page costs
+// the same however far the list is scrolled.
+func (r *ContactRepo) ListContactsPage(userID uint, filters ContactSearchFilters, page ContactPageRequest) (ContactPage, error) {
+	sortBy := page.Sort
+	if sortBy == "" {
+		sortBy = ContactSorts[0]
+	}
+	sort, ok := contactSorts[sortBy]
+	if !ok {
+		return ContactPage{}, fmt.Errorf("unknown contact sort %q", sortBy)
+	}
+	limit := page.Limit
+	if limit <= 0 {
+		limit = DefaultContactPageSize
+	}
+	limit = min(limit, MaxContactPageSize)
+
+	direction, comparison := "ASC", ">"
+	if page.Desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	query := r.DB.Table("contacts").
+		Select("contacts.id, ("+sort.expr+")::TEXT AS sort_value").
+		Where("contacts.user_id = ? AND contacts.deleted_at IS NULL", userID)
+	query, err := r.applyContactFilters(query, userID, filters)
+	if err != nil {
+		return ContactPage{}, err
+	}
+
+	if page.Cursor != "" {
+		cursor, err := decodeContactCursor(page.Cursor, sortBy, page.Desc)
+		if err != nil {
+			return ContactPage{}, err
+		}
+		query = query.Where(fmt.Sprintf("(%s, contacts.id) %s (CAST(? AS %s), ?)", sort.expr, comparison, sort.typ),
+			cursor.Value, cursor.ID)
+	}
+
+	// One row more than the page tells whether there is a next page
+	var keys []struct {
+		ID        uint
+		SortValue string
+	}
+	if err := query.Order(fmt.Sprintf("%s %s, contacts.id %s", sort.expr, direction, direction)).
+		Limit(limit + 1).
+		Scan(&keys).Error; err != nil {
+		return ContactPage{}, err
+	}
+
+	var res ContactPage
+	if len(keys) > limit {
+		keys = keys[:limit]
+		last := keys[limit-1]
+		res.NextCursor = contactCursor{Sort: sortBy, Desc: page.Desc, Value: last.SortValue, ID: last.ID}.encode()
+	}
+	if len(keys) == 0 {
+		res.Contacts = []entity.Contact{}
+		return res, nil
+	}
+
+	ids := make([]uint, 0, len(keys))
+	for _, key := range keys {
+		ids = append(ids, key.ID)
+	}
+	var contacts []entity.Contact
+	if err := preloadNotes(preloadChannels(preloadTags(r.DB))).Where("id IN ?", ids).Find(&contacts).Error; err != nil {
+		return ContactPage{}, err
+	}
+	byID := map[uint]entity.Contact{}
+	for _, contact := range contacts {
+		byID[contact.ID] = contact
+	}
+
+	// Keep the order of the first query
+	res.Contacts = make([]entity.Contact, 0, len(keys))
+	for _, key := range keys {
+		if contact, ok := byID[key.ID]; ok {
+			res.Contacts = append(res.Contacts, contact)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestContactCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor contactCursor
+	}{
+		{"name", contactCursor{Sort: "name", Value: "jane doe", ID: 42}},
+		{"descending", contactCursor{Sort: "created", Desc: true, Value: "2024-01-02 03:04:05.123456+00", ID: 7}},
+		{"timestamp without fraction", contactCursor{Sort: "created", Value: "2024-01-02 03:04:05+05:30", ID: 8}},
+		{"timestamp without zone", contactCursor{Sort: "created", Value: "2024-01-02 03:04:05.5", ID: 8}},
+		{"birthday", contactCursor{Sort: "birthday", Value: "20415", ID: 1}},
+		{"never contacted", contactCursor{Sort: "last_contacted", Value: "0001-01-01", ID: 2}},
+		{"strength", contactCursor{Sort: "strength", Desc: true, Value: "87", ID: 4}},
+		{"text that needs escaping", contactCursor{Sort: "company", Value: `a/b+c="d"&ü`, ID: 3}},
+		{"empty value", contactCursor{Sort: "company", ID: 9}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := tt.cursor.encode()
+			if _, err := base64.RawURLEncoding.DecodeString(encoded); err != nil {
+				t.Errorf("encode() = %q is not URL safe base64: %v", encoded, err)
+			}
+			got, err := decodeContactCursor(encoded, tt.cursor.Sort, tt.cursor.Desc)
+			if err != nil {
+				t.Fatalf("decodeContactCursor(%q): %v", encoded, err)
+			}
+			if got != tt.cursor {
+				t.Errorf("decodeContactCursor(encode()) = %+v, want %+v", got, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestDecodeContactCursorInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+		sort   string
+		desc   bool
+	}{
+		{"not base64", "not a cursor!", "name", false},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"s":"name","v":"ab","id":1}`)), "name", false},
+		{"not JSON", base64.RawURLEncoding.EncodeToString([]byte("name:a:1")), "name", false},
+		{"wrong types", base64.RawURLEncoding.EncodeToString([]byte(`{"s":"name","v":"a","id":"1"}`)), "name", false},
+		{"other sort", contactCursor{Sort: "name", Value: "a", ID: 1}.encode(), "company", false},
+		{"other direction", contactCursor{Sort: "name", Value: "a", ID: 1}.encode(), "name", true},
+		{"unknown sort", contactCursor{Sort: "id", Value: "1", ID: 1}.encode(), "id", false},
+		{"name value for a date sort", contactCursor{Sort: "last_contacted", Value: "jane", ID: 1}.encode(), "last_contacted", false},
+		{"impossible date", contactCursor{Sort: "last_contacted", Value: "2024-02-30", ID: 1}.encode(), "last_contacted", false},
+		{"date for a timestamp sort", contactCursor{Sort: "created", Value: "2024-01-02", ID: 1}.encode(), "created", false},
+		{"tampered timestamp", contactCursor{Sort: "created", Value: "2024-01-02 03:04:05'; --", ID: 1}.encode(), "created", false},
+		{"fraction for an integer sort", contactCursor{Sort: "strength", Value: "8.5", ID: 1}.encode(), "strength", false},
+		{"integer out of range", contactCursor{Sort: "strength", Value: "99999999999", ID: 1}.encode(), "strength", false},
+		{"empty integer", contactCursor{Sort: "strength", ID: 1}.encode(), "strength", false},
+	}
+	for _, tt := range tests {
+		if _, err := decodeContactCursor(tt.cursor, tt.sort, tt.desc); err != ErrInvalidCursor {
+			t.Errorf("%s: decodeContactCursor(%q, %q, %v) = %v, want ErrInvalidCursor", tt.name, tt.cursor, tt.sort, tt.desc, err)
+		}
+	}
+}
+
+func TestIsContactSort(t *testing.T) {
+	for _, sort := range ContactSorts {
+		if !IsContactSort(sort) {
+			t.Errorf("IsContactSort(%q) = false", sort)
+		}
+	}
+	for _, sort := range []string{"", "id", "contacts.name", "name; DROP TABLE contacts"} {
+		if IsContactSort(sort) {
+			t.Errorf("IsContactSort(%q) = true", sort)
+		}
+	}
+}
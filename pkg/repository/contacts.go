@@ -1,7 +1,9 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/La002/personal-crm/config"
@@ -16,58 +18,68 @@ type ContactRepo struct {
 }
 
 // MCP specific methods
-func (r *ContactRepo) AppendNotes(id string, userID uint, newNotes string) error {
-	var contact entity.Contact
+func (r *ContactRepo) UpdateContactFields(id string, userID uint, updates map[string]interface{}, actor string) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var before entity.Contact
+		if err := tx.Where("id = ? AND user_id = ?", id, userID).First(&before).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("no contact found with id %s", id)
+			}
+			return err
+		}
 
-	// First, get the current contact
-	if err := r.DB.Where("id = ? AND user_id = ?", id, userID).First(&contact).Error; err != nil {
-		return err
-	}
+		result := tx.Model(&entity.Contact{}).
+			Where("id = ? AND user_id = ?", id, userID).
+			Updates(updates)
 
-	// Append new notes with timestamp
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	separator := "\n---\n"
-	if contact.Notes == "" {
-		contact.Notes = fmt.Sprintf("[%s]\n%s", timestamp, newNotes)
-	} else {
-		contact.Notes = fmt.Sprintf("%s%s[%s]\n%s", contact.Notes, separator, timestamp, newNotes)
-	}
+		if result.Error != nil {
+			return result.Error
+		}
 
-	// Update the notes field
-	result := r.DB.Model(&entity.Contact{}).
-		Where("id = ? AND user_id = ?", id, userID).
-		Update("notes", contact.Notes)
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("no contact found with id %s", id)
+		}
 
-	if result.Error != nil {
-		return result.Error
-	}
+		var after entity.Contact
+		if err := tx.Where("id = ? AND user_id = ?", id, userID).First(&after).Error; err != nil {
+			return err
+		}
+		if err := syncContactChannels(tx, &after); err != nil {
+			return err
+		}
 
-	return nil
+		changes := diffContacts(&before, &after, actor)
+		if len(changes) == 0 {
+			return nil
+		}
+		return tx.Create(&changes).Error
+	})
 }
 
-func (r *ContactRepo) UpdateContactFields(id string, userID uint, updates map[string]interface{}) error {
-	result := r.DB.Model(&entity.Contact{}).
-		Where("id = ? AND user_id = ?", id, userID).
-		Updates(updates)
+func (r *ContactRepo) SearchContactsAdvanced(userID uint, filters ContactSearchFilters) ([]entity.Contact, error) {
+	var contacts []entity.Contact
+	query, err := r.applyContactFilters(preloadNotes(preloadChannels(preloadTags(r.DB))).Where("user_id = ?", userID), userID, filters)
+	if err != nil {
+		return nil, err
+	}
 
-	if result.Error != nil {
-		return result.Error
+	// Apply limit
+	if filters.Limit > 0 {
+		query = query.Limit(filters.Limit)
 	}
 
-	if result.RowsAffected == 0 {
-		return fmt.Errorf("no contact found with id %s", id)
+	if err := query.Find(&contacts).Error; err != nil {
+		return nil, err
 	}
 
-	return nil
+	return contacts, nil
 }
 
-func (r *ContactRepo) SearchContactsAdvanced(userID uint, filters ContactSearchFilters) ([]entity.Contact, error) {
-	var contacts []entity.Contact
-	query := r.DB.Where("user_id = ?", userID)
-
+// applyContactFilters adds the conditions of filters, except the limit, to a contact query
+func (r *ContactRepo) applyContactFilters(query *gorm.DB, userID uint, filters ContactSearchFilters) (*gorm.DB, error) {
 	// Apply relationship filter with prefix matching
 	if filters.Relationship != nil && *filters.Relationship != "" {
-		query = query.Where("relationship::TEXT ILIKE ?", *filters.Relationship+"%")
+		query = query.Where("relationship ILIKE ?", *filters.Relationship+"%")
 	}
 
 	// Apply VIP filter
@@ -77,29 +89,32 @@ func (r *ContactRepo) SearchContactsAdvanced(userID uint, filters ContactSearchF
 
 	// Apply location filter with prefix matching
 	if filters.Location != nil && *filters.Location != "" {
-		query = query.Where("location ILIKE ?", *filters.Location+"%")
+		query = query.Where(`(location ILIKE ? OR EXISTS (
+			SELECT 1 FROM contact_addresses ca WHERE ca.contact_id = contacts.id
+				AND (ca.city ILIKE ? OR ca.region ILIKE ? OR ca.country ILIKE ?)))`,
+			*filters.Location+"%", *filters.Location+"%", *filters.Location+"%", *filters.Location+"%")
 	}
 
-	// Apply last contacted before filter
+	// Apply last contacted before filter, contacts never contacted are included
 	if filters.LastContactedBefore != nil {
-		query = query.Where("last_contacted < ?", *filters.LastContactedBefore)
+		query = query.Where("(last_contacted IS NULL OR last_contacted < ?)", entity.NewDate(*filters.LastContactedBefore))
 	}
 
-	// Apply limit
-	if filters.Limit > 0 {
-		query = query.Limit(filters.Limit)
+	// Apply contact selection
+	if filters.IDs != nil {
+		query = query.Where("contacts.id IN ?", filters.IDs)
 	}
 
-	if err := query.Find(&contacts).Error; err != nil {
-		return nil, err
-	}
+	// Apply tag filter, contacts must carry every tag
+	query = withTags(query, filters.Tags)
 
-	return contacts, nil
+	// Apply custom field filters
+	return r.withCustomFields(query, userID, filters.CustomFields)
 }
 
 func (r *ContactRepo) GetAllContactsWithLimit(userID uint, limit int) ([]entity.Contact, error) {
 	var contacts []entity.Contact
-	query := r.DB.Where("user_id = ?", userID)
+	query := preloadNotes(preloadChannels(preloadTags(r.DB))).Where("user_id = ?", userID)
 
 	if limit > 0 {
 		query = query.Limit(limit)
@@ -119,6 +134,7 @@ func NewContactRepo(config *config.Configuration, l *logger.Logger) *ContactRepo
 }
 
 func (r *ContactRepo) CreateContact(contact *entity.Contact) error {
+	prepareNewContact(contact)
 	if err := r.DB.Create(contact).Error; err != nil {
 		return err
 	}
@@ -126,16 +142,17 @@ func (r *ContactRepo) CreateContact(contact *entity.Contact) error {
 }
 
 func (r *ContactRepo) SaveContact(newContact *entity.Contact) error {
-
-	if err := r.DB.Save(newContact).Error; err != nil {
-		return err
-	}
-	return nil
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Tags", "Channels", "Addresses", "Notes").Save(newContact).Error; err != nil {
+			return err
+		}
+		return syncContactChannels(tx, newContact)
+	})
 }
 
 func (r *ContactRepo) GetContact(id string, userID uint) (entity.Contact, error) {
 	var contact entity.Contact
-	if err := r.DB.Where("id = ? AND user_id = ?", id, userID).First(&contact).Error; err != nil {
+	if err := preloadNotes(preloadChannels(preloadTags(r.DB))).Where("id = ? AND user_id = ?", id, userID).First(&contact).Error; err != nil {
 		return entity.Contact{}, err
 	}
 	return contact, nil
@@ -143,7 +160,12 @@ func (r *ContactRepo) GetContact(id string, userID uint) (entity.Contact, error)
 
 func (r *ContactRepo) GetContactByEmail(email string, userID uint) (entity.Contact, error) {
 	var contact entity.Contact
-	if err := r.DB.Where("email = ? AND user_id = ?", email, userID).First(&contact).Error; err != nil {
+	err := preloadChannels(r.DB).
+		Where("user_id = ? AND EXISTS (SELECT 1 FROM contact_channels cc WHERE cc.contact_id = contacts.id AND cc.kind = ? AND cc.normalized = ?)",
+			userID, entity.EmailChannel, normalizeEmail(email)).
+		Order("id").
+		First(&contact).Error
+	if err != nil {
 		return entity.Contact{}, err
 	}
 	return contact, nil
@@ -151,29 +173,44 @@ func (r *ContactRepo) GetContactByEmail(email string, userID uint) (entity.Conta
 
 func (r *ContactRepo) GetAllContacts(userID uint) ([]entity.Contact, error) {
 	var contacts []entity.Contact
-	if err := r.DB.Where("user_id = ?", userID).Select("id", "name", "relationship", "industry", "company", "birthday", "Vip", "last_met", "last_contacted", "last_update").Find(&contacts).Error; err != nil {
+	if err := preloadTags(r.DB).Where("user_id = ?", userID).Select("id", "name", "relationship", "industry", "company", "birthday", "Vip", "last_met", "last_contacted", "last_update", "photo_id").Find(&contacts).Error; err != nil {
 		return nil, err
 	}
 	return contacts, nil
 }
 
-func (r *ContactRepo) SortContacts(sortBy string, sortOrder string, userID uint) ([]entity.Contact, error) {
-	var contacts []entity.Contact
-	if err := r.DB.Where("user_id = ?", userID).Order(sortBy + " " + sortOrder).Find(&contacts).Error; err != nil {
-		return nil, err
-	}
-
-	return contacts, nil
+// ContactName is the id and name of a contact, enough to offer it in a
+// select
+type ContactName struct {
+	ID   uint
+	Name string
 }
 
-func (r *ContactRepo) FilterContactsByVipStatus(flag bool, userID uint) ([]entity.Contact, error) {
-	var contacts []entity.Contact
-	if err := r.DB.Where("vip = ? AND user_id = ?", flag, userID).Find(&contacts).Error; err != nil {
+// SearchContactNames returns up to limit contacts whose name contains q,
+// ordered by name. Contacts in exclude are left out.
+func (r *ContactRepo) SearchContactNames(userID uint, q string, exclude []uint, limit int) ([]ContactName, error) {
+	var names []ContactName
+	query := r.DB.Model(&entity.Contact{}).Select("id", "name").Where("user_id = ?", userID)
+	if q = strings.TrimSpace(q); q != "" {
+		query = query.Where(`name ILIKE ? ESCAPE '\'`, "%"+escapeLike(q)+"%")
+	}
+	if len(exclude) > 0 {
+		query = query.Where("id NOT IN ?", exclude)
+	}
+	if err := query.Order("name, id").Limit(limit).Find(&names).Error; err != nil {
 		return nil, err
 	}
-	return contacts, nil
+	return names, nil
 }
 
+// escapeLike escapes the LIKE wildcards in s so that it matches literally,
+// with a backslash as the escape character
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// DeleteContact moves a contact to the trash. Everything about it, including
+// its links to other contacts, is kept until it is purged.
 func (r *ContactRepo) DeleteContact(id string, userID uint) error {
 	result := r.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&entity.Contact{})
 	if result.Error != nil {
@@ -185,15 +222,6 @@ func (r *ContactRepo) DeleteContact(id string, userID uint) error {
 	return nil
 }
 
-func (r *ContactRepo) SearchContactsByRelationship(relation string, userID uint) ([]entity.Contact, error) {
-	var contacts []entity.Contact
-	if err := r.DB.Where("relationship::TEXT ILIKE ? AND user_id = ?", "%"+relation+"%", userID).Find(&contacts).Error; err != nil {
-		return nil, err
-	}
-
-	return contacts, nil
-}
-
 func (r *ContactRepo) UpdateCalendarSync(contactID string, userID uint, eventID string, synced bool) error {
 	return r.DB.Model(&entity.Contact{}).
 		Where("id = ? AND user_id = ?", contactID, userID).
@@ -205,60 +233,53 @@ func (r *ContactRepo) UpdateCalendarSync(contactID string, userID uint, eventID
 }
 
 // Dashboard methods
+// GetUpcomingBirthdays returns the contacts whose next birthday, with or
+// without a known year, is at most days away
 func (r *ContactRepo) GetUpcomingBirthdays(userID uint, days int) ([]entity.Contact, error) {
 	var contacts []entity.Contact
-
-	// Get all contacts with birthdays
-	err := r.DB.Where("user_id = ? AND birthday != ''", userID).Find(&contacts).Error
+	err := r.DB.Where("user_id = ? AND birthday IS NOT NULL", userID).Find(&contacts).Error
 	if err != nil {
 		return nil, err
 	}
 
-	// Filter for upcoming birthdays
 	upcoming := []entity.Contact{}
-	now := time.Now()
-
+	today := entity.Today()
 	for _, contact := range contacts {
-		if contact.Birthday == "" {
-			continue
-		}
-
-		// Parse birthday
-		bday, err := time.Parse("2006-01-02", contact.Birthday)
-		if err != nil {
-			continue
-		}
-
-		// Calculate next birthday this year
-		nextBday := time.Date(now.Year(), bday.Month(), bday.Day(), 0, 0, 0, 0, now.Location())
-
-		// If birthday already passed this year, check next year
-		if nextBday.Before(now) {
-			nextBday = nextBday.AddDate(1, 0, 0)
-		}
-
-		// Check if within next N days
-		daysUntil := int(nextBday.Sub(now).Hours() / 24)
-		if daysUntil <= days {
+		if today.DaysUntil(contact.Birthday.Next(today)) <= days {
 			upcoming = append(upcoming, contact)
 		}
 	}
-
 	return upcoming, nil
 }
 
-func (r *ContactRepo) GetNeedsAttention(userID uint, daysThreshold int) ([]entity.Contact, error) {
+// GetContactsWithCadence returns the contacts that have a keep-in-touch
+// cadence of their own, from a tag or as VIP, with their tags
+func (r *ContactRepo) GetContactsWithCadence(userID uint) ([]entity.Contact, error) {
 	var contacts []entity.Contact
-	threshold := time.Now().AddDate(0, 0, -daysThreshold)
-
-	// Get VIP contacts where last_contacted is older than threshold or empty
-	err := r.DB.Where("user_id = ? AND vip = ? AND (last_contacted = '' OR last_contacted < ?)",
-		userID, true, threshold.Format("2006-01-02")).
+	err := preloadTags(r.DB).
+		Where("user_id = ?", userID).
+		Where(`cadence_days > 0 OR vip OR EXISTS (
+			SELECT 1 FROM contact_tags ct JOIN tags t ON t.id = ct.tag_id
+			WHERE ct.contact_id = contacts.id AND t.cadence_days > 0 AND t.deleted_at IS NULL)`).
 		Find(&contacts).Error
-
 	return contacts, err
 }
 
+// SnoozeContact postpones the next keep-in-touch due date of a contact until
+// the given date, or ends the snooze for the zero Date
+func (r *ContactRepo) SnoozeContact(contactID, userID uint, until entity.Date) error {
+	result := r.DB.Model(&entity.Contact{}).
+		Where("id = ? AND user_id = ?", contactID, userID).
+		UpdateColumn("snoozed_until", until)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("no contact found with id %d", contactID)
+	}
+	return nil
+}
+
 func (r *ContactRepo) GetRecentActivity(userID uint, limit int) ([]entity.Contact, error) {
 	var contacts []entity.Contact
 	err := r.DB.Where("user_id = ?", userID).
@@ -287,6 +308,10 @@ func (r *ContactRepo) GetEventByID(eventID, userID uint) (entity.Event, error) {
 	return event, err
 }
 
+func (r *ContactRepo) SaveEvent(event *entity.Event) error {
+	return r.DB.Save(event).Error
+}
+
 func (r *ContactRepo) DeleteEvent(eventID, userID uint) error {
 	return r.DB.Where("id = ? AND user_id = ?", eventID, userID).Delete(&entity.Event{}).Error
 }
@@ -300,6 +325,7 @@ func (r *ContactRepo) UpdateEventGoogleID(eventID, userID uint, googleEventID st
 func (r *ContactRepo) GetUpcomingEvents(userID uint, days int) ([]entity.Event, error) {
 	var events []entity.Event
 	err := r.DB.Where("user_id = ?", userID).
+		Where("contact_id IN (SELECT id FROM contacts WHERE deleted_at IS NULL)").
 		Order("event_date ASC").
 		Find(&events).Error
 	return events, err
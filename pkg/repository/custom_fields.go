@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"gorm.io/gorm"
+)
+
+// Custom field methods
+
+// ErrInvalidFilter is returned for a custom field filter value that does not
+// fit the type of the field
+var ErrInvalidFilter = errors.New("invalid filter")
+
+func (r *ContactRepo) CreateCustomFieldDefinition(def *entity.CustomFieldDefinition) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		// New fields go to the end of the list
+		var position int
+		if err := tx.Model(&entity.CustomFieldDefinition{}).
+			Where("user_id = ?", def.UserID).
+			Select("COALESCE(MAX(position), -1) + 1").
+			Scan(&position).Error; err != nil {
+			return err
+		}
+		def.Position = position
+		return tx.Create(def).Error
+	})
+}
+
+// GetCustomFieldDefinitions returns the user's custom fields in display order
+func (r *ContactRepo) GetCustomFieldDefinitions(userID uint) ([]entity.CustomFieldDefinition, error) {
+	var defs []entity.CustomFieldDefinition
+	err := r.DB.Where("user_id = ?", userID).Order("position, id").Find(&defs).Error
+	return defs, err
+}
+
+func (r *ContactRepo) GetCustomFieldDefinitionByID(fieldID, userID uint) (entity.CustomFieldDefinition, error) {
+	var def entity.CustomFieldDefinition
+	err := r.DB.Where("id = ? AND user_id = ?", fieldID, userID).First(&def).Error
+	return def, err
+}
+
+// UpdateCustomFieldDefinition saves the label and options of a field. The key
+// and type are fixed once created, since stored values depend on them.
+func (r *ContactRepo) UpdateCustomFieldDefinition(def *entity.CustomFieldDefinition) error {
+	return r.DB.Model(def).
+		Where("user_id = ?", def.UserID).
+		Updates(map[string]interface{}{"label": def.Label, "options": def.Options, "position": def.Position}).Error
+}
+
+// DeleteCustomFieldDefinition deletes a field and removes its value from every contact
+func (r *ContactRepo) DeleteCustomFieldDefinition(fieldID, userID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var def entity.CustomFieldDefinition
+		if err := tx.Where("id = ? AND user_id = ?", fieldID, userID).First(&def).Error; err != nil {
+			return fmt.Errorf("no custom field found with id %d", fieldID)
+		}
+		if err := tx.Delete(&def).Error; err != nil {
+			return err
+		}
+		return tx.Exec("UPDATE contacts SET custom_fields = custom_fields - ? WHERE user_id = ? AND custom_fields->>? IS NOT NULL",
+			def.Key, userID, def.Key).Error
+	})
+}
+
+// withCustomFields scopes a contact query to contacts whose custom fields match
+// the given values. Text and URL fields match on a case insensitive substring,
+// other types must be equal. Unknown keys are ignored.
+func (r *ContactRepo) withCustomFields(query *gorm.DB, userID uint, values map[string]string) (*gorm.DB, error) {
+	if len(values) == 0 {
+		return query, nil
+	}
+
+	defs, err := r.GetCustomFieldDefinitions(userID)
+	if err != nil {
+		return nil, err
+	}
+	byKey := map[string]entity.CustomFieldDefinition{}
+	for _, def := range defs {
+		byKey[def.Key] = def
+	}
+
+	for key, value := range values {
+		value = strings.TrimSpace(value)
+		def, ok := byKey[key]
+		if !ok || value == "" {
+			continue
+		}
+
+		if def.Type == entity.TextField || def.Type == entity.URLField {
+			query = query.Where(`custom_fields->>? ILIKE ? ESCAPE '\'`, key, "%"+escapeLike(value)+"%")
+			continue
+		}
+
+		// Compare against the stored form, e.g. "7.0" is stored as "7"
+		normalized, err := def.Normalize(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+		}
+		query = query.Where("custom_fields->>? = ?", key, normalized)
+	}
+	return query, nil
+}
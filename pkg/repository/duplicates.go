@@ -0,0 +1,446 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"gorm.io/gorm"
+)
+
+// Duplicate detection and merge methods
+
+// DuplicatePair is two contacts that probably describe the same person.
+// Contact is the older of the two and the suggested survivor of a merge.
+type DuplicatePair struct {
+	Contact entity.Contact
+	Other   entity.Contact
+	// Score is between 0 and 1, higher means more likely the same person
+	Score   float64
+	Reasons []string
+}
+
+// MinDuplicateScore is the score below which pairs are not reported
+const MinDuplicateScore = 0.35
+
+// minPhoneDigits keeps short numbers like extensions from matching each other
+const minPhoneDigits = 7
+
+var nonDigits = regexp.MustCompile(`\D`)
+
+// normalizeEmail is the Go side of contact_channels.normalized for emails,
+// see migration 000011
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// normalizePhone is the Go side of contact_channels.normalized for phone
+// numbers. It returns "" for numbers too short to compare.
+func normalizePhone(phone string) string {
+	digits := nonDigits.ReplaceAllString(phone, "")
+	if len(digits) < minPhoneDigits {
+		return ""
+	}
+	if len(digits) > 10 {
+		digits = digits[len(digits)-10:]
+	}
+	return digits
+}
+
+// scoreDuplicate combines the signals of a candidate pair into a score and
+// the human readable reasons behind it
+func scoreDuplicate(sameEmail, samePhone bool, nameSimilarity float64) (float64, []string) {
+	var score float64
+	var reasons []string
+	if sameEmail {
+		score += 0.6
+		reasons = append(reasons, "same email")
+	}
+	if samePhone {
+		score += 0.5
+		reasons = append(reasons, "same phone number")
+	}
+	if nameSimilarity >= 1 {
+		reasons = append(reasons, "same name")
+	} else if nameSimilarity > 0 {
+		reasons = append(reasons, fmt.Sprintf("similar name (%.0f%%)", nameSimilarity*100))
+	}
+	score += 0.6 * nameSimilarity
+	if score > 1 {
+		score = 1
+	}
+	return score, reasons
+}
+
+// FindDuplicateContacts returns the pairs of the user's contacts that share an
+// email or phone number or have similar names, best matches first. Every
+// email and phone number of a contact is compared, not only the primary ones.
+func (r *ContactRepo) FindDuplicateContacts(userID uint) ([]DuplicatePair, error) {
+	var candidates []struct {
+		ContactID      uint
+		OtherID        uint
+		SameEmail      bool
+		SamePhone      bool
+		NameSimilarity float64
+	}
+	err := r.DB.Raw(`
+		WITH shared AS (
+			SELECT a.contact_id, b.contact_id AS other_id,
+				BOOL_OR(a.kind = 'email') AS same_email,
+				BOOL_OR(a.kind = 'phone') AS same_phone
+			FROM contact_channels a
+			JOIN contact_channels b ON b.user_id = a.user_id AND b.kind = a.kind
+				AND b.normalized = a.normalized AND b.contact_id > a.contact_id
+			WHERE a.user_id = ? AND a.normalized <> ''
+				AND (a.kind = 'email' OR LENGTH(a.normalized) >= ?)
+			GROUP BY a.contact_id, b.contact_id
+		)
+		SELECT a.id AS contact_id, b.id AS other_id,
+			COALESCE(s.same_email, FALSE) AS same_email,
+			COALESCE(s.same_phone, FALSE) AS same_phone,
+			similarity(a.name, b.name) AS name_similarity
+		FROM contacts a
+		JOIN contacts b ON b.user_id = a.user_id AND b.id > a.id AND b.deleted_at IS NULL
+		LEFT JOIN shared s ON s.contact_id = a.id AND s.other_id = b.id
+		WHERE a.user_id = ? AND a.deleted_at IS NULL
+			AND (s.contact_id IS NOT NULL OR a.name % b.name)`, userID, minPhoneDigits, userID).
+		Scan(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var pairs []DuplicatePair
+	ids := map[uint]bool{}
+	for _, candidate := range candidates {
+		score, reasons := scoreDuplicate(candidate.SameEmail, candidate.SamePhone, candidate.NameSimilarity)
+		if score < MinDuplicateScore {
+			continue
+		}
+		pairs = append(pairs, DuplicatePair{
+			Contact: entity.Contact{Model: gorm.Model{ID: candidate.ContactID}},
+			Other:   entity.Contact{Model: gorm.Model{ID: candidate.OtherID}},
+			Score:   score,
+			Reasons: reasons,
+		})
+		ids[candidate.ContactID] = true
+		ids[candidate.OtherID] = true
+	}
+	if len(pairs) == 0 {
+		return []DuplicatePair{}, nil
+	}
+
+	contacts, err := r.contactsByID(userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pairs {
+		pairs[i].Contact = contacts[pairs[i].Contact.ID]
+		pairs[i].Other = contacts[pairs[i].Other.ID]
+	}
+
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].Score > pairs[j].Score
+	})
+	return pairs, nil
+}
+
+// FindDuplicatesOf returns the user's other contacts that share an email or
+// phone number with contact. It is used to stop a contact from being created twice.
+func (r *ContactRepo) FindDuplicatesOf(contact entity.Contact) ([]DuplicatePair, error) {
+	emails, phones := map[string]bool{}, map[string]bool{}
+	for _, email := range append(contact.ChannelValues(entity.EmailChannel), contact.Email) {
+		if email = normalizeEmail(email); email != "" {
+			emails[email] = true
+		}
+	}
+	for _, phone := range append(contact.ChannelValues(entity.PhoneChannel), contact.PhoneNumber) {
+		if phone = normalizePhone(phone); phone != "" {
+			phones[phone] = true
+		}
+	}
+	pairs := []DuplicatePair{}
+	if len(emails) == 0 && len(phones) == 0 {
+		return pairs, nil
+	}
+
+	conditions := r.DB.Where("1 = 0")
+	if len(emails) > 0 {
+		conditions = conditions.Or("cc.kind = ? AND cc.normalized IN ?", entity.EmailChannel, mapKeys(emails))
+	}
+	if len(phones) > 0 {
+		conditions = conditions.Or("cc.kind = ? AND cc.normalized IN ?", entity.PhoneChannel, mapKeys(phones))
+	}
+	var ids []uint
+	if err := r.DB.Table("contact_channels cc").
+		Where("cc.user_id = ? AND cc.contact_id <> ?", contact.UserID, contact.ID).
+		Where(conditions).
+		Distinct().
+		Pluck("cc.contact_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return pairs, nil
+	}
+
+	var matches []entity.Contact
+	if err := preloadChannels(r.DB).Where("user_id = ? AND id IN ?", contact.UserID, ids).
+		Order("id").
+		Find(&matches).Error; err != nil {
+		return nil, err
+	}
+
+	for _, match := range matches {
+		var nameSimilarity float64
+		if strings.EqualFold(strings.TrimSpace(match.Name), strings.TrimSpace(contact.Name)) {
+			nameSimilarity = 1
+		}
+		var sameEmail, samePhone bool
+		for _, channel := range match.Channels {
+			switch channel.Kind {
+			case entity.EmailChannel:
+				sameEmail = sameEmail || emails[normalizeEmail(channel.Value)]
+			case entity.PhoneChannel:
+				samePhone = samePhone || phones[normalizePhone(channel.Value)]
+			}
+		}
+		score, reasons := scoreDuplicate(sameEmail, samePhone, nameSimilarity)
+		pairs = append(pairs, DuplicatePair{Contact: match, Other: contact, Score: score, Reasons: reasons})
+	}
+	return pairs, nil
+}
+
+// mapKeys returns the keys of a set
+func mapKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (r *ContactRepo) contactsByID(userID uint, ids map[uint]bool) (map[uint]entity.Contact, error) {
+	list := make([]uint, 0, len(ids))
+	for id := range ids {
+		list = append(list, id)
+	}
+
+	var contacts []entity.Contact
+	if err := preloadChannels(preloadTags(r.DB)).Where("id IN ? AND user_id = ?", list, userID).Find(&contacts).Error; err != nil {
+		return nil, err
+	}
+	res := map[uint]entity.Contact{}
+	for _, contact := range contacts {
+		res[contact.ID] = contact
+	}
+	return res, nil
+}
+
+// MergeField is one field compared on the merge screen
+type MergeField struct {
+	Column        string
+	Label         string
+	SurvivorValue string
+	MergedValue   string
+	Default       entity.MergeSource
+}
+
+// MergeFields lists the tracked fields and custom fields of two contacts with
+// the source a merge uses when the user does not choose one
+func MergeFields(survivor, merged *entity.Contact) []MergeField {
+	var fields []MergeField
+	add := func(column, label, survivorValue, mergedValue string) {
+		fields = append(fields, MergeField{
+			Column:        column,
+			Label:         label,
+			SurvivorValue: survivorValue,
+			MergedValue:   mergedValue,
+			Default:       defaultMergeSource(survivorValue, mergedValue),
+		})
+	}
+
+	for _, field := range TrackedContactFields {
+		add(field.Column, field.Label, field.Get(survivor), field.Get(merged))
+	}
+
+	keys := map[string]bool{}
+	for _, key := range append(survivor.CustomFields.Keys(), merged.CustomFields.Keys()...) {
+		if !keys[key] {
+			keys[key] = true
+			add(customFieldPrefix+key, ContactFieldLabel(customFieldPrefix+key), survivor.CustomFields[key], merged.CustomFields[key])
+		}
+	}
+	return fields
+}
+
+// defaultMergeSource keeps the survivor's value unless it is empty
+func defaultMergeSource(survivorValue, mergedValue string) entity.MergeSource {
+	switch {
+	case survivorValue == mergedValue:
+		return entity.KeepSurvivor
+	case survivorValue == "" || survivorValue == "false":
+		return entity.TakeMerged
+	}
+	return entity.KeepSurvivor
+}
+
+// MergeContacts merges the contact mergedID into survivorID. Each field is
+// taken from the contact named in choices, falling back to the default of
+// MergeFields. Events, notes, attachments, interactions, tasks, tags and the
+// birthday calendar sync move to the survivor, which also takes the merged
+// contact's photo when it has none. The merged contact is deleted and the
+// merge is recorded.
+func (r *ContactRepo) MergeContacts(survivorID, mergedID, userID uint, choices map[string]entity.MergeSource, actor string) (entity.Contact, error) {
+	var survivor entity.Contact
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if survivorID == mergedID {
+			return fmt.Errorf("a contact cannot be merged into itself")
+		}
+
+		var merged entity.Contact
+		if err := tx.Where("id = ? AND user_id = ?", survivorID, userID).First(&survivor).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("no contact found with id %d", survivorID)
+			}
+			return err
+		}
+		if err := tx.Where("id = ? AND user_id = ?", mergedID, userID).First(&merged).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("no contact found with id %d", mergedID)
+			}
+			return err
+		}
+
+		snapshot := entity.FieldValues{}
+		taken := entity.StringList{}
+		customFields := entity.CustomFields{}
+		for key, value := range survivor.CustomFields {
+			customFields[key] = value
+		}
+
+		for _, field := range MergeFields(&survivor, &merged) {
+			if field.MergedValue != "" {
+				snapshot[field.Column] = field.MergedValue
+			}
+
+			source, ok := choices[field.Column]
+			if !ok || !source.Valid() {
+				source = field.Default
+			}
+			if source == entity.KeepSurvivor || field.SurvivorValue == field.MergedValue {
+				continue
+			}
+
+			value := field.MergedValue
+			taken = append(taken, field.Column)
+
+			if key, ok := strings.CutPrefix(field.Column, customFieldPrefix); ok {
+				if value == "" {
+					delete(customFields, key)
+				} else {
+					customFields[key] = value
+				}
+				continue
+			}
+			for _, tracked := range TrackedContactFields {
+				if tracked.Column == field.Column {
+					tracked.Set(&survivor, value)
+				}
+			}
+		}
+		survivor.CustomFields = customFields
+
+		// The survivor takes over the birthday reminder when it has none of its own
+		if survivor.GoogleCalendarEventID == "" && merged.GoogleCalendarEventID != "" {
+			survivor.GoogleCalendarEventID = merged.GoogleCalendarEventID
+			survivor.CalendarSyncEnabled = merged.CalendarSyncEnabled
+			survivor.CalendarSyncedAt = merged.CalendarSyncedAt
+		}
+
+		// Moved first, so choosing the merged contact's email or phone number
+		// makes the moved value primary instead of replacing the survivor's
+		if err := moveContactChannels(tx, survivorID, mergedID); err != nil {
+			return err
+		}
+		if err := saveContactWithHistory(tx, &survivor, actor); err != nil {
+			return err
+		}
+
+		// Events, notes and attachments belong to a single contact and move as a whole
+		for _, model := range []interface{}{&entity.Event{}, &entity.Note{}, &entity.Attachment{}} {
+			if err := tx.Model(model).
+				Where("contact_id = ? AND user_id = ?", mergedID, userID).
+				Update("contact_id", survivorID).Error; err != nil {
+				return err
+			}
+		}
+		// The merged contact's photo is used when the survivor has none
+		if err := tx.Exec(`UPDATE contacts SET photo_id = (SELECT photo_id FROM contacts WHERE id = ? AND user_id = ?)
+			WHERE id = ? AND user_id = ? AND photo_id IS NULL`, mergedID, userID, survivorID, userID).Error; err != nil {
+			return err
+		}
+
+		// Join rows are copied, skipping the ones the survivor already has
+		for _, join := range []struct{ table, column string }{
+			{"contact_tags", "tag_id"},
+			{"interaction_contacts", "interaction_id"},
+			{"task_contacts", "task_id"},
+			{"household_members", "household_id"},
+		} {
+			if err := tx.Exec(fmt.Sprintf(`INSERT INTO %[1]s (contact_id, %[2]s)
+				SELECT ?, %[2]s FROM %[1]s WHERE contact_id = ?
+				ON CONFLICT DO NOTHING`, join.table, join.column), survivorID, mergedID).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE contact_id = ?", join.table), mergedID).Error; err != nil {
+				return err
+			}
+		}
+		if err := refreshLastContact(tx, []uint{survivorID}); err != nil {
+			return err
+		}
+		if err := moveContactLinks(tx, survivorID, mergedID); err != nil {
+			return err
+		}
+
+		// The merged contact keeps its history and can be looked up from the merge record
+		if err := tx.Model(&merged).Updates(map[string]interface{}{
+			"google_calendar_event_id": "",
+			"calendar_sync_enabled":    false,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&merged).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(&entity.ContactMerge{
+			UserID:      userID,
+			SurvivorID:  survivorID,
+			MergedID:    mergedID,
+			MergedName:  merged.Name,
+			Snapshot:    snapshot,
+			TakenFields: taken,
+			Actor:       actor,
+		}).Error; err != nil {
+			return err
+		}
+
+		return preloadNotes(preloadChannels(preloadTags(tx))).Where("id = ? AND user_id = ?", survivorID, userID).First(&survivor).Error
+	})
+
+	return survivor, err
+}
+
+// GetContactMerges returns the merges into a contact, newest first
+func (r *ContactRepo) GetContactMerges(contactID, userID uint) ([]entity.ContactMerge, error) {
+	var merges []entity.ContactMerge
+	err := r.DB.Where("survivor_id = ? AND user_id = ?", contactID, userID).
+		Order("created_at DESC").
+		Find(&merges).Error
+	return merges, err
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		phone string
+		want  string
+	}{
+		{"", ""},
+		{"ext. 123", ""},
+		{"555-123", ""},
+		{"555-1234", "5551234"},
+		{"(555) 123-4567", "5551234567"},
+		{"+1 (555) 123-4567", "5551234567"},
+		{"001 555 123 4567", "5551234567"},
+		{"+44 20 7946 0958", "2079460958"},
+	}
+	for _, tt := range tests {
+		if got := normalizePhone(tt.phone); got != tt.want {
+			t.Errorf("normalizePhone(%q) = %q, want %q", tt.phone, got, tt.want)
+		}
+	}
+}
+
+func TestScoreDuplicate(t *testing.T) {
+	tests := []struct {
+		name           string
+		sameEmail      bool
+		samePhone      bool
+		nameSimilarity float64
+		score          float64
+		reasons        []string
+	}{
+		{"nothing in common", false, false, 0, 0, nil},
+		{"same email", true, false, 0, 0.6, []string{"same email"}},
+		{"same phone", false, true, 0, 0.5, []string{"same phone number"}},
+		{"same name", false, false, 1, 0.6, []string{"same name"}},
+		{"similar name", false, false, 0.5, 0.3, []string{"similar name (50%)"}},
+		{"everything, capped", true, true, 1, 1, []string{"same email", "same phone number", "same name"}},
+		{"phone and similar name", false, true, 0.4, 0.74, []string{"same phone number", "similar name (40%)"}},
+	}
+	for _, tt := range tests {
+		score, reasons := scoreDuplicate(tt.sameEmail, tt.samePhone, tt.nameSimilarity)
+		if math.Abs(score-tt.score) > 1e-9 || !reflect.DeepEqual(reasons, tt.reasons) {
+			t.Errorf("%s: scoreDuplicate = %v, %q, want %v, %q", tt.name, score, reasons, tt.score, tt.reasons)
+		}
+	}
+}
+
+// A similar name alone is reported from about 58% similarity, a shared email
+// or phone number always is
+func TestScoreDuplicateCutoff(t *testing.T) {
+	tests := []struct {
+		sameEmail      bool
+		samePhone      bool
+		nameSimilarity float64
+		reported       bool
+	}{
+		{false, false, 0.3, false},
+		{false, false, 0.58, false},
+		{false, false, 0.59, true},
+		{true, false, 0, true},
+		{false, true, 0, true},
+	}
+	for _, tt := range tests {
+		score, _ := scoreDuplicate(tt.sameEmail, tt.samePhone, tt.nameSimilarity)
+		if reported := score >= MinDuplicateScore; reported != tt.reported {
+			t.Errorf("scoreDuplicate(%v, %v, %v) = %v, reported %v, want %v", tt.sameEmail, tt.samePhone, tt.nameSimilarity, score, reported, tt.reported)
+		}
+	}
+}
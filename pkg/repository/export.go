@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"github.com/La002/personal-crm/pkg/entity"
+	"gorm.io/gorm"
+)
+
+// Export methods
+
+// AccountData is everything stored for a user, as read for an account export
+type AccountData struct {
+	User         entity.User
+	Contacts     []entity.Contact
+	Events       []entity.Event
+	Attachments  []entity.Attachment
+	Interactions []entity.Interaction
+	Tasks        []entity.Task
+	Tags         []entity.Tag
+	CustomFields []entity.CustomFieldDefinition
+	// RelationshipTypes are in display order
+	RelationshipTypes []entity.RelationshipType
+	Households        []entity.Household
+	History           []entity.DetailChanges
+	Merges            []entity.ContactMerge
+	Links             []entity.ContactLink
+}
+
+// GetAccountData reads all data of a user in one repeatable read transaction,
+// so the export is consistent even when the user keeps editing meanwhile
+func (r *ContactRepo) GetAccountData(userID uint) (AccountData, error) {
+	var data AccountData
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SET TRANSACTION ISOLATION LEVEL REPEATABLE READ").Error; err != nil {
+			return err
+		}
+		if err := tx.First(&data.User, userID).Error; err != nil {
+			return err
+		}
+		if err := preloadNotes(preloadChannels(tx.Preload("Tags"))).Where("user_id = ?", userID).Order("id").Find(&data.Contacts).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Order("id").Find(&data.Events).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Order("id").Find(&data.Attachments).Error; err != nil {
+			return err
+		}
+		if err := tx.Preload("Contacts", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id")
+		}).Where("user_id = ?", userID).Order("occurred_on, id").Find(&data.Interactions).Error; err != nil {
+			return err
+		}
+		if err := tx.Preload("Contacts", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id")
+		}).Where("user_id = ?", userID).Order("id").Find(&data.Tasks).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Order("LOWER(name)").Find(&data.Tags).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Order("position, id").Find(&data.CustomFields).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Order("position, id").Find(&data.RelationshipTypes).Error; err != nil {
+			return err
+		}
+		if err := tx.Preload("Members", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id")
+		}).Preload("Events", func(db *gorm.DB) *gorm.DB {
+			return db.Order("event_date, id")
+		}).Where("user_id = ?", userID).Order("id").Find(&data.Households).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Order("changed_at, person_id").Find(&data.History).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Order("id").Find(&data.Merges).Error; err != nil {
+			return err
+		}
+		return tx.Preload("LinkType").Where("user_id = ?", userID).Order("id").Find(&data.Links).Error
+	})
+	return data, err
+}
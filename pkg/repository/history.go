@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ContactField describes a contact column whose changes are recorded in the history
+type ContactField struct {
+	Column string
+	Label  string
+	Get    func(c *entity.Contact) string
+	Set    func(c *entity.Contact, value string)
+}
+
+// TrackedContactFields lists the user editable contact fields, in display order.
+// LastMet and LastContacted are derived from interactions and not tracked.
+var TrackedContactFields = []ContactField{
+	{"name", "Name", func(c *entity.Contact) string { return c.Name }, func(c *entity.Contact, v string) { c.Name = v }},
+	{"relationship", "Relationship", func(c *entity.Contact) string { return string(c.Relationship) }, func(c *entity.Contact, v string) { c.Relationship = entity.Relation(v) }},
+	{"industry", "Industry", func(c *entity.Contact) string { return c.Industry }, func(c *entity.Contact, v string) { c.Industry = v }},
+	{"company", "Company", func(c *entity.Contact) string { return c.Company }, func(c *entity.Contact, v string) { c.Company = v }},
+	{"birthday", "Birthday", func(c *entity.Contact) string { return c.Birthday.String() }, func(c *entity.Contact, v string) { c.Birthday, _ = entity.ParseBirthday(v) }},
+	{"vip", "VIP", func(c *entity.Contact) string { return strconv.FormatBool(c.Vip) }, func(c *entity.Contact, v string) { c.Vip, _ = strconv.ParseBool(v) }},
+	{"spouse", "Spouse", func(c *entity.Contact) string { return c.Spouse }, func(c *entity.Contact, v string) { c.Spouse = v }},
+	{"children", "Children", func(c *entity.Contact) string { return c.Children }, func(c *entity.Contact, v string) { c.Children = v }},
+	{"location", "Location", func(c *entity.Contact) string { return c.Location }, func(c *entity.Contact, v string) { c.Location = v }},
+	{"phone_number", "Phone", func(c *entity.Contact) string { return c.PhoneNumber }, func(c *entity.Contact, v string) { c.PhoneNumber = v }},
+	{"email", "Email", func(c *entity.Contact) string { return c.Email }, func(c *entity.Contact, v string) { c.Email = v }},
+	{"linked_in", "LinkedIn", func(c *entity.Contact) string { return c.LinkedIn }, func(c *entity.Contact, v string) { c.LinkedIn = v }},
+	{"instagram", "Instagram", func(c *entity.Contact) string { return c.Instagram }, func(c *entity.Contact, v string) { c.Instagram = v }},
+	{"x", "X", func(c *entity.Contact) string { return c.X }, func(c *entity.Contact, v string) { c.X = v }},
+	{"last_update", "Last Update", func(c *entity.Contact) string { return c.LastUpdate.String() }, func(c *entity.Contact, v string) { c.LastUpdate, _ = entity.ParseDate(v) }},
+	{"status", "Status", func(c *entity.Contact) string { return c.Status }, func(c *entity.Contact, v string) { c.Status = v }},
+	{"cadence_days", "Keep In Touch", func(c *entity.Contact) string { return entity.CadenceLabel(c.CadenceDays) }, func(c *entity.Contact, v string) { c.CadenceDays = parseCadenceLabel(v) }},
+}
+
+// parseCadenceLabel reads a cadence written by entity.CadenceLabel, e.g. when
+// restoring a revision or importing a CSV file, or a plain number of days
+func parseCadenceLabel(label string) int {
+	for _, preset := range entity.CadencePresets {
+		if strings.EqualFold(label, preset.Label) {
+			return preset.Days
+		}
+	}
+	var days int
+	if _, err := fmt.Sscanf(label, "Every %d days", &days); err == nil {
+		return days
+	}
+	if strings.EqualFold(label, "Every day") {
+		return 1
+	}
+	days, _ = entity.ParseCadence(label)
+	return days
+}
+
+// customFieldPrefix marks history rows that record a custom field, e.g. "custom_fields.diet"
+const customFieldPrefix = "custom_fields."
+
+// ContactFieldLabel returns the display label of a tracked column
+func ContactFieldLabel(column string) string {
+	for _, field := range TrackedContactFields {
+		if field.Column == column {
+			return field.Label
+		}
+	}
+	// Custom fields and columns that are no longer tracked, e.g. last_met
+	label := strings.ReplaceAll(strings.TrimPrefix(column, customFieldPrefix), "_", " ")
+	return strings.ToUpper(label[:1]) + label[1:]
+}
+
+// diffContacts returns one change row per tracked field that differs between old and new
+func diffContacts(old, new *entity.Contact, actor string) []entity.DetailChanges {
+	revision := uuid.New()
+	now := time.Now()
+
+	var changes []entity.DetailChanges
+	addChange := func(column, oldValue, newValue string) {
+		if oldValue == newValue {
+			return
+		}
+		changes = append(changes, entity.DetailChanges{
+			UserID:       new.UserID,
+			PersonId:     new.ID,
+			Revision:     revision,
+			ChangedField: column,
+			OldValue:     oldValue,
+			NewValue:     newValue,
+			Actor:        actor,
+			ChangedAt:    now,
+		})
+	}
+
+	for _, field := range TrackedContactFields {
+		addChange(field.Column, field.Get(old), field.Get(new))
+	}
+
+	// Each custom field is recorded on its own so it can be restored like a column
+	keys := map[string]bool{}
+	for _, key := range append(old.CustomFields.Keys(), new.CustomFields.Keys()...) {
+		if !keys[key] {
+			keys[key] = true
+			addChange(customFieldPrefix+key, old.CustomFields[key], new.CustomFields[key])
+		}
+	}
+	return changes
+}
+
+// saveContactWithHistory saves contact inside tx and records the fields that changed
+func saveContactWithHistory(tx *gorm.DB, contact *entity.Contact, actor string) error {
+	var existing entity.Contact
+	if err := tx.Where("id = ? AND user_id = ?", contact.ID, contact.UserID).First(&existing).Error; err != nil {
+		return err
+	}
+
+	// Tags, channels and addresses are saved separately
+	if err := tx.Omit("Tags", "Channels", "Addresses", "Notes").Save(contact).Error; err != nil {
+		return err
+	}
+	if err := syncContactChannels(tx, contact); err != nil {
+		return err
+	}
+
+	changes := diffContacts(&existing, contact, actor)
+	if len(changes) == 0 {
+		return nil
+	}
+	return tx.Create(&changes).Error
+}
+
+// SaveContactWithHistory saves an existing contact and records every changed field
+func (r *ContactRepo) SaveContactWithHistory(contact *entity.Contact, actor string) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		return saveContactWithHistory(tx, contact, actor)
+	})
+}
+
+func (r *ContactRepo) GetContactHistory(contactID, userID uint) ([]entity.DetailChanges, error) {
+	var changes []entity.DetailChanges
+	err := r.DB.Where("person_id = ? AND user_id = ?", contactID, userID).
+		Order("changed_at DESC").
+		Find(&changes).Error
+	return changes, err
+}
+
+// RestoreContactRevision rolls a contact back to its state right after the given
+// revision by undoing every later change. The restore itself is recorded as a new revision.
+func (r *ContactRepo) RestoreContactRevision(contactID, userID uint, revision uuid.UUID, actor string) (entity.Contact, error) {
+	var contact entity.Contact
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var target entity.DetailChanges
+		if err := tx.Where("person_id = ? AND user_id = ? AND revision = ?", contactID, userID, revision).
+			First(&target).Error; err != nil {
+			return err
+		}
+
+		var later []entity.DetailChanges
+		if err := tx.Where("person_id = ? AND user_id = ? AND changed_at > ?", contactID, userID, target.ChangedAt).
+			Order("changed_at DESC").
+			Find(&later).Error; err != nil {
+			return err
+		}
+
+		if len(later) == 0 {
+			return fmt.Errorf("revision %s is already the current version", revision)
+		}
+
+		if err := tx.Where("id = ? AND user_id = ?", contactID, userID).First(&contact).Error; err != nil {
+			return err
+		}
+		undoChanges(&contact, later)
+
+		return saveContactWithHistory(tx, &contact, actor)
+	})
+
+	return contact, err
+}
+
+// undoChanges sets the fields of contact back to the values they had before
+// changes, which are ordered from newest to oldest
+func undoChanges(contact *entity.Contact, changes []entity.DetailChanges) {
+	// Walking from newest to oldest leaves each field with the value it had
+	// before the oldest change
+	restored := map[string]string{}
+	for _, change := range changes {
+		restored[change.ChangedField] = change.OldValue
+	}
+
+	for _, field := range TrackedContactFields {
+		if value, ok := restored[field.Column]; ok {
+			field.Set(contact, value)
+		}
+	}
+
+	customFields := entity.CustomFields{}
+	for key, value := range contact.CustomFields {
+		customFields[key] = value
+	}
+	for column, value := range restored {
+		if key, ok := strings.CutPrefix(column, customFieldPrefix); ok {
+			if value == "" {
+				delete(customFields, key)
+			} else {
+				customFields[key] = value
+			}
+		}
+	}
+	contact.CustomFields = customFields
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/entity"
+)
+
+// change is the column and values of a history row
+type change struct {
+	column, old, new string
+}
+
+func TestDiffContacts(t *testing.T) {
+	base := func() entity.Contact {
+		c := entity.Contact{
+			UserID:       1,
+			Name:         "Jane Doe",
+			Relationship: "Friend",
+			Birthday:     entity.Date{Month: time.April, Day: 15},
+			CadenceDays:  30,
+			CustomFields: entity.CustomFields{"diet": "Vegan"},
+		}
+		c.ID = 7
+		return c
+	}
+	tests := []struct {
+		name   string
+		update func(c *entity.Contact)
+		want   []change
+	}{
+		{
+			name:   "nothing changed",
+			update: func(c *entity.Contact) {},
+		},
+		{
+			name: "untracked fields",
+			update: func(c *entity.Contact) {
+				c.LastContacted = entity.Date{Year: 2024, Month: time.May, Day: 1}
+				c.StrengthScore = 80
+			},
+		},
+		{
+			name: "tracked fields in display order",
+			update: func(c *entity.Contact) {
+				c.Email = "jane@example.test"
+				c.Name = "Jane Smith"
+				c.Vip = true
+				c.Relationship = ""
+			},
+			want: []change{
+				{"name", "Jane Doe", "Jane Smith"},
+				{"relationship", "Friend", ""},
+				{"vip", "false", "true"},
+				{"email", "", "jane@example.test"},
+			},
+		},
+		{
+			name: "formatted values",
+			update: func(c *entity.Contact) {
+				c.Birthday = entity.Date{Year: 1990, Month: time.April, Day: 15}
+				c.CadenceDays = 91
+			},
+			want: []change{
+				{"birthday", "--04-15", "1990-04-15"},
+				{"cadence_days", "Every month", "Every quarter"},
+			},
+		},
+		{
+			name: "custom fields, the old ones first",
+			update: func(c *entity.Contact) {
+				c.CustomFields = entity.CustomFields{"shoe_size": "42", "allergies": "nuts"}
+			},
+			want: []change{
+				{"custom_fields.diet", "Vegan", ""},
+				{"custom_fields.allergies", "", "nuts"},
+				{"custom_fields.shoe_size", "", "42"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old, new := base(), base()
+			tt.update(&new)
+
+			rows := diffContacts(&old, &new, "web")
+			var got []change
+			for _, row := range rows {
+				got = append(got, change{row.ChangedField, row.OldValue, row.NewValue})
+				if row.Revision != rows[0].Revision || row.Actor != "web" || row.UserID != 1 || row.PersonId != 7 {
+					t.Errorf("row %+v does not belong to the revision of the first row %+v", row, rows[0])
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffContacts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Restoring a revision undoes every later change, newest first, and keeps
+// the changes of the revision itself and the ones before it
+func TestUndoChanges(t *testing.T) {
+	states := []entity.Contact{
+		{Name: "Jane", Company: "ACME", CadenceDays: 7},
+		{Name: "Jane Doe", Company: "ACME", CadenceDays: 10, Birthday: entity.Date{Month: time.April, Day: 15}, CustomFields: entity.CustomFields{"diet": "Vegan"}},
+		{Name: "Jane Doe", Company: "Initech", CadenceDays: 10, Vip: true, Birthday: entity.Date{Year: 1990, Month: time.April, Day: 15}, CustomFields: entity.CustomFields{"diet": "Omnivore", "shoe_size": "38"}},
+		{Name: "Jane Smith", Company: "", CadenceDays: 0, Vip: true, CustomFields: entity.CustomFields{"shoe_size": "39"}},
+	}
+	// revisions[i] turns states[i] into states[i+1]
+	var revisions [][]entity.DetailChanges
+	for i := 0; i+1 < len(states); i++ {
+		revisions = append(revisions, diffContacts(&states[i], &states[i+1], "web"))
+	}
+
+	// The last revision is the current version, it cannot be restored
+	for target := 0; target+1 < len(revisions); target++ {
+		var later []entity.DetailChanges
+		for i := len(revisions) - 1; i > target; i-- {
+			later = append(later, revisions[i]...)
+		}
+		contact := states[len(states)-1]
+		undoChanges(&contact, later)
+
+		want := states[target+1]
+		for _, field := range TrackedContactFields {
+			if got, want := field.Get(&contact), field.Get(&want); got != want {
+				t.Errorf("restoring revision %d: %s = %q, want %q", target, field.Column, got, want)
+			}
+		}
+		if len(want.CustomFields) == 0 {
+			want.CustomFields = entity.CustomFields{}
+		}
+		if !reflect.DeepEqual(contact.CustomFields, want.CustomFields) {
+			t.Errorf("restoring revision %d: custom fields = %v, want %v", target, contact.CustomFields, want.CustomFields)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"gorm.io/gorm"
+)
+
+// Household methods
+
+func (r *ContactRepo) CreateHousehold(household *entity.Household) error {
+	return r.DB.Omit("Members", "Events").Create(household).Error
+}
+
+// GetHouseholds returns the user's households ordered by name, with their
+// members
+func (r *ContactRepo) GetHouseholds(userID uint) ([]entity.Household, error) {
+	var households []entity.Household
+	err := preloadHouseholdMembers(r.DB).
+		Where("user_id = ?", userID).
+		Order("LOWER(name)").
+		Find(&households).Error
+	return households, err
+}
+
+// GetHouseholdByID returns a household with its members and events
+func (r *ContactRepo) GetHouseholdByID(householdID, userID uint) (entity.Household, error) {
+	var household entity.Household
+	err := preloadHouseholdMembers(r.DB).
+		Preload("Events", func(db *gorm.DB) *gorm.DB {
+			return db.Order("event_date, id")
+		}).
+		Where("id = ? AND user_id = ?", householdID, userID).
+		First(&household).Error
+	return household, err
+}
+
+// GetHouseholdByName looks a household up by name, ignoring case
+func (r *ContactRepo) GetHouseholdByName(name string, userID uint) (entity.Household, error) {
+	var household entity.Household
+	err := r.DB.Where("LOWER(name) = LOWER(?) AND user_id = ?", strings.TrimSpace(name), userID).First(&household).Error
+	return household, err
+}
+
+// GetHouseholdsByContact returns the households a contact is a member of
+func (r *ContactRepo) GetHouseholdsByContact(contactID, userID uint) ([]entity.Household, error) {
+	var households []entity.Household
+	err := preloadHouseholdMembers(r.DB).
+		Where("user_id = ?", userID).
+		Where("id IN (SELECT household_id FROM household_members WHERE contact_id = ?)", contactID).
+		Order("LOWER(name)").
+		Find(&households).Error
+	return households, err
+}
+
+// UpdateHousehold saves the name, greeting and shared address of a household
+func (r *ContactRepo) UpdateHousehold(household *entity.Household) error {
+	result := r.DB.Model(&entity.Household{}).
+		Where("id = ? AND user_id = ?", household.ID, household.UserID).
+		Updates(map[string]interface{}{
+			"name":        household.Name,
+			"greeting":    household.Greeting,
+			"street":      household.Street,
+			"city":        household.City,
+			"region":      household.Region,
+			"postal_code": household.PostalCode,
+			"country":     household.Country,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("no household found with id %d", household.ID)
+	}
+	return nil
+}
+
+// DeleteHousehold deletes a household. Its members are kept, its memberships
+// and events cascade.
+func (r *ContactRepo) DeleteHousehold(householdID, userID uint) error {
+	result := r.DB.Where("id = ? AND user_id = ?", householdID, userID).Delete(&entity.Household{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("no household found with id %d", householdID)
+	}
+	return nil
+}
+
+// AddHouseholdMembers adds contacts to a household. Contacts that are
+// already members or belong to another user are skipped. It returns the
+// number of contacts that were added.
+func (r *ContactRepo) AddHouseholdMembers(householdID, userID uint, contactIDs []uint) (int64, error) {
+	if len(contactIDs) == 0 {
+		return 0, nil
+	}
+	if _, err := r.getHousehold(householdID, userID); err != nil {
+		return 0, err
+	}
+
+	result := r.DB.Exec(`
+		INSERT INTO household_members (household_id, contact_id)
+		SELECT ?, id FROM contacts
+		WHERE id IN ? AND user_id = ? AND deleted_at IS NULL
+		ON CONFLICT DO NOTHING`, householdID, contactIDs, userID)
+	return result.RowsAffected, result.Error
+}
+
+// RemoveHouseholdMembers removes contacts from a household and returns the
+// number of contacts that were removed
+func (r *ContactRepo) RemoveHouseholdMembers(householdID, userID uint, contactIDs []uint) (int64, error) {
+	if len(contactIDs) == 0 {
+		return 0, nil
+	}
+	if _, err := r.getHousehold(householdID, userID); err != nil {
+		return 0, err
+	}
+
+	result := r.DB.Exec("DELETE FROM household_members WHERE household_id = ? AND contact_id IN ?", householdID, contactIDs)
+	return result.RowsAffected, result.Error
+}
+
+// getHousehold looks a household up without its members and events
+func (r *ContactRepo) getHousehold(householdID, userID uint) (entity.Household, error) {
+	var household entity.Household
+	if err := r.DB.Where("id = ? AND user_id = ?", householdID, userID).First(&household).Error; err != nil {
+		return household, fmt.Errorf("no household found with id %d", householdID)
+	}
+	return household, nil
+}
+
+// preloadHouseholdMembers loads the members of each household, ordered by
+// name, with their channels and addresses. Contacts in the trash are left out.
+func preloadHouseholdMembers(query *gorm.DB) *gorm.DB {
+	return query.Preload("Members", func(db *gorm.DB) *gorm.DB {
+		return preloadChannels(db).Order("LOWER(contacts.name)")
+	})
+}
+
+// Household event methods
+
+func (r *ContactRepo) CreateHouseholdEvent(event *entity.HouseholdEvent) error {
+	return r.DB.Create(event).Error
+}
+
+func (r *ContactRepo) GetHouseholdEventByID(eventID, userID uint) (entity.HouseholdEvent, error) {
+	var event entity.HouseholdEvent
+	err := r.DB.Where("id = ? AND user_id = ?", eventID, userID).First(&event).Error
+	return event, err
+}
+
+func (r *ContactRepo) DeleteHouseholdEvent(eventID, userID uint) error {
+	return r.DB.Where("id = ? AND user_id = ?", eventID, userID).Delete(&entity.HouseholdEvent{}).Error
+}
+
+// GetHouseholdEvents returns every household event of the user ordered by
+// date
+func (r *ContactRepo) GetHouseholdEvents(userID uint) ([]entity.HouseholdEvent, error) {
+	var events []entity.HouseholdEvent
+	err := r.DB.Where("user_id = ?", userID).
+		Order("event_date ASC").
+		Find(&events).Error
+	return events, err
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"gorm.io/gorm"
+)
+
+// Import methods
+
+// ContactImport is a contact to create together with the names of its tags
+type ContactImport struct {
+	Contact entity.Contact
+	Tags    []string
+}
+
+// ImportContacts creates the contacts in a single transaction, so either all
+// of them are imported or none is. Tags are looked up by name, ignoring case,
+// and created with the default color when missing.
+func (r *ContactRepo) ImportContacts(userID uint, imports []ContactImport) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		tags := map[string]uint{}
+
+		for i := range imports {
+			contact := &imports[i].Contact
+			contact.UserID = userID
+			prepareNewContact(contact)
+			if err := tx.Omit("Tags").Create(contact).Error; err != nil {
+				return err
+			}
+
+			for _, name := range imports[i].Tags {
+				key := strings.ToLower(name)
+				tagID, ok := tags[key]
+				if !ok {
+					var tag entity.Tag
+					err := tx.Where("LOWER(name) = ? AND user_id = ?", key, userID).First(&tag).Error
+					if errors.Is(err, gorm.ErrRecordNotFound) {
+						tag = entity.Tag{UserID: userID, Name: name, Color: entity.DefaultTagColor}
+						err = tx.Create(&tag).Error
+					}
+					if err != nil {
+						return err
+					}
+					tagID = tag.ID
+					tags[key] = tagID
+				}
+
+				if err := tx.Exec("INSERT INTO contact_tags (contact_id, tag_id) VALUES (?, ?) ON CONFLICT DO NOTHING",
+					contact.ID, tagID).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
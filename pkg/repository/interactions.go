@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"gorm.io/gorm"
+)
+
+// Interaction methods
+
+// CreateInteraction logs an interaction with the given contacts and refreshes
+// their derived last contacted / last met dates
+func (r *ContactRepo) CreateInteraction(interaction *entity.Interaction, contactIDs []uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var contacts []entity.Contact
+		if err := tx.Select("id").
+			Where("id IN ? AND user_id = ?", contactIDs, interaction.UserID).
+			Find(&contacts).Error; err != nil {
+			return err
+		}
+		if len(contacts) == 0 || len(contacts) != len(uniqueIDs(contactIDs)) {
+			return fmt.Errorf("no contact found for interaction")
+		}
+
+		interaction.Contacts = contacts
+		if err := tx.Omit("Contacts.*").Create(interaction).Error; err != nil {
+			return err
+		}
+		// Getting in touch ends a snooze of the keep-in-touch reminder
+		if err := tx.Model(&entity.Contact{}).Where("id IN ?", contactIDs).UpdateColumn("snoozed_until", nil).Error; err != nil {
+			return err
+		}
+
+		return refreshLastContact(tx, contactIDs)
+	})
+}
+
+func (r *ContactRepo) GetInteractionsByContact(contactID, userID uint) ([]entity.Interaction, error) {
+	var interactions []entity.Interaction
+	err := r.DB.Preload("Contacts", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "name")
+	}).
+		Joins("JOIN interaction_contacts ic ON ic.interaction_id = interactions.id").
+		Where("ic.contact_id = ? AND interactions.user_id = ?", contactID, userID).
+		Order("interactions.occurred_on DESC, interactions.id DESC").
+		Find(&interactions).Error
+	return interactions, err
+}
+
+func (r *ContactRepo) GetInteractionByID(interactionID, userID uint) (entity.Interaction, error) {
+	var interaction entity.Interaction
+	err := r.DB.Preload("Contacts", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "name")
+	}).
+		Where("id = ? AND user_id = ?", interactionID, userID).
+		First(&interaction).Error
+	return interaction, err
+}
+
+// DeleteInteraction removes an interaction and refreshes the derived dates of its contacts
+func (r *ContactRepo) DeleteInteraction(interactionID, userID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var contactIDs []uint
+		if err := tx.Table("interaction_contacts").
+			Where("interaction_id = ?", interactionID).
+			Pluck("contact_id", &contactIDs).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("id = ? AND user_id = ?", interactionID, userID).Delete(&entity.Interaction{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("no interaction found with id %d", interactionID)
+		}
+
+		return refreshLastContact(tx, contactIDs)
+	})
+}
+
+// refreshLastContact derives last_contacted from the newest interaction of any
+// type and last_met from the newest meeting
+func refreshLastContact(tx *gorm.DB, contactIDs []uint) error {
+	if len(contactIDs) == 0 {
+		return nil
+	}
+
+	return tx.Exec(`
+		UPDATE contacts SET
+			last_contacted = (
+				SELECT MAX(i.occurred_on)
+				FROM interactions i
+				JOIN interaction_contacts ic ON ic.interaction_id = i.id
+				WHERE ic.contact_id = contacts.id AND i.deleted_at IS NULL
+			),
+			last_met = (
+				SELECT MAX(i.occurred_on)
+				FROM interactions i
+				JOIN interaction_contacts ic ON ic.interaction_id = i.id
+				WHERE ic.contact_id = contacts.id AND i.deleted_at IS NULL AND i.type = ?
+			)
+		WHERE id IN ?`, entity.Meeting, contactIDs).Error
+}
+
+func uniqueIDs(ids []uint) []uint {
+	seen := map[uint]bool{}
+	var res []uint
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			res = append(res, id)
+		}
+	}
+	return res
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/La002/personal-crm/pkg/entity"
+	"github.com/google/uuid"
 )
 
 type ContactDao interface {
@@ -12,25 +13,154 @@ type ContactDao interface {
 	GetContact(id string, userID uint) (entity.Contact, error)
 	GetContactByEmail(email string, userID uint) (entity.Contact, error)
 	GetAllContacts(userID uint) ([]entity.Contact, error)
-	SortContacts(sortBy string, sortOrder string, userID uint) ([]entity.Contact, error)
+	SearchContactNames(userID uint, q string, exclude []uint, limit int) ([]ContactName, error)
+	ListContactsPage(userID uint, filters ContactSearchFilters, page ContactPageRequest) (ContactPage, error)
 	DeleteContact(id string, userID uint) error
-	FilterContactsByVipStatus(flag bool, userID uint) ([]entity.Contact, error)
-	SearchContactsByRelationship(filter string, userID uint) ([]entity.Contact, error)
 	UpdateCalendarSync(contactID string, userID uint, eventID string, synced bool) error
 
+	// Bulk contact methods
+	DeleteContacts(contactIDs []uint, userID uint) ([]entity.Contact, error)
+	UpdateContacts(contactIDs []uint, userID uint, updates map[string]interface{}, actor string) ([]entity.Contact, error)
+
+	// Trash methods
+	GetDeletedContacts(userID uint) ([]entity.Contact, error)
+	RestoreContacts(contactIDs []uint, userID uint) (int64, error)
+	PurgeContacts(contactIDs []uint, userID uint) ([]PurgedContact, error)
+	GetExpiredContacts(deletedBefore time.Time) ([]entity.Contact, error)
+	PurgeDeletedEvents(deletedBefore time.Time) (int64, error)
+
+	// Dashboard methods
+	GetUpcomingBirthdays(userID uint, days int) ([]entity.Contact, error)
+	GetContactsWithCadence(userID uint) ([]entity.Contact, error)
+	SnoozeContact(contactID, userID uint, until entity.Date) error
+
+	// Strength methods
+	GetStrengthUserIDs() ([]uint, error)
+	GetStrengthInputs(userID uint, today entity.Date) ([]entity.Contact, map[uint]entity.StrengthInputs, error)
+	UpdateContactStrengths(userID uint, strengths []ContactStrength) error
+	GetDriftingContacts(userID uint, limit int) ([]entity.Contact, error)
+
 	// Event methods
 	CreateEvent(event *entity.Event) error
 	GetEventsByContact(contactID, userID uint) ([]entity.Event, error)
 	GetEventByID(eventID, userID uint) (entity.Event, error)
+	SaveEvent(event *entity.Event) error
 	DeleteEvent(eventID, userID uint) error
 	UpdateEventGoogleID(eventID, userID uint, googleEventID string) error
 	GetUpcomingEvents(userID uint, days int) ([]entity.Event, error)
 
+	// Interaction methods
+	CreateInteraction(interaction *entity.Interaction, contactIDs []uint) error
+	GetInteractionsByContact(contactID, userID uint) ([]entity.Interaction, error)
+	GetInteractionByID(interactionID, userID uint) (entity.Interaction, error)
+	DeleteInteraction(interactionID, userID uint) error
+
+	// Note methods
+	CreateNote(note *entity.Note) error
+	AppendNotes(id string, userID uint, body string) (entity.Note, error)
+	GetNoteByID(noteID, userID uint) (entity.Note, error)
+	GetNotesByContact(contactID, userID uint) ([]entity.Note, error)
+	UpdateNote(note *entity.Note) error
+	DeleteNote(noteID, userID uint) error
+
+	// Attachment methods
+	CreateAttachment(attachment *entity.Attachment, asPhoto bool) error
+	GetAttachmentByID(attachmentID, userID uint) (entity.Attachment, error)
+	GetAttachmentsByContact(contactID, userID uint) ([]entity.Attachment, error)
+	DeleteAttachment(attachmentID, userID uint) error
+	SetContactPhoto(contactID, userID uint, attachmentID *uint) error
+	GetContactPhoto(contactID, userID uint) (entity.Attachment, error)
+
+	// Smart list methods
+	CreateSmartList(list *entity.SmartList) error
+	GetSmartLists(userID uint) ([]entity.SmartList, error)
+	GetSmartListByID(listID, userID uint) (entity.SmartList, error)
+	GetSmartListByName(name string, userID uint) (entity.SmartList, error)
+	UpdateSmartList(list *entity.SmartList) error
+	DeleteSmartList(listID, userID uint) error
+	CountContacts(userID uint, filters ContactSearchFilters) (int64, error)
+
+	// Task methods
+	CreateTask(task *entity.Task, contactIDs []uint) error
+	GetTaskByID(taskID, userID uint) (entity.Task, error)
+	GetTasks(userID uint, filters TaskFilters) ([]entity.Task, error)
+	UpdateTask(task *entity.Task, contactIDs []uint) error
+	DeleteTask(taskID, userID uint) error
+	UpdateTaskGoogleID(taskID, userID uint, googleEventID string) error
+
+	// Tag methods
+	CreateTag(tag *entity.Tag) error
+	GetTags(userID uint) ([]TagWithCount, error)
+	GetTagByID(tagID, userID uint) (entity.Tag, error)
+	GetTagByName(name string, userID uint) (entity.Tag, error)
+	UpdateTag(tag *entity.Tag) error
+	DeleteTag(tagID, userID uint) error
+	AddTagToContacts(tagID, userID uint, contactIDs []uint) (int64, error)
+	RemoveTagFromContacts(tagID, userID uint, contactIDs []uint) (int64, error)
+
+	// Custom field methods
+	CreateCustomFieldDefinition(def *entity.CustomFieldDefinition) error
+	GetCustomFieldDefinitions(userID uint) ([]entity.CustomFieldDefinition, error)
+	GetCustomFieldDefinitionByID(fieldID, userID uint) (entity.CustomFieldDefinition, error)
+	UpdateCustomFieldDefinition(def *entity.CustomFieldDefinition) error
+	DeleteCustomFieldDefinition(fieldID, userID uint) error
+
+	// Relationship type methods
+	CreateRelationshipType(t *entity.RelationshipType) error
+	GetRelationshipTypes(userID uint) ([]entity.RelationshipType, error)
+	GetRelationshipTypeCounts(userID uint) ([]RelationshipTypeWithCount, error)
+	GetRelationshipTypeByID(typeID, userID uint) (entity.RelationshipType, error)
+	GetRelationshipTypeByName(name string, userID uint) (entity.RelationshipType, error)
+	UpdateRelationshipType(t *entity.RelationshipType, actor string) error
+	DeleteRelationshipType(typeID, userID uint, actor string) error
+
+	// Household methods
+	CreateHousehold(household *entity.Household) error
+	GetHouseholds(userID uint) ([]entity.Household, error)
+	GetHouseholdByID(householdID, userID uint) (entity.Household, error)
+	GetHouseholdByName(name string, userID uint) (entity.Household, error)
+	GetHouseholdsByContact(contactID, userID uint) ([]entity.Household, error)
+	UpdateHousehold(household *entity.Household) error
+	DeleteHousehold(householdID, userID uint) error
+	AddHouseholdMembers(householdID, userID uint, contactIDs []uint) (int64, error)
+	RemoveHouseholdMembers(householdID, userID uint, contactIDs []uint) (int64, error)
+	CreateHouseholdEvent(event *entity.HouseholdEvent) error
+	GetHouseholdEventByID(eventID, userID uint) (entity.HouseholdEvent, error)
+	DeleteHouseholdEvent(eventID, userID uint) error
+	GetHouseholdEvents(userID uint) ([]entity.HouseholdEvent, error)
+
+	// Link methods
+	GetLinkTypes() ([]entity.LinkType, error)
+	GetLinkTypeByKey(key string) (entity.LinkType, error)
+	CreateContactLink(link *entity.ContactLink) error
+	GetContactLinks(contactID, userID uint) ([]LinkedContact, error)
+	DeleteContactLink(linkID, userID uint) error
+
+	// Channel methods
+	SaveContactWithChannels(contact *entity.Contact, actor string) error
+
+	// Duplicate and merge methods
+	FindDuplicateContacts(userID uint) ([]DuplicatePair, error)
+	FindDuplicatesOf(contact entity.Contact) ([]DuplicatePair, error)
+	MergeContacts(survivorID, mergedID, userID uint, choices map[string]entity.MergeSource, actor string) (entity.Contact, error)
+	GetContactMerges(contactID, userID uint) ([]entity.ContactMerge, error)
+
+	// Import methods
+	ImportContacts(userID uint, imports []ContactImport) error
+
+	// Export methods
+	GetAccountData(userID uint) (AccountData, error)
+
 	// MCP specific
 	GetAllContactsWithLimit(userID uint, limit int) ([]entity.Contact, error)
 	SearchContactsAdvanced(userID uint, filters ContactSearchFilters) ([]entity.Contact, error)
-	UpdateContactFields(id string, userID uint, updates map[string]interface{}) error
-	AppendNotes(id string, userID uint, newNotes string) error
+	SearchContactsFullText(userID uint, q string, filters ContactSearchFilters) ([]ContactSearchResult, error)
+	UpdateContactFields(id string, userID uint, updates map[string]interface{}, actor string) error
+
+	// Change history
+	SaveContactWithHistory(contact *entity.Contact, actor string) error
+	GetContactHistory(contactID, userID uint) ([]entity.DetailChanges, error)
+	RestoreContactRevision(contactID, userID uint, revision uuid.UUID, actor string) (entity.Contact, error)
 }
 
 type UserDao interface {
@@ -46,5 +176,8 @@ type ContactSearchFilters struct {
 	VipOnly             *bool
 	Location            *string
 	LastContactedBefore *time.Time
+	Tags                []string          // tag names, contacts must carry all of them
+	CustomFields        map[string]string // custom field values by key
+	IDs                 []uint            // restricts the results to these contacts
 	Limit               int
 }
@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"gorm.io/gorm"
+)
+
+// Link methods
+
+// ErrLinkExists is returned when the same link between two contacts is added twice
+var ErrLinkExists = errors.New("the contacts are already linked this way")
+
+// LinkedContact is a link as seen from one of its contacts
+type LinkedContact struct {
+	LinkID   uint
+	Type     entity.LinkType
+	Label    string // how the link reads from the contact, e.g. "Reports to"
+	Outgoing bool   // whether the contact is the "from" side of the link
+	Contact  entity.Contact
+	Note     string
+}
+
+// GetLinkTypes returns the catalogue of link types in display order
+func (r *ContactRepo) GetLinkTypes() ([]entity.LinkType, error) {
+	var types []entity.LinkType
+	err := r.DB.Order("position, id").Find(&types).Error
+	return types, err
+}
+
+func (r *ContactRepo) GetLinkTypeByKey(key string) (entity.LinkType, error) {
+	var linkType entity.LinkType
+	if err := r.DB.Where("key = ?", key).First(&linkType).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entity.LinkType{}, fmt.Errorf("no link type found with key %q", key)
+		}
+		return entity.LinkType{}, err
+	}
+	return linkType, nil
+}
+
+// CreateContactLink links two contacts of the same user. Symmetric links are
+// stored with the lower contact id first, so they are only stored once.
+func (r *ContactRepo) CreateContactLink(link *entity.ContactLink) error {
+	if link.FromContactID == link.ToContactID {
+		return fmt.Errorf("a contact cannot be linked to itself")
+	}
+
+	var linkType entity.LinkType
+	if err := r.DB.First(&linkType, link.LinkTypeID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("no link type found with id %d", link.LinkTypeID)
+		}
+		return err
+	}
+	if linkType.Symmetric && link.FromContactID > link.ToContactID {
+		link.FromContactID, link.ToContactID = link.ToContactID, link.FromContactID
+	}
+
+	var count int64
+	if err := r.DB.Model(&entity.Contact{}).
+		Where("id IN ? AND user_id = ?", []uint{link.FromContactID, link.ToContactID}, link.UserID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count != 2 {
+		return fmt.Errorf("no contact found with id %d or %d", link.FromContactID, link.ToContactID)
+	}
+
+	var existing int64
+	if err := r.DB.Model(&entity.ContactLink{}).
+		Where("from_contact_id = ? AND to_contact_id = ? AND link_type_id = ?", link.FromContactID, link.ToContactID, link.LinkTypeID).
+		Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return ErrLinkExists
+	}
+
+	if err := r.DB.Omit("FromContact", "ToContact", "LinkType").Create(link).Error; err != nil {
+		return err
+	}
+	link.LinkType = linkType
+	return nil
+}
+
+// GetContactLinks returns the links of a contact in both directions, ordered by
+// link type and then by age
+func (r *ContactRepo) GetContactLinks(contactID, userID uint) ([]LinkedContact, error) {
+	var links []entity.ContactLink
+	err := r.DB.Select("contact_links.*").
+		Preload("LinkType").Preload("FromContact").Preload("ToContact").
+		Joins("JOIN link_types lt ON lt.id = contact_links.link_type_id").
+		Where("contact_links.user_id = ? AND (contact_links.from_contact_id = ? OR contact_links.to_contact_id = ?)", userID, contactID, contactID).
+		Order("lt.position, contact_links.id").
+		Find(&links).Error
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]LinkedContact, 0, len(links))
+	for _, link := range links {
+		outgoing := link.FromContactID == contactID
+		other := link.ToContact
+		if !outgoing {
+			other = link.FromContact
+		}
+		// Links to contacts in the trash are kept for a restore but not shown
+		if other.ID == 0 {
+			continue
+		}
+		res = append(res, LinkedContact{
+			LinkID:   link.ID,
+			Type:     link.LinkType,
+			Label:    link.LinkType.LabelFrom(outgoing),
+			Outgoing: outgoing,
+			Contact:  other,
+			Note:     link.Note,
+		})
+	}
+	return res, nil
+}
+
+func (r *ContactRepo) DeleteContactLink(linkID, userID uint) error {
+	result := r.DB.Where("id = ? AND user_id = ?", linkID, userID).Delete(&entity.ContactLink{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("no link found with id %d", linkID)
+	}
+	return nil
+}
+
+// moveContactLinks re-points the links of a merged contact to the survivor.
+// Links between the two contacts and links the survivor already has are dropped.
+func moveContactLinks(tx *gorm.DB, survivorID, mergedID uint) error {
+	if err := tx.Exec(`DELETE FROM contact_links
+		WHERE (from_contact_id = ? AND to_contact_id = ?) OR (from_contact_id = ? AND to_contact_id = ?)`,
+		survivorID, mergedID, mergedID, survivorID).Error; err != nil {
+		return err
+	}
+
+	var links []entity.ContactLink
+	if err := tx.Preload("LinkType").Where("from_contact_id = ? OR to_contact_id = ?", mergedID, mergedID).Find(&links).Error; err != nil {
+		return err
+	}
+	for _, link := range links {
+		from, to := link.FromContactID, link.ToContactID
+		if from == mergedID {
+			from = survivorID
+		} else {
+			to = survivorID
+		}
+		if link.LinkType.Symmetric && from > to {
+			from, to = to, from
+		}
+		if err := tx.Exec(`UPDATE contact_links SET from_contact_id = ?, to_contact_id = ?, updated_at = NOW()
+			WHERE id = ? AND NOT EXISTS (
+				SELECT 1 FROM contact_links WHERE from_contact_id = ? AND to_contact_id = ? AND link_type_id = ?)`,
+			from, to, link.ID, from, to, link.LinkTypeID).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Exec("DELETE FROM contact_links WHERE from_contact_id = ? OR to_contact_id = ?", mergedID, mergedID).Error
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"gorm.io/gorm"
+)
+
+// Note methods
+
+// noteOrder lists pinned notes first, then the newest first
+const noteOrder = "pinned DESC, created_at DESC, id DESC"
+
+func preloadNotes(query *gorm.DB) *gorm.DB {
+	return query.Preload("Notes", func(db *gorm.DB) *gorm.DB {
+		return db.Order(noteOrder)
+	})
+}
+
+// CreateNote adds a note to a contact of the note's user
+func (r *ContactRepo) CreateNote(note *entity.Note) error {
+	var count int64
+	if err := r.DB.Model(&entity.Contact{}).
+		Where("id = ? AND user_id = ?", note.ContactID, note.UserID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("no contact found with id %d", note.ContactID)
+	}
+	return r.DB.Create(note).Error
+}
+
+// AppendNotes adds a note with the given body to a contact
+func (r *ContactRepo) AppendNotes(id string, userID uint, body string) (entity.Note, error) {
+	var contact entity.Contact
+	if err := r.DB.Select("id").Where("id = ? AND user_id = ?", id, userID).First(&contact).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entity.Note{}, fmt.Errorf("no contact found with id %s", id)
+		}
+		return entity.Note{}, err
+	}
+
+	note := entity.Note{UserID: userID, ContactID: contact.ID, Body: body}
+	if err := r.DB.Create(&note).Error; err != nil {
+		return entity.Note{}, err
+	}
+	return note, nil
+}
+
+func (r *ContactRepo) GetNoteByID(noteID, userID uint) (entity.Note, error) {
+	var note entity.Note
+	err := r.DB.Where("id = ? AND user_id = ?", noteID, userID).First(&note).Error
+	return note, err
+}
+
+func (r *ContactRepo) GetNotesByContact(contactID, userID uint) ([]entity.Note, error) {
+	var notes []entity.Note
+	err := r.DB.Where("contact_id = ? AND user_id = ?", contactID, userID).
+		Order(noteOrder).
+		Find(&notes).Error
+	return notes, err
+}
+
+// UpdateNote changes the body of a note, marking it as edited when the body
+// differs, and its pinned flag
+func (r *ContactRepo) UpdateNote(note *entity.Note) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var existing entity.Note
+		if err := tx.Where("id = ? AND user_id = ?", note.ID, note.UserID).First(&existing).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("no note found with id %d", note.ID)
+			}
+			return err
+		}
+
+		updates := map[string]interface{}{"pinned": note.Pinned}
+		if note.Body != existing.Body {
+			now := time.Now()
+			note.EditedAt = &now
+			updates["body"] = note.Body
+			updates["edited_at"] = now
+		}
+		return tx.Model(note).Updates(updates).Error
+	})
+}
+
+func (r *ContactRepo) DeleteNote(noteID, userID uint) error {
+	result := r.DB.Where("id = ? AND user_id = ?", noteID, userID).Delete(&entity.Note{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("no note found with id %d", noteID)
+	}
+	return nil
+}
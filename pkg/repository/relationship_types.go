@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"gorm.io/gorm"
+)
+
+// Relationship type methods
+
+// RelationshipTypeWithCount is a relationship type together with the number
+// of contacts having it
+type RelationshipTypeWithCount struct {
+	entity.RelationshipType
+	ContactCount int
+}
+
+// defaultRelationshipTypes are the relationship types a new user starts with
+func defaultRelationshipTypes(userID uint) []entity.RelationshipType {
+	types := make([]entity.RelationshipType, 0, len(entity.DefaultRelationshipTypes))
+	for i, name := range entity.DefaultRelationshipTypes {
+		types = append(types, entity.RelationshipType{UserID: userID, Name: name, Position: i})
+	}
+	return types
+}
+
+func (r *ContactRepo) CreateRelationshipType(t *entity.RelationshipType) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		// New types go to the end of the list
+		var position int
+		if err := tx.Model(&entity.RelationshipType{}).
+			Where("user_id = ?", t.UserID).
+			Select("COALESCE(MAX(position), -1) + 1").
+			Scan(&position).Error; err != nil {
+			return err
+		}
+		t.Position = position
+		return tx.Create(t).Error
+	})
+}
+
+// GetRelationshipTypes returns the user's relationship types in display order
+func (r *ContactRepo) GetRelationshipTypes(userID uint) ([]entity.RelationshipType, error) {
+	var types []entity.RelationshipType
+	err := r.DB.Where("user_id = ?", userID).Order("position, id").Find(&types).Error
+	return types, err
+}
+
+// GetRelationshipTypeCounts returns the user's relationship types in display
+// order, with their contact counts
+func (r *ContactRepo) GetRelationshipTypeCounts(userID uint) ([]RelationshipTypeWithCount, error) {
+	var types []RelationshipTypeWithCount
+	err := r.DB.Model(&entity.RelationshipType{}).
+		Select("relationship_types.*, (SELECT COUNT(*) FROM contacts c WHERE c.user_id = relationship_types.user_id AND c.relationship = relationship_types.name AND c.deleted_at IS NULL) AS contact_count").
+		Where("relationship_types.user_id = ?", userID).
+		Order("relationship_types.position, relationship_types.id").
+		Scan(&types).Error
+	return types, err
+}
+
+func (r *ContactRepo) GetRelationshipTypeByID(typeID, userID uint) (entity.RelationshipType, error) {
+	var t entity.RelationshipType
+	err := r.DB.Where("id = ? AND user_id = ?", typeID, userID).First(&t).Error
+	return t, err
+}
+
+// GetRelationshipTypeByName finds a relationship type of the user by name, ignoring case
+func (r *ContactRepo) GetRelationshipTypeByName(name string, userID uint) (entity.RelationshipType, error) {
+	var t entity.RelationshipType
+	err := r.DB.Where("LOWER(name) = LOWER(?) AND user_id = ?", strings.TrimSpace(name), userID).First(&t).Error
+	return t, err
+}
+
+// UpdateRelationshipType saves the name and position of a relationship type.
+// A new name is given to every contact of the type, including those in the
+// trash, recorded in their history, and to the smart lists filtering on it.
+func (r *ContactRepo) UpdateRelationshipType(t *entity.RelationshipType, actor string) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var old entity.RelationshipType
+		if err := tx.Where("id = ? AND user_id = ?", t.ID, t.UserID).First(&old).Error; err != nil {
+			return fmt.Errorf("no relationship type found with id %d", t.ID)
+		}
+		if err := tx.Model(&old).Updates(map[string]interface{}{"name": t.Name, "position": t.Position}).Error; err != nil {
+			return err
+		}
+		if old.Name == t.Name {
+			return nil
+		}
+
+		if err := setContactsRelationship(tx, t.UserID, old.Name, entity.Relation(t.Name), actor); err != nil {
+			return err
+		}
+		return tx.Exec("UPDATE smart_lists SET filters = jsonb_set(filters, '{relationship}', to_jsonb(?::TEXT)) WHERE user_id = ? AND filters->>'relationship' = ?",
+			t.Name, t.UserID, old.Name).Error
+	})
+}
+
+// DeleteRelationshipType deletes a relationship type and clears it from every
+// contact having it, recorded in their history. Smart lists filtering on it
+// are kept and stop matching, like lists on a deleted custom field.
+func (r *ContactRepo) DeleteRelationshipType(typeID, userID uint, actor string) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var t entity.RelationshipType
+		if err := tx.Where("id = ? AND user_id = ?", typeID, userID).First(&t).Error; err != nil {
+			return fmt.Errorf("no relationship type found with id %d", typeID)
+		}
+		if err := tx.Delete(&t).Error; err != nil {
+			return err
+		}
+		return setContactsRelationship(tx, userID, t.Name, "", actor)
+	})
+}
+
+// setContactsRelationship gives every contact of the user with the
+// relationship from, including those in the trash, the relationship to, or
+// none when it is empty, and records the change in their history
+func setContactsRelationship(tx *gorm.DB, userID uint, from string, to entity.Relation, actor string) error {
+	var contacts []entity.Contact
+	if err := tx.Unscoped().Where("user_id = ? AND relationship = ?", userID, from).Find(&contacts).Error; err != nil {
+		return err
+	}
+	if len(contacts) == 0 {
+		return nil
+	}
+	if err := tx.Unscoped().Model(&entity.Contact{}).
+		Where("id IN ? AND user_id = ?", contactIDsOf(contacts), userID).
+		UpdateColumn("relationship", to).Error; err != nil {
+		return err
+	}
+
+	var changes []entity.DetailChanges
+	for i := range contacts {
+		updated := contacts[i]
+		updated.Relationship = to
+		changes = append(changes, diffContacts(&contacts[i], &updated, actor)...)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return tx.Create(&changes).Error
+}
@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/La002/personal-crm/pkg/entity"
+)
+
+// Markers wrapped around matched words in snippets. They are plain text so the
+// snippet can be escaped before the markers are turned into highlighting.
+const (
+	HighlightStart = "\x02"
+	HighlightStop  = "\x03"
+)
+
+// ContactSearchResult is a contact matched by a full-text search
+type ContactSearchResult struct {
+	Contact entity.Contact
+	Rank    float64
+	// Snippet is an excerpt of the matched text with HighlightStart/HighlightStop
+	// around the matched words. It is empty when only the name matched.
+	Snippet string
+}
+
+var searchWord = regexp.MustCompile(`[\pL\pN]+`)
+
+// prefixTSQuery turns free text into a tsquery matching every word as a prefix,
+// e.g. "acme eng" becomes "acme:* & eng:*". It returns "" when q has no words.
+func prefixTSQuery(q string) string {
+	words := searchWord.FindAllString(q, -1)
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+// SearchContactsFullText ranks the user's contacts against q. A contact matches
+// when its name, company, industry or location contain every word of q (as a
+// prefix), when one of its notes, event titles or postal addresses does, when
+// one of its emails or phone numbers contains q, or when its name is close to
+// q, which catches typos. The other filters narrow the results as in
+// SearchContactsAdvanced.
+func (r *ContactRepo) SearchContactsFullText(userID uint, q string, filters ContactSearchFilters) ([]ContactSearchResult, error) {
+	tsquery := prefixTSQuery(q)
+	if tsquery == "" {
+		return nil, nil
+	}
+
+	// Phone numbers also match on their digits, so "555 0100" finds "+1 (555) 010-0100"
+	digits := nonDigits.ReplaceAllString(q, "")
+	if len(digits) < minSearchDigits {
+		digits = ""
+	} else if len(digits) > 10 {
+		digits = digits[len(digits)-10:]
+	}
+	params := map[string]interface{}{"tsquery": tsquery, "q": q, "like": "%" + escapeLike(q) + "%", "digits": digits}
+
+	query := r.DB.Table("contacts").
+		Select(`contacts.id,
+			ts_rank(contacts.search_vector, to_tsquery('english', @tsquery))
+				+ similarity(contacts.name, @q)
+				+ CASE WHEN EXISTS (`+noteMatch+`) THEN 0.1 ELSE 0 END
+				+ CASE WHEN EXISTS (`+eventMatch+`) THEN 0.1 ELSE 0 END
+				+ CASE WHEN EXISTS (`+channelMatch+`) OR EXISTS (`+addressMatch+`) THEN 0.2 ELSE 0 END AS rank,
+			CASE WHEN contacts.search_vector @@ to_tsquery('english', @tsquery) THEN ts_headline('english',
+				concat_ws(' · ', contacts.company, contacts.industry, contacts.location),
+				to_tsquery('english', @tsquery),
+				'StartSel=`+HighlightStart+`, StopSel=`+HighlightStop+`, MaxWords=20, MinWords=5, MaxFragments=2, FragmentDelimiter=" … "')
+			WHEN EXISTS (`+noteMatch+`) THEN (SELECT ts_headline('english', string_agg(n.body, ' · '), to_tsquery('english', @tsquery),
+				'StartSel=`+HighlightStart+`, StopSel=`+HighlightStop+`, MaxWords=20, MinWords=5, MaxFragments=2, FragmentDelimiter=" … "')
+				FROM notes n
+				WHERE n.contact_id = contacts.id AND n.deleted_at IS NULL
+					AND to_tsvector('english', n.body) @@ to_tsquery('english', @tsquery))
+			ELSE (SELECT ts_headline('english', string_agg(e.title, ' · '), to_tsquery('english', @tsquery),
+				'StartSel=`+HighlightStart+`, StopSel=`+HighlightStop+`, HighlightAll=true')
+				FROM events e
+				WHERE e.contact_id = contacts.id AND e.deleted_at IS NULL
+					AND to_tsvector('english', e.title) @@ to_tsquery('english', @tsquery))
+			END AS snippet`,
+			params).
+		Where("contacts.user_id = ? AND contacts.deleted_at IS NULL", userID).
+		Where(`contacts.search_vector @@ to_tsquery('english', @tsquery)
+			OR @q <% contacts.name
+			OR EXISTS (`+noteMatch+`)
+			OR EXISTS (`+eventMatch+`)
+			OR EXISTS (`+channelMatch+`)
+			OR EXISTS (`+addressMatch+`)`,
+			params)
+
+	query, err := r.applyContactFilters(query, userID, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	query = query.Order("rank DESC").Order("contacts.name")
+	if filters.Limit > 0 {
+		query = query.Limit(filters.Limit)
+	}
+
+	var matches []struct {
+		ID      uint
+		Rank    float64
+		Snippet *string
+	}
+	if err := query.Scan(&matches).Error; err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return []ContactSearchResult{}, nil
+	}
+
+	ids := make([]uint, 0, len(matches))
+	for _, match := range matches {
+		ids = append(ids, match.ID)
+	}
+
+	var contacts []entity.Contact
+	if err := preloadNotes(preloadChannels(preloadTags(r.DB))).Where("id IN ?", ids).Find(&contacts).Error; err != nil {
+		return nil, err
+	}
+	byID := map[uint]entity.Contact{}
+	for _, contact := range contacts {
+		byID[contact.ID] = contact
+	}
+
+	// Keep the ranked order of the first query
+	results := make([]ContactSearchResult, 0, len(matches))
+	for _, match := range matches {
+		contact, ok := byID[match.ID]
+		if !ok {
+			continue
+		}
+		result := ContactSearchResult{Contact: contact, Rank: match.Rank}
+		if match.Snippet != nil {
+			result.Snippet = *match.Snippet
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// noteMatch is the condition that one of the contact's notes has every word of
+// the query. The expression matches idx_notes_body_search.
+const noteMatch = `SELECT 1 FROM notes n
+	WHERE n.contact_id = contacts.id AND n.deleted_at IS NULL
+		AND to_tsvector('english', n.body) @@ to_tsquery('english', @tsquery)`
+
+// eventMatch is the condition that one of the contact's events has a matching title
+const eventMatch = `SELECT 1 FROM events e
+	WHERE e.contact_id = contacts.id AND e.deleted_at IS NULL
+		AND to_tsvector('english', e.title) @@ to_tsquery('english', @tsquery)`
+
+// channelMatch is the condition that one of the contact's emails or phone numbers contains the query
+const channelMatch = `SELECT 1 FROM contact_channels cc
+	WHERE cc.contact_id = contacts.id
+		AND (cc.value ILIKE @like ESCAPE '\'
+			OR (cc.kind = 'phone' AND @digits <> '' AND cc.normalized LIKE '%' || @digits || '%'))`
+
+// addressMatch is the condition that one of the contact's postal addresses has
+// every word of the query. The expression matches idx_contact_addresses_search.
+const addressMatch = `SELECT 1 FROM contact_addresses ca
+	WHERE ca.contact_id = contacts.id
+		AND to_tsvector('simple', ca.street || ' ' || ca.city || ' ' || ca.region || ' ' || ca.postal_code || ' ' || ca.country)
+			@@ to_tsquery('simple', @tsquery)`
+
+// minSearchDigits is the number of digits a query needs to be matched against phone numbers
+const minSearchDigits = 4
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/entity"
+)
+
+// Smart list methods
+
+func (r *ContactRepo) CreateSmartList(list *entity.SmartList) error {
+	return r.DB.Create(list).Error
+}
+
+// GetSmartLists returns the smart lists of a user, pinned ones first, by name
+func (r *ContactRepo) GetSmartLists(userID uint) ([]entity.SmartList, error) {
+	var lists []entity.SmartList
+	err := r.DB.Where("user_id = ?", userID).Order("pinned DESC, LOWER(name), id").Find(&lists).Error
+	return lists, err
+}
+
+func (r *ContactRepo) GetSmartListByID(listID, userID uint) (entity.SmartList, error) {
+	var list entity.SmartList
+	err := r.DB.Where("id = ? AND user_id = ?", listID, userID).First(&list).Error
+	return list, err
+}
+
+func (r *ContactRepo) GetSmartListByName(name string, userID uint) (entity.SmartList, error) {
+	var list entity.SmartList
+	err := r.DB.Where("LOWER(name) = LOWER(?) AND user_id = ?", strings.TrimSpace(name), userID).First(&list).Error
+	return list, err
+}
+
+func (r *ContactRepo) UpdateSmartList(list *entity.SmartList) error {
+	result := r.DB.Model(list).
+		Where("user_id = ?", list.UserID).
+		Updates(map[string]interface{}{"name": list.Name, "pinned": list.Pinned, "filters": list.Filters})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("no smart list found with id %d", list.ID)
+	}
+	return nil
+}
+
+func (r *ContactRepo) DeleteSmartList(listID, userID uint) error {
+	result := r.DB.Where("id = ? AND user_id = ?", listID, userID).Delete(&entity.SmartList{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("no smart list found with id %d", listID)
+	}
+	return nil
+}
+
+// CountContacts returns the number of contacts matching filters, ignoring
+// their limit
+func (r *ContactRepo) CountContacts(userID uint, filters ContactSearchFilters) (int64, error) {
+	query, err := r.applyContactFilters(r.DB.Model(&entity.Contact{}).Where("user_id = ?", userID), userID, filters)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = query.Count(&count).Error
+	return count, err
+}
+
+// SmartListFilters turns the conditions of a smart list into search filters
+// for the given day
+func SmartListFilters(filters entity.SmartListFilters, today time.Time) ContactSearchFilters {
+	res := ContactSearchFilters{
+		VipOnly:             filters.Vip,
+		LastContactedBefore: filters.NotContactedSince(today),
+		Tags:                filters.Tags,
+		CustomFields:        filters.CustomFields,
+	}
+	if filters.Relationship != "" {
+		res.Relationship = &filters.Relationship
+	}
+	if filters.Location != "" {
+		res.Location = &filters.Location
+	}
+	return res
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"github.com/La002/personal-crm/pkg/entity"
+	"gorm.io/gorm"
+)
+
+// Strength methods
+
+// ContactStrength is the strength computed for a contact
+type ContactStrength struct {
+	ContactID     uint
+	Score         int
+	DriftingSince entity.Date
+}
+
+// GetStrengthUserIDs returns the users that have contacts to score
+func (r *ContactRepo) GetStrengthUserIDs() ([]uint, error) {
+	var userIDs []uint
+	err := r.DB.Model(&entity.Contact{}).Distinct("user_id").Order("user_id").Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
+
+// GetStrengthInputs returns the contacts of a user with their tags, and by
+// contact id the interaction and event counts their strength is computed from
+func (r *ContactRepo) GetStrengthInputs(userID uint, today entity.Date) ([]entity.Contact, map[uint]entity.StrengthInputs, error) {
+	var contacts []entity.Contact
+	if err := preloadTags(r.DB).Where("user_id = ?", userID).Order("id").Find(&contacts).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var interactions []struct {
+		ContactID          uint
+		RecentInteractions int
+		Interactions       int
+		FirstInteraction   entity.Date
+	}
+	if err := r.DB.Raw(`
+		SELECT ic.contact_id,
+			COUNT(*) FILTER (WHERE i.occurred_on >= ?) AS recent_interactions,
+			COUNT(*) AS interactions,
+			MIN(i.occurred_on) AS first_interaction
+		FROM interactions i
+		JOIN interaction_contacts ic ON ic.interaction_id = i.id
+		WHERE i.user_id = ? AND i.deleted_at IS NULL AND i.occurred_on >= ? AND i.occurred_on <= ?
+		GROUP BY ic.contact_id`,
+		today.AddDays(-entity.StrengthWindowDays), userID, today.AddDays(-entity.RhythmWindowDays), today).
+		Scan(&interactions).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var events []struct {
+		ContactID uint
+		Events    int
+	}
+	if err := r.DB.Model(&entity.Event{}).
+		Select("contact_id, COUNT(*) AS events").
+		Where("user_id = ?", userID).
+		Group("contact_id").
+		Scan(&events).Error; err != nil {
+		return nil, nil, err
+	}
+
+	inputs := map[uint]entity.StrengthInputs{}
+	for _, row := range interactions {
+		inputs[row.ContactID] = entity.StrengthInputs{
+			RecentInteractions: row.RecentInteractions,
+			Interactions:       row.Interactions,
+			FirstInteraction:   row.FirstInteraction,
+		}
+	}
+	for _, row := range events {
+		in := inputs[row.ContactID]
+		in.Events = row.Events
+		inputs[row.ContactID] = in
+	}
+	return contacts, inputs, nil
+}
+
+// UpdateContactStrengths stores the strength of contacts of a user. It leaves
+// updated_at alone, a new score is not a change of the contact.
+func (r *ContactRepo) UpdateContactStrengths(userID uint, strengths []ContactStrength) error {
+	if len(strengths) == 0 {
+		return nil
+	}
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		for _, s := range strengths {
+			if err := tx.Model(&entity.Contact{}).
+				Where("id = ? AND user_id = ?", s.ContactID, userID).
+				UpdateColumns(map[string]interface{}{
+					"strength_score": s.Score,
+					"drifting_since": s.DriftingSince,
+				}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetDriftingContacts returns the contacts of a user whose relationship is
+// drifting away, the weakest first, at most limit of them when limit > 0
+func (r *ContactRepo) GetDriftingContacts(userID uint, limit int) ([]entity.Contact, error) {
+	var contacts []entity.Contact
+	query := preloadTags(r.DB).
+		Where("user_id = ? AND drifting_since IS NOT NULL", userID).
+		Order("strength_score, drifting_since, id")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&contacts).Error
+	return contacts, err
+}
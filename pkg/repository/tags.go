@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"gorm.io/gorm"
+)
+
+// Tag methods
+
+// TagWithCount is a tag together with the number of contacts carrying it
+type TagWithCount struct {
+	entity.Tag
+	ContactCount int
+}
+
+func (r *ContactRepo) CreateTag(tag *entity.Tag) error {
+	return r.DB.Create(tag).Error
+}
+
+// GetTags returns the user's tags ordered by name, with their contact counts
+func (r *ContactRepo) GetTags(userID uint) ([]TagWithCount, error) {
+	var tags []TagWithCount
+	err := r.DB.Model(&entity.Tag{}).
+		Select("tags.*, (SELECT COUNT(*) FROM contact_tags ct JOIN contacts c ON c.id = ct.contact_id WHERE ct.tag_id = tags.id AND c.deleted_at IS NULL) AS contact_count").
+		Where("tags.user_id = ?", userID).
+		Order("LOWER(tags.name)").
+		Scan(&tags).Error
+	return tags, err
+}
+
+func (r *ContactRepo) GetTagByID(tagID, userID uint) (entity.Tag, error) {
+	var tag entity.Tag
+	err := r.DB.Where("id = ? AND user_id = ?", tagID, userID).First(&tag).Error
+	return tag, err
+}
+
+// GetTagByName looks a tag up by name, ignoring case
+func (r *ContactRepo) GetTagByName(name string, userID uint) (entity.Tag, error) {
+	var tag entity.Tag
+	err := r.DB.Where("LOWER(name) = LOWER(?) AND user_id = ?", strings.TrimSpace(name), userID).First(&tag).Error
+	return tag, err
+}
+
+func (r *ContactRepo) UpdateTag(tag *entity.Tag) error {
+	return r.DB.Model(tag).
+		Where("user_id = ?", tag.UserID).
+		Updates(map[string]interface{}{"name": tag.Name, "color": tag.Color, "cadence_days": tag.CadenceDays}).Error
+}
+
+// DeleteTag deletes a tag and detaches it from every contact
+func (r *ContactRepo) DeleteTag(tagID, userID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND user_id = ?", tagID, userID).Delete(&entity.Tag{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("no tag found with id %d", tagID)
+		}
+		return tx.Exec("DELETE FROM contact_tags WHERE tag_id = ?", tagID).Error
+	})
+}
+
+// AddTagToContacts attaches a tag to the given contacts. Contacts that already
+// carry the tag or belong to another user are skipped. It returns the number
+// of contacts that were tagged.
+func (r *ContactRepo) AddTagToContacts(tagID, userID uint, contactIDs []uint) (int64, error) {
+	if len(contactIDs) == 0 {
+		return 0, nil
+	}
+	if _, err := r.GetTagByID(tagID, userID); err != nil {
+		return 0, err
+	}
+
+	result := r.DB.Exec(`
+		INSERT INTO contact_tags (contact_id, tag_id)
+		SELECT id, ? FROM contacts
+		WHERE id IN ? AND user_id = ? AND deleted_at IS NULL
+		ON CONFLICT DO NOTHING`, tagID, contactIDs, userID)
+	return result.RowsAffected, result.Error
+}
+
+// RemoveTagFromContacts detaches a tag from the given contacts and returns
+// the number of contacts it was removed from
+func (r *ContactRepo) RemoveTagFromContacts(tagID, userID uint, contactIDs []uint) (int64, error) {
+	if len(contactIDs) == 0 {
+		return 0, nil
+	}
+	if _, err := r.GetTagByID(tagID, userID); err != nil {
+		return 0, err
+	}
+
+	result := r.DB.Exec("DELETE FROM contact_tags WHERE tag_id = ? AND contact_id IN ?", tagID, contactIDs)
+	return result.RowsAffected, result.Error
+}
+
+// withTags scopes a contact query to contacts carrying every one of the named tags
+func withTags(query *gorm.DB, names []string) *gorm.DB {
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		query = query.Where(`EXISTS (
+			SELECT 1 FROM contact_tags ct
+			JOIN tags t ON t.id = ct.tag_id
+			WHERE ct.contact_id = contacts.id AND t.deleted_at IS NULL AND LOWER(t.name) = LOWER(?))`, name)
+	}
+	return query
+}
+
+// preloadTags loads the tags of each contact, ordered by name
+func preloadTags(query *gorm.DB) *gorm.DB {
+	return query.Preload("Tags", func(db *gorm.DB) *gorm.DB {
+		return db.Order("LOWER(tags.name)")
+	})
+}
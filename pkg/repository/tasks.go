@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"gorm.io/gorm"
+)
+
+// Task methods
+
+// TaskFilters narrows down GetTasks. Zero values do not filter.
+type TaskFilters struct {
+	Status    entity.TaskStatus
+	ContactID uint        // tasks linked to this contact
+	DueBy     entity.Date // tasks due on or before this date
+}
+
+// taskOrder lists open tasks first, by due date with undated ones last and
+// then by priority, followed by done tasks, the most recently completed first
+const taskOrder = `tasks.status = 'done',
+	tasks.due_on ASC NULLS LAST,
+	CASE tasks.priority WHEN 'high' THEN 0 WHEN 'normal' THEN 1 ELSE 2 END,
+	tasks.completed_at DESC,
+	tasks.id`
+
+// CreateTask stores a task linked to the given contacts of its user
+func (r *ContactRepo) CreateTask(task *entity.Task, contactIDs []uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		contacts, err := taskContacts(tx, task.UserID, contactIDs)
+		if err != nil {
+			return err
+		}
+		task.Contacts = contacts
+		return tx.Omit("Contacts.*").Create(task).Error
+	})
+}
+
+func (r *ContactRepo) GetTaskByID(taskID, userID uint) (entity.Task, error) {
+	var task entity.Task
+	err := r.DB.Preload("Contacts", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "name")
+	}).
+		Where("id = ? AND user_id = ?", taskID, userID).
+		First(&task).Error
+	return task, err
+}
+
+func (r *ContactRepo) GetTasks(userID uint, filters TaskFilters) ([]entity.Task, error) {
+	query := r.DB.Preload("Contacts", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "name")
+	}).
+		Where("tasks.user_id = ?", userID)
+
+	if filters.Status != "" {
+		query = query.Where("tasks.status = ?", filters.Status)
+	}
+	if filters.ContactID != 0 {
+		query = query.Where("EXISTS (SELECT 1 FROM task_contacts tc WHERE tc.task_id = tasks.id AND tc.contact_id = ?)", filters.ContactID)
+	}
+	if !filters.DueBy.IsZero() {
+		query = query.Where("tasks.due_on <= ?", filters.DueBy)
+	}
+
+	var tasks []entity.Task
+	err := query.Order(taskOrder).Find(&tasks).Error
+	return tasks, err
+}
+
+// UpdateTask saves a task. Its contacts are replaced when contactIDs is not nil.
+func (r *ContactRepo) UpdateTask(task *entity.Task, contactIDs []uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Contacts").Save(task).Error; err != nil {
+			return err
+		}
+		if contactIDs == nil {
+			return nil
+		}
+		contacts, err := taskContacts(tx, task.UserID, contactIDs)
+		if err != nil {
+			return err
+		}
+		task.Contacts = contacts
+		return tx.Model(task).Omit("Contacts.*").Association("Contacts").Replace(contacts)
+	})
+}
+
+func (r *ContactRepo) DeleteTask(taskID, userID uint) error {
+	result := r.DB.Where("id = ? AND user_id = ?", taskID, userID).Delete(&entity.Task{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("no task found with id %d", taskID)
+	}
+	return nil
+}
+
+func (r *ContactRepo) UpdateTaskGoogleID(taskID, userID uint, googleEventID string) error {
+	return r.DB.Model(&entity.Task{}).
+		Where("id = ? AND user_id = ?", taskID, userID).
+		Update("google_calendar_event_id", googleEventID).Error
+}
+
+// taskContacts loads the contacts a task is linked to, checking they all belong to the user
+func taskContacts(tx *gorm.DB, userID uint, contactIDs []uint) ([]entity.Contact, error) {
+	var contacts []entity.Contact
+	if err := tx.Select("id", "name").
+		Where("id IN ? AND user_id = ?", contactIDs, userID).
+		Find(&contacts).Error; err != nil {
+		return nil, err
+	}
+	if len(contacts) == 0 || len(contacts) != len(uniqueIDs(contactIDs)) {
+		return nil, fmt.Errorf("no contact found for task")
+	}
+	return contacts, nil
+}
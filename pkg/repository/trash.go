@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/La002/personal-crm/pkg/entity"
+	"gorm.io/gorm"
+)
+
+// Trash methods
+
+// PurgedContact is a contact that was permanently deleted, with what it left
+// outside the database: its Google Calendar events and its stored files
+type PurgedContact struct {
+	ID               uint
+	UserID           uint
+	Name             string
+	CalendarEventIDs []string
+	StorageKeys      []string
+}
+
+// inTrash scopes a contact query to deleted contacts. Contacts merged into
+// another one are deleted too but are not in the trash, they are the record
+// of the merge and go away with the contact they were merged into.
+func inTrash(db *gorm.DB) *gorm.DB {
+	return db.Unscoped().Where("contacts.deleted_at IS NOT NULL AND NOT EXISTS (SELECT 1 FROM contact_merges cm WHERE cm.merged_id = contacts.id)")
+}
+
+// GetDeletedContacts returns the contacts in the trash of a user, the most
+// recently deleted first
+func (r *ContactRepo) GetDeletedContacts(userID uint) ([]entity.Contact, error) {
+	var contacts []entity.Contact
+	err := inTrash(r.DB).Where("user_id = ?", userID).Order("deleted_at DESC, id").Find(&contacts).Error
+	return contacts, err
+}
+
+// RestoreContacts takes contacts out of the trash and returns how many were
+// restored. Their links, notes, events and other records were kept and come
+// back with them.
+func (r *ContactRepo) RestoreContacts(contactIDs []uint, userID uint) (int64, error) {
+	if len(contactIDs) == 0 {
+		return 0, nil
+	}
+	result := inTrash(r.DB.Model(&entity.Contact{})).
+		Where("id IN ? AND user_id = ?", contactIDs, userID).
+		Update("deleted_at", nil)
+	return result.RowsAffected, result.Error
+}
+
+// PurgeContacts permanently deletes contacts of a user that are in the trash,
+// with everything recorded about them. Tasks and interactions that were only
+// about the purged contacts are deleted as well. Ids of contacts that are not
+// in the trash are ignored.
+func (r *ContactRepo) PurgeContacts(contactIDs []uint, userID uint) ([]PurgedContact, error) {
+	var purged []PurgedContact
+	if len(contactIDs) == 0 {
+		return purged, nil
+	}
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var contacts []entity.Contact
+		if err := inTrash(tx).Where("id IN ? AND user_id = ?", contactIDs, userID).Find(&contacts).Error; err != nil {
+			return err
+		}
+		if len(contacts) == 0 {
+			return nil
+		}
+		ids := contactIDsOf(contacts)
+
+		byID := make(map[uint]int, len(contacts))
+		for _, contact := range contacts {
+			byID[contact.ID] = len(purged)
+			p := PurgedContact{ID: contact.ID, UserID: contact.UserID, Name: contact.Name}
+			if contact.GoogleCalendarEventID != "" {
+				p.CalendarEventIDs = append(p.CalendarEventIDs, contact.GoogleCalendarEventID)
+			}
+			purged = append(purged, p)
+		}
+
+		// Events deleted one by one were already removed from the calendar
+		var events []entity.Event
+		if err := tx.Where("contact_id IN ? AND google_calendar_event_id <> ''", ids).Find(&events).Error; err != nil {
+			return err
+		}
+		for _, event := range events {
+			p := &purged[byID[event.ContactID]]
+			p.CalendarEventIDs = append(p.CalendarEventIDs, event.GoogleCalendarEventID)
+		}
+
+		var attachments []entity.Attachment
+		if err := tx.Unscoped().Where("contact_id IN ?", ids).Find(&attachments).Error; err != nil {
+			return err
+		}
+		for _, attachment := range attachments {
+			p := &purged[byID[attachment.ContactID]]
+			p.StorageKeys = append(p.StorageKeys, attachment.StorageKey)
+			if attachment.ThumbnailKey != "" {
+				p.StorageKeys = append(p.StorageKeys, attachment.ThumbnailKey)
+			}
+		}
+
+		// Tasks about nobody else go with the contacts. Their calendar events
+		// are listed with the first of their contacts.
+		var tasks []struct {
+			ID                    uint
+			GoogleCalendarEventID string
+			ContactID             uint
+		}
+		if err := tx.Raw(`SELECT t.id, COALESCE(t.google_calendar_event_id, '') AS google_calendar_event_id, MIN(tc.contact_id) AS contact_id
+			FROM tasks t JOIN task_contacts tc ON tc.task_id = t.id
+			WHERE t.user_id = ? AND tc.contact_id IN ?
+				AND NOT EXISTS (SELECT 1 FROM task_contacts o WHERE o.task_id = t.id AND o.contact_id NOT IN ?)
+			GROUP BY t.id`, userID, ids, ids).Scan(&tasks).Error; err != nil {
+			return err
+		}
+		taskIDs := make([]uint, 0, len(tasks))
+		for _, task := range tasks {
+			taskIDs = append(taskIDs, task.ID)
+			if task.GoogleCalendarEventID != "" {
+				p := &purged[byID[task.ContactID]]
+				p.CalendarEventIDs = append(p.CalendarEventIDs, task.GoogleCalendarEventID)
+			}
+		}
+		if len(taskIDs) > 0 {
+			if err := tx.Unscoped().Where("id IN ?", taskIDs).Delete(&entity.Task{}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Exec(`DELETE FROM interactions i
+			WHERE i.user_id = ?
+				AND EXISTS (SELECT 1 FROM interaction_contacts ic WHERE ic.interaction_id = i.id AND ic.contact_id IN ?)
+				AND NOT EXISTS (SELECT 1 FROM interaction_contacts o WHERE o.interaction_id = i.id AND o.contact_id NOT IN ?)`,
+			userID, ids, ids).Error; err != nil {
+			return err
+		}
+
+		// Contacts merged into the purged ones only remain as the record of the merge
+		if err := tx.Exec("DELETE FROM contacts WHERE id IN (SELECT merged_id FROM contact_merges WHERE survivor_id IN ?)", ids).Error; err != nil {
+			return err
+		}
+
+		// Events, notes, channels, links, attachments and history cascade
+		return tx.Unscoped().Where("id IN ? AND user_id = ?", ids, userID).Delete(&entity.Contact{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return purged, nil
+}
+
+// GetExpiredContacts returns the id and user of every contact, of any user,
+// that was put in the trash before deletedBefore
+func (r *ContactRepo) GetExpiredContacts(deletedBefore time.Time) ([]entity.Contact, error) {
+	var contacts []entity.Contact
+	err := inTrash(r.DB).Select("id", "user_id").Where("deleted_at < ?", deletedBefore).Order("user_id, id").Find(&contacts).Error
+	return contacts, err
+}
+
+// PurgeDeletedEvents permanently deletes events, of any user, that were
+// deleted before deletedBefore and returns how many were purged
+func (r *ContactRepo) PurgeDeletedEvents(deletedBefore time.Time) (int64, error) {
+	result := r.DB.Unscoped().Where("deleted_at < ?", deletedBefore).Delete(&entity.Event{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to purge deleted events: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
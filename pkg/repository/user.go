@@ -19,11 +19,15 @@ func NewUserRepo(config *config.Configuration, l *logger.Logger) *UserRepo {
 	}
 }
 
+// CreateUser creates a user with the default relationship types
 func (r *UserRepo) CreateUser(user *entity.User) error {
-	if err := r.DB.Create(user).Error; err != nil {
-		return err
-	}
-	return nil
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		types := defaultRelationshipTypes(user.ID)
+		return tx.Create(&types).Error
+	})
 }
 
 func (r *UserRepo) GetUserByGoogleID(id string) (entity.User, error) {
@@ -0,0 +1,43 @@
+// Package storage keeps the files of attachments and contact photos.
+//
+// Files are addressed by keys such as "users/1/contacts/2/<uuid>" chosen by
+// the caller. The local backend writes them below a directory, the S3 backend
+// to a bucket of any S3-compatible service (AWS S3, MinIO, ...).
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/La002/personal-crm/config"
+)
+
+// ErrNotFound is returned when no file is stored under a key
+var ErrNotFound = errors.New("file not found")
+
+// Storage stores files by key
+type Storage interface {
+	// Put stores data under key, replacing any file with the same key
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	// Get opens the file stored under key, the caller closes it
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the file stored under key. Deleting a missing file is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// New returns the backend selected in the configuration
+func New(cfg config.Storage) (Storage, error) {
+	switch cfg.Backend {
+	case "", "local":
+		path := cfg.LocalPath
+		if path == "" {
+			path = "data/attachments"
+		}
+		return NewLocal(path)
+	case "s3":
+		return NewS3(cfg.S3)
+	}
+	return nil, fmt.Errorf("unknown storage backend %q, use local or s3", cfg.Backend)
+}
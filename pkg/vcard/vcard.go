@@ -0,0 +1,479 @@
+// Package vcard reads and writes vCard files (RFC 6350 and RFC 2426).
+//
+// Only the properties the CRM maps onto contacts are kept: FN, N, TEL, EMAIL,
+// BDAY, ORG, ADR, URL, NOTE and CATEGORIES. Parse accepts vCard 3.0 and 4.0,
+// Encode writes vCard 4.0.
+package vcard
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Card is a single vCard
+type Card struct {
+	Version       string
+	FormattedName string
+	Name          Name
+	Org           string
+	Birthday      string // as written in the card, see BirthdayDate
+	Note          string
+	Tels          []Value
+	Emails        []Value
+	URLs          []Value
+	Addresses     []Address
+	Categories    []string
+}
+
+// Name is the structured N property
+type Name struct {
+	Family     string
+	Given      string
+	Additional string
+	Prefix     string
+	Suffix     string
+}
+
+// Value is a TEL, EMAIL or URL with its TYPE parameters, e.g. "work" or "cell"
+type Value struct {
+	Text  string
+	Types []string
+	Pref  bool
+}
+
+// Address is the structured ADR property
+type Address struct {
+	Types      []string
+	Pref       bool
+	POBox      string
+	Extended   string
+	Street     string
+	Locality   string
+	Region     string
+	PostalCode string
+	Country    string
+}
+
+// DisplayName returns FN, or the name built from N when FN is missing
+func (c Card) DisplayName() string {
+	if c.FormattedName != "" {
+		return c.FormattedName
+	}
+	parts := []string{c.Name.Prefix, c.Name.Given, c.Name.Additional, c.Name.Family, c.Name.Suffix}
+	return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
+
+// BirthdayDate returns BDAY as YYYY-MM-DD, or --MM-DD when the card has no
+// year, e.g. "--0415". ok is false when the card has no birthday or it is not
+// a date.
+func (c Card) BirthdayDate() (string, bool) {
+	value := c.Birthday
+	if i := strings.IndexByte(value, 'T'); i >= 0 {
+		value = value[:i]
+	}
+	for _, layout := range []string{"2006-01-02", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("2006-01-02"), true
+		}
+	}
+	// Dates without year are "--MMDD" in vCard 4.0 and often "--MM-DD" in 3.0.
+	// 2004 is a leap year, so 29 February is accepted.
+	if rest, found := strings.CutPrefix(value, "--"); found {
+		for _, layout := range []string{"01-02", "0102"} {
+			if t, err := time.Parse("2006 "+layout, "2004 "+rest); err == nil {
+				return t.Format("--01-02"), true
+			}
+		}
+	}
+	return "", false
+}
+
+// Place returns a short "City, Region, Country" description of an address
+func (a Address) Place() string {
+	var parts []string
+	for _, part := range []string{a.Locality, a.Region, a.Country} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Preferred returns the value marked as preferred, or the first one
+func Preferred(values []Value) (Value, bool) {
+	for _, value := range values {
+		if value.Pref {
+			return value, true
+		}
+	}
+	if len(values) == 0 {
+		return Value{}, false
+	}
+	return values[0], true
+}
+
+// Parse reads every card of a .vcf file
+func Parse(r io.Reader) ([]Card, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var cards []Card
+	var card *Card
+	for _, line := range lines {
+		if strings.TrimSpace(line.text) == "" {
+			continue
+		}
+
+		prop, err := parseLine(line.text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line.number, err)
+		}
+
+		switch {
+		case prop.name == "BEGIN" && strings.EqualFold(prop.value, "VCARD"):
+			if card != nil {
+				return nil, fmt.Errorf("line %d: BEGIN:VCARD inside another card", line.number)
+			}
+			card = &Card{}
+		case prop.name == "END" && strings.EqualFold(prop.value, "VCARD"):
+			if card == nil {
+				return nil, fmt.Errorf("line %d: END:VCARD without BEGIN:VCARD", line.number)
+			}
+			cards = append(cards, *card)
+			card = nil
+		case card == nil:
+			return nil, fmt.Errorf("line %d: property %s outside of a card", line.number, prop.name)
+		default:
+			card.set(prop)
+		}
+	}
+	if card != nil {
+		return nil, fmt.Errorf("missing END:VCARD")
+	}
+	return cards, nil
+}
+
+func (c *Card) set(prop property) {
+	switch prop.name {
+	case "VERSION":
+		c.Version = prop.value
+	case "FN":
+		c.FormattedName = unescape(prop.value)
+	case "N":
+		parts := splitStructured(prop.value, 5)
+		c.Name = Name{Family: parts[0], Given: parts[1], Additional: parts[2], Prefix: parts[3], Suffix: parts[4]}
+	case "ORG":
+		// Organization units follow the name, e.g. "ACME;Engineering"
+		var units []string
+		for _, unit := range splitStructured(prop.value, 0) {
+			if unit != "" {
+				units = append(units, unit)
+			}
+		}
+		c.Org = strings.Join(units, ", ")
+	case "BDAY":
+		c.Birthday = prop.value
+	case "NOTE":
+		if c.Note != "" {
+			c.Note += "\n"
+		}
+		c.Note += unescape(prop.value)
+	case "TEL":
+		c.Tels = append(c.Tels, prop.typedValue(strings.TrimPrefix(unescape(prop.value), "tel:")))
+	case "EMAIL":
+		c.Emails = append(c.Emails, prop.typedValue(unescape(prop.value)))
+	case "URL":
+		c.URLs = append(c.URLs, prop.typedValue(unescape(prop.value)))
+	case "ADR":
+		parts := splitStructured(prop.value, 7)
+		v := prop.typedValue("")
+		c.Addresses = append(c.Addresses, Address{
+			Types: v.Types, Pref: v.Pref,
+			POBox: parts[0], Extended: parts[1], Street: parts[2], Locality: parts[3],
+			Region: parts[4], PostalCode: parts[5], Country: parts[6],
+		})
+	case "CATEGORIES":
+		for _, category := range splitList(prop.value) {
+			if category != "" {
+				c.Categories = append(c.Categories, category)
+			}
+		}
+	}
+}
+
+type line struct {
+	number int
+	text   string
+}
+
+// unfold joins continuation lines, which start with a space or a tab
+func unfold(r io.Reader) ([]line, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	var lines []line
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if number == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if len(lines) > 0 && (strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t")) {
+			lines[len(lines)-1].text += text[1:]
+			continue
+		}
+		lines = append(lines, line{number: number, text: text})
+	}
+	return lines, scanner.Err()
+}
+
+type property struct {
+	name   string
+	params map[string][]string
+	value  string
+}
+
+// parseLine splits a content line like "item1.TEL;TYPE=cell:+1 555" into its
+// name, parameters and raw value. Groups are dropped.
+func parseLine(text string) (property, error) {
+	// The value starts at the first colon outside of a quoted parameter value
+	colon, quoted := -1, false
+	for i, r := range text {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return property{}, fmt.Errorf("missing ':' in %q", text)
+	}
+
+	head := splitParams(text[:colon])
+	name := strings.ToUpper(head[0])
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+	if name == "" {
+		return property{}, fmt.Errorf("missing property name in %q", text)
+	}
+
+	prop := property{name: name, params: map[string][]string{}, value: text[colon+1:]}
+	for _, param := range head[1:] {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok {
+			// vCard 2.1 style bare types, e.g. TEL;CELL:
+			key, value = "TYPE", param
+		}
+		key = strings.ToUpper(key)
+		for _, v := range splitParamValues(value) {
+			prop.params[key] = append(prop.params[key], v)
+		}
+	}
+	return prop, nil
+}
+
+// typedValue attaches the TYPE and PREF parameters of a property to a value
+func (p property) typedValue(text string) Value {
+	v := Value{Text: strings.TrimSpace(text)}
+	for _, t := range p.params["TYPE"] {
+		t = strings.ToLower(t)
+		if t == "pref" {
+			v.Pref = true
+			continue
+		}
+		v.Types = append(v.Types, t)
+	}
+	if len(p.params["PREF"]) > 0 {
+		v.Pref = true
+	}
+	return v
+}
+
+func splitParams(head string) []string {
+	var parts []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range head {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ';' && !quoted:
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	return append(parts, current.String())
+}
+
+func splitParamValues(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// splitStructured splits a structured value on unescaped semicolons and
+// unescapes each component. With n > 0 the result always has n components.
+func splitStructured(value string, n int) []string {
+	parts := splitEscaped(value, ';')
+	for i := range parts {
+		parts[i] = strings.TrimSpace(unescape(parts[i]))
+	}
+	for n > 0 && len(parts) < n {
+		parts = append(parts, "")
+	}
+	return parts
+}
+
+// splitList splits a comma separated text list like CATEGORIES
+func splitList(value string) []string {
+	parts := splitEscaped(value, ',')
+	for i := range parts {
+		parts[i] = strings.TrimSpace(unescape(parts[i]))
+	}
+	return parts
+}
+
+func splitEscaped(value string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+func unescape(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i == len(value)-1 {
+			b.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
+
+func escape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "\r\n", `\n`, "\n", `\n`, ",", `\,`, ";", `\;`).Replace(value)
+}
+
+// Encode writes cards as vCard 4.0
+func Encode(w io.Writer, cards []Card) error {
+	bw := bufio.NewWriter(w)
+	for _, card := range cards {
+		for _, text := range card.lines() {
+			if _, err := bw.WriteString(fold(text)); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+func (c Card) lines() []string {
+	lines := []string{"BEGIN:VCARD", "VERSION:4.0", "FN:" + escape(c.DisplayName())}
+
+	n := []string{c.Name.Family, c.Name.Given, c.Name.Additional, c.Name.Prefix, c.Name.Suffix}
+	for i := range n {
+		n[i] = escape(n[i])
+	}
+	lines = append(lines, "N:"+strings.Join(n, ";"))
+
+	if c.Org != "" {
+		lines = append(lines, "ORG:"+escape(c.Org))
+	}
+	if c.Birthday != "" {
+		lines = append(lines, "BDAY:"+c.Birthday)
+	}
+	for _, tel := range c.Tels {
+		lines = append(lines, "TEL"+typeParams(tel.Types, tel.Pref)+":"+escape(tel.Text))
+	}
+	for _, email := range c.Emails {
+		lines = append(lines, "EMAIL"+typeParams(email.Types, email.Pref)+":"+escape(email.Text))
+	}
+	for _, adr := range c.Addresses {
+		parts := []string{adr.POBox, adr.Extended, adr.Street, adr.Locality, adr.Region, adr.PostalCode, adr.Country}
+		for i := range parts {
+			parts[i] = escape(parts[i])
+		}
+		lines = append(lines, "ADR"+typeParams(adr.Types, adr.Pref)+":"+strings.Join(parts, ";"))
+	}
+	for _, url := range c.URLs {
+		// URIs are not escaped as text
+		lines = append(lines, "URL"+typeParams(url.Types, url.Pref)+":"+url.Text)
+	}
+	if len(c.Categories) > 0 {
+		categories := make([]string, 0, len(c.Categories))
+		for _, category := range c.Categories {
+			categories = append(categories, escape(category))
+		}
+		lines = append(lines, "CATEGORIES:"+strings.Join(categories, ","))
+	}
+	if c.Note != "" {
+		lines = append(lines, "NOTE:"+escape(c.Note))
+	}
+	return append(lines, "END:VCARD")
+}
+
+func typeParams(types []string, pref bool) string {
+	var params string
+	if len(types) > 0 {
+		params += ";TYPE=" + strings.Join(types, ",")
+	}
+	if pref {
+		params += ";PREF=1"
+	}
+	return params
+}
+
+// fold splits a content line into lines of at most 75 octets, without
+// breaking UTF-8 sequences, and terminates it with CRLF
+func fold(text string) string {
+	var b strings.Builder
+	limit := 75
+	for len(text) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		b.WriteString(text[:cut])
+		b.WriteString("\r\n ")
+		text = text[cut:]
+		// Continuation lines start with a space that counts towards the limit
+		limit = 74
+	}
+	b.WriteString(text)
+	b.WriteString("\r\n")
+	return b.String()
+}
@@ -0,0 +1,251 @@
+package vcard
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		vcf  string
+		want []Card
+	}{
+		{
+			name: "vcard 4.0",
+			vcf: "BEGIN:VCARD\r\nVERSION:4.0\r\nFN:Jane Doe\r\nN:Doe;Jane;;Dr.;\r\n" +
+				"ORG:ACME;Engineering\r\nBDAY:19850415\r\nTEL;TYPE=cell;PREF=1:tel:+1 555 0100\r\n" +
+				"EMAIL;TYPE=work:jane@acme.test\r\nURL:https://acme.test\r\n" +
+				"ADR;TYPE=home:;;1 Main St;Springfield;IL;62701;USA\r\nCATEGORIES:friends,work\r\nEND:VCARD\r\n",
+			want: []Card{{
+				Version:       "4.0",
+				FormattedName: "Jane Doe",
+				Name:          Name{Family: "Doe", Given: "Jane", Prefix: "Dr."},
+				Org:           "ACME, Engineering",
+				Birthday:      "19850415",
+				Tels:          []Value{{Text: "+1 555 0100", Types: []string{"cell"}, Pref: true}},
+				Emails:        []Value{{Text: "jane@acme.test", Types: []string{"work"}}},
+				URLs:          []Value{{Text: "https://acme.test"}},
+				Addresses: []Address{{
+					Types: []string{"home"}, Street: "1 Main St", Locality: "Springfield",
+					Region: "IL", PostalCode: "62701", Country: "USA",
+				}},
+				Categories: []string{"friends", "work"},
+			}},
+		},
+		{
+			name: "vcard 3.0 with groups, bare types and folded lines",
+			vcf: "\ufeffBEGIN:VCARD\nVERSION:3.0\nFN:John\n  Smith\nitem1.TEL;CELL;TYPE=pref:555\n" +
+				"EMAIL;TYPE=\"home,internet\":john@example.test\nNOTE:first\\nline\nNOTE:second\nEND:VCARD\n",
+			want: []Card{{
+				Version:       "3.0",
+				FormattedName: "John Smith",
+				Tels:          []Value{{Text: "555", Types: []string{"cell"}, Pref: true}},
+				Emails:        []Value{{Text: "john@example.test", Types: []string{"home", "internet"}}},
+				Note:          "first\nline\nsecond",
+			}},
+		},
+		{
+			name: "escaped values",
+			vcf:  "BEGIN:VCARD\nFN:Doe\\, Jane\nN:O\\;Brien;Ann\nCATEGORIES:a\\,b,c\nEND:VCARD\n",
+			want: []Card{{
+				FormattedName: "Doe, Jane",
+				Name:          Name{Family: "O;Brien", Given: "Ann"},
+				Categories:    []string{"a,b", "c"},
+			}},
+		},
+		{
+			name: "several cards and blank lines",
+			vcf:  "BEGIN:VCARD\nFN:A\nEND:VCARD\n\nBEGIN:VCARD\nFN:B\nEND:VCARD\n",
+			want: []Card{{FormattedName: "A"}, {FormattedName: "B"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.vcf))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		vcf  string
+		want string
+	}{
+		{"missing colon", "BEGIN:VCARD\nFN Jane\nEND:VCARD\n", "line 2: missing ':'"},
+		{"nested card", "BEGIN:VCARD\nBEGIN:VCARD\n", "line 2: BEGIN:VCARD inside another card"},
+		{"end without begin", "END:VCARD\n", "line 1: END:VCARD without BEGIN:VCARD"},
+		{"property outside of a card", "FN:Jane\n", "line 1: property FN outside of a card"},
+		{"missing end", "BEGIN:VCARD\nFN:Jane\n", "missing END:VCARD"},
+		{"missing name", "BEGIN:VCARD\n:Jane\n", "line 2: missing property name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.vcf))
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("Parse error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBirthdayDate(t *testing.T) {
+	tests := []struct {
+		birthday string
+		want     string
+		ok       bool
+	}{
+		{"1985-04-15", "1985-04-15", true},
+		{"19850415", "1985-04-15", true},
+		{"1985-04-15T00:00:00Z", "1985-04-15", true},
+		{"--0415", "--04-15", true},
+		{"--04-15", "--04-15", true},
+		{"--0229", "--02-29", true},
+		{"--0230", "", false},
+		{"1985-02-30", "", false},
+		{"April 15", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := Card{Birthday: tt.birthday}.BirthdayDate()
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("BirthdayDate(%q) = %q, %v, want %q, %v", tt.birthday, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	tests := []struct {
+		card Card
+		want string
+	}{
+		{Card{FormattedName: "Jane", Name: Name{Family: "Doe"}}, "Jane"},
+		{Card{Name: Name{Prefix: "Dr.", Given: "Jane", Additional: "Q", Family: "Doe", Suffix: "PhD"}}, "Dr. Jane Q Doe PhD"},
+		{Card{Name: Name{Family: "Doe"}}, "Doe"},
+		{Card{}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.card.DisplayName(); got != tt.want {
+			t.Errorf("DisplayName(%+v) = %q, want %q", tt.card, got, tt.want)
+		}
+	}
+}
+
+func TestPreferred(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []Value
+		want   Value
+		ok     bool
+	}{
+		{"none", nil, Value{}, false},
+		{"first", []Value{{Text: "a"}, {Text: "b"}}, Value{Text: "a"}, true},
+		{"preferred", []Value{{Text: "a"}, {Text: "b", Pref: true}}, Value{Text: "b", Pref: true}, true},
+	}
+	for _, tt := range tests {
+		got, ok := Preferred(tt.values)
+		if !reflect.DeepEqual(got, tt.want) || ok != tt.ok {
+			t.Errorf("%s: Preferred = %+v, %v, want %+v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPlace(t *testing.T) {
+	tests := []struct {
+		address Address
+		want    string
+	}{
+		{Address{Street: "1 Main St", Locality: "Springfield", Region: "IL", Country: "USA"}, "Springfield, IL, USA"},
+		{Address{Locality: "Paris", Country: "France"}, "Paris, France"},
+		{Address{Street: "1 Main St"}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.address.Place(); got != tt.want {
+			t.Errorf("Place(%+v) = %q, want %q", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	card := Card{
+		Name:       Name{Family: "Doe", Given: "Jane"},
+		Org:        "ACME; Inc",
+		Birthday:   "--0415",
+		Note:       "likes tea,\ncoffee",
+		Tels:       []Value{{Text: "+1 555", Types: []string{"cell"}, Pref: true}},
+		Emails:     []Value{{Text: "jane@acme.test"}},
+		URLs:       []Value{{Text: "https://acme.test/a,b"}},
+		Addresses:  []Address{{Types: []string{"home"}, Street: "1 Main St", Locality: "Springfield"}},
+		Categories: []string{"friends", "a,b"},
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, []Card{card}); err != nil {
+		t.Fatal(err)
+	}
+	want := "BEGIN:VCARD\r\nVERSION:4.0\r\nFN:Jane Doe\r\nN:Doe;Jane;;;\r\nORG:ACME\\; Inc\r\nBDAY:--0415\r\n" +
+		"TEL;TYPE=cell;PREF=1:+1 555\r\nEMAIL:jane@acme.test\r\nADR;TYPE=home:;;1 Main St;Springfield;;;\r\n" +
+		"URL:https://acme.test/a,b\r\nCATEGORIES:friends,a\\,b\r\nNOTE:likes tea\\,\\ncoffee\r\nEND:VCARD\r\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Encode = %q, want %q", got, want)
+	}
+
+	// What is written reads back the same
+	cards, err := Parse(&buf)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	card.Version = "4.0"
+	card.FormattedName = "Jane Doe"
+	if len(cards) != 1 || !reflect.DeepEqual(cards[0], card) {
+		t.Errorf("Parse(Encode) = %+v, want %+v", cards, card)
+	}
+}
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"short", "FN:Jane"},
+		{"ascii", "NOTE:" + strings.Repeat("a", 200)},
+		{"multibyte", "NOTE:" + strings.Repeat("é", 100)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folded := fold(tt.text)
+			if !strings.HasSuffix(folded, "\r\n") {
+				t.Fatalf("fold(%q) does not end with CRLF", tt.text)
+			}
+			lines := strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n")
+			for i, line := range lines {
+				if len(line) > 75 {
+					t.Errorf("line %d is %d octets long", i, len(line))
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("line %d breaks a UTF-8 sequence: %q", i, line)
+				}
+				if i > 0 && !strings.HasPrefix(line, " ") {
+					t.Errorf("continuation line %d does not start with a space", i)
+				}
+			}
+
+			unfolded, err := unfold(strings.NewReader(folded))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(unfolded) != 1 || unfolded[0].text != tt.text {
+				t.Errorf("unfold(fold(%q)) = %+v", tt.text, unfolded)
+			}
+		})
+	}
+}
@@ -13,3 +13,53 @@ function toggleVipFields() {
         }
     });
 }
+
+// Downloads the contacts selected in the contacts table, or all of them when none is selected
+function exportSelected(url) {
+    const params = new URLSearchParams();
+    document.querySelectorAll('#contacts-table input[name=contact_ids]:checked')
+        .forEach(cb => params.append('contact_ids', cb.value));
+    const separator = url.includes('?') ? '&' : '?';
+    window.location = params.toString() ? url + separator + params.toString() : url;
+}
+
+// Adds an empty row to a list of emails, phone numbers or addresses on the edit
+// form. The primary radio button of a row holds the row's index.
+function addChannelRow(containerId) {
+    const container = document.getElementById(containerId);
+    const rows = container.children;
+    const row = rows[rows.length - 1].cloneNode(true);
+    row.querySelectorAll('input').forEach(input => {
+        if (input.type === 'radio') {
+            input.checked = false;
+            input.value = rows.length;
+        } else {
+            input.value = '';
+        }
+    });
+    container.appendChild(row);
+}
+
+// Shows the undo toast after contacts were moved to the trash. The server
+// sends their ids with the contactsDeleted event.
+let undoContactIds = [];
+let undoToastTimer;
+function showUndoToast(event) {
+    undoContactIds = event.detail.ids || [];
+    const count = undoContactIds.length;
+    document.getElementById('undo-toast-message').textContent = event.detail.name
+        ? event.detail.name + ' moved to the trash'
+        : count + ' contacts moved to the trash';
+    const toast = document.getElementById('undo-toast');
+    toast.classList.remove('hidden');
+    clearTimeout(undoToastTimer);
+    undoToastTimer = setTimeout(() => toast.classList.add('hidden'), 10000);
+}
+
+// Restores the contacts of the undo toast, the table reloads on contactsChanged
+function undoDelete() {
+    document.getElementById('undo-toast').classList.add('hidden');
+    const values = {contact_ids: undoContactIds};
+    undoContactIds = [];
+    htmx.ajax('POST', '/trash/restore', {target: '#undo-toast-message', values: values});
+}